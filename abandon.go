@@ -5,11 +5,21 @@ import (
 	"github.com/eaciit/asn1-ber"
 )
 
-// Will return an error. Normally due to closed connection.
+/*
+AbandonRequest ::= [APPLICATION 16] MessageID
+
+Abandon asks the server to stop processing the operation with abandonMessageID.
+The server doesn't respond to an abandon request, the operation waiting for
+abandonMessageID is released right away and returns an ErrorAbandoned *Error.
+The MessageID of a running search is available from ConnectionInfo in a
+SearchResultHandler.
+
+Will return an error. Normally due to closed connection.
+*/
 func (l *Connection) Abandon(abandonMessageID int64) error {
 	messageID, ok := l.nextMessageID()
 	if !ok {
-		return newError(ErrorClosing, "MessageID channel is closed.")
+		return l.messageIDError()
 	}
 
 	encodedAbandon := ber.NewInteger(ber.ClassApplication, ber.TypePrimitive, ber.Tag(ApplicationAbandonRequest), abandonMessageID, ApplicationAbandonRequest.String())
@@ -37,6 +47,7 @@ func (l *Connection) Abandon(abandonMessageID int64) error {
 	if l.Debug {
 		fmt.Printf("%d: NOT waiting Abandon for response\n", messageID)
 	}
+	l.abandonMessage(abandonMessageID)
 
 	// success
 	return nil
@@ -1,13 +1,18 @@
 package ldap
 
 import (
+	"context"
 	"fmt"
 	"github.com/eaciit/asn1-ber"
 	"log"
 )
 
+// LDAP add request [https://tools.ietf.org/html/rfc4511#section-4.7]
 type AddRequest struct {
-	Entry    *Entry
+	// Entry to add, the DN and the initial attribute list
+	Entry *Entry
+
+	// Server controls
 	Controls []Control
 }
 
@@ -17,19 +22,24 @@ func (req *AddRequest) RecordType() uint8 {
 }
 */
 
-func (l *Connection) Add(req *AddRequest) error {
-	messageID, ok := l.nextMessageID()
-	if !ok {
-		return newError(ErrorClosing, "messageID channel is closed.")
-	}
+// Add creates the entry of the AddRequest on the server and returns the
+// result with the response controls, also if the add failed.
+func (l *Connection) Add(req *AddRequest) (*LDAPResult, error) {
+	return l.AddContext(context.Background(), req)
+}
 
-	if l.Debug {
-		log.Println("sdfsadfsdf")
+// AddContext is Add with ctx, the request is abandoned when ctx is done before
+// the response arrived.
+func (l *Connection) AddContext(ctx context.Context, req *AddRequest) (*LDAPResult, error) {
+	messageID, release, err := l.nextRequestID(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer release()
 
 	encodedAdd, err := encodeAddRequest(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if l.Debug {
@@ -38,10 +48,10 @@ func (l *Connection) Add(req *AddRequest) error {
 
 	packet, err := requestBuildPacket(messageID, encodedAdd, req.Controls)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return l.sendReqRespPacket(messageID, packet)
+	return l.sendReqRespResult(ctx, messageID, packet)
 }
 
 /*
@@ -56,6 +66,9 @@ func (l *Connection) Add(req *AddRequest) error {
         vals       SET OF value AttributeValue } // vals is not empty
 */
 func encodeAddRequest(addReq *AddRequest) (*ber.Packet, error) {
+	if addReq.Entry == nil {
+		return nil, newError(ErrorEncoding, "AddRequest has no entry.")
+	}
 	addPacket := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(ApplicationAddRequest), nil, ApplicationAddRequest.String())
 	addPacket.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, addReq.Entry.DN, "LDAP DN"))
 
@@ -79,10 +92,15 @@ func encodeAddRequest(addReq *AddRequest) (*ber.Packet, error) {
 }
 
 func (req *AddRequest) Bytes() []byte {
-	encoded, _ := encodeAddRequest(req)
+	encoded, err := encodeAddRequest(req)
+	if err != nil {
+		return nil
+	}
 	return encoded.Bytes()
 }
 
+// NewAddRequest returns an AddRequest for dn without any attributes,
+// add them with AddAttribute or AddAttributes.
 func NewAddRequest(dn string) (req *AddRequest) {
 	req = &AddRequest{Entry: NewEntry(dn), Controls: make([]Control, 0)}
 	return
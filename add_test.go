@@ -3,7 +3,7 @@ package ldap
 import (
 	//"encoding/hex"
 	"fmt"
-	//"github.com/eaciit/asn1-ber"
+	"github.com/eaciit/asn1-ber"
 	"testing"
 	//"bytes"
 )
@@ -48,3 +48,57 @@ func TestAdd(t *testing.T) {
 	}
 	fmt.Println("TestAdd finsished.")
 }
+
+func TestAddEncodeAddRequest(t *testing.T) {
+	addReq := NewAddRequest(addDNs[0])
+	for _, attr := range addAttrs {
+		addReq.AddAttribute(&attr)
+	}
+	p, err := encodeAddRequest(addReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Tag != ber.Tag(ApplicationAddRequest) {
+		t.Errorf("Expected tag %d, got %d", ApplicationAddRequest, p.Tag)
+	}
+	if len(p.Children) != 2 || len(p.Children[1].Children) != len(addAttrs) {
+		t.Errorf("Unexpected AddRequest structure")
+	}
+
+	emptyAttr := NewAddRequest(addDNs[0])
+	emptyAttr.Entry.Attributes = append(emptyAttr.Entry.Attributes, &EntryAttribute{Name: "cn"})
+	if _, err := encodeAddRequest(emptyAttr); err == nil {
+		t.Errorf("Expected error for attribute without values")
+	}
+
+	if _, err := encodeAddRequest(&AddRequest{}); err == nil {
+		t.Errorf("Expected error for AddRequest without an entry")
+	}
+}
+
+func TestReferralAddRequest(t *testing.T) {
+	req := NewReferralAddRequest("ou=remote,o=bigcorp", []string{"ldap://ldap.example.com/ou=remote,o=bigcorp"})
+	if values := req.Entry.GetAttributeValues(AttributeRef); len(values) != 1 {
+		t.Errorf("Unexpected ref values %v", values)
+	}
+
+	encoded, err := encodeAddRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := requestBuildPacket(1, encoded, req.Controls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = ber.DecodePacket(p.Bytes())
+	if len(p.Children) != 3 || len(p.Children[2].Children) != 1 {
+		t.Fatal("ManageDsaIT control missing")
+	}
+	control := p.Children[2].Children[0]
+	if packetString(control.Children[0]) != string(ControlTypeManageDsaITRequest) {
+		t.Errorf("Unexpected control type %q", packetString(control.Children[0]))
+	}
+	if len(control.Children) != 2 {
+		t.Errorf("ManageDsaIT control should be critical without a value, got %d children", len(control.Children))
+	}
+}
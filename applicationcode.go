@@ -30,4 +30,5 @@ const (
 	ApplicationSearchResultReference ApplicationCode = 19
 	ApplicationExtendedRequest       ApplicationCode = 23
 	ApplicationExtendedResponse      ApplicationCode = 24
+	ApplicationIntermediateResponse  ApplicationCode = 25
 )
@@ -7,13 +7,13 @@ import "fmt"
 const (
 	_ApplicationCode_name_0 = "ApplicationBindRequestApplicationBindResponseApplicationUnbindRequestApplicationSearchRequestApplicationSearchResultEntryApplicationSearchResultDoneApplicationModifyRequestApplicationModifyResponseApplicationAddRequestApplicationAddResponseApplicationDelRequestApplicationDelResponseApplicationModifyDNRequestApplicationModifyDNResponseApplicationCompareRequestApplicationCompareResponseApplicationAbandonRequest"
 	_ApplicationCode_name_1 = "ApplicationSearchResultReference"
-	_ApplicationCode_name_2 = "ApplicationExtendedRequestApplicationExtendedResponse"
+	_ApplicationCode_name_2 = "ApplicationExtendedRequestApplicationExtendedResponseApplicationIntermediateResponse"
 )
 
 var (
 	_ApplicationCode_index_0 = [...]uint16{0, 22, 45, 69, 93, 121, 148, 172, 197, 218, 240, 261, 283, 309, 336, 361, 387, 412}
 	_ApplicationCode_index_1 = [...]uint8{0, 32}
-	_ApplicationCode_index_2 = [...]uint8{0, 26, 53}
+	_ApplicationCode_index_2 = [...]uint8{0, 26, 53, 84}
 )
 
 func (i ApplicationCode) String() string {
@@ -22,7 +22,7 @@ func (i ApplicationCode) String() string {
 		return _ApplicationCode_name_0[_ApplicationCode_index_0[i]:_ApplicationCode_index_0[i+1]]
 	case i == 19:
 		return _ApplicationCode_name_1
-	case 23 <= i && i <= 24:
+	case 23 <= i && i <= 25:
 		i -= 23
 		return _ApplicationCode_name_2[_ApplicationCode_index_2[i]:_ApplicationCode_index_2[i+1]]
 	default:
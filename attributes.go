@@ -0,0 +1,68 @@
+package ldap
+
+import (
+	"context"
+)
+
+// Attribute selectors of SearchRequest.Attributes [https://tools.ietf.org/html/rfc4511#section-4.5.1.8]
+const (
+	AllUserAttributes = "*"
+	// AllOperationalAttributes [https://tools.ietf.org/html/rfc3673]
+	AllOperationalAttributes = "+"
+	// NoAttributes returns the DNs of the entries only
+	NoAttributes = "1.1"
+)
+
+// Operational attributes [https://tools.ietf.org/html/rfc4512#section-3.4],
+// servers only return them when requested by name or with
+// AllOperationalAttributes
+const (
+	AttributeCreatorsName          = "creatorsName"
+	AttributeCreateTimestamp       = "createTimestamp"
+	AttributeModifiersName         = "modifiersName"
+	AttributeModifyTimestamp       = "modifyTimestamp"
+	AttributeStructuralObjectClass = "structuralObjectClass"
+	AttributeSubschemaSubentry     = "subschemaSubentry"
+	AttributeEntryDN               = "entryDN"
+	AttributeEntryUUID             = "entryUUID"
+	AttributeHasSubordinates       = "hasSubordinates"
+)
+
+// AllAttributes returns the attributes of a search for all user and
+// operational attributes.
+func AllAttributes() []string {
+	return []string{AllUserAttributes, AllOperationalAttributes}
+}
+
+// WithOperationalAttributes returns attributes, all user attributes if
+// empty, together with the operational attributes, all of them if none
+// are named.
+func WithOperationalAttributes(attributes []string, operational ...string) []string {
+	if len(operational) == 0 {
+		operational = []string{AllOperationalAttributes}
+	}
+	if len(attributes) == 0 {
+		attributes = []string{AllUserAttributes}
+	}
+	return append(append(make([]string, 0, len(attributes)+len(operational)), attributes...), operational...)
+}
+
+// ReadEntry returns the entry dn with attributes, all user attributes if
+// none, with a search of the base object. A missing entry fails with a
+// ResultNoSuchObject *Error.
+func (l *Connection) ReadEntry(dn string, attributes ...string) (*Entry, error) {
+	return l.ReadEntryContext(context.Background(), dn, attributes...)
+}
+
+// ReadEntryContext is ReadEntry with ctx.
+func (l *Connection) ReadEntryContext(ctx context.Context, dn string, attributes ...string) (*Entry, error) {
+	searchRequest := NewSimpleSearchRequest(dn, ScopeBaseObject, "(objectClass=*)", attributes)
+	result, err := l.SearchContext(ctx, searchRequest)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Entries) == 0 {
+		return nil, newError(ResultNoSuchObject, "No entry "+dn+" returned")
+	}
+	return result.Entries[0], nil
+}
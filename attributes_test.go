@@ -0,0 +1,52 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"reflect"
+	"testing"
+)
+
+func TestWithOperationalAttributes(t *testing.T) {
+	for _, test := range []struct {
+		attributes, operational, expected []string
+	}{
+		{nil, nil, []string{"*", "+"}},
+		{[]string{"cn"}, nil, []string{"cn", "+"}},
+		{nil, []string{AttributeModifyTimestamp}, []string{"*", "modifyTimestamp"}},
+		{[]string{"cn", "mail"}, []string{AttributeEntryUUID, AttributeCreatorsName}, []string{"cn", "mail", "entryUUID", "creatorsName"}},
+	} {
+		if attributes := WithOperationalAttributes(test.attributes, test.operational...); !reflect.DeepEqual(attributes, test.expected) {
+			t.Errorf("Expected %v, got %v", test.expected, attributes)
+		}
+	}
+}
+
+func TestReadEntry(t *testing.T) {
+	l, s := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		messageID := mockMessageID(request)
+		if packetString(request.Children[1].Children[0]) != "cn=bob,o=bigcorp" {
+			s.respondResult(messageID, ApplicationSearchResultDone, ResultNoSuchObject, "")
+			return
+		}
+		s.respond(messageID, mockSearchEntry("cn=bob,o=bigcorp", map[string][]string{"modifyTimestamp": {"20240101000000Z"}}))
+		s.respondResult(messageID, ApplicationSearchResultDone, ResultSuccess, "")
+	})
+	defer l.Close()
+
+	entry, err := l.ReadEntry("cn=bob,o=bigcorp", AttributeModifyTimestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.GetAttributeValue(AttributeModifyTimestamp) != "20240101000000Z" {
+		t.Errorf("Unexpected entry %v", entry)
+	}
+	request := (<-s.requests).Children[1]
+	if scope, _ := packetInt64(request.Children[1]); Scope(scope) != ScopeBaseObject || packetString(request.Children[7].Children[0]) != AttributeModifyTimestamp {
+		t.Errorf("Expected a base object search for modifyTimestamp, got %v", request)
+	}
+
+	_, err = l.ReadEntry("cn=alice,o=bigcorp")
+	if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ResultNoSuchObject {
+		t.Errorf("Expected ResultNoSuchObject, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package ldap
+
+import (
+	"context"
+	"strings"
+)
+
+// AuthenticateRequest describes the users Authenticate finds and verifies
+// the passwords of.
+type AuthenticateRequest struct {
+	BaseDN string
+	Scope  Scope
+	// Filter of the user, "%s" is replaced by the escaped username, e.g.
+	// "(&(objectClass=person)(uid=%s))"
+	Filter string
+	// Attributes of the user entry returned
+	Attributes []string
+	// BindConnection the user is bound on, e.g. a connection in the
+	// FastBind mode; nil to bind on the searching connection and rebind it
+	// with its last bind afterwards
+	BindConnection *Connection
+}
+
+// NewAuthenticateRequest returns the AuthenticateRequest of the users
+// matching filter in the subtree of baseDN.
+func NewAuthenticateRequest(baseDN, filter string, attributes []string) *AuthenticateRequest {
+	return &AuthenticateRequest{BaseDN: baseDN, Scope: ScopeWholeSubtree, Filter: filter, Attributes: attributes}
+}
+
+// Authenticate searches the entry of username with the identity of the
+// connection, e.g. a service account, binds as its DN with password and
+// returns the entry if the bind succeeded. An unknown username, one
+// matching several entries and a wrong password all fail with a
+// ResultInvalidCredentials *Error, an empty password with an
+// ErrorEmptyPassword *Error. Without a BindConnection the connection is
+// rebound with its last bind, or anonymously without one, so the
+// operations running concurrently may run as the user in the meantime. The
+// rebind isn't bound by ctx, only by RequestTimeout, and the connection is
+// closed if it fails rather than left bound as the user.
+func (l *Connection) Authenticate(req *AuthenticateRequest, username, password string) (*Entry, error) {
+	return l.AuthenticateContext(context.Background(), req, username, password)
+}
+
+// AuthenticateContext is Authenticate with ctx.
+func (l *Connection) AuthenticateContext(ctx context.Context, req *AuthenticateRequest, username, password string) (*Entry, error) {
+	if password == "" {
+		return nil, newError(ErrorEmptyPassword, "Refusing to authenticate "+username+" with an empty password")
+	}
+	filter := strings.Replace(req.Filter, "%s", EscapeFilterValue(username), -1)
+	searchRequest := NewSearchRequest(req.BaseDN, req.Scope, NeverDerefAliases, 2, 0, false, filter, req.Attributes, nil)
+	result, err := l.SearchContext(ctx, searchRequest)
+	if lerr, ok := err.(*Error); ok && lerr.ResultCode == ResultSizeLimitExceeded {
+		return nil, newError(ResultInvalidCredentials, "Several entries match the user "+username)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch len(result.Entries) {
+	case 0:
+		return nil, newError(ResultInvalidCredentials, "No entry matches the user "+username)
+	case 1:
+	default:
+		return nil, newError(ResultInvalidCredentials, "Several entries match the user "+username)
+	}
+	entry := result.Entries[0]
+
+	if req.BindConnection != nil {
+		if err := req.BindConnection.BindContext(ctx, entry.DN, password); err != nil {
+			return nil, err
+		}
+		return entry, nil
+	}
+	l.lastBindLock.Lock()
+	rebind, fastBind := l.lastBind, l.fastBind
+	l.lastBindLock.Unlock()
+	err = l.BindContext(ctx, entry.DN, password)
+	if !fastBind {
+		// binds in the fast bind mode don't change the identity
+		if rerr := l.restoreBind(context.Background(), rebind); rerr != nil {
+			l.setCloseError("Restoring the bind failed: ", rerr)
+			l.stop()
+			return nil, rerr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// restoreBind binds the connection with rebind, the last bind before
+// another one, or anonymously if nil. rebind stays the bind replayed after
+// a reconnect also if it fails.
+func (l *Connection) restoreBind(ctx context.Context, rebind func(ctx context.Context) error) error {
+	var err error
+	if rebind == nil {
+		err = l.BindContext(ctx, "", "")
+	} else {
+		err = rebind(ctx)
+	}
+	if err != nil || rebind == nil {
+		l.setLastBind(rebind)
+	}
+	return err
+}
@@ -0,0 +1,118 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"reflect"
+	"testing"
+)
+
+func TestAuthenticate(t *testing.T) {
+	passwords := map[string]string{"cn=service,o=bigcorp": "service", "cn=alice,o=bigcorp": "secret"}
+	var binds []string
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		messageID := mockMessageID(request)
+		switch request.Children[1].Tag {
+		case ber.Tag(ApplicationBindRequest):
+			dn := packetString(request.Children[1].Children[1])
+			binds = append(binds, dn)
+			if password := packetString(request.Children[1].Children[2]); dn != "" && passwords[dn] != password {
+				s.respondResult(messageID, ApplicationBindResponse, ResultInvalidCredentials, "")
+				return
+			}
+			s.respondResult(messageID, ApplicationBindResponse, ResultSuccess, "")
+		case ber.Tag(ApplicationSearchRequest):
+			filter, _ := DecompileFilter(request.Children[1].Children[6])
+			switch filter {
+			case "(&(objectClass=person)(uid=alice))":
+				s.respond(messageID, mockSearchEntry("cn=alice,o=bigcorp", map[string][]string{"mail": {"alice@example.com"}}))
+			case "(&(objectClass=person)(uid=\\2a))":
+				s.respond(messageID, mockSearchEntry("cn=alice,o=bigcorp", nil))
+				s.respond(messageID, mockSearchEntry("cn=bob,o=bigcorp", nil))
+			}
+			s.respondResult(messageID, ApplicationSearchResultDone, ResultSuccess, "")
+		}
+	})
+	defer l.Close()
+
+	req := NewAuthenticateRequest("o=bigcorp", "(&(objectClass=person)(uid=%s))", []string{"mail"})
+	if err := l.Bind("cn=service,o=bigcorp", "service"); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := l.Authenticate(req, "alice", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.DN != "cn=alice,o=bigcorp" || entry.GetAttributeValue("mail") != "alice@example.com" {
+		t.Errorf("Unexpected entry %v", entry)
+	}
+	_, err = l.Authenticate(req, "alice", "wrong")
+	if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ResultInvalidCredentials {
+		t.Errorf("Expected invalid credentials, got %v", err)
+	}
+	if expected := []string{"cn=service,o=bigcorp", "cn=alice,o=bigcorp", "cn=service,o=bigcorp", "cn=alice,o=bigcorp", "cn=service,o=bigcorp"}; !reflect.DeepEqual(binds, expected) {
+		t.Errorf("Expected the service account to be rebound, got binds %v", binds)
+	}
+
+	for _, username := range []string{"bob", "*"} {
+		_, err = l.Authenticate(req, username, "secret")
+		if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ResultInvalidCredentials {
+			t.Errorf("Expected invalid credentials for %s, got %v", username, err)
+		}
+	}
+	_, err = l.Authenticate(req, "alice", "")
+	if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ErrorEmptyPassword {
+		t.Errorf("Expected ErrorEmptyPassword, got %v", err)
+	}
+	if len(binds) != 5 {
+		t.Errorf("Expected no more binds, got %v", binds[5:])
+	}
+
+	// bound on another connection
+	other, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		binds = append(binds, "other "+packetString(request.Children[1].Children[1]))
+		s.respondResult(mockMessageID(request), ApplicationBindResponse, ResultSuccess, "")
+	})
+	defer other.Close()
+	req.BindConnection = other
+	if _, err := l.Authenticate(req, "alice", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if binds[len(binds)-1] != "other cn=alice,o=bigcorp" {
+		t.Errorf("Expected the bind on the other connection, got binds %v", binds)
+	}
+}
+
+func TestAuthenticateRestoreFailed(t *testing.T) {
+	binds := 0
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		messageID := mockMessageID(request)
+		switch request.Children[1].Tag {
+		case ber.Tag(ApplicationBindRequest):
+			binds++
+			if binds > 2 {
+				// the password of the service account changed
+				s.respondResult(messageID, ApplicationBindResponse, ResultInvalidCredentials, "")
+				return
+			}
+			s.respondResult(messageID, ApplicationBindResponse, ResultSuccess, "")
+		case ber.Tag(ApplicationSearchRequest):
+			s.respond(messageID, mockSearchEntry("cn=alice,o=bigcorp", nil))
+			s.respondResult(messageID, ApplicationSearchResultDone, ResultSuccess, "")
+		case ber.Tag(ApplicationDelRequest):
+			s.respondResult(messageID, ApplicationDelResponse, ResultSuccess, "")
+		}
+	})
+	defer l.Close()
+
+	req := NewAuthenticateRequest("o=bigcorp", "(uid=%s)", nil)
+	if err := l.Bind("cn=service,o=bigcorp", "service"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := l.Authenticate(req, "alice", "secret")
+	if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ResultInvalidCredentials {
+		t.Errorf("Expected the failed rebind, got %v", err)
+	}
+	if _, err := l.Delete(NewDeleteRequest("cn=bob,o=bigcorp")); err == nil {
+		t.Error("Expected the connection bound as the user to be closed")
+	}
+}
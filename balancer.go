@@ -0,0 +1,112 @@
+package ldap
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// ServerStatus is the load of a server of a ServerList passed to a Balancer.
+type ServerStatus struct {
+	URL string
+	// Open connections of the ServerList to the server
+	Connections int
+	// Operations waiting for a response over these connections
+	Outstanding int
+}
+
+// Balancer spreads the connections of a ServerList over its servers, see
+// ServerList.Balancer.
+type Balancer interface {
+	// Order returns the URLs of servers in the order to try them for a new
+	// connection, servers are in the order of ServerList.URLs
+	Order(servers []ServerStatus) []string
+}
+
+// LeastOutstandingBalancer prefers the servers with the fewest outstanding
+// operations, then the fewest connections.
+type LeastOutstandingBalancer struct{}
+
+func (LeastOutstandingBalancer) Order(servers []ServerStatus) []string {
+	sorted := append([]ServerStatus(nil), servers...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Outstanding != sorted[j].Outstanding {
+			return sorted[i].Outstanding < sorted[j].Outstanding
+		}
+		return sorted[i].Connections < sorted[j].Connections
+	})
+	return serverURLs(sorted)
+}
+
+// WeightedBalancer orders the servers randomly with a probability of coming
+// first in proportion to their weight, a server without weight has the weight
+// 1 and a weight of 0 puts a server last.
+type WeightedBalancer struct {
+	Weights map[string]int
+}
+
+func (b WeightedBalancer) Order(servers []ServerStatus) []string {
+	remaining := append([]ServerStatus(nil), servers...)
+	ordered := make([]string, 0, len(servers))
+	for len(remaining) > 0 {
+		total := 0
+		for _, server := range remaining {
+			total += b.weight(server.URL)
+		}
+		i := 0
+		if total > 0 {
+			n := rand.Intn(total)
+			for ; n >= b.weight(remaining[i].URL); i++ {
+				n -= b.weight(remaining[i].URL)
+			}
+		}
+		ordered = append(ordered, remaining[i].URL)
+		remaining = append(remaining[:i], remaining[i+1:]...)
+	}
+	return ordered
+}
+
+func (b WeightedBalancer) weight(serverURL string) int {
+	weight, ok := b.Weights[serverURL]
+	if !ok {
+		return 1
+	}
+	if weight < 0 {
+		return 0
+	}
+	return weight
+}
+
+// LocalityBalancer prefers the servers for which Local returns true, e.g. the
+// domain controllers of the site of the client. Both the local and the other
+// servers are ordered by Balancer, in the order of the URLs if nil.
+type LocalityBalancer struct {
+	Local    func(serverURL string) bool
+	Balancer Balancer
+}
+
+func (b LocalityBalancer) Order(servers []ServerStatus) []string {
+	var local, remote []ServerStatus
+	for _, server := range servers {
+		if b.Local != nil && b.Local(server.URL) {
+			local = append(local, server)
+		} else {
+			remote = append(remote, server)
+		}
+	}
+	return append(b.order(local), b.order(remote)...)
+}
+
+func (b LocalityBalancer) order(servers []ServerStatus) []string {
+	if b.Balancer == nil {
+		return serverURLs(servers)
+	}
+	return b.Balancer.Order(servers)
+}
+
+func serverURLs(servers []ServerStatus) []string {
+	urls := make([]string, len(servers))
+	for i, server := range servers {
+		urls[i] = server.URL
+	}
+	return urls
+}
@@ -0,0 +1,57 @@
+package ldap
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBalancers(t *testing.T) {
+	servers := []ServerStatus{
+		{URL: "ldap://a", Connections: 2, Outstanding: 3},
+		{URL: "ldap://b", Connections: 1, Outstanding: 0},
+		{URL: "ldap://c", Connections: 0, Outstanding: 0},
+	}
+	if urls := (LeastOutstandingBalancer{}).Order(servers); !reflect.DeepEqual(urls, []string{"ldap://c", "ldap://b", "ldap://a"}) {
+		t.Errorf("Unexpected least outstanding order %v", urls)
+	}
+
+	weighted := WeightedBalancer{Weights: map[string]int{"ldap://a": 0, "ldap://b": 5}}
+	for i := 0; i < 10; i++ {
+		if urls := weighted.Order(servers); len(urls) != 3 || urls[2] != "ldap://a" {
+			t.Fatalf("Expected the server of weight 0 last, got %v", urls)
+		}
+	}
+
+	locality := LocalityBalancer{
+		Local:    func(serverURL string) bool { return strings.HasSuffix(serverURL, "b") },
+		Balancer: LeastOutstandingBalancer{},
+	}
+	if urls := locality.Order(servers); !reflect.DeepEqual(urls, []string{"ldap://b", "ldap://c", "ldap://a"}) {
+		t.Errorf("Unexpected locality order %v", urls)
+	}
+}
+
+func TestServerListLeastOutstanding(t *testing.T) {
+	first, _ := mockListener(t, nil)
+	defer first.Close()
+	second, _ := mockListener(t, nil)
+	defer second.Close()
+	servers := &ServerList{
+		URLs:     []string{"ldap://" + first.Addr().String(), "ldap://" + second.Addr().String()},
+		Balancer: LeastOutstandingBalancer{},
+	}
+
+	var addrs []string
+	for i := 0; i < 2; i++ {
+		l := &Connection{Servers: servers}
+		if err := l.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		defer l.Close()
+		addrs = append(addrs, l.Addr)
+	}
+	if addrs[0] != first.Addr().String() || addrs[1] != second.Addr().String() {
+		t.Errorf("Expected a connection to each server, got %v", addrs)
+	}
+}
@@ -1,28 +1,101 @@
 package ldap
 
 import (
+	"context"
 	"github.com/eaciit/asn1-ber"
 )
 
+// SimpleBindRequest is a simple bind with optional request controls like
+// NewControlPasswordPolicyRequest or NewControlAuthzIdRequest.
+type SimpleBindRequest struct {
+	Username string
+	Password string
+	Controls []Control
+	// AllowEmptyPassword sends a Username with an empty Password, an
+	// unauthenticated bind [https://tools.ietf.org/html/rfc4513#section-5.1.2]
+	// many servers accept as anonymous. Without it such a bind is refused
+	// with an ErrorEmptyPassword *Error, so an empty password doesn't pass
+	// as a successful authentication of Username.
+	AllowEmptyPassword bool
+}
+
+func NewSimpleBindRequest(username, password string, controls []Control) *SimpleBindRequest {
+	return &SimpleBindRequest{Username: username, Password: password, Controls: controls}
+}
+
+func (req *SimpleBindRequest) AddControl(control Control) {
+	if req.Controls == nil {
+		req.Controls = make([]Control, 0)
+	}
+	req.Controls = append(req.Controls, control)
+}
+
 /*
 Simple bind to the server. If using a timeout you should close the connection
-on a bind failure.
+on a bind failure. A username with an empty password is refused, see
+UnauthenticatedBind.
 */
 func (l *Connection) Bind(username, password string) error {
-	messageID, ok := l.nextMessageID()
-	if !ok {
-		return newError(ErrorClosing, "MessageID channel is closed.")
-	}
+	return l.BindContext(context.Background(), username, password)
+}
 
-	encodedBind := encodeSimpleBindRequest(username, password)
+// BindContext is Bind with ctx, see SimpleBindContext.
+func (l *Connection) BindContext(ctx context.Context, username, password string) error {
+	_, err := l.SimpleBindContext(ctx, NewSimpleBindRequest(username, password, nil))
+	return err
+}
+
+// UnauthenticatedBind binds as username without a password, the
+// unauthenticated bind Bind refuses.
+func (l *Connection) UnauthenticatedBind(username string) error {
+	return l.UnauthenticatedBindContext(context.Background(), username)
+}
+
+// UnauthenticatedBindContext is UnauthenticatedBind with ctx.
+func (l *Connection) UnauthenticatedBindContext(ctx context.Context, username string) error {
+	_, err := l.SimpleBindContext(ctx, &SimpleBindRequest{Username: username, AllowEmptyPassword: true})
+	return err
+}
 
-	packet, err := requestBuildPacket(messageID, encodedBind, nil)
+// SimpleBind binds with the request controls of req and returns the result
+// including the response controls, also if the bind failed.
+func (l *Connection) SimpleBind(req *SimpleBindRequest) (*LDAPResult, error) {
+	return l.SimpleBindContext(context.Background(), req)
+}
+
+// SimpleBindContext is SimpleBind with ctx. A bind can't be abandoned, when
+// ctx is done before the response arrived the connection is closed, as its
+// identity is unknown then.
+func (l *Connection) SimpleBindContext(ctx context.Context, req *SimpleBindRequest) (*LDAPResult, error) {
+	if req.Password == "" && req.Username != "" && !req.AllowEmptyPassword {
+		return nil, newError(ErrorEmptyPassword, "Refusing an unauthenticated bind of "+req.Username+" with an empty password, see AllowEmptyPassword")
+	}
+	if req.Password != "" {
+		if err := l.credentialsAllowed(); err != nil {
+			return nil, err
+		}
+	}
+	messageID, release, err := l.nextRequestID(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer release()
 
-	return l.sendReqRespPacket(messageID, packet)
+	encodedBind := encodeSimpleBindRequest(req.Username, req.Password)
 
+	packet, err := requestBuildPacket(messageID, encodedBind, req.Controls)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := l.sendReqRespResult(ctx, messageID, packet)
+	if err == nil {
+		l.setLastBind(func(ctx context.Context) error {
+			_, err := l.SimpleBindContext(ctx, req)
+			return err
+		})
+	}
+	return result, err
 }
 
 func encodeSimpleBindRequest(username, password string) (bindRequest *ber.Packet) {
@@ -32,3 +105,44 @@ func encodeSimpleBindRequest(username, password string) (bindRequest *ber.Packet
 	bindRequest.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, password, "Password"))
 	return
 }
+
+// ExternalBind binds with the SASL EXTERNAL mechanism [https://tools.ietf.org/html/rfc4422#appendix-A],
+// the server authenticates the client by the TLS client certificate or the
+// user of the process connected to an ldapi:// socket. authzID requests to
+// act as another identity, empty for the authenticated one.
+func (l *Connection) ExternalBind(authzID string) (*LDAPResult, error) {
+	return l.ExternalBindContext(context.Background(), authzID)
+}
+
+// ExternalBindContext is ExternalBind with ctx, see SimpleBindContext.
+func (l *Connection) ExternalBindContext(ctx context.Context, authzID string) (*LDAPResult, error) {
+	result, err := l.externalBind(ctx, authzID)
+	if err == nil {
+		l.setLastBind(func(ctx context.Context) error {
+			_, err := l.ExternalBindContext(ctx, authzID)
+			return err
+		})
+	}
+	return result, err
+}
+
+// externalBind is ExternalBindContext without replaying the bind after a
+// reconnect, for the AutoExternalBind of Connect.
+func (l *Connection) externalBind(ctx context.Context, authzID string) (*LDAPResult, error) {
+	return l.saslBind(ctx, &externalMechanism{authzID})
+}
+
+// encodeSaslBindRequest encodes the bind of mechanism, the credentials are
+// left out if nil.
+func encodeSaslBindRequest(mechanism string, credentials []byte) (bindRequest *ber.Packet) {
+	bindRequest = ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(ApplicationBindRequest), nil, "Bind Request")
+	bindRequest.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 3, "Version"))
+	bindRequest.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "User Name"))
+	sasl := ber.Encode(ber.ClassContext, ber.TypeConstructed, 3, nil, "SASL Credentials")
+	sasl.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, mechanism, "Mechanism"))
+	if credentials != nil {
+		sasl.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(credentials), "Credentials"))
+	}
+	bindRequest.AppendChild(sasl)
+	return
+}
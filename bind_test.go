@@ -0,0 +1,101 @@
+package ldap
+
+import (
+	"context"
+	"github.com/eaciit/asn1-ber"
+	"testing"
+	"time"
+)
+
+func mockControl(controlType ControlType, value *ber.Packet) *ber.Packet {
+	control := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	control.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(controlType), "Control Type"))
+	if value != nil {
+		octetString := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value")
+		octetString.AppendChild(value)
+		control.AppendChild(octetString)
+	}
+	return control
+}
+
+func TestSimpleBindResponseControls(t *testing.T) {
+	l, s := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		policy := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "PasswordPolicyResponseValue")
+		policy.AppendChild(ber.NewInteger(ber.ClassContext, ber.TypePrimitive, 1, PasswordPolicyAccountLocked, "error"))
+		s.respond(mockMessageID(request),
+			mockLDAPResult(ApplicationBindResponse, ResultInvalidCredentials, ""),
+			mockControl(ControlTypePasswordPolicy, policy))
+	})
+	defer l.Close()
+
+	req := NewSimpleBindRequest("uid=bob,o=bigcorp", "secret", nil)
+	req.AddControl(NewControlPasswordPolicyRequest())
+	result, err := l.SimpleBind(req)
+	if err == nil {
+		t.Fatal("Expected bind to fail")
+	}
+	if result == nil || result.ResultCode != ResultInvalidCredentials {
+		t.Fatalf("Unexpected result %v", result)
+	}
+	_, control := FindControl(result.Controls, ControlTypePasswordPolicy)
+	policy, ok := control.(*ControlPasswordPolicyResponse)
+	if !ok {
+		t.Fatalf("Password policy response control not returned: %v", result.Controls)
+	}
+	if policy.Error != PasswordPolicyAccountLocked || policy.TimeBeforeExpiration != -1 {
+		t.Errorf("Unexpected password policy response %s", policy)
+	}
+
+	request := <-s.requests
+	if len(request.Children) != 3 {
+		t.Errorf("Request controls missing from bind request")
+	}
+}
+
+func TestSimpleBindEmptyPassword(t *testing.T) {
+	l, s := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		s.respondResult(mockMessageID(request), ApplicationBindResponse, ResultSuccess, "")
+	})
+	defer l.Close()
+
+	err := l.Bind("uid=bob,o=bigcorp", "")
+	if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ErrorEmptyPassword {
+		t.Fatalf("Expected ErrorEmptyPassword, got %v", err)
+	}
+	if err := l.Bind("", ""); err != nil {
+		t.Fatal(err)
+	}
+	if request := <-s.requests; packetString(request.Children[1].Children[1]) != "" {
+		t.Errorf("Expected the anonymous bind, got %v", request)
+	}
+	if err := l.UnauthenticatedBind("uid=bob,o=bigcorp"); err != nil {
+		t.Fatal(err)
+	}
+	if request := <-s.requests; packetString(request.Children[1].Children[1]) != "uid=bob,o=bigcorp" {
+		t.Errorf("Expected the unauthenticated bind, got %v", request)
+	}
+}
+
+func TestBindContextCanceled(t *testing.T) {
+	// the server never answers the bind
+	l, s := newMockConnection(t, nil)
+	defer l.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.BindContext(ctx, "cn=admin,o=bigcorp", "secret"); err == nil {
+		t.Fatal("Expected the bind to time out")
+	}
+	// the bind isn't abandoned, the connection is closed instead
+	if bind := <-s.requests; bind.Children[1].Tag != ber.Tag(ApplicationBindRequest) {
+		t.Errorf("Expected the bind, got %v", bind.Children[1])
+	}
+	if _, err := l.Delete(NewDeleteRequest("cn=bob,o=bigcorp")); err == nil {
+		t.Error("Expected the connection to be closed after the interrupted bind")
+	}
+	select {
+	case request := <-s.requests:
+		t.Errorf("Expected nothing more to be sent, got %v", request.Children[1])
+	default:
+	}
+}
@@ -0,0 +1,94 @@
+package ldap
+
+import (
+	"strconv"
+	"strings"
+)
+
+//go:generate stringer -type=BindFailureReason
+
+// BindFailureReason is the reason a bind failed, see BindFailure.
+type BindFailureReason uint8
+
+const (
+	// no reason known, e.g. for a successful bind
+	BindFailureUnknown BindFailureReason = 0
+	// wrong password, data 52e of Active Directory
+	BindFailureInvalidCredentials BindFailureReason = 1
+	// unknown user, data 525; login forms should not tell it apart from a
+	// wrong password
+	BindFailureNoSuchUser BindFailureReason = 2
+	// account disabled, data 533
+	BindFailureAccountDisabled BindFailureReason = 3
+	// account expired, data 701
+	BindFailureAccountExpired BindFailureReason = 4
+	// password expired, data 532 or passwordExpired of ppolicy
+	BindFailurePasswordExpired BindFailureReason = 5
+	// password has to be changed, e.g. after a reset, data 773 or
+	// changeAfterReset of ppolicy
+	BindFailureMustChangePassword BindFailureReason = 6
+	// account locked out, data 775 or accountLocked of ppolicy
+	BindFailureAccountLocked BindFailureReason = 7
+	// logon outside the allowed hours, data 530
+	BindFailureTimeRestriction BindFailureReason = 8
+	// logon from a workstation not allowed, data 531
+	BindFailureWorkstationRestriction BindFailureReason = 9
+)
+
+// bindFailureDataCodes maps the data codes of the diagnostic messages of
+// Active Directory to the reasons.
+var bindFailureDataCodes = map[uint64]BindFailureReason{
+	0x525: BindFailureNoSuchUser,
+	0x52e: BindFailureInvalidCredentials,
+	0x530: BindFailureTimeRestriction,
+	0x531: BindFailureWorkstationRestriction,
+	0x532: BindFailurePasswordExpired,
+	0x533: BindFailureAccountDisabled,
+	0x701: BindFailureAccountExpired,
+	0x773: BindFailureMustChangePassword,
+	0x775: BindFailureAccountLocked,
+}
+
+// BindFailure returns the reason of the bind result, decoded from the
+// ControlPasswordPolicyResponse of the OpenLDAP ppolicy overlay, requested
+// with NewControlPasswordPolicyRequest, or the data code in the diagnostic
+// message of Active Directory, e.g. "80090308: LdapErr: DSID-0C09044E,
+// comment: AcceptSecurityContext error, data 52e, v4563". Other
+// ResultInvalidCredentials results are BindFailureInvalidCredentials. A
+// successful bind of a user who has to change the password returns
+// BindFailureMustChangePassword.
+func BindFailure(result *LDAPResult) BindFailureReason {
+	if result == nil {
+		return BindFailureUnknown
+	}
+	if _, control := FindControl(result.Controls, ControlTypePasswordPolicy); control != nil {
+		if policy, ok := control.(*ControlPasswordPolicyResponse); ok {
+			switch policy.Error {
+			case PasswordPolicyExpired:
+				return BindFailurePasswordExpired
+			case PasswordPolicyAccountLocked:
+				return BindFailureAccountLocked
+			case PasswordPolicyChangeAfterReset:
+				return BindFailureMustChangePassword
+			}
+		}
+	}
+	if result.ResultCode == ResultSuccess {
+		return BindFailureUnknown
+	}
+	if i := strings.Index(result.DiagnosticMessage, "data "); i >= 0 {
+		data := result.DiagnosticMessage[i+len("data "):]
+		if end := strings.IndexAny(data, ", "); end >= 0 {
+			data = data[:end]
+		}
+		if code, err := strconv.ParseUint(data, 16, 32); err == nil {
+			if reason, ok := bindFailureDataCodes[code]; ok {
+				return reason
+			}
+		}
+	}
+	if result.ResultCode == ResultInvalidCredentials {
+		return BindFailureInvalidCredentials
+	}
+	return BindFailureUnknown
+}
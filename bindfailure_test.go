@@ -0,0 +1,34 @@
+package ldap
+
+import (
+	"testing"
+)
+
+func TestBindFailure(t *testing.T) {
+	policy := func(error int64) []Control {
+		return []Control{&ControlPasswordPolicyResponse{TimeBeforeExpiration: -1, GraceAuthNsRemaining: -1, Error: error}}
+	}
+	for _, test := range []struct {
+		result   *LDAPResult
+		expected BindFailureReason
+	}{
+		{nil, BindFailureUnknown},
+		{&LDAPResult{ResultCode: ResultSuccess}, BindFailureUnknown},
+		{&LDAPResult{ResultCode: ResultInvalidCredentials}, BindFailureInvalidCredentials},
+		{&LDAPResult{ResultCode: ResultInvalidCredentials, DiagnosticMessage: "80090308: LdapErr: DSID-0C09044E, comment: AcceptSecurityContext error, data 52e, v4563"}, BindFailureInvalidCredentials},
+		{&LDAPResult{ResultCode: ResultInvalidCredentials, DiagnosticMessage: "80090308: LdapErr: DSID-0C09044E, comment: AcceptSecurityContext error, data 525, v4563"}, BindFailureNoSuchUser},
+		{&LDAPResult{ResultCode: ResultInvalidCredentials, DiagnosticMessage: "80090308: LdapErr: DSID-0C09044E, comment: AcceptSecurityContext error, data 533, v2580"}, BindFailureAccountDisabled},
+		{&LDAPResult{ResultCode: ResultInvalidCredentials, DiagnosticMessage: "80090308: LdapErr: DSID-0C09044E, comment: AcceptSecurityContext error, data 701, v4563"}, BindFailureAccountExpired},
+		{&LDAPResult{ResultCode: ResultInvalidCredentials, DiagnosticMessage: "80090308: LdapErr: DSID-0C090447, comment: AcceptSecurityContext error, data 773, v3839"}, BindFailureMustChangePassword},
+		{&LDAPResult{ResultCode: ResultInvalidCredentials, DiagnosticMessage: "80090308: LdapErr: DSID-0C09044E, comment: AcceptSecurityContext error, data 775, v4563"}, BindFailureAccountLocked},
+		{&LDAPResult{ResultCode: ResultInvalidCredentials, DiagnosticMessage: "data 999"}, BindFailureInvalidCredentials},
+		{&LDAPResult{ResultCode: ResultUnwillingToPerform, DiagnosticMessage: "unauthenticated bind (DN with no password) disallowed"}, BindFailureUnknown},
+		{&LDAPResult{ResultCode: ResultInvalidCredentials, Controls: policy(PasswordPolicyAccountLocked)}, BindFailureAccountLocked},
+		{&LDAPResult{ResultCode: ResultInvalidCredentials, Controls: policy(PasswordPolicyExpired)}, BindFailurePasswordExpired},
+		{&LDAPResult{ResultCode: ResultSuccess, Controls: policy(PasswordPolicyChangeAfterReset)}, BindFailureMustChangePassword},
+	} {
+		if reason := BindFailure(test.result); reason != test.expected {
+			t.Errorf("Expected %s for %v, got %s", test.expected, test.result, reason)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"github.com/eaciit/asn1-ber"
+)
+
+// Cancel Operation [https://tools.ietf.org/html/rfc3909]
+const ExtendedOperationCancel = "1.3.6.1.1.8"
+
+/*
+cancelRequestValue ::= SEQUENCE {
+     cancelID        MessageID }
+*/
+
+// Cancel asks the server to stop processing the operation with
+// cancelMessageID like Abandon, but the server responds once the operation
+// stopped: Cancel returns nil if it was canceled, otherwise an error with
+// ResultNoSuchOperation, ResultTooLate or ResultCannotCancel. The operation
+// waiting for cancelMessageID is released right away and returns an
+// ErrorAbandoned *Error.
+func (l *Connection) Cancel(cancelMessageID int64) error {
+	return l.CancelContext(context.Background(), cancelMessageID)
+}
+
+// CancelContext is Cancel with ctx.
+func (l *Connection) CancelContext(ctx context.Context, cancelMessageID int64) error {
+	value := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "cancelRequestValue")
+	value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, cancelMessageID, "cancelID"))
+	// the response of the canceled operation is dropped
+	l.abandonMessage(cancelMessageID)
+	_, err := l.ExtendedContext(ctx, NewExtendedRequest(ExtendedOperationCancel, value))
+	return err
+}
+
+// cancelOnDone cancels the request of messageID for CancelOnDone and
+// abandons it if the server doesn't support cancel.
+func (l *Connection) cancelOnDone(messageID int64) {
+	err := l.Cancel(messageID)
+	if lerr, ok := err.(*Error); ok {
+		switch lerr.ResultCode {
+		case ResultNoSuchOperation, ResultTooLate, ErrorClosing:
+			// finished already or the connection closed
+			return
+		}
+	}
+	if err == nil {
+		return
+	}
+	if l.Debug {
+		fmt.Printf("%d: error canceling the request, abandoning it: %v\n", messageID, err)
+	}
+	if err := l.Abandon(messageID); err != nil && l.Debug {
+		fmt.Printf("%d: error abandoning the request: %v\n", messageID, err)
+	}
+}
@@ -0,0 +1,72 @@
+package ldap
+
+import (
+	"context"
+	"github.com/eaciit/asn1-ber"
+	"testing"
+	"time"
+)
+
+// mockCancelServer never answers searches and responds to cancels with
+// resultCode, the search is answered with ResultCanceled on success.
+func mockCancelServer(t *testing.T, resultCode ResultCode) (*Connection, *mockServer) {
+	searches := make(chan int64, 1)
+	return newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		switch request.Children[1].Tag {
+		case ber.Tag(ApplicationSearchRequest):
+			searches <- mockMessageID(request)
+		case ber.Tag(ApplicationExtendedRequest):
+			if resultCode == ResultSuccess {
+				s.respondResult(<-searches, ApplicationSearchResultDone, ResultCanceled, "")
+			}
+			s.respond(mockMessageID(request), mockExtendedResponse(resultCode, "", nil))
+		}
+	})
+}
+
+func TestCancelOnDone(t *testing.T) {
+	l, s := mockCancelServer(t, ResultSuccess)
+	defer l.Close()
+	l.CancelOnDone = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", nil)
+	if _, err := l.SearchContext(ctx, searchRequest); err == nil {
+		t.Fatal("Expected the search to time out")
+	}
+
+	search, cancelRequest := <-s.requests, <-s.requests
+	if name := packetString(cancelRequest.Children[1].Children[0]); name != ExtendedOperationCancel {
+		t.Fatalf("Expected a cancel, got %v", cancelRequest.Children[1])
+	}
+	value := ber.DecodePacket(cancelRequest.Children[1].Children[1].Data.Bytes())
+	if cancelID, _ := packetInt64(value.Children[0]); cancelID != mockMessageID(search) {
+		t.Errorf("Expected the cancel of %d, got %d", mockMessageID(search), cancelID)
+	}
+}
+
+func TestCancelOnDoneUnsupported(t *testing.T) {
+	l, s := mockCancelServer(t, ResultProtocolError)
+	defer l.Close()
+	l.CancelOnDone = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", nil)
+	l.SearchContext(ctx, searchRequest)
+
+	search := <-s.requests
+	<-s.requests
+	select {
+	case abandon := <-s.requests:
+		if abandon.Children[1].Tag != ber.Tag(ApplicationAbandonRequest) {
+			t.Fatalf("Expected an abandon, got %v", abandon.Children[1])
+		}
+		if abandonID, _ := packetInt64(abandon.Children[1]); abandonID != mockMessageID(search) {
+			t.Errorf("Expected the abandon of %d, got %d", mockMessageID(search), abandonID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The search was not abandoned after the cancel failed")
+	}
+}
@@ -0,0 +1,106 @@
+package ldap
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+)
+
+// Channel binding types [https://tools.ietf.org/html/rfc5929] binding a SASL
+// authentication to the TLS connection, e.g. for Active Directory with a
+// LdapEnforceChannelBinding of 2
+const (
+	ChannelBindingTLSUnique         = "tls-unique"
+	ChannelBindingTLSServerEndPoint = "tls-server-end-point"
+	// ChannelBindingTLSExporter [https://tools.ietf.org/html/rfc9266], the
+	// replacement of tls-unique with TLS 1.3
+	ChannelBindingTLSExporter = "tls-exporter"
+	// ChannelBindingNone sends no channel binding with GSSAPIMechanism and
+	// SPNEGOMechanism
+	ChannelBindingNone = "none"
+)
+
+// ChannelBinding returns the type and data of the channel binding cbType of
+// the TLS connection, for an empty cbType tls-exporter with TLS 1.3 and
+// tls-unique before.
+func (s *SASLServerInfo) ChannelBinding(cbType string) (string, []byte, error) {
+	state := s.TLS
+	if state == nil {
+		return "", nil, newError(ErrorInvalidArgument, "Channel binding requires TLS")
+	}
+	if cbType == "" {
+		cbType = ChannelBindingTLSUnique
+		if state.Version >= tls.VersionTLS13 {
+			cbType = ChannelBindingTLSExporter
+		}
+	}
+	var data []byte
+	switch cbType {
+	case ChannelBindingTLSUnique:
+		data = state.TLSUnique
+	case ChannelBindingTLSExporter:
+		data, _ = state.ExportKeyingMaterial("EXPORTER-Channel-Binding", nil, 32)
+	case ChannelBindingTLSServerEndPoint:
+		data = tlsServerEndPoint(state)
+	default:
+		return "", nil, newError(ErrorInvalidArgument, "Unsupported channel binding type "+cbType)
+	}
+	if len(data) == 0 {
+		return "", nil, newError(ErrorInvalidArgument, "No channel binding "+cbType+" for the TLS connection")
+	}
+	return cbType, data, nil
+}
+
+// gssapiChannelBinding returns the application data of the channel bindings
+// of GSSAPI and GSS-SPNEGO, "<type>:<data>". An empty cbType is
+// tls-server-end-point, the type verified by Active Directory, or none
+// without TLS or a server certificate.
+func gssapiChannelBinding(cbType string, server *SASLServerInfo) ([]byte, error) {
+	if cbType == ChannelBindingNone {
+		return nil, nil
+	}
+	if cbType == "" {
+		if server.TLS == nil || tlsServerEndPoint(server.TLS) == nil {
+			return nil, nil
+		}
+		cbType = ChannelBindingTLSServerEndPoint
+	}
+	cbType, data, err := server.ChannelBinding(cbType)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(cbType+":"), data...), nil
+}
+
+// tlsServerEndPoint returns the hash of the server certificate of the
+// channel binding type tls-server-end-point, nil without a certificate.
+func tlsServerEndPoint(state *tls.ConnectionState) []byte {
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	cert := state.PeerCertificates[0]
+	switch cert.SignatureAlgorithm {
+	case x509.SHA384WithRSA, x509.ECDSAWithSHA384, x509.SHA384WithRSAPSS:
+		sum := sha512.Sum384(cert.Raw)
+		return sum[:]
+	case x509.SHA512WithRSA, x509.ECDSAWithSHA512, x509.SHA512WithRSAPSS:
+		sum := sha512.Sum512(cert.Raw)
+		return sum[:]
+	}
+	// SHA-256 also for MD5 and SHA-1
+	sum := sha256.Sum256(cert.Raw)
+	return sum[:]
+}
+
+// gssChannelBindingsHash returns the MD5 of the gss_channel_bindings_struct
+// [https://tools.ietf.org/html/rfc4121#section-4.1.1.2] of the application
+// data without addresses, sent by Kerberos and NTLMv2.
+func gssChannelBindingsHash(application []byte) []byte {
+	bindings := make([]byte, 20, 20+len(application))
+	binary.LittleEndian.PutUint32(bindings[16:], uint32(len(application)))
+	sum := md5.Sum(append(bindings, application...))
+	return sum[:]
+}
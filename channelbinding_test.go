@@ -0,0 +1,52 @@
+package ldap
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+)
+
+func TestChannelBinding(t *testing.T) {
+	plain := &SASLServerInfo{Host: "dc.example.com"}
+	if _, _, err := plain.ChannelBinding(""); err == nil {
+		t.Error("Expected an error for channel binding without TLS")
+	}
+	if data, err := gssapiChannelBinding("", plain); err != nil || data != nil {
+		t.Errorf("Expected no channel binding without TLS, got %x: %v", data, err)
+	}
+	if _, err := gssapiChannelBinding(ChannelBindingTLSUnique, plain); err == nil {
+		t.Error("Expected an error for channel binding without TLS")
+	}
+
+	cert := &x509.Certificate{Raw: []byte("certificate"), SignatureAlgorithm: x509.SHA384WithRSA}
+	server := &SASLServerInfo{Host: "dc.example.com", TLS: &tls.ConnectionState{
+		Version: tls.VersionTLS12, TLSUnique: []byte("unique"), PeerCertificates: []*x509.Certificate{cert}}}
+	if cbType, data, err := server.ChannelBinding(""); err != nil || cbType != ChannelBindingTLSUnique || string(data) != "unique" {
+		t.Errorf("Unexpected channel binding %s %q: %v", cbType, data, err)
+	}
+	hash := sha512.Sum384(cert.Raw)
+	if _, data, err := server.ChannelBinding(ChannelBindingTLSServerEndPoint); err != nil || !bytes.Equal(data, hash[:]) {
+		t.Errorf("Unexpected tls-server-end-point %x: %v", data, err)
+	}
+	if _, _, err := server.ChannelBinding("tls-foo"); err == nil {
+		t.Error("Expected an error for an unsupported channel binding type")
+	}
+
+	if data, err := gssapiChannelBinding("", server); err != nil || !bytes.Equal(data, append([]byte("tls-server-end-point:"), hash[:]...)) {
+		t.Errorf("Unexpected GSSAPI channel binding %x: %v", data, err)
+	}
+	if data, err := gssapiChannelBinding(ChannelBindingTLSUnique, server); err != nil || string(data) != "tls-unique:unique" {
+		t.Errorf("Unexpected GSSAPI channel binding %q: %v", data, err)
+	}
+	if data, err := gssapiChannelBinding(ChannelBindingNone, server); err != nil || data != nil {
+		t.Errorf("Expected no channel binding, got %x: %v", data, err)
+	}
+
+	// TLS 1.3 has no tls-unique
+	server.TLS.Version, server.TLS.TLSUnique = tls.VersionTLS13, nil
+	if _, err := gssapiChannelBinding(ChannelBindingTLSUnique, server); err == nil {
+		t.Error("Expected an error for tls-unique with TLS 1.3")
+	}
+}
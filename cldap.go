@@ -0,0 +1,289 @@
+package ldap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/eaciit/asn1-ber"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Connectionless LDAP [https://tools.ietf.org/html/rfc1798], Active Directory
+// answers searches of the RootDSE over UDP, most notably the Netlogon ping
+// [https://msdn.microsoft.com/en-us/library/cc223811.aspx].
+
+// DefaultCLDAPTimeout is the time CLDAPSearch waits for the response if no
+// timeout is given.
+const DefaultCLDAPTimeout = 5 * time.Second
+
+// number of CLDAP requests, the messageID wraps around after MaxMessageID
+var cldapMessageID int64
+
+// CLDAPSearch sends searchRequest in a UDP datagram to addr, host or host:port
+// with the port defaulting to 389, and waits at most timeout for the result.
+// Servers only answer searches of the RootDSE like the Netlogon ping, there is
+// no bind and no retransmission.
+func CLDAPSearch(addr string, searchRequest *SearchRequest, timeout time.Duration) (*SearchResult, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, DefaultLdapPort)
+	}
+	if timeout <= 0 {
+		timeout = DefaultCLDAPTimeout
+	}
+
+	searchPacket, err := encodeSearchRequest(searchRequest)
+	if err != nil {
+		return nil, err
+	}
+	messageID := (atomic.AddInt64(&cldapMessageID, 1)-1)%MaxMessageID + 1
+	packet, err := requestBuildPacket(messageID, searchPacket, searchRequest.Controls)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("udp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(packet.Bytes()); err != nil {
+		return nil, newError(ErrorNetwork, err.Error())
+	}
+
+	result := &SearchResult{
+		Entries:   make([]*Entry, 0),
+		Referrals: make([]string, 0),
+		Controls:  make([]Control, 0)}
+	buf := make([]byte, 65536)
+	for {
+		n, err := conn.Read(buf)
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return result, newError(ErrorNetwork, "Timeout waiting for CLDAP response")
+		} else if err != nil {
+			return result, newError(ErrorNetwork, err.Error())
+		}
+
+		// the entry and the result done are usually sent in one datagram
+		reader := bytes.NewReader(buf[:n])
+		for reader.Len() > 0 {
+			response, err := ber.ReadPacket(reader)
+			if err != nil {
+				return result, newError(ErrorDecoding, "Couldn't decode CLDAP response: "+err.Error())
+			}
+			if len(response.Children) < 2 {
+				return result, newError(ErrorDecoding, "Invalid CLDAP response.")
+			}
+			if id, _ := packetInt64(response.Children[0]); id != messageID {
+				// a late response to an earlier request
+				continue
+			}
+			discreteSearchResult, err := decodeSearchResponse(response)
+			if err != nil {
+				return result, err
+			}
+			result.ProcessDiscreteResult(discreteSearchResult, nil)
+			if discreteSearchResult.SearchResultType == SearchResultDone {
+				return result, nil
+			}
+		}
+	}
+}
+
+// NtVer flags of a Netlogon ping, the version of the response requested
+const (
+	NetlogonNtVersion1               = 0x00000001
+	NetlogonNtVersion5               = 0x00000002
+	NetlogonNtVersion5EX             = 0x00000004
+	NetlogonNtVersion5EXWithIP       = 0x00000008
+	NetlogonNtVersionWithClosestSite = 0x00000010
+)
+
+// Opcodes of a NETLOGON_SAM_LOGON_RESPONSE_EX
+const (
+	NetlogonLogonSamLogonResponseEx = 23
+	NetlogonLogonSamPauseResponseEx = 24
+	NetlogonLogonSamUserUnknownEx   = 25
+)
+
+// Flags of a NETLOGON_SAM_LOGON_RESPONSE_EX, the capabilities of the domain
+// controller
+const (
+	NetlogonFlagPDC           = 0x00000001
+	NetlogonFlagGC            = 0x00000004
+	NetlogonFlagLDAP          = 0x00000008
+	NetlogonFlagDS            = 0x00000010
+	NetlogonFlagKDC           = 0x00000020
+	NetlogonFlagTimeServ      = 0x00000040
+	NetlogonFlagClosest       = 0x00000080
+	NetlogonFlagWritable      = 0x00000100
+	NetlogonFlagGoodTimeServ  = 0x00000200
+	NetlogonFlagNDNC          = 0x00000400
+	NetlogonFlagSelectSecret  = 0x00000800
+	NetlogonFlagFullSecret    = 0x00001000
+	NetlogonFlagWS            = 0x00002000
+	NetlogonFlagDS8           = 0x00004000
+	NetlogonFlagDS9           = 0x00008000
+	NetlogonFlagDS10          = 0x00010000
+	NetlogonFlagDNSController = 0x20000000
+	NetlogonFlagDNSDomain     = 0x40000000
+	NetlogonFlagDNSForest     = 0x80000000
+)
+
+// AttributeNetlogon is the attribute of the RootDSE returning the Netlogon
+// ping response.
+const AttributeNetlogon = "Netlogon"
+
+// NetlogonSamLogonResponseEx is the NETLOGON_SAM_LOGON_RESPONSE_EX of a
+// Netlogon ping [https://msdn.microsoft.com/en-us/library/cc223807.aspx].
+type NetlogonSamLogonResponseEx struct {
+	// NetlogonLogonSam*
+	Opcode uint16
+	// NetlogonFlag*
+	Flags      uint32
+	DomainGUID string
+
+	DnsForestName       string
+	DnsDomainName       string
+	DnsHostName         string
+	NetbiosDomainName   string
+	NetbiosComputerName string
+	UserName            string
+	DcSiteName          string
+	ClientSiteName      string
+	// IPv4 address of the domain controller, requested with
+	// NetlogonNtVersion5EXWithIP
+	DcIP net.IP
+	// requested with NetlogonNtVersionWithClosestSite
+	NextClosestSiteName string
+
+	NtVersion uint32
+	LmNtToken uint16
+	Lm20Token uint16
+}
+
+// NewNetlogonPingRequest returns the search of the RootDSE for a Netlogon
+// ping of a domain controller of dnsDomain, empty for any domain, requesting
+// the response version ntVersion (NetlogonNtVersion*).
+func NewNetlogonPingRequest(dnsDomain string, ntVersion uint32) *SearchRequest {
+	ntVer := make([]byte, 4)
+	binary.LittleEndian.PutUint32(ntVer, ntVersion)
+	filter := "(NtVer=" + EscapeFilterValue(string(ntVer)) + ")"
+	if dnsDomain != "" {
+		filter = "(&(DnsDomain=" + EscapeFilterValue(dnsDomain) + ")" + filter + ")"
+	}
+	return NewSimpleSearchRequest("", ScopeBaseObject, filter, []string{AttributeNetlogon})
+}
+
+// NetlogonPing sends a Netlogon ping over CLDAP to the domain controller at
+// addr for dnsDomain, the response tells the domain and site of the domain
+// controller and the site of the client. The 5EX version of the response is
+// requested including the IP of the domain controller.
+func NetlogonPing(addr, dnsDomain string, timeout time.Duration) (*NetlogonSamLogonResponseEx, error) {
+	ntVersion := uint32(NetlogonNtVersion5EX | NetlogonNtVersion5EXWithIP)
+	result, err := CLDAPSearch(addr, NewNetlogonPingRequest(dnsDomain, ntVersion), timeout)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Entries) == 0 || len(result.Entries[0].GetAttributeValues(AttributeNetlogon)) == 0 {
+		return nil, newError(ErrorDecoding, "No Netlogon attribute in the response.")
+	}
+	return ParseNetlogonSamLogonResponseEx([]byte(result.Entries[0].GetAttributeValue(AttributeNetlogon)), ntVersion)
+}
+
+// ParseNetlogonSamLogonResponseEx decodes the value of the Netlogon attribute
+// returned for a Netlogon ping with ntVersion, which determines the fields
+// present.
+func ParseNetlogonSamLogonResponseEx(data []byte, ntVersion uint32) (*NetlogonSamLogonResponseEx, error) {
+	if len(data) < 24 {
+		return nil, newError(ErrorDecoding, fmt.Sprintf("NETLOGON_SAM_LOGON_RESPONSE_EX too short: %d bytes", len(data)))
+	}
+	response := &NetlogonSamLogonResponseEx{
+		Opcode: binary.LittleEndian.Uint16(data[0:2]),
+		Flags:  binary.LittleEndian.Uint32(data[4:8]),
+	}
+	switch response.Opcode {
+	case NetlogonLogonSamLogonResponseEx, NetlogonLogonSamPauseResponseEx, NetlogonLogonSamUserUnknownEx:
+	default:
+		return nil, newError(ErrorDecoding, fmt.Sprintf("Unexpected Netlogon opcode %d", response.Opcode))
+	}
+	var err error
+	if response.DomainGUID, err = FormatGUID(data[8:24]); err != nil {
+		return nil, err
+	}
+
+	offset := 24
+	for _, name := range []*string{
+		&response.DnsForestName, &response.DnsDomainName, &response.DnsHostName,
+		&response.NetbiosDomainName, &response.NetbiosComputerName, &response.UserName,
+		&response.DcSiteName, &response.ClientSiteName,
+	} {
+		if *name, offset, err = decodeNetlogonName(data, offset); err != nil {
+			return nil, err
+		}
+	}
+
+	if ntVersion&NetlogonNtVersion5EXWithIP != 0 {
+		if offset >= len(data) || offset+1+int(data[offset]) > len(data) {
+			return nil, newError(ErrorDecoding, "NETLOGON_SAM_LOGON_RESPONSE_EX truncated in DcSockAddr")
+		}
+		sockAddr := data[offset+1 : offset+1+int(data[offset])]
+		// sockaddr_in with the family AF_INET
+		if len(sockAddr) >= 8 && binary.LittleEndian.Uint16(sockAddr[0:2]) == 2 {
+			response.DcIP = net.IPv4(sockAddr[4], sockAddr[5], sockAddr[6], sockAddr[7])
+		}
+		offset += 1 + len(sockAddr)
+	}
+	if ntVersion&NetlogonNtVersionWithClosestSite != 0 {
+		if response.NextClosestSiteName, offset, err = decodeNetlogonName(data, offset); err != nil {
+			return nil, err
+		}
+	}
+
+	if offset+8 > len(data) {
+		return nil, newError(ErrorDecoding, "NETLOGON_SAM_LOGON_RESPONSE_EX truncated in NtVersion")
+	}
+	response.NtVersion = binary.LittleEndian.Uint32(data[offset : offset+4])
+	response.LmNtToken = binary.LittleEndian.Uint16(data[offset+4 : offset+6])
+	response.Lm20Token = binary.LittleEndian.Uint16(data[offset+6 : offset+8])
+	return response, nil
+}
+
+// decodeNetlogonName decodes the name at offset compressed as in DNS messages
+// [https://tools.ietf.org/html/rfc1035#section-4.1.4] and returns it with the
+// offset after it.
+func decodeNetlogonName(data []byte, offset int) (string, int, error) {
+	var labels []string
+	next := -1
+	for pointers := 0; ; {
+		if offset >= len(data) {
+			return "", 0, newError(ErrorDecoding, "Netlogon name out of range")
+		}
+		length := int(data[offset])
+		switch {
+		case length == 0:
+			if next == -1 {
+				next = offset + 1
+			}
+			return strings.Join(labels, "."), next, nil
+		case length&0xc0 == 0xc0:
+			if offset+1 >= len(data) || pointers > 32 {
+				return "", 0, newError(ErrorDecoding, "Invalid Netlogon name pointer")
+			}
+			if next == -1 {
+				next = offset + 2
+			}
+			offset = (length&0x3f)<<8 | int(data[offset+1])
+			pointers++
+		default:
+			if offset+1+length > len(data) {
+				return "", 0, newError(ErrorDecoding, "Netlogon name out of range")
+			}
+			labels = append(labels, string(data[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+}
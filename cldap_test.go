@@ -0,0 +1,104 @@
+package ldap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/eaciit/asn1-ber"
+	"net"
+	"testing"
+)
+
+// mockNetlogonResponse returns a NETLOGON_SAM_LOGON_RESPONSE_EX of dc1 in
+// example.com with the IP 10.0.0.1 and compressed names.
+func mockNetlogonResponse() []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, uint16(NetlogonLogonSamLogonResponseEx))
+	binary.Write(&b, binary.LittleEndian, uint16(0))
+	binary.Write(&b, binary.LittleEndian, uint32(NetlogonFlagPDC|NetlogonFlagLDAP|NetlogonFlagWritable))
+	b.Write([]byte{0x10, 0xb8, 0xa7, 0x6b, 0xad, 0x9d, 0xd1, 0x11, 0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8})
+	// DnsForestName at 24, DnsDomainName and DnsHostName point to it
+	b.Write([]byte("\x07example\x03com\x00"))
+	b.Write([]byte{0xc0, 24})
+	b.Write([]byte("\x03dc1\xc0\x18"))
+	b.Write([]byte("\x07EXAMPLE\x00"))
+	b.Write([]byte("\x03DC1\x00"))
+	b.Write([]byte{0x00})
+	// DcSiteName at 60, ClientSiteName points to it
+	b.Write([]byte("\x17Default-First-Site-Name\x00"))
+	b.Write([]byte{0xc0, 60})
+	b.Write([]byte{16, 2, 0, 0, 0, 10, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0})
+	binary.Write(&b, binary.LittleEndian, uint32(NetlogonNtVersion1|NetlogonNtVersion5EX|NetlogonNtVersion5EXWithIP))
+	binary.Write(&b, binary.LittleEndian, uint16(0xffff))
+	binary.Write(&b, binary.LittleEndian, uint16(0xffff))
+	return b.Bytes()
+}
+
+func TestParseNetlogonSamLogonResponseEx(t *testing.T) {
+	response, err := ParseNetlogonSamLogonResponseEx(mockNetlogonResponse(), NetlogonNtVersion5EX|NetlogonNtVersion5EXWithIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.DnsForestName != "example.com" || response.DnsDomainName != "example.com" || response.DnsHostName != "dc1.example.com" ||
+		response.NetbiosDomainName != "EXAMPLE" || response.NetbiosComputerName != "DC1" || response.UserName != "" {
+		t.Errorf("Unexpected names %+v", response)
+	}
+	if response.DcSiteName != "Default-First-Site-Name" || response.ClientSiteName != "Default-First-Site-Name" {
+		t.Errorf("Unexpected sites %q, %q", response.DcSiteName, response.ClientSiteName)
+	}
+	if response.DomainGUID != "6ba7b810-9dad-11d1-80b4-00c04fd430c8" || response.Flags&NetlogonFlagWritable == 0 {
+		t.Errorf("Unexpected GUID %s or flags %x", response.DomainGUID, response.Flags)
+	}
+	if !response.DcIP.Equal(net.IPv4(10, 0, 0, 1)) || response.NtVersion != 0xd || response.LmNtToken != 0xffff {
+		t.Errorf("Unexpected IP %s or version %x", response.DcIP, response.NtVersion)
+	}
+
+	looping := mockNetlogonResponse()[:26]
+	looping[24], looping[25] = 0xc0, 24
+	if _, err := ParseNetlogonSamLogonResponseEx(looping, NetlogonNtVersion5EX); err == nil {
+		t.Error("Expected an error for a looping name pointer")
+	}
+	if _, err := ParseNetlogonSamLogonResponseEx(mockNetlogonResponse()[:70], NetlogonNtVersion5EX); err == nil {
+		t.Error("Expected an error for a truncated response")
+	}
+}
+
+func TestNetlogonPing(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	requests := make(chan *ber.Packet, 1)
+	go func() {
+		buf := make([]byte, 4096)
+		n, addr, err := server.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		request := ber.DecodePacket(buf[:n])
+		requests <- request
+		messageID := mockMessageID(request)
+
+		entry := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+		entry.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+		entry.AppendChild(mockSearchEntry("", map[string][]string{"netlogon": {string(mockNetlogonResponse())}}))
+		done := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+		done.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+		done.AppendChild(mockLDAPResult(ApplicationSearchResultDone, ResultSuccess, ""))
+		server.WriteTo(append(entry.Bytes(), done.Bytes()...), addr)
+	}()
+
+	response, err := NetlogonPing(server.LocalAddr().String(), "example.com", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.DnsHostName != "dc1.example.com" || !response.DcIP.Equal(net.IPv4(10, 0, 0, 1)) {
+		t.Errorf("Unexpected response %+v", response)
+	}
+
+	filter := (<-requests).Children[1].Children[6]
+	if len(filter.Children) != 2 || packetString(filter.Children[0].Children[1]) != "example.com" ||
+		packetString(filter.Children[1].Children[1]) != "\x0c\x00\x00\x00" {
+		t.Errorf("Unexpected filter %v", filter.Children)
+	}
+}
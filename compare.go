@@ -1,9 +1,14 @@
 package ldap
 
 import (
+	"context"
 	"github.com/eaciit/asn1-ber"
+	"sync"
 )
 
+// maximum number of compare requests CompareMany keeps in flight
+const compareManyInFlight = 64
+
 /*
 CompareRequest ::= [APPLICATION 14] SEQUENCE {
     entry           LDAPDN,
@@ -21,31 +26,88 @@ type CompareRequest struct {
 	Controls []Control
 }
 
-func (l *Connection) Compare(req *CompareRequest) (bool, error) {
-	messageID, ok := l.nextMessageID()
-	if !ok {
-		return false, newError(ErrorClosing, "MessageID channel is closed.")
+// CompareResult is the result of a Compare, Match is true for compareTrue.
+type CompareResult struct {
+	LDAPResult
+	Match bool
+}
+
+// Compare asserts the value of the CompareRequest on the server. compareTrue
+// and compareFalse are returned as a CompareResult with a nil error, any other
+// result code like noSuchObject or insufficientAccessRights returns an *Error
+// along with the result.
+func (l *Connection) Compare(req *CompareRequest) (*CompareResult, error) {
+	return l.CompareContext(context.Background(), req)
+}
+
+// CompareContext is Compare with ctx, the request is abandoned when ctx is done
+// before the response arrived. A compare that failed because the connection
+// broke is retried once after reconnecting, see AutoReconnect.
+func (l *Connection) CompareContext(ctx context.Context, req *CompareRequest) (*CompareResult, error) {
+	result, err := l.compare(ctx, req)
+	if l.retryable(ctx, err) {
+		return l.compare(ctx, req)
 	}
+	return result, err
+}
+
+func (l *Connection) compare(ctx context.Context, req *CompareRequest) (*CompareResult, error) {
+	messageID, release, err := l.nextRequestID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	encodedCompare, err := encodeCompareRequest(req)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	packet, err := requestBuildPacket(messageID, encodedCompare, req.Controls)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+
+	result, err := l.sendReqRespResult(ctx, messageID, packet)
+	if result == nil {
+		return nil, err
+	}
+	switch result.ResultCode {
+	case ResultCompareTrue:
+		return &CompareResult{LDAPResult: *result, Match: true}, nil
+	case ResultCompareFalse:
+		return &CompareResult{LDAPResult: *result, Match: false}, nil
 	}
+	return &CompareResult{LDAPResult: *result}, err
+}
+
+// CompareMany pipelines the compare requests over the connection without
+// waiting for the responses in between. The result and error of reqs[i] are
+// returned at index i, as returned by Compare.
+func (l *Connection) CompareMany(reqs []*CompareRequest) ([]*CompareResult, []error) {
+	results := make([]*CompareResult, len(reqs))
+	errs := make([]error, len(reqs))
+
+	inFlight := make(chan struct{}, compareManyInFlight)
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		inFlight <- struct{}{}
+		wg.Add(1)
+		go func(i int, req *CompareRequest) {
+			defer wg.Done()
+			results[i], errs[i] = l.Compare(req)
+			<-inFlight
+		}(i, req)
+	}
+	wg.Wait()
+	return results, errs
+}
 
-	// CompareTrue = 6, CompareFalse = 5
-	// returns an "Error"
-	err = l.sendReqRespPacket(messageID, packet)
-	if lerr, ok := err.(*Error); ok {
-		return lerr.ResultCode == ResultCompareTrue, nil
-	} else {
-		return false, err
+func (req *CompareRequest) AddControl(control Control) {
+	if req.Controls == nil {
+		req.Controls = make([]Control, 0)
 	}
-	//return l.sendReqRespPacket(messageID, packet)
+	req.Controls = append(req.Controls, control)
 }
 
 func encodeCompareRequest(req *CompareRequest) (*ber.Packet, error) {
@@ -0,0 +1,65 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"testing"
+)
+
+func TestCompareResult(t *testing.T) {
+	resultCodes := map[string]ResultCode{
+		"cn=true":    ResultCompareTrue,
+		"cn=false":   ResultCompareFalse,
+		"cn=missing": ResultNoSuchObject,
+	}
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		dn := packetString(request.Children[1].Children[0])
+		s.respondResult(mockMessageID(request), ApplicationCompareResponse, resultCodes[dn], dn)
+	})
+	defer l.Close()
+
+	result, err := l.Compare(NewCompareRequest("cn=true", "cn", "bob"))
+	if err != nil || !result.Match {
+		t.Errorf("Expected a match, got %v, %v", result, err)
+	}
+
+	result, err = l.Compare(NewCompareRequest("cn=false", "cn", "bob"))
+	if err != nil || result.Match || result.ResultCode != ResultCompareFalse {
+		t.Errorf("Expected no match, got %v, %v", result, err)
+	}
+
+	result, err = l.Compare(NewCompareRequest("cn=missing", "cn", "bob"))
+	if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ResultNoSuchObject {
+		t.Errorf("Expected noSuchObject, got %v", err)
+	}
+	if result == nil || result.Match || result.DiagnosticMessage != "cn=missing" {
+		t.Errorf("Unexpected result %v", result)
+	}
+}
+
+func TestCompareMany(t *testing.T) {
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		compareTrue := packetString(request.Children[1].Children[1].Children[1]) == "bob"
+		resultCode := ResultCompareFalse
+		if compareTrue {
+			resultCode = ResultCompareTrue
+		}
+		s.respondResult(mockMessageID(request), ApplicationCompareResponse, resultCode, "")
+	})
+	defer l.Close()
+
+	values := []string{"bob", "alice", "bob", "eve"}
+	reqs := make([]*CompareRequest, len(values))
+	for i, value := range values {
+		reqs[i] = NewCompareRequest("cn=bob,o=bigcorp", "cn", value)
+	}
+	results, errs := l.CompareMany(reqs)
+	for i, value := range values {
+		if errs[i] != nil {
+			t.Errorf("Compare %d failed: %v", i, errs[i])
+			continue
+		}
+		if results[i].Match != (value == "bob") {
+			t.Errorf("Compare %d of %q returned Match %t", i, value, results[i].Match)
+		}
+	}
+}
@@ -1,19 +1,29 @@
 package ldap
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
 	"github.com/eaciit/asn1-ber"
 	"log"
 	"net"
-	"os"
 	"sync"
 	"time"
 )
 
+// DefaultFallbackDelay is the delay of Happy Eyeballs [https://tools.ietf.org/html/rfc8305#section-5]
+// before also trying the addresses of the other IP family of a host name.
+const DefaultFallbackDelay = 250 * time.Millisecond
+
+// Connection is safe for concurrent use by multiple goroutines, requests are
+// written one at a time and each response is routed to the operation waiting
+// for its messageID. Set the fields before Connect.
 type Connection struct {
+	// Upgrade the connection with StartTLS once connected
 	IsTLS bool
+	// Dial with TLS from the start, e.g. for ldaps://. A connection upgraded
+	// with StartTLS isn't marked as SSL, so it reconnects in plaintext
 	IsSSL bool
 	Debug bool
 
@@ -21,16 +31,145 @@ type Connection struct {
 	NetworkConnectTimeout       time.Duration
 	ReadTimeout                 time.Duration
 	AbandonMessageOnReadTimeout bool
-
+	// Default timeout of an operation covering the write of the request and
+	// the wait for the response, the operation is abandoned and returns an
+	// ErrorTimeout *Error. Deadlines of the context of an operation apply as
+	// well, persistent searches aren't limited
+	RequestTimeout time.Duration
+	// Stop operations whose context is done or whose RequestTimeout passed
+	// with the Cancel extended operation instead of an Abandon, for servers
+	// supporting it. The cancel runs in the background and falls back to an
+	// Abandon if the server rejects it
+	CancelOnDone bool
+	// How long Unbind waits for outstanding operations before abandoning them
+	DrainTimeout time.Duration
+	// Maximum of requests in flight, further operations wait in the order
+	// they were started until a response arrived or their context is done.
+	// A persistent search takes up a slot until it ends. 0 for no limit
+	MaxOutstanding int
+	// Maximum of responses to one operation queued until it takes them, e.g.
+	// the entries of a search a slow SearchResultHandler didn't process yet.
+	// Once the queue is full the reader pauses, delaying the responses to
+	// the other operations as well, so a SearchResultHandler waiting for
+	// another operation on the connection may then wait forever.
+	// DefaultMaxQueuedResponses if 0 and negative for no limit
+	MaxQueuedResponses int
+	// Period of the TCP keepalive probes, 0 for the default of the net package
+	// and negative to disable them. Applied as well to the TCP connections
+	// returned by DialContext, e.g. the one to a proxy
+	KeepAlive time.Duration
+	// Delay between the connection attempts to the IPv6 and IPv4 addresses of
+	// a host name with Happy Eyeballs, see net.Dialer.FallbackDelay;
+	// DefaultFallbackDelay if 0 and negative to try the addresses one after
+	// the other
+	FallbackDelay time.Duration
+	// Interval of the Heartbeat while connected, so idle connections through
+	// firewalls don't silently die, 0 disables it. The connection is closed
+	// when the server doesn't answer a heartbeat within the interval
+	HeartbeatInterval time.Duration
+	// Probe of the Heartbeat, RootDSEHealthCheck if nil
+	HealthCheck HealthCheck
+	// Close the connection once no operation ran for MaxIdleTime or it is
+	// older than MaxLifetime, before load balancers or servers drop it. The
+	// connection is only closed without outstanding operations, the next
+	// operation reconnects with AutoReconnect or Servers
+	MaxIdleTime time.Duration
+	MaxLifetime time.Duration
+	// Network of Addr as used in the net package, "tcp" if empty and "unix"
+	// for a unix domain socket
+	Network string
+	// Dials Addr instead of net.Dial if set, e.g. the DialContext of a
+	// net.Dialer with a custom resolver or a test transport. The context
+	// carries the NetworkConnectTimeout as deadline.
+	DialContext DialContextFunc
+	// Servers to connect to instead of Addr, Connect tries them in their
+	// order. Once the connection broke the next operation reconnects to the
+	// next available server
+	Servers *ServerList
+	// Reconnect to Addr with the next operation once the connection broke,
+	// connections to Servers always reconnect. The last successful bind is
+	// replayed after a reconnect and a Search or Compare that failed because
+	// the connection broke is retried once
+	AutoReconnect bool
+	// Binds after a reconnect instead of replaying the last bind, e.g. with
+	// fresh credentials or a SASL mechanism that can't be replayed
+	RebindHandler func(l *Connection) error
+
+	// Called with unsolicited notifications like the Notice of Disconnection
+	NotificationHandler UnsolicitedNotificationHandler
+	// Called in the order of the changes from the goroutine changing the
+	// state of the connection, or the one still calling it for an earlier
+	// change; the hooks must return quickly and run operations on the
+	// connection in a goroutine of their own
+	OnStateChange func(l *Connection, state ConnectionState)
+	// Called once the connection closed, with the cause if it broke and nil
+	// for Close, Unbind and MaxIdleTime or MaxLifetime
+	OnClose func(l *Connection, err error)
+	// Called once the connection reconnected and rebound
+	OnReconnect func(l *Connection)
+
+	// Configuration of TLS for SSL and StartTLS, e.g. MinVersion,
+	// CipherSuites or InsecureSkipVerify. A missing ServerName defaults to
+	// the host of Addr.
 	TlsConfig *tls.Config
+	// Returns the configuration of TLS instead of TlsConfig if set, called for
+	// every Connect and StartTLS so a reconnect picks up rotated certificates
+	TLSConfigProvider func() (*tls.Config, error)
+	// Resumes the TLS sessions of earlier handshakes to cut the latency of
+	// reconnects, e.g. a tls.NewLRUClientSessionCache shared by the
+	// connections to the same servers. Used unless the configuration of TLS
+	// has a ClientSessionCache, defaults to the TLSSessionCache of Servers
+	TLSSessionCache tls.ClientSessionCache
+	// Bind with SASL EXTERNAL once Connect established TLS, so the server maps
+	// the client certificate of TlsConfig to the identity of the connection
+	AutoExternalBind bool
+	// Don't go on without TLS: a failed or declined StartTLS closes the
+	// connection, and binds and password modifies sending a password, and
+	// SASL binds with a SASLCleartextMechanism, fail with
+	// ResultConfidentialityRequired before anything is sent unless the
+	// connection is encrypted or a unix domain socket
+	RequireTLS bool
 
 	conn               net.Conn
-	chanResults        map[int64]chan *ber.Packet
-	lockChanResults    sync.RWMutex
+	connLock           sync.RWMutex
+	responses          map[int64]*responseQueue
+	lockResponses      sync.RWMutex
 	chanProcessMessage chan *messagePacket
 	closeLock          sync.RWMutex
 	chanMessageID      chan int64
 	connected          bool
+	abandonedMessages  map[int64]bool
+	chanDone           chan struct{}
+	chanStopped        chan struct{}
+	chanReaderDone     chan struct{}
+	lastMessageID      int64
+	draining           bool
+	chanDrained        chan struct{}
+	disconnectError    error
+	closed             bool
+	server             string
+	reconnectLock      sync.Mutex
+	rebinding          bool
+	lastBind           func(ctx context.Context) error
+	lastBindLock       sync.Mutex
+	fastBind           bool
+	tlsStarted         bool
+	limiter            requestLimiter
+	connectedAt        time.Time
+	lastUsed           time.Time
+	expiring           bool
+	inUse              int
+	closeError         error
+	state              ConnectionState
+	stateChanges       []ConnectionState
+	notifyingState     bool
+	stateLock          sync.Mutex
+
+	readerPauseLock  sync.Mutex
+	readerPauseID    int64
+	chanReaderResume chan struct{}
+
+	turnHandler TurnHandler
 }
 
 // NewConnection creates a new Connection object. The address is in the same format as
@@ -39,6 +178,24 @@ func NewConnection(address string) *Connection {
 	return &Connection{Addr: address}
 }
 
+// NewUnixConnection creates a new Connection to the unix domain socket at path,
+// like the ldapi socket of a local server.
+func NewUnixConnection(path string) *Connection {
+	return &Connection{Network: "unix", Addr: path}
+}
+
+// NewConn returns a Connection over the already established conn, e.g. a
+// tunneled or proxied connection or one end of a net.Pipe in tests. Start runs
+// the protocol over it, a *tls.Conn is marked as SSL.
+func NewConn(conn net.Conn) *Connection {
+	l := &Connection{conn: conn}
+	if addr := conn.RemoteAddr(); addr != nil {
+		l.Addr = addr.String()
+	}
+	_, l.IsSSL = conn.(*tls.Conn)
+	return l
+}
+
 // Behaves like NewConnection, except that an additional parameter tlsConfig is expected.
 // The resulting connection uses TLS.
 func NewTLSConnection(address string, tlsConfig *tls.Config) *Connection {
@@ -62,27 +219,68 @@ func NewSSLConnection(address string, tlsConfig *tls.Config) *Connection {
 // Connect connects using information in Connection.
 // Connection should be populated with connection information.
 func (l *Connection) Connect() error {
-	l.chanResults = map[int64]chan *ber.Packet{}
-	l.chanProcessMessage = make(chan *messagePacket)
-	l.chanMessageID = make(chan int64)
+	return l.ConnectContext(context.Background())
+}
 
+// ConnectContext is Connect dialing with ctx, the dial fails once ctx is
+// done.
+func (l *Connection) ConnectContext(ctx context.Context) error {
+	if l.Servers != nil && l.conn == nil {
+		if err := l.connectServers(ctx); err != nil {
+			return err
+		}
+		l.Servers.MarkSucceeded(l.server)
+		return nil
+	}
+	return l.connect(ctx)
+}
+
+func (l *Connection) connect(ctx context.Context) error {
+	l.setClosed(false)
+	l.setState(StateConnecting)
 	if l.conn == nil {
 		var c net.Conn
 		var err error
+		network := l.Network
+		if network == "" {
+			network = "tcp"
+		}
 		if l.NetworkConnectTimeout > 0 {
-			c, err = net.DialTimeout("tcp", l.Addr, l.NetworkConnectTimeout)
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, l.NetworkConnectTimeout)
+			defer cancel()
+		}
+		if l.DialContext != nil {
+			if c, err = l.DialContext(ctx, network, l.Addr); err == nil {
+				l.setKeepAlive(c)
+			}
 		} else {
-			c, err = net.Dial("tcp", l.Addr)
+			dialer := net.Dialer{KeepAlive: l.KeepAlive, FallbackDelay: l.FallbackDelay}
+			if dialer.FallbackDelay == 0 {
+				dialer.FallbackDelay = DefaultFallbackDelay
+			}
+			c, err = dialer.DialContext(ctx, network, l.Addr)
 		}
 
 		if err != nil {
+			l.setState(StateClosed)
 			return err
 		}
 
+		l.tlsStarted = false
 		if l.IsSSL {
-			tlsConn := tls.Client(c, l.TlsConfig)
+			config, err := l.connectionTLSConfig()
+			if err != nil {
+				c.Close()
+				l.setState(StateClosed)
+				return err
+			}
+			l.setState(StateTLS)
+			tlsConn := tls.Client(c, l.clientTLSConfig(config))
 			err = tlsConn.Handshake()
 			if err != nil {
+				c.Close()
+				l.setState(StateClosed)
 				return err
 			}
 			l.conn = tlsConn
@@ -90,78 +288,339 @@ func (l *Connection) Connect() error {
 			l.conn = c
 		}
 	}
+	// reset after dialing, so a failed reconnect leaves the closed channels
+	// of the broken connection
+	l.closeLock.Lock()
+	l.lockResponses.Lock()
+	l.responses = map[int64]*responseQueue{}
+	l.abandonedMessages = map[int64]bool{}
+	l.chanProcessMessage = make(chan *messagePacket)
+	l.chanMessageID = make(chan int64)
+	l.chanDone = make(chan struct{})
+	l.chanStopped = make(chan struct{})
+	l.chanReaderDone = make(chan struct{})
+	l.draining = false
+	l.chanDrained = nil
+	l.disconnectError = nil
+	l.closeError = nil
+	l.connectedAt = time.Now()
+	l.lastUsed = l.connectedAt
+	l.expiring = false
+	l.lockResponses.Unlock()
+	if l.Servers != nil && l.server != "" {
+		l.Servers.register(l.server, l)
+	}
 	l.start()
 	l.connected = true
-	if l.IsTLS {
-		err := l.startTLS()
+	l.closeLock.Unlock()
+	if l.IsTLS && !l.IsSSL {
+		err := l.StartTLS(nil)
 		if err != nil {
+			// don't leave the connection running in plaintext
+			l.stop()
 			return err
 		}
 	}
+	if l.AutoExternalBind && l.tlsActive() {
+		if _, err := l.externalBind(context.Background(), ""); err != nil {
+			l.Close()
+			return err
+		}
+	}
+	if l.HeartbeatInterval > 0 {
+		go l.heartbeat(l.chanDone)
+	}
+	if l.MaxIdleTime > 0 || l.MaxLifetime > 0 {
+		go l.reap(l.chanDone)
+	}
+	l.setState(StateReady)
 	return nil
 }
 
+// Start runs the protocol over the connection passed to NewConn, it is Connect
+// without dialing.
+func (l *Connection) Start() error {
+	if l.conn == nil {
+		return newError(ErrorNetwork, "No connection to start, use Connect.")
+	}
+	return l.Connect()
+}
+
 func (l *Connection) start() {
-	go l.reader()
-	go l.processMessages()
+	go l.reader(l.chanDone, l.chanReaderDone)
+	go l.processMessages(l.chanDone, l.chanStopped, l.chanReaderDone)
 }
 
-// Close closes the connection.
+// Close closes the connection right away, operations still waiting for a
+// response fail with ErrorClosing. Use Unbind to end the session gracefully.
 func (l *Connection) Close() error {
 	if l.Debug {
 		log.Println("Starting Close()")
 	}
-	l.sendProcessMessage(&messagePacket{Op: MessageQuit})
+	l.setClosed(true)
+	if l.State() != StateClosed {
+		l.setState(StateClosing)
+	}
+	l.disconnect()
 	return nil
 }
 
+// disconnect closes the connection without Close, so a connection to Servers
+// fails over.
+func (l *Connection) disconnect() {
+	l.sendProcessMessage(&messagePacket{Op: MessageQuit})
+}
+
+// disconnectSession disconnects if done is still the chanDone of the
+// connection, so goroutines of a connection that already broke don't close
+// the reconnected one.
+func (l *Connection) disconnectSession(done <-chan struct{}) {
+	l.closeLock.RLock()
+	current := l.chanDone == done
+	l.closeLock.RUnlock()
+	if current {
+		l.disconnect()
+	}
+}
+
+// stop disconnects and waits until the goroutines of the connection stopped.
+func (l *Connection) stop() {
+	l.closeLock.RLock()
+	stopped := l.chanStopped
+	l.closeLock.RUnlock()
+	l.disconnect()
+	<-stopped
+}
+
+func (l *Connection) setClosed(closed bool) {
+	l.closeLock.Lock()
+	defer l.closeLock.Unlock()
+	l.closed = closed
+}
+
+// MaxMessageID is the largest messageID, MessageID ::= INTEGER (0 .. maxInt)
+const MaxMessageID = 1<<31 - 1
+
+// followingMessageID returns the messageID to use after id, wrapping around
+// after MaxMessageID to 1. 0 is reserved for unsolicited notifications and
+// the IDs of operations still outstanding are skipped.
+func (l *Connection) followingMessageID(id int64) int64 {
+	l.lockResponses.RLock()
+	defer l.lockResponses.RUnlock()
+	for {
+		if id >= MaxMessageID {
+			id = 1
+		} else {
+			id++
+		}
+		if _, outstanding := l.responses[id]; !outstanding {
+			return id
+		}
+	}
+}
+
+// messageIDError is the error of an operation for which nextMessageID
+// failed, the cause if the connection broke.
+func (l *Connection) messageIDError() error {
+	l.lockResponses.RLock()
+	defer l.lockResponses.RUnlock()
+	if l.disconnectError != nil {
+		return l.disconnectError
+	}
+	if l.closeError != nil {
+		return l.closeError
+	}
+	return newError(ErrorClosing, "MessageID channel is closed.")
+}
+
 // Returns the next available messageID
 func (l *Connection) nextMessageID() (messageID int64, ok bool) {
-	messageID, ok = <-l.chanMessageID
+	l.refreshExpired()
+	l.reconnect()
+	l.closeLock.RLock()
+	chanMessageID := l.chanMessageID
+	l.closeLock.RUnlock()
+	messageID, ok = <-chanMessageID
 	if l.Debug {
 		log.Printf("MessageID: %d, ok: %v\n", messageID, ok)
 	}
 	return
 }
 
-// StartTLS sends the command to start a TLS session and then creates a new TLS Client
-func (l *Connection) startTLS() error {
-	messageID, ok := l.nextMessageID()
-	if !ok {
-		return newError(ErrorClosing, "MessageID channel is closed.")
+const ExtendedOperationStartTLS = "1.3.6.1.4.1.1466.20037"
+
+// StartTLS sends the StartTLS extended request and, once the server agreed,
+// upgrades the connection to TLS using config, the one of the Connection if
+// nil. The message reader is paused after the StartTLS response until the
+// handshake is done, so nothing else is read from the connection in the
+// meantime. With RequireTLS the connection is closed if StartTLS fails.
+func (l *Connection) StartTLS(config *tls.Config) error {
+	err := l.startTLS(config)
+	if err != nil && l.RequireTLS && !l.tlsActive() {
+		l.Close()
 	}
+	return err
+}
 
-	if l.IsSSL {
+func (l *Connection) startTLS(config *tls.Config) error {
+	if l.tlsActive() {
 		return newError(ErrorNetwork, "Already encrypted")
 	}
+	if config == nil {
+		var err error
+		if config, err = l.connectionTLSConfig(); err != nil {
+			return err
+		}
+	}
 
-	tlsRequest := encodeTLSRequest()
-	packet, err := requestBuildPacket(messageID, tlsRequest, nil)
+	messageID, release, err := l.nextRequestID(context.Background())
+	if err != nil {
+		return err
+	}
+	defer release()
 
+	packet, err := requestBuildPacket(messageID, encodeExtendedRequest(NewExtendedRequest(ExtendedOperationStartTLS, nil)), nil)
 	if err != nil {
 		return err
 	}
 
-	err = l.sendReqRespPacket(messageID, packet)
+	l.pauseReader(messageID)
+	defer l.resumeReader()
+
+	responsePacket, err := l.sendReqResp(context.Background(), messageID, packet)
 	if err != nil {
 		return err
 	}
+	response, err := decodeExtendedResponse(responsePacket)
+	if err != nil {
+		return err
+	}
+	if err := response.err(); err != nil {
+		return err
+	}
 
-	conn := tls.Client(l.conn, l.TlsConfig)
+	l.setState(StateTLS)
+	conn := tls.Client(l.currentConn(), l.clientTLSConfig(config))
 	err = conn.Handshake()
 	if err != nil {
+		l.Close()
 		return err
 	}
-	l.IsSSL = true
+	l.connLock.Lock()
+	l.tlsStarted = true
 	l.conn = conn
+	l.connLock.Unlock()
+	l.setState(StateReady)
 
 	return nil
 }
 
-func encodeTLSRequest() (tlsRequest *ber.Packet) {
-	tlsRequest = ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(ApplicationExtendedRequest), nil, "Start TLS")
-	tlsRequest.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, "1.3.6.1.4.1.1466.20037", "TLS Extended Command"))
-	return
+// setKeepAlive applies KeepAlive to c if it is set and c a TCP connection.
+func (l *Connection) setKeepAlive(c net.Conn) {
+	tcp, ok := c.(*net.TCPConn)
+	if !ok || l.KeepAlive == 0 {
+		return
+	}
+	if l.KeepAlive < 0 {
+		tcp.SetKeepAlive(false)
+		return
+	}
+	tcp.SetKeepAlive(true)
+	tcp.SetKeepAlivePeriod(l.KeepAlive)
+}
+
+// tlsActive returns whether the connection runs over TLS, dialed with it or
+// upgraded with StartTLS.
+func (l *Connection) tlsActive() bool {
+	l.connLock.RLock()
+	defer l.connLock.RUnlock()
+	return l.IsSSL || l.tlsStarted
+}
+
+// currentConn returns the connection, which StartTLS and SASL security layers
+// replace while the reader is paused. The writer holds connLock while it
+// writes, so a swap waits for the write to finish.
+func (l *Connection) currentConn() net.Conn {
+	l.connLock.RLock()
+	defer l.connLock.RUnlock()
+	return l.conn
+}
+
+// credentialsAllowed returns the error for sending a password over a
+// connection without TLS with RequireTLS.
+func (l *Connection) credentialsAllowed() error {
+	if l.RequireTLS && !l.tlsActive() && l.Network != "unix" {
+		return newError(ResultConfidentialityRequired, "Refusing to send credentials without TLS, see RequireTLS")
+	}
+	return nil
+}
+
+// connectionTLSConfig returns the configuration of TLS from the
+// TLSConfigProvider or TlsConfig.
+func (l *Connection) connectionTLSConfig() (*tls.Config, error) {
+	if l.TLSConfigProvider == nil {
+		return l.TlsConfig, nil
+	}
+	return l.TLSConfigProvider()
+}
+
+// clientTLSConfig returns config with the TLSSessionCache and the ServerName
+// defaulting to the host of Addr, so the certificate of the server is
+// verified against it.
+func (l *Connection) clientTLSConfig(config *tls.Config) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	}
+	cache := l.TLSSessionCache
+	if cache == nil && l.Servers != nil {
+		cache = l.Servers.TLSSessionCache
+	}
+	if config.ServerName != "" && (config.ClientSessionCache != nil || cache == nil) {
+		return config
+	}
+	config = config.Clone()
+	if config.ClientSessionCache == nil {
+		config.ClientSessionCache = cache
+	}
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(l.Addr)
+		if err != nil {
+			host = l.Addr
+		}
+		// certificates name the IP of a link-local address without its zone
+		config.ServerName = stripZone(host)
+	}
+	return config
+}
+
+// pauseReader makes the reader wait for resumeReader after it passed on the
+// response to messageID.
+func (l *Connection) pauseReader(messageID int64) {
+	l.readerPauseLock.Lock()
+	defer l.readerPauseLock.Unlock()
+	l.readerPauseID = messageID
+	l.chanReaderResume = make(chan struct{})
+}
+
+func (l *Connection) resumeReader() {
+	l.readerPauseLock.Lock()
+	defer l.readerPauseLock.Unlock()
+	if l.chanReaderResume != nil {
+		close(l.chanReaderResume)
+		l.chanReaderResume = nil
+	}
+	l.readerPauseID = 0
+}
+
+// readerPaused returns the channel the reader waits on after messageID, nil
+// if the reader keeps on reading.
+func (l *Connection) readerPaused(messageID int64) chan struct{} {
+	l.readerPauseLock.Lock()
+	defer l.readerPauseLock.Unlock()
+	if l.chanReaderResume != nil && l.readerPauseID == messageID {
+		return l.chanReaderResume
+	}
+	return nil
 }
 
 const (
@@ -169,43 +628,58 @@ const (
 	MessageRequest  = 1
 	MessageResponse = 2
 	MessageFinish   = 3
+	MessageAbandon  = 4
+	MessageUnbind   = 5
 )
 
 type messagePacket struct {
 	Op        int
 	MessageID int64
 	Packet    *ber.Packet
-	Channel   chan *ber.Packet
 }
 
-func (l *Connection) getNewResultChannel(message_id int64) (out chan *ber.Packet, err error) {
-	// as soon as a channel is requested add to chanResults to never miss
+func (l *Connection) registerMessage(message_id int64) (out *responseQueue, err error) {
+	// as soon as a queue is requested add to responses to never miss
 	// on cleanup.
-	l.lockChanResults.Lock()
-	defer l.lockChanResults.Unlock()
+	l.lockResponses.Lock()
+	defer l.lockResponses.Unlock()
 
-	if l.chanResults == nil {
+	if l.responses == nil {
+		if l.disconnectError != nil {
+			return nil, l.disconnectError
+		}
+		if l.closeError != nil {
+			return nil, l.closeError
+		}
 		return nil, newError(ErrorClosing, "Connection closing/closed")
 	}
 
-	if _, ok := l.chanResults[message_id]; ok {
-		errStr := fmt.Sprintf("chanResults already allocated, message_id: %d", message_id)
+	if l.draining {
+		return nil, newError(ErrorClosing, "Connection unbinding")
+	}
+
+	if _, ok := l.responses[message_id]; ok {
+		errStr := fmt.Sprintf("responses already allocated, message_id: %d", message_id)
 		return nil, newError(ErrorUnknown, errStr)
 	}
 
-	out = make(chan *ber.Packet, ResultChanBufferSize)
-	l.chanResults[message_id] = out
+	limit := l.MaxQueuedResponses
+	if limit == 0 {
+		limit = DefaultMaxQueuedResponses
+	}
+	out = newResponseQueue(limit)
+	l.responses[message_id] = out
 	return
 }
 
-func (l *Connection) sendMessage(p *ber.Packet) (out chan *ber.Packet, err error) {
+func (l *Connection) sendMessage(p *ber.Packet) (out *responseQueue, err error) {
 	message_id, ok := p.Children[0].Value.(int64)
 	if !ok {
 		return nil, errors.New(fmt.Sprintf("type assertion int64 for %v failed!", p.Children[0].Value))
 	}
 	// sendProcessMessage may not process a message on shutdown
-	// getNewResultChannel adds id/chan to chan results
-	out, err = l.getNewResultChannel(message_id)
+	// registerMessage adds the queue of the id to responses
+	out, err = l.registerMessage(message_id)
 	if err != nil {
 		return
 	}
@@ -213,30 +687,49 @@ func (l *Connection) sendMessage(p *ber.Packet) (out chan *ber.Packet, err error
 		log.Printf("sendMessage-> message_id: %d, out: %v\n", message_id, out)
 	}
 
-	message_packet := &messagePacket{Op: MessageRequest, MessageID: message_id, Packet: p, Channel: out}
+	message_packet := &messagePacket{Op: MessageRequest, MessageID: message_id, Packet: p}
 	l.sendProcessMessage(message_packet)
 	return
 }
 
-func (l *Connection) processMessages() {
+func (l *Connection) processMessages(done, stopped, readerDone chan struct{}) {
+	defer close(stopped)
+	defer l.closedState()
 	defer l.closeAllChannels()
 	defer func() {
 		// Close all channels, connection and quit.
 		// Use closeLock to stop MessageRequests
 		// and l.connected to stop any future MessageRequests.
+		// Closing chanDone first releases the senders blocked on
+		// chanProcessMessage, which hold closeLock.RLock.
+		if l.Servers != nil && l.server != "" {
+			l.Servers.unregister(l.server, l)
+		}
+		// stop sending messages of this connection
+		close(done)
 		l.closeLock.Lock()
-		defer l.closeLock.Unlock()
 		l.connected = false
 		// will shutdown reader.
-		l.conn.Close()
+		l.currentConn().Close()
+		l.closeLock.Unlock()
+		// release the reader waiting for room in a full queue
+		l.lockResponses.RLock()
+		for _, queue := range l.responses {
+			queue.close()
+		}
+		l.lockResponses.RUnlock()
+		<-readerDone
 	}()
-	var message_id int64 = 1
+	// messageIDs go on after a reconnect, so operations of the broken
+	// connection finishing late don't touch the ones of the new connection
+	message_id := l.followingMessageID(l.lastMessageID)
 	var message_packet *messagePacket
 
 	for {
 		select {
 		case l.chanMessageID <- message_id:
-			message_id++
+			l.lastMessageID = message_id
+			message_id = l.followingMessageID(message_id)
 		case message_packet = <-l.chanProcessMessage:
 			switch message_packet.Op {
 			case MessageQuit:
@@ -249,47 +742,93 @@ func (l *Connection) processMessages() {
 				if l.Debug {
 					fmt.Printf("Sending message %d\n", message_packet.MessageID)
 				}
-				buf := message_packet.Packet.Bytes()
-				for len(buf) > 0 {
-					n, err := l.conn.Write(buf)
-					if err != nil {
-						if l.Debug {
-							fmt.Printf("Error Sending Message: %s\n", err)
-						}
-						return
-					}
-					if n == len(buf) {
-						break
-					}
-					buf = buf[n:]
+				if err := l.writePacket(message_packet.Packet); err != nil {
+					l.setCloseError("Sending the request failed: ", err)
+					return
+				}
+			case MessageUnbind:
+				// Write the unbind request and quit, the server closes
+				// the connection without a response.
+				if l.Debug {
+					fmt.Printf("Sending unbind %d\n", message_packet.MessageID)
 				}
+				l.writePacket(message_packet.Packet)
+				return
 			case MessageFinish:
 				// Remove from message list
 				if l.Debug {
 					fmt.Printf("Finished message %d\n", message_packet.MessageID)
 				}
-				l.lockChanResults.Lock()
-				delete(l.chanResults, message_packet.MessageID)
-				l.lockChanResults.Unlock()
+				l.lockResponses.Lock()
+				if queue, ok := l.responses[message_packet.MessageID]; ok {
+					// release the reader waiting for room in the queue
+					queue.close()
+				}
+				delete(l.responses, message_packet.MessageID)
+				delete(l.abandonedMessages, message_packet.MessageID)
+				l.checkDrained()
+				l.lockResponses.Unlock()
+			case MessageAbandon:
+				// Stop routing responses of the abandoned message and
+				// release whoever is waiting on them.
+				if l.Debug {
+					fmt.Printf("Abandoned message %d\n", message_packet.MessageID)
+				}
+				l.lockResponses.Lock()
+				if queue, ok := l.responses[message_packet.MessageID]; ok {
+					delete(l.responses, message_packet.MessageID)
+					l.abandonedMessages[message_packet.MessageID] = true
+					queue.close()
+				}
+				l.checkDrained()
+				l.lockResponses.Unlock()
 			}
 		}
 	}
 }
 
+func (l *Connection) writePacket(p *ber.Packet) error {
+	l.connLock.RLock()
+	defer l.connLock.RUnlock()
+	if l.RequestTimeout > 0 {
+		l.conn.SetWriteDeadline(time.Now().Add(l.RequestTimeout))
+	}
+	buf := p.Bytes()
+	for len(buf) > 0 {
+		n, err := l.conn.Write(buf)
+		if err != nil {
+			if l.Debug {
+				fmt.Printf("Error Sending Message: %s\n", err)
+			}
+			return err
+		}
+		buf = buf[n:]
+	}
+	return nil
+}
+
+// checkDrained closes chanDrained once no operation is outstanding during
+// Unbind. lockResponses must be held.
+func (l *Connection) checkDrained() {
+	if l.draining && l.chanDrained != nil && len(l.responses) == 0 {
+		close(l.chanDrained)
+		l.chanDrained = nil
+	}
+}
+
 func (l *Connection) closeAllChannels() {
-	l.lockChanResults.Lock()
-	defer l.lockChanResults.Unlock()
-	for MessageID, Channel := range l.chanResults {
+	l.lockResponses.Lock()
+	defer l.lockResponses.Unlock()
+	for MessageID, queue := range l.responses {
 		if l.Debug {
-			fmt.Printf("Closing channel for MessageID %d\n", MessageID)
+			fmt.Printf("Closing responses for MessageID %d\n", MessageID)
 		}
-		close(Channel)
-		delete(l.chanResults, MessageID)
+		queue.close()
+		delete(l.responses, MessageID)
 	}
-	l.chanResults = nil
+	l.responses = nil
 
 	close(l.chanMessageID)
-	l.chanMessageID = nil
 
 	close(l.chanProcessMessage)
 	l.chanProcessMessage = nil
@@ -300,56 +839,95 @@ func (l *Connection) finishMessage(MessageID int64) {
 	l.sendProcessMessage(message_packet)
 }
 
-func (l *Connection) reader() {
-	defer l.Close()
+// abandonMessage closes the response queue of MessageID, responses still
+// arriving for it are dropped by the reader.
+func (l *Connection) abandonMessage(MessageID int64) {
+	message_packet := &messagePacket{Op: MessageAbandon, MessageID: MessageID}
+	l.sendProcessMessage(message_packet)
+}
+
+// closedQueueError is returned to operations whose response queue was closed
+func (l *Connection) closedQueueError(MessageID int64) error {
+	l.lockResponses.RLock()
+	abandoned := l.abandonedMessages[MessageID]
+	disconnectError := l.disconnectError
+	closeError := l.closeError
+	l.lockResponses.RUnlock()
+	if abandoned {
+		return newError(ErrorAbandoned, fmt.Sprintf("Message %d was abandoned", MessageID))
+	}
+	if disconnectError != nil {
+		return disconnectError
+	}
+	if closeError != nil {
+		return closeError
+	}
+	return newError(ErrorClosing, "Response Channel Closed")
+}
+
+func (l *Connection) reader(done, readerDone chan struct{}) {
+	defer close(readerDone)
+	defer l.disconnectSession(done)
 	for {
-		p, err := ber.ReadPacket(l.conn)
+		p, err := ber.ReadPacket(l.currentConn())
 		if err != nil {
 			if l.Debug {
 				fmt.Printf("ldap.reader: %s\n", err)
 			}
+			l.setCloseError("Reading the response failed: ", err)
 			return
 		}
 
 		addLDAPDescriptions(p)
 
+		if len(p.Children) < 2 {
+			l.setCloseError("Invalid response: ", newError(ErrorDecoding, "Response without a messageID and protocolOp"))
+			return
+		}
 		message_id, ok := p.Children[0].Value.(int64)
 		if !ok {
 			// type assertion failed.. maybe we better stop
+			l.setCloseError("Invalid response: ", newError(ErrorDecoding, "Response without a messageID"))
 			return
 		}
 
+		if message_id == 0 {
+			l.handleUnsolicitedNotification(p)
+			continue
+		}
+
+		if handler := l.getTurnHandler(); handler != nil && isRequest(ApplicationCode(p.Children[1].Tag)) {
+			go l.handleTurnedRequest(handler, message_id, p)
+			continue
+		}
+
 		message_packet := &messagePacket{Op: MessageResponse, MessageID: message_id, Packet: p}
 
-		l.readerToChanResults(message_packet)
+		l.routeResponse(message_packet)
+
+		if resume := l.readerPaused(message_id); resume != nil {
+			<-resume
+		}
 	}
 }
 
-func (l *Connection) readerToChanResults(message_packet *messagePacket) {
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Fprintln(os.Stderr, "Recovered in readerToChanResults", r)
-		}
-	}()
+// routeResponse adds the response to the queue of its message, dropping
+// responses to messages no longer outstanding.
+func (l *Connection) routeResponse(message_packet *messagePacket) {
 	if l.Debug {
 		fmt.Printf("Receiving message %d\n", message_packet.MessageID)
 	}
 
-	// very small chance on disconnect to write to a closed channel as
-	// lockChanResults is unlocked immediately hence defer above.
-	// Don't lock while sending to chanResult below as that can block and hold
-	// the lock.
-	l.lockChanResults.RLock()
-	chanResult, ok := l.chanResults[message_packet.MessageID]
-	l.lockChanResults.RUnlock()
+	l.lockResponses.RLock()
+	queue, ok := l.responses[message_packet.MessageID]
+	l.lockResponses.RUnlock()
 
 	if !ok {
 		if l.Debug {
-			fmt.Printf("Message Result chan not found (possible Abandon), MessageID: %d\n", message_packet.MessageID)
+			fmt.Printf("Message Result queue not found (possible Abandon), MessageID: %d\n", message_packet.MessageID)
 		}
 	} else {
-		// chanResult is a buffered channel of ResultChanBufferSize
-		chanResult <- message_packet.Packet
+		queue.push(message_packet.Packet)
 	}
 }
 
@@ -360,7 +938,10 @@ func (l *Connection) sendProcessMessage(message *messagePacket) {
 		l.closeLock.RLock()
 		defer l.closeLock.RUnlock()
 		if l.connected {
-			l.chanProcessMessage <- message
+			select {
+			case l.chanProcessMessage <- message:
+			case <-l.chanDone:
+			}
 		}
 	}()
 }
@@ -0,0 +1,652 @@
+package ldap
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"github.com/eaciit/asn1-ber"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockServer reads requests from the server side of a net.Pipe and passes them
+// to handle, which writes the responses it wants to send back.
+type mockServer struct {
+	conn     net.Conn
+	requests chan *ber.Packet
+}
+
+func newMockConnection(t *testing.T, handle func(s *mockServer, request *ber.Packet)) (*Connection, *mockServer) {
+	client, server := net.Pipe()
+	s := &mockServer{conn: server, requests: make(chan *ber.Packet, 16)}
+	go s.serve(handle)
+
+	l := NewConn(client)
+	if err := l.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return l, s
+}
+
+// serve reads the requests of the connection and passes them to handle.
+func (s *mockServer) serve(handle func(s *mockServer, request *ber.Packet)) {
+	for {
+		p, err := ber.ReadPacket(s.conn)
+		if err != nil {
+			return
+		}
+		select {
+		case s.requests <- p:
+		default:
+		}
+		if handle != nil {
+			handle(s, p)
+		}
+	}
+}
+
+func (s *mockServer) respond(messageID int64, response *ber.Packet, controls ...*ber.Packet) {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	p.AppendChild(response)
+	if len(controls) > 0 {
+		c := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
+		for _, control := range controls {
+			c.AppendChild(control)
+		}
+		p.AppendChild(c)
+	}
+	s.conn.Write(p.Bytes())
+}
+
+func (s *mockServer) respondResult(messageID int64, application ApplicationCode, resultCode ResultCode, diagnosticMessage string) {
+	s.respond(messageID, mockLDAPResult(application, resultCode, diagnosticMessage))
+}
+
+func mockLDAPResult(application ApplicationCode, resultCode ResultCode, diagnosticMessage string) *ber.Packet {
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(application), nil, application.String())
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, uint64(resultCode), "resultCode"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, diagnosticMessage, "diagnosticMessage"))
+	return response
+}
+
+func mockMessageID(p *ber.Packet) int64 {
+	messageID, _ := packetInt64(p.Children[0])
+	return messageID
+}
+
+func TestAbandonReleasesOperation(t *testing.T) {
+	// the server never answers the modify request
+	l, s := newMockConnection(t, nil)
+	defer l.Close()
+
+	done := make(chan error)
+	go func() {
+		_, err := l.Modify(NewModifyRequest("cn=bob,o=bigcorp"))
+		done <- err
+	}()
+
+	modify := <-s.requests
+	if err := l.Abandon(mockMessageID(modify)); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-done:
+		lerr, ok := err.(*Error)
+		if !ok || lerr.ResultCode != ErrorAbandoned {
+			t.Errorf("Expected ErrorAbandoned, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Modify was not released by Abandon")
+	}
+
+	abandon := <-s.requests
+	if abandon.Children[1].Tag != ber.Tag(ApplicationAbandonRequest) {
+		t.Errorf("Expected an abandon request, got tag %d", abandon.Children[1].Tag)
+	}
+}
+
+func TestModifyContextCanceled(t *testing.T) {
+	// the server never answers the modify request
+	l, s := newMockConnection(t, nil)
+	defer l.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := l.ModifyContext(ctx, NewModifyRequest("cn=bob,o=bigcorp"))
+		done <- err
+	}()
+
+	modify := <-s.requests
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Modify was not released by the canceled context")
+	}
+
+	abandon := <-s.requests
+	if abandon.Children[1].Tag != ber.Tag(ApplicationAbandonRequest) {
+		t.Fatalf("Expected an abandon request, got tag %d", abandon.Children[1].Tag)
+	}
+	if abandonID, _ := packetInt64(abandon.Children[1]); abandonID != mockMessageID(modify) {
+		t.Errorf("Abandoned message %d instead of %d", abandonID, mockMessageID(modify))
+	}
+
+	if _, err := l.ModifyContext(ctx, NewModifyRequest("cn=bob,o=bigcorp")); err != context.Canceled {
+		t.Errorf("Expected context.Canceled for a canceled context, got %v", err)
+	}
+}
+
+func TestModifyConnectionBroken(t *testing.T) {
+	// the server drops the connection instead of answering
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		s.conn.Close()
+	})
+	defer l.Close()
+
+	done := make(chan error)
+	go func() {
+		_, err := l.Modify(NewModifyRequest("cn=bob,o=bigcorp"))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ErrorNetwork || !errors.Is(err, io.EOF) {
+			t.Errorf("Expected ErrorNetwork caused by io.EOF, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Modify was not released by the broken connection")
+	}
+	if _, err := l.Modify(NewModifyRequest("cn=bob,o=bigcorp")); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected the cause for the next operation, got %v", err)
+	}
+}
+
+func TestShortResponse(t *testing.T) {
+	// the server answers with nothing but the messageID
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+		p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, mockMessageID(request), "MessageID"))
+		s.conn.Write(p.Bytes())
+	})
+	defer l.Close()
+
+	_, err := l.Modify(NewModifyRequest("cn=bob,o=bigcorp"))
+	if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ErrorNetwork || !strings.Contains(err.Error(), "Invalid response") {
+		t.Errorf("Expected the invalid response to close the connection, got %v", err)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	// the server never answers the modify request
+	l, s := newMockConnection(t, nil)
+	defer l.Close()
+	l.RequestTimeout = 50 * time.Millisecond
+
+	_, err := l.Modify(NewModifyRequest("cn=bob,o=bigcorp"))
+	if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ErrorTimeout || !lerr.Timeout() {
+		t.Fatalf("Expected ErrorTimeout, got %v", err)
+	}
+
+	modify := <-s.requests
+	abandon := <-s.requests
+	if abandonID, _ := packetInt64(abandon.Children[1]); abandon.Children[1].Tag != ber.Tag(ApplicationAbandonRequest) || abandonID != mockMessageID(modify) {
+		t.Errorf("Expected the modify to be abandoned, got %v", abandon.Children[1])
+	}
+}
+
+func TestNewConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	l := NewConn(tls.Client(client, &tls.Config{InsecureSkipVerify: true}))
+	if !l.IsSSL || l.Addr != "pipe" {
+		t.Errorf("Unexpected connection over a *tls.Conn: SSL %t, address %s", l.IsSSL, l.Addr)
+	}
+	if err := new(Connection).Start(); err == nil {
+		t.Error("Expected an error starting without a connection")
+	}
+}
+
+func TestConnectionDialContext(t *testing.T) {
+	var network, address string
+	var deadline bool
+	l := NewConnection("ldap.example.com:389")
+	l.NetworkConnectTimeout = time.Minute
+	l.DialContext = func(ctx context.Context, n, a string) (net.Conn, error) {
+		network, address = n, a
+		_, deadline = ctx.Deadline()
+		client, server := net.Pipe()
+		go func() {
+			s := &mockServer{conn: server}
+			for {
+				request, err := ber.ReadPacket(server)
+				if err != nil {
+					return
+				}
+				s.respondResult(mockMessageID(request), ApplicationDelResponse, ResultSuccess, "")
+			}
+		}()
+		return client, nil
+	}
+	if err := l.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if network != "tcp" || address != "ldap.example.com:389" || !deadline {
+		t.Errorf("Unexpected dial of %s %s, deadline %t", network, address, deadline)
+	}
+	if _, err := l.Delete(NewDeleteRequest("cn=bob,o=bigcorp")); err != nil {
+		t.Errorf("Delete over the dialed connection failed: %s", err)
+	}
+}
+
+// mockCertificate returns a self-signed certificate for ldap.example.com
+func mockCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ldap.example.com"},
+		DNSNames:     []string{"ldap.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestStartTLS(t *testing.T) {
+	serverConfig := &tls.Config{Certificates: []tls.Certificate{mockCertificate(t)}}
+	l, s := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		switch ApplicationCode(request.Children[1].Tag) {
+		case ApplicationExtendedRequest:
+			s.respond(mockMessageID(request), mockExtendedResponse(ResultSuccess, "", nil))
+			tlsConn := tls.Server(s.conn, serverConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			s.conn = tlsConn
+		case ApplicationDelRequest:
+			s.respondResult(mockMessageID(request), ApplicationDelResponse, ResultSuccess, "")
+		}
+	})
+	defer l.Close()
+
+	if err := l.StartTLS(&tls.Config{InsecureSkipVerify: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !l.tlsActive() || !l.encrypted() || l.IsSSL {
+		t.Errorf("Connection not marked as upgraded with StartTLS")
+	}
+	if _, err := l.Delete(NewDeleteRequest("cn=bob,o=bigcorp")); err != nil {
+		t.Errorf("Delete over TLS failed: %s", err)
+	}
+	if _, ok := s.conn.(*tls.Conn); !ok {
+		t.Errorf("Server side was not upgraded")
+	}
+}
+
+// mockStartTLS returns a handler of newMockConnection accepting StartTLS with
+// serverConfig.
+func mockStartTLS(serverConfig *tls.Config) func(s *mockServer, request *ber.Packet) {
+	return func(s *mockServer, request *ber.Packet) {
+		if ApplicationCode(request.Children[1].Tag) == ApplicationExtendedRequest {
+			s.respond(mockMessageID(request), mockExtendedResponse(ResultSuccess, "", nil))
+			tlsConn := tls.Server(s.conn, serverConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			s.conn = tlsConn
+		}
+	}
+}
+
+func TestStartTLSConnectionConfig(t *testing.T) {
+	certificate := mockCertificate(t)
+	l, _ := newMockConnection(t, mockStartTLS(&tls.Config{Certificates: []tls.Certificate{certificate}}))
+	defer l.Close()
+
+	roots := x509.NewCertPool()
+	parsed, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots.AddCert(parsed)
+	// the certificate is verified against the host of Addr
+	l.Addr = "ldap.example.com:389"
+	l.TlsConfig = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS13}
+	if err := l.StartTLS(nil); err != nil {
+		t.Fatal(err)
+	}
+	state := l.conn.(*tls.Conn).ConnectionState()
+	if state.Version != tls.VersionTLS13 || state.ServerName != "ldap.example.com" {
+		t.Errorf("Unexpected TLS version %x, server name %s", state.Version, state.ServerName)
+	}
+	if l.TlsConfig.ServerName != "" {
+		t.Error("TlsConfig was modified")
+	}
+}
+
+// mockDeclineStartTLS is a handler of newMockConnection declining StartTLS.
+func mockDeclineStartTLS(s *mockServer, request *ber.Packet) {
+	if ApplicationCode(request.Children[1].Tag) == ApplicationExtendedRequest {
+		s.respond(mockMessageID(request), mockExtendedResponse(ResultUnavailable, "", nil))
+	}
+}
+
+func TestRequireTLS(t *testing.T) {
+	l, s := newMockConnection(t, mockDeclineStartTLS)
+	defer l.Close()
+	l.RequireTLS = true
+
+	err := l.Bind("cn=admin,o=bigcorp", "secret")
+	if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ResultConfidentialityRequired {
+		t.Errorf("Expected ResultConfidentialityRequired for a bind without TLS, got %v", err)
+	}
+	if _, err := l.PasswordModify(NewPasswordModifyRequest("", "old", "new")); err == nil {
+		t.Error("Expected an error for a password modify without TLS")
+	}
+	if _, err := l.OAuthBearerBind("token"); err == nil {
+		t.Error("Expected an error for a bearer token without TLS")
+	}
+	if len(s.requests) != 0 {
+		t.Errorf("Expected nothing to be sent, got %v", <-s.requests)
+	}
+
+	if err := l.StartTLS(&tls.Config{InsecureSkipVerify: true}); err == nil {
+		t.Fatal("Expected the declined StartTLS to fail")
+	}
+	if _, err := l.Delete(NewDeleteRequest("cn=bob,o=bigcorp")); err == nil {
+		t.Error("Expected the connection to be closed after the declined StartTLS")
+	}
+}
+
+func TestConnectStartTLSDeclined(t *testing.T) {
+	listener, servers := mockListener(t, mockDeclineStartTLS)
+	defer listener.Close()
+	l := NewConnection(listener.Addr().String())
+	l.IsTLS = true
+	if err := l.Connect(); err == nil {
+		l.Close()
+		t.Fatal("Expected Connect to fail with the declined StartTLS")
+	}
+	if l.State() != StateClosed {
+		t.Errorf("Expected StateClosed, got %s", l.State())
+	}
+	if extended := <-(<-servers).requests; extended.Children[1].Tag != ber.Tag(ApplicationExtendedRequest) {
+		t.Errorf("Expected StartTLS, got %v", extended.Children[1])
+	}
+	// nothing can be sent in plaintext
+	if _, err := l.conn.Write([]byte{0}); err == nil {
+		t.Error("Expected the connection to be closed")
+	}
+}
+
+func TestUnbindDrainsOperations(t *testing.T) {
+	release := make(chan struct{})
+	l, s := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		if request.Children[1].Tag == ber.Tag(ApplicationModifyRequest) {
+			go func() {
+				<-release
+				s.respondResult(mockMessageID(request), ApplicationModifyResponse, ResultSuccess, "")
+			}()
+		}
+	})
+	l.DrainTimeout = 5 * time.Second
+
+	done := make(chan error)
+	go func() {
+		_, err := l.Modify(NewModifyRequest("cn=bob,o=bigcorp"))
+		done <- err
+	}()
+	<-s.requests
+
+	unbound := make(chan error)
+	go func() {
+		unbound <- l.Unbind()
+	}()
+	close(release)
+
+	if err := <-done; err != nil {
+		t.Errorf("Outstanding modify failed: %v", err)
+	}
+	select {
+	case err := <-unbound:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Unbind did not return")
+	}
+
+	unbind := <-s.requests
+	if unbind.Children[1].Tag != ber.Tag(ApplicationUnbindRequest) {
+		t.Errorf("Expected an unbind request, got tag %d", unbind.Children[1].Tag)
+	}
+	if _, err := l.Modify(NewModifyRequest("cn=bob,o=bigcorp")); err == nil {
+		t.Error("Expected an error on an unbound connection")
+	}
+}
+
+func TestUnbindAbandonsAfterDrainTimeout(t *testing.T) {
+	// the server never answers the modify request
+	l, s := newMockConnection(t, nil)
+	l.DrainTimeout = 10 * time.Millisecond
+
+	done := make(chan error)
+	go func() {
+		_, err := l.Modify(NewModifyRequest("cn=bob,o=bigcorp"))
+		done <- err
+	}()
+	<-s.requests
+
+	if err := l.Unbind(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		lerr, ok := err.(*Error)
+		if !ok || lerr.ResultCode != ErrorAbandoned {
+			t.Errorf("Expected ErrorAbandoned, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Modify was not released by Unbind")
+	}
+}
+
+func TestNoticeOfDisconnection(t *testing.T) {
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		notice := mockLDAPResult(ApplicationExtendedResponse, ResultUnavailable, "shutting down")
+		notice.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 10, ExtendedOperationNoticeOfDisconnection, "responseName"))
+		s.respond(0, notice)
+	})
+	notifications := make(chan *ExtendedResponse, 1)
+	l.NotificationHandler = func(notification *ExtendedResponse) {
+		notifications <- notification
+	}
+
+	_, err := l.Modify(NewModifyRequest("cn=bob,o=bigcorp"))
+	lerr, ok := err.(*Error)
+	if !ok || lerr.ResultCode != ResultUnavailable {
+		t.Fatalf("Expected the error of the notice, got %v", err)
+	}
+
+	select {
+	case notification := <-notifications:
+		if notification.Name != ExtendedOperationNoticeOfDisconnection || notification.DiagnosticMessage != "shutting down" {
+			t.Errorf("Unexpected notification %v", notification)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("NotificationHandler was not called")
+	}
+}
+
+func TestFollowingMessageID(t *testing.T) {
+	l := &Connection{responses: map[int64]*responseQueue{}}
+	if id := l.followingMessageID(41); id != 42 {
+		t.Errorf("Expected 42, got %d", id)
+	}
+	// wraps around without 0 and skips the outstanding operations
+	l.responses[1] = newResponseQueue(0)
+	l.responses[2] = newResponseQueue(0)
+	if id := l.followingMessageID(MaxMessageID - 1); id != MaxMessageID {
+		t.Errorf("Expected MaxMessageID, got %d", id)
+	}
+	if id := l.followingMessageID(MaxMessageID); id != 3 {
+		t.Errorf("Expected 3 after the wrap around, got %d", id)
+	}
+}
+
+func TestMessageIDsAfterManyOperations(t *testing.T) {
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		s.respondResult(mockMessageID(request), ApplicationDelResponse, ResultSuccess, "")
+	})
+	defer l.Close()
+	seen := map[int64]bool{}
+	for i := 0; i < 1000; i++ {
+		id, ok := l.nextMessageID()
+		if !ok || id <= 0 || id > MaxMessageID || seen[id] {
+			t.Fatalf("Unexpected messageID %d", id)
+		}
+		seen[id] = true
+	}
+	if _, err := l.Delete(NewDeleteRequest("cn=bob,o=bigcorp")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrentOperations(t *testing.T) {
+	// searches are answered in reverse order of arrival, each with an entry
+	// named after its base
+	var lock sync.Mutex
+	var pending []*ber.Packet
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		switch request.Children[1].Tag {
+		case ber.Tag(ApplicationSearchRequest):
+			lock.Lock()
+			pending = append(pending, request)
+			if len(pending) < 4 {
+				lock.Unlock()
+				return
+			}
+			answer := pending
+			pending = nil
+			lock.Unlock()
+			for i := len(answer) - 1; i >= 0; i-- {
+				messageID := mockMessageID(answer[i])
+				s.respond(messageID, mockSearchEntry(packetString(answer[i].Children[1].Children[0]), nil))
+				s.respondResult(messageID, ApplicationSearchResultDone, ResultSuccess, "")
+			}
+		case ber.Tag(ApplicationModifyRequest):
+			s.respondResult(mockMessageID(request), ApplicationModifyResponse, ResultSuccess, "")
+		}
+	})
+	defer l.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			base := fmt.Sprintf("cn=user%d,o=bigcorp", i)
+			result, err := l.Search(NewSimpleSearchRequest(base, ScopeBaseObject, "(objectClass=*)", nil))
+			if err != nil {
+				errs <- err
+			} else if len(result.Entries) != 1 || result.Entries[0].DN != base {
+				errs <- fmt.Errorf("search of %s got %v", base, result.Entries)
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			modify := NewModifyRequest(fmt.Sprintf("cn=user%d,o=bigcorp", i))
+			modify.AddMod(NewMod(ModReplace, "description", []string{"concurrent"}))
+			if _, err := l.Modify(modify); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestConcurrentClose(t *testing.T) {
+	l, _ := newMockConnection(t, nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// never answered, fails once the connection is closed
+			l.Delete(NewDeleteRequest("cn=bob,o=bigcorp"))
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	go l.Close()
+	l.Close()
+	wg.Wait()
+}
+
+func TestTLSSessionCache(t *testing.T) {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{mockCertificate(t)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go (&mockServer{conn: conn}).serve(func(s *mockServer, request *ber.Packet) {
+				s.respondResult(mockMessageID(request), ApplicationBindResponse, ResultSuccess, "")
+			})
+		}
+	}()
+
+	cache := tls.NewLRUClientSessionCache(0)
+	for i, resumed := range []bool{false, true} {
+		l := NewSSLConnection(listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		l.TLSSessionCache = cache
+		if err := l.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		// reads the session ticket sent after the handshake
+		if err := l.Bind("cn=admin,o=bigcorp", "secret"); err != nil {
+			t.Fatal(err)
+		}
+		if state := l.conn.(*tls.Conn).ConnectionState(); state.DidResume != resumed {
+			t.Errorf("Connection %d: expected DidResume %t", i, resumed)
+		}
+		l.Close()
+	}
+}
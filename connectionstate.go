@@ -0,0 +1,99 @@
+package ldap
+
+//go:generate stringer -type=ConnectionState
+
+// ConnectionState is the state of a Connection in its lifecycle, see
+// Connection.State and Connection.OnStateChange.
+type ConnectionState uint8
+
+const (
+	// not connected yet, or closed by Close, Unbind or the server
+	StateClosed ConnectionState = 0
+	// dialing or reconnecting
+	StateConnecting ConnectionState = 1
+	// in the TLS handshake of LDAPS or StartTLS
+	StateTLS ConnectionState = 2
+	// accepting operations
+	StateReady ConnectionState = 3
+	// shutting down after Close or Unbind
+	StateClosing ConnectionState = 4
+)
+
+// State returns the current state of the connection.
+func (l *Connection) State() ConnectionState {
+	l.stateLock.Lock()
+	defer l.stateLock.Unlock()
+	return l.state
+}
+
+// setState changes the state and calls OnStateChange if it changed. The
+// changes are queued while a goroutine calls the hook, which then calls it
+// for them in order.
+func (l *Connection) setState(state ConnectionState) {
+	l.stateLock.Lock()
+	defer l.stateLock.Unlock()
+	if l.state == state {
+		return
+	}
+	l.state = state
+	if l.OnStateChange == nil {
+		return
+	}
+	l.stateChanges = append(l.stateChanges, state)
+	if l.notifyingState {
+		return
+	}
+	l.notifyingState = true
+	for len(l.stateChanges) > 0 {
+		state := l.stateChanges[0]
+		l.stateChanges = l.stateChanges[1:]
+		l.stateLock.Unlock()
+		l.OnStateChange(l, state)
+		l.stateLock.Lock()
+	}
+	l.notifyingState = false
+}
+
+// setCloseError records the cause of the connection breaking as an
+// ErrorNetwork *Error wrapping err, returned to the operations still waiting
+// and passed to OnClose. The first error wins as the ones after it are caused
+// by the connection closing, errors once the connection is closing are
+// ignored.
+func (l *Connection) setCloseError(text string, err error) {
+	l.closeLock.RLock()
+	connected := l.connected
+	l.closeLock.RUnlock()
+	if !connected {
+		return
+	}
+	l.lockResponses.Lock()
+	defer l.lockResponses.Unlock()
+	if l.closeError == nil {
+		l.closeError = &Error{ResultCode: ErrorNetwork, sText: text + err.Error(), err: err}
+	}
+}
+
+// closedState moves to StateClosed and calls OnClose once the goroutines of
+// the connection are done, with a nil error for Close, Unbind and expiring
+// connections.
+func (l *Connection) closedState() {
+	l.closeLock.RLock()
+	intended := l.closed || l.expiring
+	l.closeLock.RUnlock()
+	var err error
+	if !intended {
+		l.lockResponses.RLock()
+		err = l.disconnectError
+		if err == nil {
+			err = l.closeError
+		}
+		l.lockResponses.RUnlock()
+		if err == nil {
+			err = newError(ErrorNetwork, "Connection closed")
+		}
+	}
+	l.setState(StateClosed)
+	if l.OnClose != nil {
+		l.OnClose(l, err)
+	}
+}
@@ -0,0 +1,112 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConnectionStateHooks(t *testing.T) {
+	client, server := net.Pipe()
+	s := &mockServer{conn: server, requests: make(chan *ber.Packet, 16)}
+	go s.serve(nil)
+
+	states := make(chan ConnectionState, 16)
+	closeErrors := make(chan error, 1)
+	l := NewConn(client)
+	l.OnStateChange = func(l *Connection, state ConnectionState) { states <- state }
+	l.OnClose = func(l *Connection, err error) { closeErrors <- err }
+	if err := l.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if l.State() != StateReady {
+		t.Errorf("Expected StateReady, got %s", l.State())
+	}
+	l.Close()
+	select {
+	case err := <-closeErrors:
+		if err != nil {
+			t.Errorf("Expected no error for Close, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnClose was not called")
+	}
+
+	close(states)
+	var got []ConnectionState
+	for state := range states {
+		got = append(got, state)
+	}
+	if want := []ConnectionState{StateConnecting, StateReady, StateClosing, StateClosed}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the states %v, got %v", want, got)
+	}
+}
+
+func TestConnectionStateOrder(t *testing.T) {
+	l := &Connection{}
+	entered, release := make(chan struct{}), make(chan struct{})
+	var got []ConnectionState
+	l.OnStateChange = func(l *Connection, state ConnectionState) {
+		if state == StateConnecting {
+			close(entered)
+			<-release
+		}
+		got = append(got, state)
+	}
+	done := make(chan struct{})
+	go func() {
+		l.setState(StateConnecting)
+		close(done)
+	}()
+	// the changes while the hook runs are delivered after it
+	<-entered
+	l.setState(StateReady)
+	l.setState(StateClosing)
+	close(release)
+	<-done
+	if want := []ConnectionState{StateConnecting, StateReady, StateClosing}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the states %v, got %v", want, got)
+	}
+}
+
+func TestConnectionStateBroken(t *testing.T) {
+	client, server := net.Pipe()
+	closeErrors := make(chan error, 1)
+	l := NewConn(client)
+	l.OnClose = func(l *Connection, err error) { closeErrors <- err }
+	if err := l.Start(); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+	select {
+	case err := <-closeErrors:
+		if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ErrorNetwork {
+			t.Errorf("Expected ErrorNetwork, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnClose was not called")
+	}
+	if l.State() != StateClosed {
+		t.Errorf("Expected StateClosed, got %s", l.State())
+	}
+}
+
+func TestOnReconnect(t *testing.T) {
+	l, servers := mockReconnectServer(t)
+	reconnects := 0
+	l.OnReconnect = func(l *Connection) { reconnects++ }
+	<-servers
+
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", nil)
+	if _, err := l.Search(searchRequest); err != nil {
+		t.Fatal(err)
+	}
+	if reconnects != 1 {
+		t.Errorf("OnReconnect called %d times", reconnects)
+	}
+	if l.State() != StateReady {
+		t.Errorf("Expected StateReady, got %s", l.State())
+	}
+}
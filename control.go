@@ -1,22 +1,27 @@
 package ldap
 
 import (
-	"errors"
 	"fmt"
 	"github.com/eaciit/asn1-ber"
-	"log"
+	"strings"
 )
 
-// Control Interface
+// Control is a request or response control. Response controls are decoded with
+// DecodeControl, those without a registered decoder are returned as
+// *RawControl.
 type Control interface {
+	// OID returns the controlType of the control
+	OID() ControlType
+	// Criticality reports whether the server has to reject the operation if
+	// it doesn't support the control
+	Criticality() bool
 	Encode() (*ber.Packet, error)
-	GetControlType() ControlType
 	String() string
 }
 
 type ControlString struct {
 	ControlType  ControlType
-	Criticality  bool
+	Critical     bool
 	ControlValue string
 }
 
@@ -24,7 +29,7 @@ func NewControlStringFromPacket(p *ber.Packet) (Control, error) {
 	controlType, criticality, valuePacket := decodeControlTypeAndCrit(p)
 	c := new(ControlString)
 	c.ControlType = controlType
-	c.Criticality = criticality
+	c.Critical = criticality
 
 	// FIXME: this is hacky, but like the original implementation in the asn1-ber packet previously used
 	switch t := valuePacket.Value.(type) {
@@ -39,15 +44,19 @@ func NewControlStringFromPacket(p *ber.Packet) (Control, error) {
 	return c, nil
 }
 
-func (c *ControlString) GetControlType() ControlType {
+func (c *ControlString) OID() ControlType {
 	return c.ControlType
 }
 
+func (c *ControlString) Criticality() bool {
+	return c.Critical
+}
+
 func (c *ControlString) Encode() (p *ber.Packet, err error) {
 	p = ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
 	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(c.ControlType), fmt.Sprintf("Control Type (%v)", c.ControlType)))
-	if c.Criticality {
-		p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	if c.Critical {
+		p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Critical, "Criticality"))
 	}
 	if len(c.ControlValue) != 0 {
 		p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, c.ControlValue, "Control Value"))
@@ -56,7 +65,67 @@ func (c *ControlString) Encode() (p *ber.Packet, err error) {
 }
 
 func (c *ControlString) String() string {
-	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t  Control Value: %s", c.ControlType.String(), string(c.ControlType), c.Criticality, c.ControlValue)
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t  Control Value: %s", c.ControlType.String(), string(c.ControlType), c.Critical, c.ControlValue)
+}
+
+/**************/
+/* RawControl */
+/**************/
+
+// RawControl is a control without a registered decoder. Value holds the
+// undecoded controlValue, nil if the control had none. It is encoded as it
+// was received, so it can also be used for request controls this package
+// doesn't know.
+type RawControl struct {
+	ControlType ControlType
+	Critical    bool
+	Value       []byte
+}
+
+func NewRawControl(controlType ControlType, critical bool, value []byte) *RawControl {
+	return &RawControl{ControlType: controlType, Critical: critical, Value: value}
+}
+
+func NewRawControlFromPacket(p *ber.Packet) (Control, error) {
+	controlType, criticality, value := decodeControlTypeAndCrit(p)
+	c := &RawControl{ControlType: controlType, Critical: criticality}
+	for _, child := range p.Children[1:] {
+		if child == value {
+			c.Value = append([]byte{}, value.Data.Bytes()...)
+		}
+	}
+	return c, nil
+}
+
+func (c *RawControl) OID() ControlType {
+	return c.ControlType
+}
+
+func (c *RawControl) Criticality() bool {
+	return c.Critical
+}
+
+func (c *RawControl) Encode() (*ber.Packet, error) {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(c.ControlType), fmt.Sprintf("Control Type (%v)", c.ControlType)))
+	if c.Critical {
+		p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Critical, "Criticality"))
+	}
+	if c.Value != nil {
+		value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value")
+		value.Data.Write(c.Value)
+		p.AppendChild(value)
+	}
+	return p, nil
+}
+
+func (c *RawControl) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, Value: %x",
+		c.ControlType.String(),
+		string(c.ControlType),
+		c.Critical,
+		c.Value,
+	)
 }
 
 type ControlPaging struct {
@@ -68,24 +137,37 @@ func NewControlPaging(PagingSize uint32) *ControlPaging {
 	return &ControlPaging{PagingSize: PagingSize}
 }
 
+/*
+realSearchControlValue ::= SEQUENCE {
+        size            INTEGER (0..maxInt),
+                                -- requested page size from client
+                                -- result set size estimate from server
+        cookie          OCTET STRING }
+*/
 func NewControlPagingFromPacket(p *ber.Packet) (Control, error) {
 	_, _, value := decodeControlTypeAndCrit(p)
 	value.Description += " (Paging)"
 	c := new(ControlPaging)
 
-	if value.Value != nil {
+	if value.Value != nil || len(value.Children) == 0 {
 		value_children := ber.DecodePacket(value.Data.Bytes())
+		if value_children == nil {
+			return c, newError(ErrorDecoding, "Couldn't decode paging control value.")
+		}
 		value.Data.Truncate(0)
 		value.Value = nil
 		value.AppendChild(value_children)
 	}
 	value = value.Children[0]
+	if len(value.Children) != 2 {
+		return c, newError(ErrorDecoding, "Invalid paging control value.")
+	}
 	value.Description = "Search Control Value"
 	value.Children[0].Description = "Paging Size"
 	value.Children[1].Description = "Cookie"
-	pagingSize, ok := value.Children[0].Value.(uint64)
+	pagingSize, ok := packetInt64(value.Children[0])
 	if !ok {
-		return c, errors.New(fmt.Sprintf("type assertion uint64 for %v failed!\n", p.Children[0].Value))
+		return c, NewValueMismatchError(value.Children[0].Value)
 	}
 	c.PagingSize = uint32(pagingSize)
 	c.Cookie = value.Children[1].Data.Bytes()
@@ -93,10 +175,14 @@ func NewControlPagingFromPacket(p *ber.Packet) (Control, error) {
 	return c, nil
 }
 
-func (c *ControlPaging) GetControlType() ControlType {
+func (c *ControlPaging) OID() ControlType {
 	return ControlTypePaging
 }
 
+func (c *ControlPaging) Criticality() bool {
+	return false
+}
+
 func (c *ControlPaging) Encode() (p *ber.Packet, err error) {
 	p = ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
 	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(ControlTypePaging), fmt.Sprintf("Control Type (%v)", ControlTypePaging)))
@@ -128,9 +214,33 @@ func (c *ControlPaging) SetCookie(Cookie []byte) {
 	c.Cookie = Cookie
 }
 
+// MarshalBinary encodes the control with its cookie, so a paged search with
+// SearchPage can be continued after a restart.
+func (c *ControlPaging) MarshalBinary() ([]byte, error) {
+	p, err := c.Encode()
+	if err != nil {
+		return nil, err
+	}
+	return p.Bytes(), nil
+}
+
+// UnmarshalBinary restores a control encoded with MarshalBinary.
+func (c *ControlPaging) UnmarshalBinary(data []byte) error {
+	p := ber.DecodePacket(data)
+	if p == nil || len(p.Children) == 0 || packetString(p.Children[0]) != string(ControlTypePaging) {
+		return newError(ErrorDecoding, "Couldn't decode paging control.")
+	}
+	control, err := NewControlPagingFromPacket(p)
+	if err != nil {
+		return err
+	}
+	*c = *control.(*ControlPaging)
+	return nil
+}
+
 func FindControl(controls []Control, controlType ControlType) (position int, control Control) {
 	for pos, c := range controls {
-		if c.GetControlType() == controlType {
+		if c.OID() == controlType {
 			return pos, c
 		}
 	}
@@ -138,7 +248,7 @@ func FindControl(controls []Control, controlType ControlType) (position int, con
 }
 
 func ReplaceControl(controls []Control, control Control) (oldControl Control) {
-	ControlType := control.GetControlType()
+	ControlType := control.OID()
 	pos, c := FindControl(controls, ControlType)
 	if c != nil {
 		controls[pos] = control
@@ -161,13 +271,18 @@ func decodeControlTypeAndCrit(p *ber.Packet) (controlType ControlType, criticali
 
 	p.Children[0].Description = fmt.Sprintf("Control Type (%v)", controlType)
 	criticality = false
-	if len(p.Children) == 3 {
-		// at least guard against type assertion failure
-		criticality, _ = p.Children[1].Value.(bool)
-		p.Children[1].Description = "Criticality"
-		valuePacket = p.Children[2]
-	} else {
-		valuePacket = p.Children[1]
+	for _, child := range p.Children[1:] {
+		if child.ClassType == ber.ClassUniversal && child.Tag == ber.TagBoolean {
+			// at least guard against type assertion failure
+			criticality, _ = child.Value.(bool)
+			child.Description = "Criticality"
+		} else {
+			valuePacket = child
+		}
+	}
+	if valuePacket == nil {
+		// the controlValue is optional
+		valuePacket = ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value")
 	}
 	valuePacket.Description = "Control Value"
 	return
@@ -176,11 +291,52 @@ func decodeControlTypeAndCrit(p *ber.Packet) (controlType ControlType, criticali
 func NewControlString(ControlType ControlType, Criticality bool, ControlValue string) *ControlString {
 	return &ControlString{
 		ControlType:  ControlType,
-		Criticality:  Criticality,
+		Critical:     Criticality,
 		ControlValue: ControlValue,
 	}
 }
 
+// DecodeControl decodes a Control SEQUENCE with the decoder registered for its
+// controlType (see RegisterControl). Controls without a decoder are returned
+// as *RawControl. A decoder that fails may still return the partly decoded
+// control together with the error.
+func DecodeControl(p *ber.Packet) (Control, error) {
+	if len(p.Children) == 0 {
+		return nil, newError(ErrorDecoding, "Invalid control format.")
+	}
+	controlType, _, _ := decodeControlTypeAndCrit(p)
+	decodeFunc, err := controlType.function()
+	if err != nil {
+		return NewRawControlFromPacket(p)
+	}
+	return decodeFunc(p)
+}
+
+// decodeControls decodes the Controls packet of a response with DecodeControl
+// and fails with the error of the first control that doesn't decode, so no
+// partly decoded control is returned.
+func decodeControls(p *ber.Packet) ([]Control, error) {
+	controls := make([]Control, 0)
+	for _, child := range p.Children {
+		if len(child.Children) == 0 {
+			return nil, newError(ErrorDecoding, "Invalid control format.")
+		}
+		if _, ok := child.Children[0].Value.(string); !ok {
+			return nil, NewValueMismatchError(child.Children[0].Value)
+		}
+
+		c, err := DecodeControl(child)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			continue
+		}
+		controls = append(controls, c)
+	}
+	return controls, nil
+}
+
 func encodeControls(Controls []Control) (*ber.Packet, error) {
 	p := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
 	for _, control := range Controls {
@@ -205,6 +361,10 @@ func NewControlPermissiveModifyRequest(criticality bool) *ControlString {
 /* ManageDsaITRequest */
 /***************/
 
+// https://tools.ietf.org/html/rfc3296
+// With the control the server treats referral objects (and glue entries) as
+// regular entries instead of returning referrals, so they can be read,
+// modified and deleted. The control has no value.
 func NewControlManageDsaITRequest(criticality bool) *ControlString {
 	return NewControlString(ControlTypeManageDsaITRequest, criticality, "")
 }
@@ -217,33 +377,117 @@ func NewControlSubtreeDeleteRequest(criticality bool) *ControlString {
 	return NewControlString(ControlTypeSubtreeDeleteRequest, criticality, "")
 }
 
+/****************************/
+/* ShowDeleted/ShowRecycled */
+/****************************/
+
+// NewControlShowDeletedRequest makes Active Directory return deleted objects
+// (tombstones and, with the recycle bin enabled, deleted objects that can still
+// be restored), marked with isDeleted.
+func NewControlShowDeletedRequest(criticality bool) *ControlString {
+	return NewControlString(ControlTypeShowDeletedRequest, criticality, "")
+}
+
+// NewControlShowRecycledRequest additionally makes Active Directory return
+// recycled objects, marked with isRecycled, once the recycle bin is enabled.
+func NewControlShowRecycledRequest(criticality bool) *ControlString {
+	return NewControlString(ControlTypeShowRecycledRequest, criticality, "")
+}
+
 /***************/
 /* NoOpRequest */
 /***************/
 
+// NewControlNoOpRequest makes the server check an add, modify, delete or
+// modify DN, e.g. against the schema and access controls, without applying
+// it [https://tools.ietf.org/html/draft-zeilenga-ldap-noop]. A request that
+// would have succeeded returns the result ResultNoOperation without an error.
+// The control is always critical.
 func NewControlNoOpRequest() *ControlString {
 	return NewControlString(ControlTypeNoOpRequest, true, "")
 }
 
+/**********************/
+/* DontUseCopyRequest */
+/**********************/
+
+// https://tools.ietf.org/html/rfc6171
+// Makes the server answer a search or compare from the authoritative data
+// instead of a copy, e.g. to read an entry right after modifying it. The
+// control is always critical.
+func NewControlDontUseCopyRequest() *ControlString {
+	return NewControlString(ControlTypeDontUseCopyRequest, true, "")
+}
+
+/***********************/
+/* NotificationRequest */
+/***********************/
+
+// LDAP_SERVER_NOTIFICATION_OID, see Connection.Notification. Active Directory
+// requires the control to be critical.
+func NewControlNotificationRequest() *ControlString {
+	return NewControlString(ControlTypeNotificationRequest, true, "")
+}
+
+/***************************/
+/* AccountUsabilityRequest */
+/***************************/
+
+// NewControlAccountUsabilityRequest makes 389 Directory Server and Oracle
+// Directory Server return a ControlAccountUsability with the returned entries
+// telling whether the account can be used to bind.
+func NewControlAccountUsabilityRequest(criticality bool) *ControlString {
+	return NewControlString(ControlTypeAccountUsability, criticality, "")
+}
+
+/**********************/
+/* DomainScopeRequest */
+/**********************/
+
+// LDAP_SERVER_DOMAIN_SCOPE_OID, makes Active Directory return no referrals to
+// other domains or partitions, so a subtree search of a domain also succeeds
+// if the referred partitions can't be followed.
+func NewControlDomainScopeRequest(criticality bool) *ControlString {
+	return NewControlString(ControlTypeDomainScopeRequest, criticality, "")
+}
+
+/*************************/
+/* PasswordPolicyRequest */
+/*************************/
+
+// https://tools.ietf.org/html/draft-behera-ldap-password-policy-10
+func NewControlPasswordPolicyRequest() *ControlString {
+	return NewControlString(ControlTypePasswordPolicy, false, "")
+}
+
+/******************/
+/* AuthzIdRequest */
+/******************/
+
+// Authorization Identity Request Control [https://tools.ietf.org/html/rfc3829]
+func NewControlAuthzIdRequest(criticality bool) *ControlString {
+	return NewControlString(ControlTypeAuthzIdRequest, criticality, "")
+}
+
 /************************/
 /* MatchedValuesRequest */
 /************************/
 
 type ControlMatchedValuesRequest struct {
-	Criticality bool
-	Filter      string
+	Critical bool
+	Filter   string
 }
 
 func NewControlMatchedValuesRequest(criticality bool, filter string) *ControlMatchedValuesRequest {
 	return &ControlMatchedValuesRequest{criticality, filter}
 }
 
-func (c *ControlMatchedValuesRequest) Decode(p *ber.Packet) (*Control, error) {
-	return nil, newError(ErrorDecoding, "Decode of Control unsupported.")
+func (c *ControlMatchedValuesRequest) OID() ControlType {
+	return ControlTypeMatchedValuesRequest
 }
 
-func (c *ControlMatchedValuesRequest) GetControlType() ControlType {
-	return ControlTypeMatchedValuesRequest
+func (c *ControlMatchedValuesRequest) Criticality() bool {
+	return c.Critical
 }
 
 func (c *ControlMatchedValuesRequest) Encode() (p *ber.Packet, err error) {
@@ -252,8 +496,8 @@ func (c *ControlMatchedValuesRequest) Encode() (p *ber.Packet, err error) {
 		ber.NewString(ber.ClassUniversal, ber.TypePrimitive,
 			ber.TagOctetString, string(ControlTypeMatchedValuesRequest),
 			fmt.Sprintf("Control Type (%v)", ControlTypeMatchedValuesRequest)))
-	if c.Criticality {
-		p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	if c.Critical {
+		p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Critical, "Criticality"))
 	}
 	octetString := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Octet String")
 	simpleFilterSeq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "SimpleFilterItem")
@@ -272,7 +516,7 @@ func (c *ControlMatchedValuesRequest) String() string {
 		"Control Type: %s (%q)  Criticality: %t  Filter: %s",
 		ControlTypeMatchedValuesRequest.String(),
 		string(ControlTypeMatchedValuesRequest),
-		c.Criticality,
+		c.Critical,
 		c.Filter,
 	)
 }
@@ -297,19 +541,19 @@ type ServerSideSortAttrRuleOrder struct {
 
 type ControlServerSideSortRequest struct {
 	SortKeyList []ServerSideSortAttrRuleOrder
-	Criticality bool
+	Critical    bool
 }
 
 func NewControlServerSideSortRequest(sortKeyList []ServerSideSortAttrRuleOrder, criticality bool) *ControlServerSideSortRequest {
 	return &ControlServerSideSortRequest{sortKeyList, criticality}
 }
 
-func (c *ControlServerSideSortRequest) Decode(p *ber.Packet) (*Control, error) {
-	return nil, newError(ErrorDecoding, "Decode of Control unsupported.")
+func (c *ControlServerSideSortRequest) OID() ControlType {
+	return ControlTypeServerSideSortRequest
 }
 
-func (c *ControlServerSideSortRequest) GetControlType() ControlType {
-	return ControlTypeServerSideSortRequest
+func (c *ControlServerSideSortRequest) Criticality() bool {
+	return c.Critical
 }
 
 func (c *ControlServerSideSortRequest) Encode() (p *ber.Packet, err error) {
@@ -318,8 +562,8 @@ func (c *ControlServerSideSortRequest) Encode() (p *ber.Packet, err error) {
 		ber.NewString(ber.ClassUniversal, ber.TypePrimitive,
 			ber.TagOctetString, string(ControlTypeServerSideSortRequest),
 			fmt.Sprintf("Control Type (%v)", ControlTypeServerSideSortRequest)))
-	if c.Criticality {
-		p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	if c.Critical {
+		p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Critical, "Criticality"))
 	}
 	octetString := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Octet String")
 	seqSortKeyLists := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "SortKeyLists")
@@ -349,7 +593,7 @@ func (c *ControlServerSideSortRequest) String() string {
 		"Control Type: %s (%q)  Criticality: %t, SortKeys: ",
 		ControlTypeServerSideSortRequest.String(),
 		string(ControlTypeServerSideSortRequest),
-		c.Criticality,
+		c.Critical,
 	)
 	for _, sortKey := range c.SortKeyList {
 		ctext += fmt.Sprintf("[%s,%s,%t]", sortKey.AttributeName, sortKey.OrderingRule, sortKey.ReverseOrder)
@@ -380,7 +624,7 @@ type VlvOffSet struct {
        contextID     OCTET STRING OPTIONAL }
 */
 type ControlVlvRequest struct {
-	Criticality        bool
+	Critical           bool
 	BeforeCount        int32
 	AfterCount         int32
 	ByOffset           *VlvOffSet
@@ -388,14 +632,53 @@ type ControlVlvRequest struct {
 	ContextID          []byte
 }
 
+// NewControlVlvRequestByOffset requests the window of beforeCount entries before
+// and afterCount entries after the entry at offset (starting at 1), contentCount
+// is the client's estimate of the result size, 0 if unknown. The control is
+// critical, VLV needs a ServerSideSortRequest control in the same search.
+func NewControlVlvRequestByOffset(beforeCount, afterCount, offset, contentCount int32) *ControlVlvRequest {
+	return &ControlVlvRequest{
+		Critical:    true,
+		BeforeCount: beforeCount,
+		AfterCount:  afterCount,
+		ByOffset:    &VlvOffSet{Offset: offset, ContentCount: contentCount},
+	}
+}
+
+// NewControlVlvRequestGreaterThanOrEqual requests the window around the first
+// entry whose sort key is greater than or equal to assertion.
+func NewControlVlvRequestGreaterThanOrEqual(beforeCount, afterCount int32, assertion string) *ControlVlvRequest {
+	return &ControlVlvRequest{
+		Critical:           true,
+		BeforeCount:        beforeCount,
+		AfterCount:         afterCount,
+		GreaterThanOrEqual: assertion,
+	}
+}
+
+// Update prepares the request for the next window of the same result: it
+// reuses the contextID of the server and, when targeting by offset, its
+// contentCount estimate.
+func (c *ControlVlvRequest) Update(response *ControlVlvResponse) {
+	if response == nil {
+		return
+	}
+	if len(response.ContextID) > 0 {
+		c.ContextID = []byte(response.ContextID)
+	}
+	if c.ByOffset != nil {
+		c.ByOffset.ContentCount = int32(response.ContentCount)
+	}
+}
+
 func (c *ControlVlvRequest) Encode() (*ber.Packet, error) {
 	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "ControlVlvRequest")
 	p.AppendChild(
 		ber.NewString(ber.ClassUniversal, ber.TypePrimitive,
 			ber.TagOctetString, string(ControlTypeVlvRequest),
 			fmt.Sprintf("Control Type (%v)", ControlTypeVlvRequest)))
-	if c.Criticality {
-		p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Criticality, "Criticality"))
+	if c.Critical {
+		p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Critical, "Criticality"))
 	}
 	octetString := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Octet String")
 
@@ -438,225 +721,1743 @@ func (c *ControlVlvRequest) Encode() (*ber.Packet, error) {
 
 }
 
-func (c *ControlVlvRequest) GetControlType() string {
-	return ControlTypeVlvRequest.String()
+func (c *ControlVlvRequest) OID() ControlType {
+	return ControlTypeVlvRequest
+}
+
+func (c *ControlVlvRequest) Criticality() bool {
+	return c.Critical
 }
 
 func (c *ControlVlvRequest) String() string {
+	offset := VlvOffSet{}
+	if c.ByOffset != nil {
+		offset = *c.ByOffset
+	}
 	ctext := fmt.Sprintf(
 		"Control Type: %s (%q)  Criticality: %t, BeforeCount: %d, AfterCount: %d"+
-			", ByOffset.Offset: %d, ByOffset.ContentCount: %d, GreaterThanOrEqual: %s",
+			", ByOffset.Offset: %d, ByOffset.ContentCount: %d, GreaterThanOrEqual: %s, ContextID: %q",
 		ControlTypeVlvRequest.String(),
 		string(ControlTypeVlvRequest),
-		c.Criticality, c.BeforeCount, c.AfterCount, c.ByOffset.Offset,
-		c.ByOffset.ContentCount, c.GreaterThanOrEqual,
+		c.Critical, c.BeforeCount, c.AfterCount, offset.Offset,
+		offset.ContentCount, c.GreaterThanOrEqual, c.ContextID,
 	)
 	return ctext
 }
 
-/***********************************/
-/*      RESPONSE CONTROLS          */
-/***********************************/
+/******************/
+/* DirSyncRequest */
+/******************/
 
-/**************************/
-/* ServerSideSortResponse */
-/**************************/
+// Flags of the DirSync control
+const (
+	DirSyncObjectSecurity      = 0x00000001
+	DirSyncAncestorsFirstOrder = 0x00000800
+	DirSyncPublicDataOnly      = 0x00002000
+	DirSyncIncrementalValues   = 0x80000000
+)
 
-type ControlServerSideSortResponse struct {
-	AttributeName string // Optional
-	Criticality   bool
-	Err           error
+/*
+Active Directory DirSync [https://msdn.microsoft.com/en-us/library/cc223347.aspx]
+
+DirSyncRequestValue ::= SEQUENCE {
+     Flags         INTEGER
+     MaxBytes      INTEGER
+     Cookie        OCTET STRING }
+*/
+type ControlDirSyncRequest struct {
+	Critical bool
+	Flags    int64
+	// Maximum number of bytes the server returns, 0 for the server default
+	MaxBytes int64
+	// Cookie of the previous DirSync, empty for a full synchronization
+	Cookie []byte
 }
 
-//SortResult ::= SEQUENCE {
-//   sortResult  ENUMERATED {
-//       success                   (0), -- results are sorted
-//       operationsError           (1), -- server internal failure
-//       timeLimitExceeded         (3), -- timelimit reached before
-//                                      -- sorting was completed
-//       strongAuthRequired        (8), -- refused to return sorted
-//                                      -- results via insecure
-//                                      -- protocol
-//       adminLimitExceeded       (11), -- too many matching entries
-//                                      -- for the server to sort
-//       noSuchAttribute          (16), -- unrecognized attribute
-//                                      -- type in sort key
-//       inappropriateMatching    (18), -- unrecognized or
-//                                      -- inappropriate matching
-//                                      -- rule in sort key
-//       insufficientAccessRights (50), -- refused to return sorted
-//                                      -- results to this client
-//       busy                     (51), -- too busy to process
-//       unwillingToPerform       (53), -- unable to sort
-//       other                    (80)
-//       },
-//   attributeType [0] AttributeDescription OPTIONAL }
-func NewControlServerSideSortResponse(p *ber.Packet) (Control, error) {
-	c := new(ControlServerSideSortResponse)
-	_, criticality, value := decodeControlTypeAndCrit(p)
-	c.Criticality = criticality
+// NewControlDirSyncRequest returns a critical DirSync request control, the
+// server rejects the control otherwise.
+func NewControlDirSyncRequest(flags, maxBytes int64, cookie []byte) *ControlDirSyncRequest {
+	return &ControlDirSyncRequest{Critical: true, Flags: flags, MaxBytes: maxBytes, Cookie: cookie}
+}
 
-	if value.Value != nil {
-		sortResult := ber.DecodePacket(value.Data.Bytes())
-		value.Data.Truncate(0)
-		value.Value = nil
-		value.AppendChild(sortResult)
-	}
+func (c *ControlDirSyncRequest) OID() ControlType {
+	return ControlTypeDirSync
+}
 
-	value = value.Children[0]
-	value.Description = "ServerSideSortResponse Control Value"
+func (c *ControlDirSyncRequest) Criticality() bool {
+	return c.Critical
+}
 
-	value.Children[0].Description = "SortResult"
-	errNum, ok := value.Children[0].Value.(ResultCode)
-	if !ok {
-		return c, errors.New(fmt.Sprintf("type assertion ResultCode for %v failed!", p.Children[0].Value))
+func (c *ControlDirSyncRequest) Encode() (*ber.Packet, error) {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(ControlTypeDirSync), fmt.Sprintf("Control Type (%v)", ControlTypeDirSync)))
+	if c.Critical {
+		p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Critical, "Criticality"))
 	}
-	c.Err = newError(errNum, "")
-
-	if len(value.Children) == 2 {
-		value.Children[1].Description = "Attribute Name"
-
-		// FIXME: this is hacky, but like the original implementation in the asn1-ber packet previously used
-		switch t := value.Children[1].Value.(type) {
-		case string:
-			c.AttributeName = t
-		case []byte:
-			c.AttributeName = string(t)
-		default:
-			c.AttributeName = ""
-		}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (DirSync)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "DirSyncRequestValue")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, c.Flags, "Flags"))
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, c.MaxBytes, "MaxBytes"))
+	cookie := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Cookie")
+	cookie.Value = c.Cookie
+	cookie.Data.Write(c.Cookie)
+	seq.AppendChild(cookie)
+	value.AppendChild(seq)
+	p.AppendChild(value)
+	return p, nil
+}
 
-		value.Children[1].Value = c.AttributeName
-	}
-	return c, nil
+func (c *ControlDirSyncRequest) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, Flags: %#x, MaxBytes: %d, Cookie: %q",
+		ControlTypeDirSync.String(),
+		string(ControlTypeDirSync),
+		c.Critical,
+		c.Flags,
+		c.MaxBytes,
+		c.Cookie,
+	)
 }
 
-func (c *ControlServerSideSortResponse) Encode() (p *ber.Packet, err error) {
-	return nil, newError(ErrorEncoding, "Encode of Control unsupported.")
+func (c *ControlDirSyncRequest) SetCookie(cookie []byte) {
+	c.Cookie = cookie
 }
 
-func (c *ControlServerSideSortResponse) GetControlType() ControlType {
-	return ControlTypeServerSideSortResponse
+// MarshalBinary encodes the control with its flags and cookie, so it can be
+// stored between synchronizations with ResumeDirSync.
+func (c *ControlDirSyncRequest) MarshalBinary() ([]byte, error) {
+	p, err := c.Encode()
+	if err != nil {
+		return nil, err
+	}
+	return p.Bytes(), nil
 }
 
-func (c *ControlServerSideSortResponse) String() string {
-	err, ok := c.Err.(*Error)
+// UnmarshalBinary restores a control encoded with MarshalBinary.
+func (c *ControlDirSyncRequest) UnmarshalBinary(data []byte) error {
+	p := ber.DecodePacket(data)
+	if p == nil || len(p.Children) == 0 || packetString(p.Children[0]) != string(ControlTypeDirSync) {
+		return newError(ErrorDecoding, "Couldn't decode DirSync control.")
+	}
+	_, criticality, value := decodeControlTypeAndCrit(p)
+	dirSync := ber.DecodePacket(value.Data.Bytes())
+	if dirSync == nil || len(dirSync.Children) != 3 {
+		return newError(ErrorDecoding, "Invalid DirSyncRequestValue.")
+	}
+	flags, ok := packetInt64(dirSync.Children[0])
 	if !ok {
-		err = &Error{}
+		return NewValueMismatchError(dirSync.Children[0].Value)
 	}
-	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, AttributeName: %s, ErrorValue: %d",
-		ControlTypeServerSideSortResponse.String(),
-		string(ControlTypeServerSideSortResponse),
-		c.Criticality,
-		c.AttributeName,
-		err.ResultCode,
-	)
+	maxBytes, ok := packetInt64(dirSync.Children[1])
+	if !ok {
+		return NewValueMismatchError(dirSync.Children[1].Value)
+	}
+	*c = ControlDirSyncRequest{
+		Critical: criticality,
+		Flags:    flags,
+		MaxBytes: maxBytes,
+		Cookie:   dirSync.Children[2].Data.Bytes(),
+	}
+	return nil
 }
 
-/***************/
-/* VlvResponse */
-/***************/
+/*********************/
+/* ExtendedDNRequest */
+/*********************/
 
-type ControlVlvResponse struct {
-	Criticality    bool
-	TargetPosition uint64
-	ContentCount   uint64
-	Err            error // VirtualListViewResult
-	ContextID      string
-}
+// Formats of the GUID and SID in extended DNs
+const (
+	// <GUID=f1b8e3ed7d....>;<SID=010500000000...>
+	ExtendedDNHexString = 0
+	// <GUID=edb8e3f1-...>;<SID=S-1-5-21-...>
+	ExtendedDNStandardString = 1
+)
 
 /*
- VirtualListViewResponse ::= SEQUENCE {
-       targetPosition    INTEGER (0 .. maxInt),
-       contentCount     INTEGER (0 .. maxInt),
-       virtualListViewResult ENUMERATED {
-            success (0),
-            operationsError (1),
-            protocolError (3),
-            unwillingToPerform (53),
-            insufficientAccessRights (50),
-            timeLimitExceeded (3),
-            adminLimitExceeded (11),
-            innapropriateMatching (18),
-            sortControlMissing (60),
-            offsetRangeError (61),
-            other(80),
-            ... },
-       contextID     OCTET STRING OPTIONAL }
+LDAP_SERVER_EXTENDED_DN [https://msdn.microsoft.com/en-us/library/cc223349.aspx]
+
+ExtendedDNRequestValue ::= SEQUENCE {
+     Flag    INTEGER }
 */
-func NewControlVlvResponse(p *ber.Packet) (Control, error) {
-	c := new(ControlVlvResponse)
-	_, criticality, value := decodeControlTypeAndCrit(p)
-	c.Criticality = criticality
+type ControlExtendedDNRequest struct {
+	Critical bool
+	Flag     int
+}
 
-	if value.Value != nil {
-		vlvResult := ber.DecodePacket(value.Data.Bytes())
-		value.Data.Truncate(0)
-		value.Value = nil
-		value.AppendChild(vlvResult)
-	}
+// NewControlExtendedDNRequest makes Active Directory return the DNs of the
+// entries prefixed with their objectGUID and objectSid in format flag. They are
+// decoded into the GUID and SID of the returned entries.
+func NewControlExtendedDNRequest(flag int) *ControlExtendedDNRequest {
+	return &ControlExtendedDNRequest{Flag: flag}
+}
 
-	value = value.Children[0]
-	value.Description = "VlvResponse Control Value"
+func (c *ControlExtendedDNRequest) OID() ControlType {
+	return ControlTypeExtendedDNRequest
+}
 
-	value.Children[0].Description = "TargetPosition"
-	value.Children[1].Description = "ContentCount"
-	value.Children[2].Description = "VirtualListViewResult/Err"
+func (c *ControlExtendedDNRequest) Criticality() bool {
+	return c.Critical
+}
 
-	var ok bool
-	c.TargetPosition, ok = value.Children[0].Value.(uint64)
-	if !ok {
-		return c, errors.New(fmt.Sprintf("type assertion uint64 for %v failed!", p.Children[0].Value))
-	}
-	c.ContentCount, ok = value.Children[1].Value.(uint64)
-	if !ok {
-		return c, errors.New(fmt.Sprintf("type assertion uint64 for %v failed!", p.Children[1].Value))
+func (c *ControlExtendedDNRequest) Encode() (*ber.Packet, error) {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(ControlTypeExtendedDNRequest), fmt.Sprintf("Control Type (%v)", ControlTypeExtendedDNRequest)))
+	if c.Critical {
+		p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Critical, "Criticality"))
 	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (ExtendedDN)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "ExtendedDNRequestValue")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, c.Flag, "Flag"))
+	value.AppendChild(seq)
+	p.AppendChild(value)
+	return p, nil
+}
 
-	errNum, ok := value.Children[2].Value.(ResultCode)
-	if !ok {
-		log.Println("type assertion failed in control.go")
-		errNum = 212
-	}
-	c.Err = newError(errNum, "")
+func (c *ControlExtendedDNRequest) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, Flag: %d",
+		ControlTypeExtendedDNRequest.String(),
+		string(ControlTypeExtendedDNRequest),
+		c.Critical,
+		c.Flag,
+	)
+}
 
-	if len(value.Children) == 4 {
-		value.Children[3].Description = "ContextID"
+/******************/
+/* SDFlagsRequest */
+/******************/
 
-		// FIXME: this is hacky, but like the original implementation in the asn1-ber packet previously used
-		switch t := value.Children[3].Value.(type) {
-		case string:
-			c.ContextID = t
-		case []byte:
-			c.ContextID = string(t)
-		default:
-			c.ContextID = ""
-		}
-	}
+// Parts of the ntSecurityDescriptor for the SDFlags control
+const (
+	SDFlagsOwner = 0x1
+	SDFlagsGroup = 0x2
+	SDFlagsDacl  = 0x4
+	SDFlagsSacl  = 0x8
+)
 
-	return c, nil
+/*
+LDAP_SERVER_SD_FLAGS [https://msdn.microsoft.com/en-us/library/cc223323.aspx]
+
+SDFlagsRequestValue ::= SEQUENCE {
+     Flags    INTEGER }
+*/
+type ControlSDFlagsRequest struct {
+	Critical bool
+	Flags    int
 }
 
-func (c *ControlVlvResponse) Encode() (p *ber.Packet, err error) {
-	return nil, newError(ErrorEncoding, "Encode of Control unsupported.")
+// NewControlSDFlagsRequest restricts the ntSecurityDescriptor read or written
+// to the parts in flags, e.g. SDFlagsOwner|SDFlagsGroup|SDFlagsDacl to read it
+// without the privilege needed for the SACL.
+func NewControlSDFlagsRequest(flags int) *ControlSDFlagsRequest {
+	return &ControlSDFlagsRequest{Flags: flags}
 }
 
-func (c *ControlVlvResponse) GetControlType() ControlType {
-	return ControlTypeVlvResponse
+func (c *ControlSDFlagsRequest) OID() ControlType {
+	return ControlTypeSDFlagsRequest
 }
 
-func (c *ControlVlvResponse) String() string {
-	err, ok := c.Err.(*Error)
+func (c *ControlSDFlagsRequest) Criticality() bool {
+	return c.Critical
+}
+
+func (c *ControlSDFlagsRequest) Encode() (*ber.Packet, error) {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(ControlTypeSDFlagsRequest), fmt.Sprintf("Control Type (%v)", ControlTypeSDFlagsRequest)))
+	if c.Critical {
+		p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Critical, "Criticality"))
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (SDFlags)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "SDFlagsRequestValue")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, c.Flags, "Flags"))
+	value.AppendChild(seq)
+	p.AppendChild(value)
+	return p, nil
+}
+
+func (c *ControlSDFlagsRequest) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, Flags: %#x",
+		ControlTypeSDFlagsRequest.String(),
+		string(ControlTypeSDFlagsRequest),
+		c.Critical,
+		c.Flags,
+	)
+}
+
+/**************************/
+/* SessionTrackingRequest */
+/**************************/
+
+// Formats of the session tracking identifier
+const (
+	SessionTrackingRadiusAcctSessionId      = "1.3.6.1.4.1.21008.108.63.1.1"
+	SessionTrackingRadiusAcctMultiSessionId = "1.3.6.1.4.1.21008.108.63.1.2"
+	// the identifier is the name of the user of the client application
+	SessionTrackingUsername = "1.3.6.1.4.1.21008.108.63.1.3"
+)
+
+/*
+https://tools.ietf.org/html/draft-wahl-ldap-session-03
+
+SessionIdentifierControlValue ::= SEQUENCE {
+     sessionSourceIp                 LDAPString,
+     sessionSourceName               LDAPString,
+     formatOID                       LDAPOID,
+     sessionTrackingIdentifier       LDAPString }
+*/
+type ControlSessionTracking struct {
+	Critical bool
+	// IP address and host name of the client of the application
+	SourceIP   string
+	SourceName string
+	// SessionTracking* format of TrackingIdentifier
+	FormatOID          string
+	TrackingIdentifier string
+}
+
+// NewControlSessionTracking tags an operation with the client the application
+// acts for, servers supporting the control write it to their access log.
+func NewControlSessionTracking(sourceIP, sourceName, formatOID, trackingIdentifier string) *ControlSessionTracking {
+	return &ControlSessionTracking{
+		SourceIP:           sourceIP,
+		SourceName:         sourceName,
+		FormatOID:          formatOID,
+		TrackingIdentifier: trackingIdentifier,
+	}
+}
+
+func (c *ControlSessionTracking) OID() ControlType {
+	return ControlTypeSessionTracking
+}
+
+func (c *ControlSessionTracking) Criticality() bool {
+	return c.Critical
+}
+
+func (c *ControlSessionTracking) Encode() (*ber.Packet, error) {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(ControlTypeSessionTracking), fmt.Sprintf("Control Type (%v)", ControlTypeSessionTracking)))
+	if c.Critical {
+		p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Critical, "Criticality"))
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (SessionTracking)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "SessionIdentifierControlValue")
+	seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, c.SourceIP, "sessionSourceIp"))
+	seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, c.SourceName, "sessionSourceName"))
+	seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, c.FormatOID, "formatOID"))
+	seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, c.TrackingIdentifier, "sessionTrackingIdentifier"))
+	value.AppendChild(seq)
+	p.AppendChild(value)
+	return p, nil
+}
+
+func (c *ControlSessionTracking) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, SourceIP: %s, SourceName: %s, FormatOID: %s, TrackingIdentifier: %s",
+		ControlTypeSessionTracking.String(),
+		string(ControlTypeSessionTracking),
+		c.Critical,
+		c.SourceIP,
+		c.SourceName,
+		c.FormatOID,
+		c.TrackingIdentifier,
+	)
+}
+
+/*********************/
+/* SubentriesRequest */
+/*********************/
+
+/*
+https://tools.ietf.org/html/rfc3672#section-3
+
+SubentriesControlValue ::= BOOLEAN
+*/
+type ControlSubentriesRequest struct {
+	Critical bool
+	// true returns only the subentries, false only the regular entries
+	Visibility bool
+}
+
+// NewControlSubentriesRequest makes administrative subentries like password
+// policies or collective attribute subentries visible to a search, the
+// control should be critical.
+func NewControlSubentriesRequest(visibility, criticality bool) *ControlSubentriesRequest {
+	return &ControlSubentriesRequest{Critical: criticality, Visibility: visibility}
+}
+
+func (c *ControlSubentriesRequest) OID() ControlType {
+	return ControlTypeSubentriesRequest
+}
+
+func (c *ControlSubentriesRequest) Criticality() bool {
+	return c.Critical
+}
+
+func (c *ControlSubentriesRequest) Encode() (*ber.Packet, error) {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(ControlTypeSubentriesRequest), fmt.Sprintf("Control Type (%v)", ControlTypeSubentriesRequest)))
+	if c.Critical {
+		p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Critical, "Criticality"))
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (Subentries)")
+	value.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Visibility, "Visibility"))
+	p.AppendChild(value)
+	return p, nil
+}
+
+func (c *ControlSubentriesRequest) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, Visibility: %t",
+		ControlTypeSubentriesRequest.String(),
+		string(ControlTypeSubentriesRequest),
+		c.Critical,
+		c.Visibility,
+	)
+}
+
+/***************************/
+/* PersistentSearchRequest */
+/***************************/
+
+// Change types of the PersistentSearch and EntryChangeNotification controls
+const (
+	ChangeTypeAdd    = 1
+	ChangeTypeDelete = 2
+	ChangeTypeModify = 4
+	ChangeTypeModDN  = 8
+	ChangeTypeAny    = ChangeTypeAdd | ChangeTypeDelete | ChangeTypeModify | ChangeTypeModDN
+)
+
+/*
+https://tools.ietf.org/html/draft-ietf-ldapext-psearch-03
+
+PersistentSearch ::= SEQUENCE {
+     changeTypes INTEGER,
+     changesOnly BOOLEAN,
+     returnECs BOOLEAN }
+*/
+type ControlPersistentSearchRequest struct {
+	Critical bool
+	// ChangeType* flags of the changes returned
+	ChangeTypes int
+	// Don't return the entries matching initially
+	ChangesOnly bool
+	// Attach an EntryChangeNotification control to changed entries
+	ReturnECs bool
+}
+
+func NewControlPersistentSearchRequest(changeTypes int, changesOnly, returnECs bool) *ControlPersistentSearchRequest {
+	return &ControlPersistentSearchRequest{
+		Critical:    true,
+		ChangeTypes: changeTypes,
+		ChangesOnly: changesOnly,
+		ReturnECs:   returnECs,
+	}
+}
+
+func (c *ControlPersistentSearchRequest) OID() ControlType {
+	return ControlTypePersistentSearchRequest
+}
+
+func (c *ControlPersistentSearchRequest) Criticality() bool {
+	return c.Critical
+}
+
+func (c *ControlPersistentSearchRequest) Encode() (*ber.Packet, error) {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(ControlTypePersistentSearchRequest), fmt.Sprintf("Control Type (%v)", ControlTypePersistentSearchRequest)))
+	if c.Critical {
+		p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Critical, "Criticality"))
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (PersistentSearch)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "PersistentSearch")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, c.ChangeTypes, "changeTypes"))
+	seq.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.ChangesOnly, "changesOnly"))
+	seq.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.ReturnECs, "returnECs"))
+	value.AppendChild(seq)
+	p.AppendChild(value)
+	return p, nil
+}
+
+func (c *ControlPersistentSearchRequest) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, ChangeTypes: %d, ChangesOnly: %t, ReturnECs: %t",
+		ControlTypePersistentSearchRequest.String(),
+		string(ControlTypePersistentSearchRequest),
+		c.Critical,
+		c.ChangeTypes,
+		c.ChangesOnly,
+		c.ReturnECs,
+	)
+}
+
+/***************/
+/* SyncRequest */
+/***************/
+
+// Modes of the SyncRequest control
+const (
+	SyncRequestModeRefreshOnly       = 1
+	SyncRequestModeRefreshAndPersist = 3
+)
+
+/*
+syncRequestValue ::= SEQUENCE {
+     mode ENUMERATED {
+         -- 0 unused
+         refreshOnly       (1),
+         -- 2 reserved
+         refreshAndPersist (3)
+     },
+     cookie     syncCookie OPTIONAL,
+     reloadHint BOOLEAN DEFAULT FALSE
+}
+*/
+type ControlSyncRequest struct {
+	Critical bool
+	// SyncRequestMode*
+	Mode int
+	// Cookie of a previous synchronization, nil for the initial content
+	Cookie []byte
+	// Ask the server to send the full content if it can't send the changes
+	ReloadHint bool
+}
+
+func NewControlSyncRequest(mode int, cookie []byte, reloadHint bool) *ControlSyncRequest {
+	return &ControlSyncRequest{
+		Critical:   true,
+		Mode:       mode,
+		Cookie:     cookie,
+		ReloadHint: reloadHint,
+	}
+}
+
+func (c *ControlSyncRequest) OID() ControlType {
+	return ControlTypeSyncRequest
+}
+
+func (c *ControlSyncRequest) Criticality() bool {
+	return c.Critical
+}
+
+func (c *ControlSyncRequest) Encode() (*ber.Packet, error) {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(ControlTypeSyncRequest), fmt.Sprintf("Control Type (%v)", ControlTypeSyncRequest)))
+	if c.Critical {
+		p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Critical, "Criticality"))
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (SyncRequest)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "syncRequestValue")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, c.Mode, "mode"))
+	if c.Cookie != nil {
+		seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(c.Cookie), "cookie"))
+	}
+	if c.ReloadHint {
+		seq.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.ReloadHint, "reloadHint"))
+	}
+	value.AppendChild(seq)
+	p.AppendChild(value)
+	return p, nil
+}
+
+func (c *ControlSyncRequest) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, Mode: %d, Cookie: %q, ReloadHint: %t",
+		ControlTypeSyncRequest.String(),
+		string(ControlTypeSyncRequest),
+		c.Critical,
+		c.Mode,
+		c.Cookie,
+		c.ReloadHint,
+	)
+}
+
+/**********************/
+/* PolicyHintsRequest */
+/**********************/
+
+// Flags of the PolicyHints control
+const (
+	// enforce the password policies, including the password history
+	PolicyHintsEnforce = 0x1
+)
+
+/*
+LDAP_SERVER_POLICY_HINTS [https://msdn.microsoft.com/en-us/library/hh128228.aspx]
+
+PolicyHintsRequestValue ::= SEQUENCE {
+     Flags    INTEGER }
+*/
+type ControlPolicyHintsRequest struct {
+	Critical bool
+	Flags    int
+}
+
+// NewControlPolicyHintsRequest makes Active Directory (Windows Server 2012 R2
+// and later) enforce the password history and complexity of the domain
+// password policy when an administrator resets unicodePwd. The control is
+// critical, so a server not supporting it rejects the reset instead of
+// skipping the policy checks.
+func NewControlPolicyHintsRequest() *ControlPolicyHintsRequest {
+	return &ControlPolicyHintsRequest{Critical: true, Flags: PolicyHintsEnforce}
+}
+
+func (c *ControlPolicyHintsRequest) OID() ControlType {
+	return ControlTypePolicyHintsRequest
+}
+
+func (c *ControlPolicyHintsRequest) Criticality() bool {
+	return c.Critical
+}
+
+func (c *ControlPolicyHintsRequest) Encode() (*ber.Packet, error) {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(ControlTypePolicyHintsRequest), fmt.Sprintf("Control Type (%v)", ControlTypePolicyHintsRequest)))
+	if c.Critical {
+		p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Critical, "Criticality"))
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (PolicyHints)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "PolicyHintsRequestValue")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, c.Flags, "Flags"))
+	value.AppendChild(seq)
+	p.AppendChild(value)
+	return p, nil
+}
+
+func (c *ControlPolicyHintsRequest) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, Flags: %#x",
+		ControlTypePolicyHintsRequest.String(),
+		string(ControlTypePolicyHintsRequest),
+		c.Critical,
+		c.Flags,
+	)
+}
+
+/*****************************/
+/* GetEffectiveRightsRequest */
+/*****************************/
+
+/*
+GetEffectiveRightsRequest [https://directory.fedoraproject.org/docs/389ds/design/get-effective-rights.html]
+
+controlValue ::= OCTET STRING -- authzId of the subject, "dn:" DN
+*/
+type ControlGetEffectiveRightsRequest struct {
+	Critical bool
+	// authzId "dn:<DN>" of the user whose rights are returned, empty for the
+	// bound user
+	AuthzID string
+}
+
+// NewControlGetEffectiveRightsRequest makes 389 Directory Server and Oracle
+// Directory Server return the rights authzID has on the returned entries in
+// the entryLevelRights and attributeLevelRights attributes, see
+// ParseEffectiveRights. The attributes have to be requested, e.g. with the
+// attributes "*" and "aclRights".
+func NewControlGetEffectiveRightsRequest(authzID string) *ControlGetEffectiveRightsRequest {
+	return &ControlGetEffectiveRightsRequest{AuthzID: authzID}
+}
+
+func (c *ControlGetEffectiveRightsRequest) OID() ControlType {
+	return ControlTypeGetEffectiveRightsRequest
+}
+
+func (c *ControlGetEffectiveRightsRequest) Criticality() bool {
+	return c.Critical
+}
+
+func (c *ControlGetEffectiveRightsRequest) Encode() (*ber.Packet, error) {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(ControlTypeGetEffectiveRightsRequest), fmt.Sprintf("Control Type (%v)", ControlTypeGetEffectiveRightsRequest)))
+	if c.Critical {
+		p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Critical, "Criticality"))
+	}
+	if len(c.AuthzID) > 0 {
+		value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (GetEffectiveRights)")
+		value.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, c.AuthzID, "authzId"))
+		p.AppendChild(value)
+	}
+	return p, nil
+}
+
+func (c *ControlGetEffectiveRightsRequest) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, AuthzID: %s",
+		ControlTypeGetEffectiveRightsRequest.String(),
+		string(ControlTypeGetEffectiveRightsRequest),
+		c.Critical,
+		c.AuthzID,
+	)
+}
+
+/*******************/
+/* GetStatsRequest */
+/*******************/
+
+// Flags of the GetStats control
+const (
+	GetStatsNormal       = 0
+	GetStatsStats        = 1
+	GetStatsOnlyOptimize = 2
+	// return the statistics as pairs of their names and values, Windows
+	// Server 2008 and later
+	GetStatsExtendedFormat = 4
+)
+
+/*
+LDAP_SERVER_GET_STATS [https://msdn.microsoft.com/en-us/library/cc223350.aspx]
+
+StatsRequestValue ::= SEQUENCE {
+     flags    INTEGER }
+*/
+type ControlGetStatsRequest struct {
+	Critical bool
+	Flags    int
+}
+
+// NewControlGetStatsRequest makes Active Directory return a ControlGetStats
+// with the statistics of the search, with GetStatsOnlyOptimize without
+// running it. Active Directory only returns the statistics to members of the
+// Administrators group.
+func NewControlGetStatsRequest(flags int) *ControlGetStatsRequest {
+	return &ControlGetStatsRequest{Flags: flags}
+}
+
+func (c *ControlGetStatsRequest) OID() ControlType {
+	return ControlTypeGetStats
+}
+
+func (c *ControlGetStatsRequest) Criticality() bool {
+	return c.Critical
+}
+
+func (c *ControlGetStatsRequest) Encode() (*ber.Packet, error) {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(ControlTypeGetStats), fmt.Sprintf("Control Type (%v)", ControlTypeGetStats)))
+	if c.Critical {
+		p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Critical, "Criticality"))
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (GetStats)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "StatsRequestValue")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, c.Flags, "flags"))
+	value.AppendChild(seq)
+	p.AppendChild(value)
+	return p, nil
+}
+
+func (c *ControlGetStatsRequest) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, Flags: %#x",
+		ControlTypeGetStats.String(),
+		string(ControlTypeGetStats),
+		c.Critical,
+		c.Flags,
+	)
+}
+
+/************************/
+/* SearchOptionsRequest */
+/************************/
+
+// Flags of the SearchOptions control
+const (
+	// SERVER_SEARCH_FLAG_DOMAIN_SCOPE, like NewControlDomainScopeRequest
+	SearchOptionsDomainScope = 0x1
+	// SERVER_SEARCH_FLAG_PHANTOM_ROOT, a subtree search with an empty base DN
+	// covers all naming contexts of the server
+	SearchOptionsPhantomRoot = 0x2
+)
+
+/*
+LDAP_SERVER_SEARCH_OPTIONS [https://msdn.microsoft.com/en-us/library/cc223324.aspx]
+
+SearchOptionsRequestValue ::= SEQUENCE {
+     Flags    INTEGER }
+*/
+type ControlSearchOptionsRequest struct {
+	Critical bool
+	Flags    int
+}
+
+// NewControlSearchOptionsRequest sets the SearchOptions* flags of an Active
+// Directory search, e.g. SearchOptionsPhantomRoot to search all domains of a
+// global catalog from the empty base DN.
+func NewControlSearchOptionsRequest(flags int) *ControlSearchOptionsRequest {
+	return &ControlSearchOptionsRequest{Flags: flags}
+}
+
+func (c *ControlSearchOptionsRequest) OID() ControlType {
+	return ControlTypeSearchOptionsRequest
+}
+
+func (c *ControlSearchOptionsRequest) Criticality() bool {
+	return c.Critical
+}
+
+func (c *ControlSearchOptionsRequest) Encode() (*ber.Packet, error) {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(ControlTypeSearchOptionsRequest), fmt.Sprintf("Control Type (%v)", ControlTypeSearchOptionsRequest)))
+	if c.Critical {
+		p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Critical, "Criticality"))
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (SearchOptions)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "SearchOptionsRequestValue")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, c.Flags, "Flags"))
+	value.AppendChild(seq)
+	p.AppendChild(value)
+	return p, nil
+}
+
+func (c *ControlSearchOptionsRequest) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, Flags: %#x",
+		ControlTypeSearchOptionsRequest.String(),
+		string(ControlTypeSearchOptionsRequest),
+		c.Critical,
+		c.Flags,
+	)
+}
+
+/*********************/
+/* VerifyNameRequest */
+/*********************/
+
+/*
+LDAP_SERVER_VERIFY_NAME [https://msdn.microsoft.com/en-us/library/cc223328.aspx]
+
+VerifyNameRequestValue ::= SEQUENCE {
+     Flags       INTEGER,
+     ServerName  OCTET STRING }  -- UTF-16LE
+*/
+type ControlVerifyNameRequest struct {
+	Critical bool
+	// DNS name of the global catalog server
+	ServerName string
+}
+
+// NewControlVerifyNameRequest makes Active Directory verify the DNs of other
+// domains written by an add or modify, e.g. member values, against the global
+// catalog serverName instead of one it chooses itself.
+func NewControlVerifyNameRequest(serverName string) *ControlVerifyNameRequest {
+	return &ControlVerifyNameRequest{ServerName: serverName}
+}
+
+func (c *ControlVerifyNameRequest) OID() ControlType {
+	return ControlTypeVerifyNameRequest
+}
+
+func (c *ControlVerifyNameRequest) Criticality() bool {
+	return c.Critical
+}
+
+func (c *ControlVerifyNameRequest) Encode() (*ber.Packet, error) {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(ControlTypeVerifyNameRequest), fmt.Sprintf("Control Type (%v)", ControlTypeVerifyNameRequest)))
+	if c.Critical {
+		p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, c.Critical, "Criticality"))
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value (VerifyName)")
+	seq := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "VerifyNameRequestValue")
+	seq.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 0, "Flags"))
+	seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, encodeUTF16LE(c.ServerName), "ServerName"))
+	value.AppendChild(seq)
+	p.AppendChild(value)
+	return p, nil
+}
+
+func (c *ControlVerifyNameRequest) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, ServerName: %s",
+		ControlTypeVerifyNameRequest.String(),
+		string(ControlTypeVerifyNameRequest),
+		c.Critical,
+		c.ServerName,
+	)
+}
+
+/***********************************/
+/*      RESPONSE CONTROLS          */
+/***********************************/
+
+/**************************/
+/* ServerSideSortResponse */
+/**************************/
+
+type ControlServerSideSortResponse struct {
+	AttributeName string // Optional
+	Critical      bool
+	Err           error
+}
+
+//SortResult ::= SEQUENCE {
+//   sortResult  ENUMERATED {
+//       success                   (0), -- results are sorted
+//       operationsError           (1), -- server internal failure
+//       timeLimitExceeded         (3), -- timelimit reached before
+//                                      -- sorting was completed
+//       strongAuthRequired        (8), -- refused to return sorted
+//                                      -- results via insecure
+//                                      -- protocol
+//       adminLimitExceeded       (11), -- too many matching entries
+//                                      -- for the server to sort
+//       noSuchAttribute          (16), -- unrecognized attribute
+//                                      -- type in sort key
+//       inappropriateMatching    (18), -- unrecognized or
+//                                      -- inappropriate matching
+//                                      -- rule in sort key
+//       insufficientAccessRights (50), -- refused to return sorted
+//                                      -- results to this client
+//       busy                     (51), -- too busy to process
+//       unwillingToPerform       (53), -- unable to sort
+//       other                    (80)
+//       },
+//   attributeType [0] AttributeDescription OPTIONAL }
+func NewControlServerSideSortResponse(p *ber.Packet) (Control, error) {
+	c := new(ControlServerSideSortResponse)
+	_, criticality, value := decodeControlTypeAndCrit(p)
+	c.Critical = criticality
+
+	if value.Value != nil || len(value.Children) == 0 {
+		sortResult := ber.DecodePacket(value.Data.Bytes())
+		if sortResult == nil {
+			return c, newError(ErrorDecoding, "Couldn't decode ServerSideSortResponse.")
+		}
+		value.Data.Truncate(0)
+		value.Value = nil
+		value.AppendChild(sortResult)
+	}
+
+	value = value.Children[0]
+	if len(value.Children) == 0 {
+		return c, newError(ErrorDecoding, "Invalid ServerSideSortResponse.")
+	}
+	value.Description = "ServerSideSortResponse Control Value"
+
+	value.Children[0].Description = "SortResult"
+	errNum, ok := packetInt64(value.Children[0])
+	if !ok {
+		return c, NewValueMismatchError(value.Children[0].Value)
+	}
+	if ResultCode(errNum) != ResultSuccess {
+		c.Err = newError(ResultCode(errNum), "")
+	}
+
+	if len(value.Children) == 2 {
+		value.Children[1].Description = "Attribute Name"
+		c.AttributeName = string(value.Children[1].Data.Bytes())
+		value.Children[1].Value = c.AttributeName
+	}
+	return c, nil
+}
+
+func (c *ControlServerSideSortResponse) Encode() (p *ber.Packet, err error) {
+	return nil, newError(ErrorEncoding, "Encode of Control unsupported.")
+}
+
+func (c *ControlServerSideSortResponse) OID() ControlType {
+	return ControlTypeServerSideSortResponse
+}
+
+func (c *ControlServerSideSortResponse) Criticality() bool {
+	return c.Critical
+}
+
+func (c *ControlServerSideSortResponse) String() string {
+	err, ok := c.Err.(*Error)
+	if !ok {
+		err = &Error{}
+	}
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, AttributeName: %s, ErrorValue: %d",
+		ControlTypeServerSideSortResponse.String(),
+		string(ControlTypeServerSideSortResponse),
+		c.Critical,
+		c.AttributeName,
+		err.ResultCode,
+	)
+}
+
+/***************/
+/* VlvResponse */
+/***************/
+
+type ControlVlvResponse struct {
+	Critical       bool
+	TargetPosition uint64
+	ContentCount   uint64
+	Err            error // VirtualListViewResult
+	ContextID      string
+}
+
+/*
+ VirtualListViewResponse ::= SEQUENCE {
+       targetPosition    INTEGER (0 .. maxInt),
+       contentCount     INTEGER (0 .. maxInt),
+       virtualListViewResult ENUMERATED {
+            success (0),
+            operationsError (1),
+            protocolError (3),
+            unwillingToPerform (53),
+            insufficientAccessRights (50),
+            timeLimitExceeded (3),
+            adminLimitExceeded (11),
+            innapropriateMatching (18),
+            sortControlMissing (60),
+            offsetRangeError (61),
+            other(80),
+            ... },
+       contextID     OCTET STRING OPTIONAL }
+*/
+func NewControlVlvResponse(p *ber.Packet) (Control, error) {
+	c := new(ControlVlvResponse)
+	_, criticality, value := decodeControlTypeAndCrit(p)
+	c.Critical = criticality
+
+	if value.Value != nil || len(value.Children) == 0 {
+		vlvResult := ber.DecodePacket(value.Data.Bytes())
+		if vlvResult == nil {
+			return c, newError(ErrorDecoding, "Couldn't decode VirtualListViewResponse.")
+		}
+		value.Data.Truncate(0)
+		value.Value = nil
+		value.AppendChild(vlvResult)
+	}
+
+	value = value.Children[0]
+	if len(value.Children) < 3 {
+		return c, newError(ErrorDecoding, "Invalid VirtualListViewResponse.")
+	}
+	value.Description = "VlvResponse Control Value"
+
+	value.Children[0].Description = "TargetPosition"
+	value.Children[1].Description = "ContentCount"
+	value.Children[2].Description = "VirtualListViewResult/Err"
+
+	targetPosition, ok := packetInt64(value.Children[0])
+	if !ok {
+		return c, NewValueMismatchError(value.Children[0].Value)
+	}
+	c.TargetPosition = uint64(targetPosition)
+	contentCount, ok := packetInt64(value.Children[1])
+	if !ok {
+		return c, NewValueMismatchError(value.Children[1].Value)
+	}
+	c.ContentCount = uint64(contentCount)
+
+	errNum, ok := packetInt64(value.Children[2])
+	if !ok {
+		return c, NewValueMismatchError(value.Children[2].Value)
+	}
+	if ResultCode(errNum) != ResultSuccess {
+		c.Err = newError(ResultCode(errNum), "")
+	}
+
+	if len(value.Children) == 4 {
+		value.Children[3].Description = "ContextID"
+		c.ContextID = string(value.Children[3].Data.Bytes())
+	}
+
+	return c, nil
+}
+
+func (c *ControlVlvResponse) Encode() (p *ber.Packet, err error) {
+	return nil, newError(ErrorEncoding, "Encode of Control unsupported.")
+}
+
+func (c *ControlVlvResponse) OID() ControlType {
+	return ControlTypeVlvResponse
+}
+
+func (c *ControlVlvResponse) Criticality() bool {
+	return c.Critical
+}
+
+func (c *ControlVlvResponse) String() string {
+	err, ok := c.Err.(*Error)
 	if !ok {
 		err = &Error{}
 	}
 	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, TargetPosition: %d, ContentCount: %d, ErrorValue: %d, ContextID: %s",
 		ControlTypeVlvResponse,
 		string(ControlTypeVlvResponse),
-		c.Criticality,
+		c.Critical,
 		c.TargetPosition,
 		c.ContentCount,
 		err.ResultCode,
 		c.ContextID,
 	)
 }
+
+/**************************/
+/* PasswordPolicyResponse */
+/**************************/
+
+/*
+PasswordPolicyResponseValue ::= SEQUENCE {
+     warning [0] CHOICE {
+          timeBeforeExpiration [0] INTEGER (0 .. maxInt),
+          graceAuthNsRemaining [1] INTEGER (0 .. maxInt) } OPTIONAL,
+     error   [1] ENUMERATED {
+          passwordExpired             (0),
+          accountLocked               (1),
+          changeAfterReset            (2),
+          passwordModNotAllowed       (3),
+          mustSupplyOldPassword       (4),
+          insufficientPasswordQuality (5),
+          passwordTooShort            (6),
+          passwordTooYoung            (7),
+          passwordInHistory           (8) } OPTIONAL }
+*/
+
+const (
+	PasswordPolicyExpired                     = 0
+	PasswordPolicyAccountLocked               = 1
+	PasswordPolicyChangeAfterReset            = 2
+	PasswordPolicyModNotAllowed               = 3
+	PasswordPolicyMustSupplyOldPassword       = 4
+	PasswordPolicyInsufficientPasswordQuality = 5
+	PasswordPolicyTooShort                    = 6
+	PasswordPolicyTooYoung                    = 7
+	PasswordPolicyInHistory                   = 8
+)
+
+var PasswordPolicyErrorMap = map[int64]string{
+	PasswordPolicyExpired:                     "passwordExpired",
+	PasswordPolicyAccountLocked:               "accountLocked",
+	PasswordPolicyChangeAfterReset:            "changeAfterReset",
+	PasswordPolicyModNotAllowed:               "passwordModNotAllowed",
+	PasswordPolicyMustSupplyOldPassword:       "mustSupplyOldPassword",
+	PasswordPolicyInsufficientPasswordQuality: "insufficientPasswordQuality",
+	PasswordPolicyTooShort:                    "passwordTooShort",
+	PasswordPolicyTooYoung:                    "passwordTooYoung",
+	PasswordPolicyInHistory:                   "passwordInHistory",
+}
+
+// ControlPasswordPolicyResponse, the fields are -1 if they weren't returned
+type ControlPasswordPolicyResponse struct {
+	Critical             bool
+	TimeBeforeExpiration int64
+	GraceAuthNsRemaining int64
+	Error                int64
+}
+
+func NewControlPasswordPolicyResponse(p *ber.Packet) (Control, error) {
+	c := &ControlPasswordPolicyResponse{TimeBeforeExpiration: -1, GraceAuthNsRemaining: -1, Error: -1}
+	_, criticality, value := decodeControlTypeAndCrit(p)
+	c.Critical = criticality
+
+	if value.Data.Len() == 0 {
+		return c, nil
+	}
+	policy := ber.DecodePacket(value.Data.Bytes())
+	if policy == nil {
+		return c, newError(ErrorDecoding, "Couldn't decode PasswordPolicyResponseValue.")
+	}
+	for _, child := range policy.Children {
+		switch child.Tag {
+		case 0:
+			if len(child.Children) == 0 {
+				// some servers send the warning CHOICE without the explicit [0]
+				child = ber.DecodePacket(child.Data.Bytes())
+				if child == nil {
+					return c, newError(ErrorDecoding, "Couldn't decode password policy warning.")
+				}
+			} else {
+				child = child.Children[0]
+			}
+			warning, ok := packetInt64(child)
+			if !ok {
+				return c, NewValueMismatchError(child.Value)
+			}
+			if child.Tag == 0 {
+				c.TimeBeforeExpiration = warning
+			} else {
+				c.GraceAuthNsRemaining = warning
+			}
+		case 1:
+			errNum, ok := packetInt64(child)
+			if !ok {
+				return c, NewValueMismatchError(child.Value)
+			}
+			c.Error = errNum
+		}
+	}
+	return c, nil
+}
+
+func (c *ControlPasswordPolicyResponse) Encode() (p *ber.Packet, err error) {
+	return nil, newError(ErrorEncoding, "Encode of Control unsupported.")
+}
+
+func (c *ControlPasswordPolicyResponse) OID() ControlType {
+	return ControlTypePasswordPolicy
+}
+
+func (c *ControlPasswordPolicyResponse) Criticality() bool {
+	return c.Critical
+}
+
+// ErrorString returns the name of the password policy error, empty if there is none
+func (c *ControlPasswordPolicyResponse) ErrorString() string {
+	return PasswordPolicyErrorMap[c.Error]
+}
+
+func (c *ControlPasswordPolicyResponse) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, TimeBeforeExpiration: %d, GraceAuthNsRemaining: %d, Error: %d (%s)",
+		ControlTypePasswordPolicy.String(),
+		string(ControlTypePasswordPolicy),
+		c.Critical,
+		c.TimeBeforeExpiration,
+		c.GraceAuthNsRemaining,
+		c.Error,
+		c.ErrorString(),
+	)
+}
+
+/*******************/
+/* AuthzIdResponse */
+/*******************/
+
+// ControlAuthzIdResponse carries the authorization identity the bind resolved
+// to, e.g. "dn:cn=bob,o=bigcorp", empty for the anonymous identity.
+type ControlAuthzIdResponse struct {
+	Critical bool
+	AuthzId  string
+}
+
+func NewControlAuthzIdResponse(p *ber.Packet) (Control, error) {
+	c := new(ControlAuthzIdResponse)
+	_, criticality, value := decodeControlTypeAndCrit(p)
+	c.Critical = criticality
+	c.AuthzId = packetString(value)
+	return c, nil
+}
+
+func (c *ControlAuthzIdResponse) Encode() (p *ber.Packet, err error) {
+	return nil, newError(ErrorEncoding, "Encode of Control unsupported.")
+}
+
+func (c *ControlAuthzIdResponse) OID() ControlType {
+	return ControlTypeAuthzIdResponse
+}
+
+func (c *ControlAuthzIdResponse) Criticality() bool {
+	return c.Critical
+}
+
+func (c *ControlAuthzIdResponse) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, AuthzId: %s",
+		ControlTypeAuthzIdResponse.String(),
+		string(ControlTypeAuthzIdResponse),
+		c.Critical,
+		c.AuthzId,
+	)
+}
+
+/*******************/
+/* DirSyncResponse */
+/*******************/
+
+type ControlDirSyncResponse struct {
+	Critical bool
+	// More changes are available, search again with Cookie
+	MoreResults bool
+	Cookie      []byte
+}
+
+/*
+DirSyncResponseValue ::= SEQUENCE {
+     MoreResults     INTEGER
+     unused          INTEGER
+     CookieServer    OCTET STRING }
+*/
+func NewControlDirSyncResponse(p *ber.Packet) (Control, error) {
+	c := new(ControlDirSyncResponse)
+	_, criticality, value := decodeControlTypeAndCrit(p)
+	c.Critical = criticality
+
+	dirSync := ber.DecodePacket(value.Data.Bytes())
+	if dirSync == nil || len(dirSync.Children) != 3 {
+		return c, newError(ErrorDecoding, "Couldn't decode DirSyncResponseValue.")
+	}
+	moreResults, ok := packetInt64(dirSync.Children[0])
+	if !ok {
+		return c, NewValueMismatchError(dirSync.Children[0].Value)
+	}
+	c.MoreResults = moreResults != 0
+	c.Cookie = dirSync.Children[2].Data.Bytes()
+	return c, nil
+}
+
+func (c *ControlDirSyncResponse) Encode() (p *ber.Packet, err error) {
+	return nil, newError(ErrorEncoding, "Encode of Control unsupported.")
+}
+
+func (c *ControlDirSyncResponse) OID() ControlType {
+	return ControlTypeDirSync
+}
+
+func (c *ControlDirSyncResponse) Criticality() bool {
+	return c.Critical
+}
+
+func (c *ControlDirSyncResponse) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, MoreResults: %t, Cookie: %q",
+		ControlTypeDirSync.String(),
+		string(ControlTypeDirSync),
+		c.Critical,
+		c.MoreResults,
+		c.Cookie,
+	)
+}
+
+/***************************/
+/* EntryChangeNotification */
+/***************************/
+
+type ControlEntryChangeNotification struct {
+	Critical bool
+	// ChangeType* of the change
+	ChangeType int
+	// DN of the entry before a ModifyDN
+	PreviousDN string
+	// -1 if not returned
+	ChangeNumber int64
+}
+
+/*
+EntryChangeNotification ::= SEQUENCE {
+     changeType ENUMERATED {
+             add             (1),
+             delete          (2),
+             modify          (4),
+             modDN           (8)
+     },
+     previousDN   LDAPDN OPTIONAL,     -- modifyDN ops. only
+     changeNumber INTEGER OPTIONAL     -- if supported
+}
+*/
+func NewControlEntryChangeNotification(p *ber.Packet) (Control, error) {
+	c := &ControlEntryChangeNotification{ChangeNumber: -1}
+	_, criticality, value := decodeControlTypeAndCrit(p)
+	c.Critical = criticality
+
+	notification := ber.DecodePacket(value.Data.Bytes())
+	if notification == nil || len(notification.Children) == 0 {
+		return c, newError(ErrorDecoding, "Couldn't decode EntryChangeNotification.")
+	}
+	changeType, ok := packetInt64(notification.Children[0])
+	if !ok {
+		return c, NewValueMismatchError(notification.Children[0].Value)
+	}
+	c.ChangeType = int(changeType)
+	for _, child := range notification.Children[1:] {
+		switch child.Tag {
+		case ber.TagOctetString:
+			c.PreviousDN = packetString(child)
+		case ber.TagInteger:
+			if changeNumber, ok := packetInt64(child); ok {
+				c.ChangeNumber = changeNumber
+			}
+		}
+	}
+	return c, nil
+}
+
+func (c *ControlEntryChangeNotification) Encode() (p *ber.Packet, err error) {
+	return nil, newError(ErrorEncoding, "Encode of Control unsupported.")
+}
+
+func (c *ControlEntryChangeNotification) OID() ControlType {
+	return ControlTypeEntryChangeNotification
+}
+
+func (c *ControlEntryChangeNotification) Criticality() bool {
+	return c.Critical
+}
+
+func (c *ControlEntryChangeNotification) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, ChangeType: %d, PreviousDN: %s, ChangeNumber: %d",
+		ControlTypeEntryChangeNotification.String(),
+		string(ControlTypeEntryChangeNotification),
+		c.Critical,
+		c.ChangeType,
+		c.PreviousDN,
+		c.ChangeNumber,
+	)
+}
+
+/*************/
+/* SyncState */
+/*************/
+
+// States of the SyncState control
+const (
+	SyncStatePresent = 0
+	SyncStateAdd     = 1
+	SyncStateModify  = 2
+	SyncStateDelete  = 3
+)
+
+type ControlSyncState struct {
+	Critical bool
+	// SyncState*
+	State int
+	// entryUUID of the entry, formatted like 6ba7b810-9dad-11d1-80b4-00c04fd430c8
+	EntryUUID string
+	Cookie    []byte
+}
+
+/*
+syncStateValue ::= SEQUENCE {
+     state ENUMERATED {
+         present (0),
+         add (1),
+         modify (2),
+         delete (3)
+     },
+     entryUUID syncUUID,
+     cookie    syncCookie OPTIONAL
+}
+*/
+func NewControlSyncState(p *ber.Packet) (Control, error) {
+	c := new(ControlSyncState)
+	_, criticality, value := decodeControlTypeAndCrit(p)
+	c.Critical = criticality
+
+	state := ber.DecodePacket(value.Data.Bytes())
+	if state == nil || len(state.Children) < 2 {
+		return c, newError(ErrorDecoding, "Couldn't decode SyncState.")
+	}
+	stateValue, ok := packetInt64(state.Children[0])
+	if !ok {
+		return c, NewValueMismatchError(state.Children[0].Value)
+	}
+	c.State = int(stateValue)
+	c.EntryUUID = formatSyncUUID(state.Children[1].Data.Bytes())
+	if len(state.Children) > 2 {
+		c.Cookie = state.Children[2].Data.Bytes()
+	}
+	return c, nil
+}
+
+func (c *ControlSyncState) Encode() (p *ber.Packet, err error) {
+	return nil, newError(ErrorEncoding, "Encode of Control unsupported.")
+}
+
+func (c *ControlSyncState) OID() ControlType {
+	return ControlTypeSyncState
+}
+
+func (c *ControlSyncState) Criticality() bool {
+	return c.Critical
+}
+
+func (c *ControlSyncState) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, State: %d, EntryUUID: %s, Cookie: %q",
+		ControlTypeSyncState.String(),
+		string(ControlTypeSyncState),
+		c.Critical,
+		c.State,
+		c.EntryUUID,
+		c.Cookie,
+	)
+}
+
+/************/
+/* SyncDone */
+/************/
+
+type ControlSyncDone struct {
+	Critical bool
+	Cookie   []byte
+	// Entries not returned during the refresh were deleted, otherwise the
+	// entries not returned as present were deleted
+	RefreshDeletes bool
+}
+
+/*
+syncDoneValue ::= SEQUENCE {
+     cookie          syncCookie OPTIONAL,
+     refreshDeletes  BOOLEAN DEFAULT FALSE
+}
+*/
+func NewControlSyncDone(p *ber.Packet) (Control, error) {
+	c := new(ControlSyncDone)
+	_, criticality, value := decodeControlTypeAndCrit(p)
+	c.Critical = criticality
+
+	done := ber.DecodePacket(value.Data.Bytes())
+	if done == nil {
+		return c, newError(ErrorDecoding, "Couldn't decode SyncDone.")
+	}
+	for _, child := range done.Children {
+		switch child.Tag {
+		case ber.TagOctetString:
+			c.Cookie = child.Data.Bytes()
+		case ber.TagBoolean:
+			c.RefreshDeletes, _ = child.Value.(bool)
+		}
+	}
+	return c, nil
+}
+
+func (c *ControlSyncDone) Encode() (p *ber.Packet, err error) {
+	return nil, newError(ErrorEncoding, "Encode of Control unsupported.")
+}
+
+func (c *ControlSyncDone) OID() ControlType {
+	return ControlTypeSyncDone
+}
+
+func (c *ControlSyncDone) Criticality() bool {
+	return c.Critical
+}
+
+func (c *ControlSyncDone) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, Cookie: %q, RefreshDeletes: %t",
+		ControlTypeSyncDone.String(),
+		string(ControlTypeSyncDone),
+		c.Critical,
+		c.Cookie,
+		c.RefreshDeletes,
+	)
+}
+
+/********************/
+/* AccountUsability */
+/********************/
+
+type ControlAccountUsability struct {
+	Critical bool
+	// The account can be used, the other fields tell why not
+	Available bool
+	// -1 if the password doesn't expire
+	SecondsBeforeExpiration int64
+	Inactive                bool
+	// The password was reset and has to be changed
+	Reset   bool
+	Expired bool
+	// Grace logins left with the expired password, -1 if not returned
+	RemainingGrace int64
+	// -1 if the account isn't locked or not unlocked automatically
+	SecondsBeforeUnlock int64
+}
+
+/*
+ACCOUNT_USABLE_RESPONSE ::= CHOICE {
+     is_available           [0] INTEGER, -- Seconds before expiration --
+     is_not_available       [1] MORE_INFO }
+
+MORE_INFO ::= SEQUENCE {
+     inactive               [0] BOOLEAN DEFAULT FALSE,
+     reset                  [1] BOOLEAN DEFAULT FALSE,
+     expired                [2] BOOLEAN DEFAULT FALSE,
+     remaining_grace        [3] INTEGER OPTIONAL,
+     seconds_before_unlock  [4] INTEGER OPTIONAL }
+*/
+func NewControlAccountUsability(p *ber.Packet) (Control, error) {
+	c := &ControlAccountUsability{SecondsBeforeExpiration: -1, RemainingGrace: -1, SecondsBeforeUnlock: -1}
+	_, criticality, value := decodeControlTypeAndCrit(p)
+	c.Critical = criticality
+
+	usability := ber.DecodePacket(value.Data.Bytes())
+	if usability == nil || usability.ClassType != ber.ClassContext {
+		return c, newError(ErrorDecoding, "Couldn't decode AccountUsability.")
+	}
+	switch usability.Tag {
+	case 0:
+		c.Available = true
+		seconds, ok := packetInt64(usability)
+		if !ok {
+			return c, NewValueMismatchError(usability.Value)
+		}
+		c.SecondsBeforeExpiration = seconds
+	case 1:
+		for _, child := range usability.Children {
+			switch child.Tag {
+			case 0:
+				c.Inactive = packetBool(child)
+			case 1:
+				c.Reset = packetBool(child)
+			case 2:
+				c.Expired = packetBool(child)
+			case 3:
+				if grace, ok := packetInt64(child); ok {
+					c.RemainingGrace = grace
+				}
+			case 4:
+				if seconds, ok := packetInt64(child); ok {
+					c.SecondsBeforeUnlock = seconds
+				}
+			}
+		}
+	default:
+		return c, newError(ErrorDecoding, "Invalid AccountUsability.")
+	}
+	return c, nil
+}
+
+func (c *ControlAccountUsability) Encode() (p *ber.Packet, err error) {
+	return nil, newError(ErrorEncoding, "Encode of Control unsupported.")
+}
+
+func (c *ControlAccountUsability) OID() ControlType {
+	return ControlTypeAccountUsability
+}
+
+func (c *ControlAccountUsability) Criticality() bool {
+	return c.Critical
+}
+
+func (c *ControlAccountUsability) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, Available: %t, SecondsBeforeExpiration: %d, Inactive: %t, Reset: %t, Expired: %t, RemainingGrace: %d, SecondsBeforeUnlock: %d",
+		ControlTypeAccountUsability.String(),
+		string(ControlTypeAccountUsability),
+		c.Critical,
+		c.Available,
+		c.SecondsBeforeExpiration,
+		c.Inactive,
+		c.Reset,
+		c.Expired,
+		c.RemainingGrace,
+		c.SecondsBeforeUnlock,
+	)
+}
+
+/************/
+/* GetStats */
+/************/
+
+type ControlGetStats struct {
+	Critical    bool
+	ThreadCount int64
+	// Milliseconds spent processing the search
+	CallTime        int64
+	EntriesReturned int64
+	EntriesVisited  int64
+	// Filter as optimized by the server
+	Filter string
+	// Indexes used for the search, e.g. "idx_cn:12:N"
+	Index string
+	// Database statistics returned since Windows Server 2003
+	PagesReferenced int64
+	PagesRead       int64
+	PagesPreread    int64
+	PagesDirtied    int64
+	PagesRedirtied  int64
+	LogRecordCount  int64
+	LogRecordBytes  int64
+}
+
+/*
+StatsResponseValue ::= SEQUENCE {
+     -- pairs of the tag of a statistic and its value
+     threadCountTag      INTEGER (1),
+     threadCount         INTEGER,
+     callTimeTag         INTEGER (2),
+     callTime            INTEGER,
+     entriesReturnedTag  INTEGER (3),
+     entriesReturned     INTEGER,
+     entriesVisitedTag   INTEGER (4),
+     entriesVisited      INTEGER,
+     filterTag           INTEGER (5),
+     filter              OCTET STRING,
+     indexTag            INTEGER (6),
+     index               OCTET STRING,
+     pagesReferencedTag  INTEGER (7),
+     ...
+     logRecordBytesTag   INTEGER (13),
+     logRecordBytes      INTEGER }
+
+With GetStatsExtendedFormat the tags are replaced by the names of the
+statistics, e.g. "threadCount", and statistics without a field are skipped.
+*/
+// getStatsTags are the tags of the statistics named in the extended format.
+var getStatsTags = map[string]int64{
+	"threadcount":     1,
+	"calltime":        2,
+	"entriesreturned": 3,
+	"entriesvisited":  4,
+	"filter":          5,
+	"index":           6,
+	"pagesreferenced": 7,
+	"pagesread":       8,
+	"pagespreread":    9,
+	"pagesdirtied":    10,
+	"pagesredirtied":  11,
+	"logrecordcount":  12,
+	"logrecordbytes":  13,
+}
+
+func NewControlGetStats(p *ber.Packet) (Control, error) {
+	c := new(ControlGetStats)
+	_, criticality, value := decodeControlTypeAndCrit(p)
+	c.Critical = criticality
+
+	stats := ber.DecodePacket(value.Data.Bytes())
+	if stats == nil {
+		return c, newError(ErrorDecoding, "Couldn't decode StatsResponseValue.")
+	}
+	for i := 0; i+1 < len(stats.Children); i += 2 {
+		var tag int64
+		if key := stats.Children[i]; key.Tag == ber.TagOctetString {
+			tag = getStatsTags[strings.ToLower(packetString(key))]
+		} else if n, ok := packetInt64(key); ok {
+			tag = n
+		} else {
+			return c, NewValueMismatchError(key.Value)
+		}
+		statistic := stats.Children[i+1]
+		if tag == 5 || tag == 6 {
+			if tag == 5 {
+				c.Filter = packetString(statistic)
+			} else {
+				c.Index = packetString(statistic)
+			}
+			continue
+		}
+		// statistics of newer servers may not be integers
+		n, _ := packetInt64(statistic)
+		switch tag {
+		case 1:
+			c.ThreadCount = n
+		case 2:
+			c.CallTime = n
+		case 3:
+			c.EntriesReturned = n
+		case 4:
+			c.EntriesVisited = n
+		case 7:
+			c.PagesReferenced = n
+		case 8:
+			c.PagesRead = n
+		case 9:
+			c.PagesPreread = n
+		case 10:
+			c.PagesDirtied = n
+		case 11:
+			c.PagesRedirtied = n
+		case 12:
+			c.LogRecordCount = n
+		case 13:
+			c.LogRecordBytes = n
+		}
+	}
+	return c, nil
+}
+
+func (c *ControlGetStats) Encode() (p *ber.Packet, err error) {
+	return nil, newError(ErrorEncoding, "Encode of Control unsupported.")
+}
+
+func (c *ControlGetStats) OID() ControlType {
+	return ControlTypeGetStats
+}
+
+func (c *ControlGetStats) Criticality() bool {
+	return c.Critical
+}
+
+func (c *ControlGetStats) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: %t, CallTime: %d, EntriesReturned: %d, EntriesVisited: %d, Filter: %s, Index: %s",
+		ControlTypeGetStats.String(),
+		string(ControlTypeGetStats),
+		c.Critical,
+		c.CallTime,
+		c.EntriesReturned,
+		c.EntriesVisited,
+		c.Filter,
+		c.Index,
+	)
+}
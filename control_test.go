@@ -0,0 +1,275 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"testing"
+)
+
+func TestControlsWithoutValue(t *testing.T) {
+	controls := []Control{
+		NewControlShowDeletedRequest(true),
+		NewControlShowRecycledRequest(true),
+		NewControlDontUseCopyRequest(),
+		NewControlAccountUsabilityRequest(true),
+		NewControlDomainScopeRequest(true),
+	}
+	for _, control := range controls {
+		p, err := control.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p = ber.DecodePacket(p.Bytes())
+		if len(p.Children) != 2 {
+			t.Errorf("%s: expected control type and criticality only, got %d children", control, len(p.Children))
+			continue
+		}
+		if packetString(p.Children[0]) != string(control.OID()) {
+			t.Errorf("%s: unexpected control type %q", control, packetString(p.Children[0]))
+		}
+		if critical, _ := p.Children[1].Value.(bool); !critical {
+			t.Errorf("%s: control not critical", control)
+		}
+	}
+}
+
+func TestControlSDFlagsRequest(t *testing.T) {
+	p, err := NewControlSDFlagsRequest(SDFlagsOwner | SDFlagsGroup | SDFlagsDacl).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = ber.DecodePacket(p.Bytes())
+	if len(p.Children) != 2 {
+		t.Fatalf("Expected control type and value, got %d children", len(p.Children))
+	}
+	value := ber.DecodePacket(p.Children[1].Data.Bytes())
+	if flags, _ := packetInt64(value.Children[0]); flags != 7 {
+		t.Errorf("Unexpected flags %d", flags)
+	}
+}
+
+func TestControlSearchOptionsRequest(t *testing.T) {
+	control := NewControlSearchOptionsRequest(SearchOptionsPhantomRoot)
+	control.Critical = true
+	p, err := control.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = ber.DecodePacket(p.Bytes())
+	if len(p.Children) != 3 {
+		t.Fatalf("Expected control type, criticality and value, got %d children", len(p.Children))
+	}
+	value := ber.DecodePacket(p.Children[2].Data.Bytes())
+	if flags, _ := packetInt64(value.Children[0]); flags != SearchOptionsPhantomRoot {
+		t.Errorf("Unexpected flags %d", flags)
+	}
+}
+
+func TestControlVerifyNameRequest(t *testing.T) {
+	p, err := NewControlVerifyNameRequest("gc.example.com").Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = ber.DecodePacket(p.Bytes())
+	value := ber.DecodePacket(p.Children[1].Data.Bytes())
+	if len(value.Children) != 2 {
+		t.Fatalf("Expected flags and server name, got %d children", len(value.Children))
+	}
+	if serverName := packetString(value.Children[1]); serverName != "g\x00c\x00.\x00e\x00x\x00a\x00m\x00p\x00l\x00e\x00.\x00c\x00o\x00m\x00" {
+		t.Errorf("Unexpected server name %q", serverName)
+	}
+}
+
+func TestControlSessionTracking(t *testing.T) {
+	control := NewControlSessionTracking("192.0.2.1", "client.example.com", SessionTrackingUsername, "bob")
+	p, err := control.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = ber.DecodePacket(p.Bytes())
+	value := ber.DecodePacket(p.Children[1].Data.Bytes())
+	expected := []string{"192.0.2.1", "client.example.com", SessionTrackingUsername, "bob"}
+	if len(value.Children) != len(expected) {
+		t.Fatalf("Expected %d values, got %d", len(expected), len(value.Children))
+	}
+	for i, child := range value.Children {
+		if packetString(child) != expected[i] {
+			t.Errorf("Value %d: expected %q, got %q", i, expected[i], packetString(child))
+		}
+	}
+}
+
+func TestControlSubentriesRequest(t *testing.T) {
+	for _, visibility := range []bool{true, false} {
+		p, err := NewControlSubentriesRequest(visibility, true).Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p = ber.DecodePacket(p.Bytes())
+		if len(p.Children) != 3 {
+			t.Fatalf("Expected control type, criticality and value, got %d children", len(p.Children))
+		}
+		value := ber.DecodePacket(p.Children[2].Data.Bytes())
+		if v, ok := value.Value.(bool); !ok || v != visibility {
+			t.Errorf("Expected visibility %t, got %v", visibility, value.Value)
+		}
+	}
+}
+
+func TestRegisterControl(t *testing.T) {
+	const controlType = ControlType("1.3.6.1.4.1.99999.1")
+	RegisterControl(controlType, NewControlStringFromPacket)
+	defer func() {
+		controlTypeFnsLock.Lock()
+		delete(controlTypeFns, controlType)
+		controlTypeFnsLock.Unlock()
+	}()
+
+	controls := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
+	controls.AppendChild(mockControl(controlType, ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "proprietary", "value")))
+	decoded, err := decodeControls(ber.DecodePacket(controls.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 1 {
+		t.Fatalf("Expected the registered control, got %d controls", len(decoded))
+	}
+	if c, ok := decoded[0].(*ControlString); !ok || c.OID() != controlType {
+		t.Errorf("Unexpected control %v", decoded[0])
+	}
+}
+
+func TestDecodeControlsError(t *testing.T) {
+	const controlType = ControlType("1.3.6.1.4.1.99999.3")
+	RegisterControl(controlType, func(p *ber.Packet) (Control, error) {
+		return &ControlString{ControlType: controlType}, newError(ErrorDecoding, "partly decoded")
+	})
+	defer func() {
+		controlTypeFnsLock.Lock()
+		delete(controlTypeFns, controlType)
+		controlTypeFnsLock.Unlock()
+	}()
+
+	controls := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
+	controls.AppendChild(mockControl(controlType, nil))
+	decoded, err := decodeControls(ber.DecodePacket(controls.Bytes()))
+	if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ErrorDecoding || decoded != nil {
+		t.Errorf("Expected the decoding error without the partly decoded control, got %v, %v", decoded, err)
+	}
+}
+
+func TestRawControl(t *testing.T) {
+	const controlType = ControlType("1.3.6.1.4.1.99999.2")
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(controlType), "Control Type"))
+	p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, true, "Criticality"))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "opaque", "Control Value"))
+	encoded := p.Bytes()
+
+	control, err := DecodeControl(ber.DecodePacket(encoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, ok := control.(*RawControl)
+	if !ok {
+		t.Fatalf("Expected a RawControl, got %T", control)
+	}
+	if raw.OID() != controlType || !raw.Criticality() || len(raw.Value) == 0 {
+		t.Errorf("Unexpected control %v", raw)
+	}
+
+	reencoded, err := raw.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(reencoded.Bytes()) != string(encoded) {
+		t.Errorf("RawControl not encoded as received: %x != %x", reencoded.Bytes(), encoded)
+	}
+}
+
+func TestControlAccountUsability(t *testing.T) {
+	available := ber.NewInteger(ber.ClassContext, ber.TypePrimitive, 0, 3600, "is_available")
+	control, err := NewControlAccountUsability(ber.DecodePacket(mockControl(ControlTypeAccountUsability, available).Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	usability := control.(*ControlAccountUsability)
+	if !usability.Available || usability.SecondsBeforeExpiration != 3600 {
+		t.Errorf("Unexpected control %s", usability)
+	}
+
+	moreInfo := ber.Encode(ber.ClassContext, ber.TypeConstructed, 1, nil, "is_not_available")
+	moreInfo.AppendChild(ber.NewBoolean(ber.ClassContext, ber.TypePrimitive, 2, true, "expired"))
+	moreInfo.AppendChild(ber.NewInteger(ber.ClassContext, ber.TypePrimitive, 3, 2, "remaining_grace"))
+	control, err = NewControlAccountUsability(ber.DecodePacket(mockControl(ControlTypeAccountUsability, moreInfo).Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	usability = control.(*ControlAccountUsability)
+	if usability.Available || !usability.Expired || usability.Inactive || usability.RemainingGrace != 2 || usability.SecondsBeforeUnlock != -1 {
+		t.Errorf("Unexpected control %s", usability)
+	}
+}
+
+func TestControlGetStats(t *testing.T) {
+	value := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "StatsResponseValue")
+	for _, stat := range []struct {
+		tag   int
+		value interface{}
+	}{{1, 1}, {2, 15}, {3, 2}, {4, 40}, {5, "(&(objectClass=user)(cn=bob))"}, {6, "idx_cn:2:N"}, {8, 3}} {
+		value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, stat.tag, "tag"))
+		switch v := stat.value.(type) {
+		case int:
+			value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, v, "value"))
+		case string:
+			value.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "value"))
+		}
+	}
+	control, err := NewControlGetStats(ber.DecodePacket(mockControl(ControlTypeGetStats, value).Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := control.(*ControlGetStats)
+	if stats.CallTime != 15 || stats.EntriesReturned != 2 || stats.EntriesVisited != 40 || stats.PagesRead != 3 ||
+		stats.Filter != "(&(objectClass=user)(cn=bob))" || stats.Index != "idx_cn:2:N" {
+		t.Errorf("Unexpected statistics %s", stats)
+	}
+
+	// GetStatsExtendedFormat
+	value = ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "StatsResponseValue")
+	for _, stat := range []struct {
+		name  string
+		value interface{}
+	}{{"threadCount", 1}, {"callTime", 15}, {"entriesReturned", 2}, {"filter", "(cn=bob)"}, {"index", "idx_cn:2:N"}, {"pagesRead", 3}, {"unknown", "?"}} {
+		value.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, stat.name, "name"))
+		switch v := stat.value.(type) {
+		case int:
+			value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, v, "value"))
+		case string:
+			value.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "value"))
+		}
+	}
+	if control, err = NewControlGetStats(ber.DecodePacket(mockControl(ControlTypeGetStats, value).Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	stats = control.(*ControlGetStats)
+	if stats.CallTime != 15 || stats.EntriesReturned != 2 || stats.PagesRead != 3 || stats.Filter != "(cn=bob)" || stats.Index != "idx_cn:2:N" {
+		t.Errorf("Unexpected statistics of the extended format %s", stats)
+	}
+}
+
+func TestControlDirSyncRequestMarshalBinary(t *testing.T) {
+	data, err := NewControlDirSyncRequest(DirSyncObjectSecurity, 1000, []byte("cookie")).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	control := new(ControlDirSyncRequest)
+	if err := control.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !control.Critical || control.Flags != DirSyncObjectSecurity || control.MaxBytes != 1000 || string(control.Cookie) != "cookie" {
+		t.Errorf("Unexpected control %s", control)
+	}
+	if err := control.UnmarshalBinary([]byte{0x30, 0x00}); err == nil {
+		t.Error("Expected an error for an invalid control")
+	}
+}
@@ -3,70 +3,135 @@ package ldap
 import (
 	"errors"
 	"github.com/eaciit/asn1-ber"
+	"sync"
 )
 
 type ControlType string
 
 const (
-	ControlTypeMatchedValuesRequest    ControlType = "1.2.826.0.1.3344810.2.3"
-	ControlTypePermissiveModifyRequest ControlType = "1.2.840.113556.1.4.1413"
-	ControlTypePaging                  ControlType = "1.2.840.113556.1.4.319"
-	ControlTypeManageDsaITRequest      ControlType = "2.16.840.1.113730.3.4.2"
-	ControlTypeSubtreeDeleteRequest    ControlType = "1.2.840.113556.1.4.805"
-	ControlTypeNoOpRequest             ControlType = "1.3.6.1.4.1.4203.1.10.2"
-	ControlTypeServerSideSortRequest   ControlType = "1.2.840.113556.1.4.473"
-	ControlTypeServerSideSortResponse  ControlType = "1.2.840.113556.1.4.474"
-	ControlTypeVlvRequest              ControlType = "2.16.840.1.113730.3.4.9"
-	ControlTypeVlvResponse             ControlType = "2.16.840.1.113730.3.4.10"
-
+	ControlTypeMatchedValuesRequest      ControlType = "1.2.826.0.1.3344810.2.3"
+	ControlTypePermissiveModifyRequest   ControlType = "1.2.840.113556.1.4.1413"
+	ControlTypePaging                    ControlType = "1.2.840.113556.1.4.319"
+	ControlTypeManageDsaITRequest        ControlType = "2.16.840.1.113730.3.4.2"
+	ControlTypeSubtreeDeleteRequest      ControlType = "1.2.840.113556.1.4.805"
+	ControlTypeNoOpRequest               ControlType = "1.3.6.1.4.1.4203.1.10.2"
+	ControlTypeServerSideSortRequest     ControlType = "1.2.840.113556.1.4.473"
+	ControlTypeServerSideSortResponse    ControlType = "1.2.840.113556.1.4.474"
+	ControlTypeVlvRequest                ControlType = "2.16.840.1.113730.3.4.9"
+	ControlTypeVlvResponse               ControlType = "2.16.840.1.113730.3.4.10"
+	ControlTypePasswordPolicy            ControlType = "1.3.6.1.4.1.42.2.27.8.5.1"
+	ControlTypeAuthzIdRequest            ControlType = "2.16.840.1.113730.3.4.16"
+	ControlTypeAuthzIdResponse           ControlType = "2.16.840.1.113730.3.4.15"
+	ControlTypeDirSync                   ControlType = "1.2.840.113556.1.4.841"
 //1.2.840.113556.1.4.473
 //1.3.6.1.1.12
 //1.3.6.1.1.13.1
 //1.3.6.1.1.13.2
 //1.3.6.1.4.1.26027.1.5.2
-//1.3.6.1.4.1.42.2.27.8.5.1
-//1.3.6.1.4.1.42.2.27.9.5.2
-//1.3.6.1.4.1.42.2.27.9.5.8
-//1.3.6.1.4.1.4203.1.10.1
 //1.3.6.1.4.1.7628.5.101.1
 //2.16.840.1.113730.3.4.12
-//2.16.840.1.113730.3.4.16
 //2.16.840.1.113730.3.4.17
 //2.16.840.1.113730.3.4.18
 //2.16.840.1.113730.3.4.19
-//2.16.840.1.113730.3.4.3
 //2.16.840.1.113730.3.4.4
 //2.16.840.1.113730.3.4.5
 //
+	ControlTypeShowDeletedRequest        ControlType = "1.2.840.113556.1.4.417"
+	ControlTypeShowRecycledRequest       ControlType = "1.2.840.113556.1.4.2064"
+	ControlTypeExtendedDNRequest         ControlType = "1.2.840.113556.1.4.529"
+	ControlTypeSDFlagsRequest            ControlType = "1.2.840.113556.1.4.801"
+	ControlTypeSessionTracking           ControlType = "1.3.6.1.4.1.21008.108.63.1"
+	ControlTypeSubentriesRequest         ControlType = "1.3.6.1.4.1.4203.1.10.1"
+	ControlTypeDontUseCopyRequest        ControlType = "1.3.6.1.1.22"
+	ControlTypePersistentSearchRequest   ControlType = "2.16.840.1.113730.3.4.3"
+	ControlTypeEntryChangeNotification   ControlType = "2.16.840.1.113730.3.4.7"
+	ControlTypeSyncRequest               ControlType = "1.3.6.1.4.1.4203.1.9.1.1"
+	ControlTypeSyncState                 ControlType = "1.3.6.1.4.1.4203.1.9.1.2"
+	ControlTypeSyncDone                  ControlType = "1.3.6.1.4.1.4203.1.9.1.3"
+	ControlTypeNotificationRequest       ControlType = "1.2.840.113556.1.4.528"
+	ControlTypePolicyHintsRequest        ControlType = "1.2.840.113556.1.4.2239"
+	ControlTypeGetEffectiveRightsRequest ControlType = "1.3.6.1.4.1.42.2.27.9.5.2"
+	ControlTypeAccountUsability          ControlType = "1.3.6.1.4.1.42.2.27.9.5.8"
+	ControlTypeGetStats                  ControlType = "1.2.840.113556.1.4.970"
+	ControlTypeDomainScopeRequest        ControlType = "1.2.840.113556.1.4.1339"
+	ControlTypeSearchOptionsRequest      ControlType = "1.2.840.113556.1.4.1340"
+	ControlTypeVerifyNameRequest         ControlType = "1.2.840.113556.1.4.1338"
 )
 
 var controlTypeStrings = map[ControlType]string{
-	ControlTypeMatchedValuesRequest:    "MatchedValuesRequest",
-	ControlTypePermissiveModifyRequest: "PermissiveModifyRequest",
-	ControlTypePaging:                  "Paging",
-	ControlTypeManageDsaITRequest:      "ManageDsaITRequest",
-	ControlTypeSubtreeDeleteRequest:    "SubtreeDeleteRequest",
-	ControlTypeNoOpRequest:             "NoOpRequest",
-	ControlTypeServerSideSortRequest:   "ServerSideSortRequest",
-	ControlTypeServerSideSortResponse:  "ServerSideSortResponse",
-	ControlTypeVlvRequest:              "VlvRequest",
-	ControlTypeVlvResponse:             "VlvResponse",
+	ControlTypeMatchedValuesRequest:      "MatchedValuesRequest",
+	ControlTypePermissiveModifyRequest:   "PermissiveModifyRequest",
+	ControlTypePaging:                    "Paging",
+	ControlTypeManageDsaITRequest:        "ManageDsaITRequest",
+	ControlTypeSubtreeDeleteRequest:      "SubtreeDeleteRequest",
+	ControlTypeNoOpRequest:               "NoOpRequest",
+	ControlTypeServerSideSortRequest:     "ServerSideSortRequest",
+	ControlTypeServerSideSortResponse:    "ServerSideSortResponse",
+	ControlTypeVlvRequest:                "VlvRequest",
+	ControlTypeVlvResponse:               "VlvResponse",
+	ControlTypePasswordPolicy:            "PasswordPolicy",
+	ControlTypeAuthzIdRequest:            "AuthzIdRequest",
+	ControlTypeAuthzIdResponse:           "AuthzIdResponse",
+	ControlTypeDirSync:                   "DirSync",
+	ControlTypeShowDeletedRequest:        "ShowDeletedRequest",
+	ControlTypeShowRecycledRequest:       "ShowRecycledRequest",
+	ControlTypeExtendedDNRequest:         "ExtendedDNRequest",
+	ControlTypeSDFlagsRequest:            "SDFlagsRequest",
+	ControlTypeSessionTracking:           "SessionTracking",
+	ControlTypeSubentriesRequest:         "SubentriesRequest",
+	ControlTypeDontUseCopyRequest:        "DontUseCopyRequest",
+	ControlTypePersistentSearchRequest:   "PersistentSearchRequest",
+	ControlTypeEntryChangeNotification:   "EntryChangeNotification",
+	ControlTypeSyncRequest:               "SyncRequest",
+	ControlTypeSyncState:                 "SyncState",
+	ControlTypeSyncDone:                  "SyncDone",
+	ControlTypeNotificationRequest:       "NotificationRequest",
+	ControlTypePolicyHintsRequest:        "PolicyHintsRequest",
+	ControlTypeGetEffectiveRightsRequest: "GetEffectiveRightsRequest",
+	ControlTypeAccountUsability:          "AccountUsability",
+	ControlTypeGetStats:                  "GetStats",
+	ControlTypeDomainScopeRequest:        "DomainScopeRequest",
+	ControlTypeSearchOptionsRequest:      "SearchOptionsRequest",
+	ControlTypeVerifyNameRequest:         "VerifyNameRequest",
 }
 
 type controlTypeFn func(p *ber.Packet) (Control, error)
 
+var controlTypeFnsLock sync.RWMutex
+
 var controlTypeFns = map[ControlType]controlTypeFn{
-	ControlTypeServerSideSortResponse: NewControlServerSideSortResponse,
-	ControlTypePaging:                 NewControlPagingFromPacket,
-	ControlTypeVlvResponse:            NewControlVlvResponse,
+	ControlTypeServerSideSortResponse:  NewControlServerSideSortResponse,
+	ControlTypePaging:                  NewControlPagingFromPacket,
+	ControlTypeVlvResponse:             NewControlVlvResponse,
+	ControlTypePasswordPolicy:          NewControlPasswordPolicyResponse,
+	ControlTypeAuthzIdResponse:         NewControlAuthzIdResponse,
+	ControlTypeDirSync:                 NewControlDirSyncResponse,
+	ControlTypeEntryChangeNotification: NewControlEntryChangeNotification,
+	ControlTypeSyncState:               NewControlSyncState,
+	ControlTypeSyncDone:                NewControlSyncDone,
+	ControlTypeAccountUsability:        NewControlAccountUsability,
+	ControlTypeGetStats:                NewControlGetStats,
 }
 
 func (c ControlType) String() string {
 	return controlTypeStrings[c]
 }
 
+// RegisterControl registers decodeFunc as the decoder of the response control
+// oid, replacing the decoder registered before, also a built-in one. Response
+// controls without a decoder are returned as *RawControl. decodeFunc gets the
+// Control SEQUENCE { controlType, criticality, controlValue } and must not keep
+// it, the control it returns is passed on in the Controls of the result.
+func RegisterControl(oid ControlType, decodeFunc func(p *ber.Packet) (Control, error)) {
+	controlTypeFnsLock.Lock()
+	defer controlTypeFnsLock.Unlock()
+	controlTypeFns[oid] = decodeFunc
+}
+
 func (c ControlType) function() (controlTypeFn, error) {
+	controlTypeFnsLock.RLock()
 	f, ok := controlTypeFns[c]
+	controlTypeFnsLock.RUnlock()
 	if !ok {
 		return nil, errors.New("No function registered for " + c.String())
 	}
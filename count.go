@@ -0,0 +1,71 @@
+package ldap
+
+import (
+	"context"
+)
+
+// Count returns the number of entries matching filter in the subtree of
+// baseDN, searching for no attributes and counting the entries as they
+// arrive instead of collecting them. The servers limiting the size of a
+// search, e.g. Active Directory to 1000 entries, fail with
+// ResultSizeLimitExceeded, see CountPaged. On an error the entries counted
+// so far are returned with it.
+func (l *Connection) Count(baseDN, filter string) (int, error) {
+	return l.CountContext(context.Background(), baseDN, filter)
+}
+
+// CountContext is Count with ctx.
+func (l *Connection) CountContext(ctx context.Context, baseDN, filter string) (int, error) {
+	handler := new(countHandler)
+	err := l.SearchWithHandlerContext(ctx, newCountRequest(baseDN, filter), handler, nil)
+	return handler.count, err
+}
+
+// CountPaged is Count with a paged search of pagingSize entries per page,
+// for the servers limiting the size of a search. Without paging support of
+// the server the first page is the whole result.
+func (l *Connection) CountPaged(baseDN, filter string, pagingSize uint32) (int, error) {
+	return l.CountPagedContext(context.Background(), baseDN, filter, pagingSize)
+}
+
+// CountPagedContext is CountPaged with ctx.
+func (l *Connection) CountPagedContext(ctx context.Context, baseDN, filter string, pagingSize uint32) (int, error) {
+	pagingControl := NewControlPaging(pagingSize)
+	pageRequest := newCountRequest(baseDN, filter).withControl(pagingControl)
+	count := 0
+	for {
+		handler := new(countHandler)
+		err := l.SearchWithHandlerContext(ctx, pageRequest, handler, nil)
+		count += handler.count
+		if err != nil {
+			return count, err
+		}
+		_, control := FindControl(handler.controls, ControlTypePaging)
+		response, ok := control.(*ControlPaging)
+		if !ok || len(response.Cookie) == 0 {
+			return count, nil
+		}
+		pagingControl.SetCookie(response.Cookie)
+	}
+}
+
+func newCountRequest(baseDN, filter string) *SearchRequest {
+	return NewSearchRequest(baseDN, ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, filter, []string{NoAttributes}, nil)
+}
+
+// countHandler counts the entries of a search and keeps the controls of
+// the search result done.
+type countHandler struct {
+	count    int
+	controls []Control
+}
+
+func (h *countHandler) ProcessDiscreteResult(dsr *DiscreteSearchResult, connInfo *ConnectionInfo) (bool, error) {
+	switch dsr.SearchResultType {
+	case SearchResultEntry:
+		h.count++
+	case SearchResultDone:
+		h.controls = dsr.Controls
+	}
+	return false, nil
+}
@@ -0,0 +1,46 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"testing"
+)
+
+func TestCount(t *testing.T) {
+	l, s := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		messageID := mockMessageID(request)
+		for _, cn := range []string{"alice", "bob", "carol"} {
+			s.respond(messageID, mockSearchEntry("cn="+cn+",o=bigcorp", nil))
+		}
+		s.respondResult(messageID, ApplicationSearchResultDone, ResultSuccess, "")
+	})
+	defer l.Close()
+
+	count, err := l.Count("o=bigcorp", "(objectClass=person)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 entries, got %d", count)
+	}
+	request := (<-s.requests).Children[1]
+	if attributes := request.Children[7].Children; len(attributes) != 1 || packetString(attributes[0]) != NoAttributes {
+		t.Errorf("Expected a search for no attributes, got %v", request)
+	}
+}
+
+func TestCountPaged(t *testing.T) {
+	l, _ := newMockConnection(t, mockPagedSearch(t, 7))
+	defer l.Close()
+
+	count, err := l.CountPaged("o=bigcorp", "(cn=*)", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 7 {
+		t.Errorf("Expected 7 entries, got %d", count)
+	}
+	// mockPagedSearch requires paging
+	if _, err := l.Count("o=bigcorp", "(cn=*)"); err == nil {
+		t.Error("Expected the error of the unpaged search")
+	}
+}
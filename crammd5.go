@@ -0,0 +1,48 @@
+package ldap
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/hex"
+)
+
+// CRAMMD5Mechanism is the SASL CRAM-MD5 mechanism
+// [https://tools.ietf.org/html/rfc2195], the password is not sent but the
+// server has to store it in plain text. Prefer DIGEST-MD5 or SCRAM where the
+// server offers them.
+type CRAMMD5Mechanism struct {
+	Username string
+	Password string
+}
+
+// CRAMMD5Bind binds with CRAM-MD5 as username with password.
+func (l *Connection) CRAMMD5Bind(username, password string) (*LDAPResult, error) {
+	return l.CRAMMD5BindContext(context.Background(), username, password)
+}
+
+// CRAMMD5BindContext is CRAMMD5Bind with ctx.
+func (l *Connection) CRAMMD5BindContext(ctx context.Context, username, password string) (*LDAPResult, error) {
+	return l.SASLBindContext(ctx, &CRAMMD5Mechanism{Username: username, Password: password})
+}
+
+func (m *CRAMMD5Mechanism) Name() string {
+	return "CRAM-MD5"
+}
+
+func (m *CRAMMD5Mechanism) Start(server *SASLServerInfo) ([]byte, error) {
+	// the server starts with the challenge
+	return nil, nil
+}
+
+func (m *CRAMMD5Mechanism) Next(challenge []byte, more bool) ([]byte, error) {
+	if !more {
+		if len(challenge) == 0 {
+			return nil, nil
+		}
+		return nil, newError(ErrorUnknown, "CRAM-MD5: unexpected credentials of the server")
+	}
+	mac := hmac.New(md5.New, []byte(m.Password))
+	mac.Write(challenge)
+	return []byte(m.Username + " " + hex.EncodeToString(mac.Sum(nil))), nil
+}
@@ -0,0 +1,29 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"testing"
+)
+
+func TestCRAMMD5Bind(t *testing.T) {
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		messageID := mockMessageID(request)
+		sasl := request.Children[1].Children[2]
+		if len(sasl.Children) == 1 {
+			// example of RFC 2195 section 2
+			s.respond(messageID, mockSASLBindResponse(ResultSaslBindInProgress, "<1896.697170952@postoffice.reston.mci.net>"))
+		} else if packetString(sasl.Children[1]) == "tim b913a602c7eda7a495b4e6e7334d3890" {
+			s.respondResult(messageID, ApplicationBindResponse, ResultSuccess, "")
+		} else {
+			s.respondResult(messageID, ApplicationBindResponse, ResultInvalidCredentials, "")
+		}
+	})
+	defer l.Close()
+
+	if _, err := l.CRAMMD5Bind("tim", "tanstaaftanstaaf"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.CRAMMD5Bind("tim", "wrong"); err == nil {
+		t.Error("Expected an error for a wrong password")
+	}
+}
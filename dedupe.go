@@ -0,0 +1,111 @@
+package ldap
+
+import (
+	"strings"
+)
+
+// EntryKey returns the identity of an entry for DedupeEntries, entries with
+// the same key are duplicates.
+type EntryKey func(entry *Entry) string
+
+// DNKey identifies entries by their DN, compared case-insensitively and
+// ignoring the spaces around the RDNs and their "=", e.g.
+// "CN=Bob, O=BigCorp" is "cn=bob,o=bigcorp".
+func DNKey(entry *Entry) string {
+	return "dn:" + normalizeDN(entry.DN)
+}
+
+// UUIDKey identifies entries by their entryUUID or, of Active Directory,
+// objectGUID, which have to be searched for, also as set by the ExtendedDN
+// control. Entries without either are identified by DNKey. Unlike the DN
+// the UUID stays the same when an entry is renamed during a search.
+func UUIDKey(entry *Entry) string {
+	if uuid := entry.GetAttributeValue(AttributeEntryUUID); uuid != "" {
+		return "uuid:" + strings.ToLower(uuid)
+	}
+	if guid, err := FormatGUID([]byte(entry.GetAttributeValue("objectGUID"))); err == nil {
+		return "uuid:" + guid
+	}
+	if entry.GUID != "" {
+		return "uuid:" + strings.ToLower(entry.GUID)
+	}
+	return DNKey(entry)
+}
+
+// DedupeEntries returns entries without the duplicates by key, DNKey if
+// nil, keeping the first of each, e.g. of the entries of a search and the
+// searches of its referrals or of SearchBases with nested base DNs.
+// entries itself is not modified.
+func DedupeEntries(entries []*Entry, key EntryKey) []*Entry {
+	if key == nil {
+		key = DNKey
+	}
+	seen := make(map[string]bool, len(entries))
+	deduped := make([]*Entry, 0, len(entries))
+	for _, entry := range entries {
+		k := key(entry)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		deduped = append(deduped, entry)
+	}
+	return deduped
+}
+
+// Dedupe removes the duplicates by key, DNKey if nil, from the entries of
+// the result, see DedupeEntries, e.g. after appending the entries of the
+// searches of its referrals.
+func (sr *SearchResult) Dedupe(key EntryKey) {
+	sr.Entries = DedupeEntries(sr.Entries, key)
+}
+
+// Dedupe removes the duplicates by key, DNKey if nil, from Entries, e.g.
+// of base DNs below each other or referrals to the same entries; the
+// results of the Bases keep them.
+func (r *MultiSearchResult) Dedupe(key EntryKey) {
+	r.Entries = DedupeEntries(r.Entries, key)
+}
+
+// normalizeDN returns dn in lower case without the spaces around its RDNs
+// and their "=", keeping escaped characters.
+func normalizeDN(dn string) string {
+	var rdns []string
+	var rdn strings.Builder
+	flush := func() {
+		rdns = append(rdns, normalizeRDN(rdn.String()))
+		rdn.Reset()
+	}
+	for i := 0; i < len(dn); i++ {
+		switch c := dn[i]; {
+		case c == '\\' && i+1 < len(dn):
+			rdn.WriteByte(c)
+			i++
+			rdn.WriteByte(dn[i])
+		case c == ',':
+			flush()
+		default:
+			rdn.WriteByte(c)
+		}
+	}
+	flush()
+	return strings.ToLower(strings.Join(rdns, ","))
+}
+
+func normalizeRDN(rdn string) string {
+	rdn = trimDNSpace(rdn)
+	if i := strings.IndexByte(rdn, '='); i != -1 {
+		rdn = trimDNSpace(rdn[:i]) + "=" + trimDNSpace(rdn[i+1:])
+	}
+	return rdn
+}
+
+// trimDNSpace trims the spaces around s except an escaped trailing one.
+func trimDNSpace(s string) string {
+	s = strings.TrimLeft(s, " ")
+	trimmed := strings.TrimRight(s, " ")
+	if strings.HasSuffix(trimmed, "\\") && len(trimmed) < len(s) {
+		trimmed += " "
+	}
+	return trimmed
+}
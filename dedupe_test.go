@@ -0,0 +1,61 @@
+package ldap
+
+import (
+	"testing"
+)
+
+func TestDNKey(t *testing.T) {
+	for dn, expected := range map[string]string{
+		"CN=Bob, O=BigCorp":        "dn:cn=bob,o=bigcorp",
+		" cn = bob ,o=bigcorp ":    "dn:cn=bob,o=bigcorp",
+		`cn=Smith\, Bob,o=bigcorp`: `dn:cn=smith\, bob,o=bigcorp`,
+		`cn=bob\ ,o=bigcorp`:       `dn:cn=bob\ ,o=bigcorp`,
+		"":                         "dn:",
+	} {
+		if key := DNKey(NewEntry(dn)); key != expected {
+			t.Errorf("Expected %s for %q, got %s", expected, dn, key)
+		}
+	}
+}
+
+func TestUUIDKey(t *testing.T) {
+	openldap := NewEntry("cn=bob,o=bigcorp")
+	openldap.AddAttributeValue(AttributeEntryUUID, "5E5F2D44-1F0A-103C-9A0C-6F7C5C9B1D2E")
+	if key := UUIDKey(openldap); key != "uuid:5e5f2d44-1f0a-103c-9a0c-6f7c5c9b1d2e" {
+		t.Errorf("Unexpected key %s", key)
+	}
+	ad := NewEntry("CN=Bob,DC=example,DC=com")
+	ad.AddAttributeValue("objectGUID", string([]byte{0xf1, 0xe3, 0xb8, 0xed, 0xd4, 0x7d, 0x4f, 0x4a, 0xa5, 0xa5, 0xec, 0x5e, 0x66, 0x70, 0xcb, 0xbc}))
+	if key := UUIDKey(ad); key != "uuid:edb8e3f1-7dd4-4a4f-a5a5-ec5e6670cbbc" {
+		t.Errorf("Unexpected key %s", key)
+	}
+	extended := NewEntry("CN=Bob,DC=example,DC=com")
+	extended.GUID = "edb8e3f1-7dd4-4a4f-a5a5-ec5e6670cbbc"
+	if UUIDKey(extended) != UUIDKey(ad) {
+		t.Errorf("Expected the key of the objectGUID, got %s", UUIDKey(extended))
+	}
+	if key := UUIDKey(NewEntry("cn=Bob,o=bigcorp")); key != "dn:cn=bob,o=bigcorp" {
+		t.Errorf("Expected the DN key, got %s", key)
+	}
+}
+
+func TestDedupeEntries(t *testing.T) {
+	renamed := NewEntry("cn=robert,o=bigcorp")
+	renamed.AddAttributeValue(AttributeEntryUUID, "5e5f2d44-1f0a-103c-9a0c-6f7c5c9b1d2e")
+	bob := NewEntry("cn=bob,o=bigcorp")
+	bob.AddAttributeValue(AttributeEntryUUID, "5e5f2d44-1f0a-103c-9a0c-6f7c5c9b1d2e")
+	entries := []*Entry{bob, NewEntry("cn=alice,o=bigcorp"), NewEntry("CN=Bob, O=BigCorp"), renamed}
+
+	if deduped := DedupeEntries(entries, nil); len(deduped) != 3 || deduped[0] != bob || deduped[2] != renamed {
+		t.Errorf("Unexpected entries deduplicated by DN %v", deduped)
+	}
+	if len(entries) != 4 || entries[3] != renamed {
+		t.Error("The entries were changed")
+	}
+
+	result := &MultiSearchResult{Entries: entries}
+	result.Dedupe(UUIDKey)
+	if len(result.Entries) != 3 || result.Entries[0] != bob || result.Entries[2].DN != "CN=Bob, O=BigCorp" {
+		t.Errorf("Unexpected entries deduplicated by UUID %v", result.Entries)
+	}
+}
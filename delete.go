@@ -5,11 +5,16 @@
 package ldap
 
 import (
+	"context"
 	"github.com/eaciit/asn1-ber"
 )
 
+// LDAP delete request [https://tools.ietf.org/html/rfc4511#section-4.8]
 type DeleteRequest struct {
-	DN       string
+	// DN of entry that is deleted
+	DN string
+
+	// Server controls
 	Controls []Control
 }
 
@@ -20,22 +25,36 @@ func (req *DeleteRequest) RecordType() uint8 {
 */
 
 /*
-Simple delete
+DelRequest ::= [APPLICATION 10] LDAPDN
+
+Delete returns the LDAPResult of the server, also on failure, so the
+matched DN and diagnostic message can be inspected.
 */
 
-func (l *Connection) Delete(delReq *DeleteRequest) (error error) {
-	messageID, ok := l.nextMessageID()
-	if !ok {
-		return newError(ErrorClosing, "MessageID channel is closed.")
+func (l *Connection) Delete(delReq *DeleteRequest) (*LDAPResult, error) {
+	return l.DeleteContext(context.Background(), delReq)
+}
+
+// DeleteContext is Delete with ctx, the request is abandoned when ctx is done
+// before the response arrived.
+func (l *Connection) DeleteContext(ctx context.Context, delReq *DeleteRequest) (*LDAPResult, error) {
+	messageID, release, err := l.nextRequestID(ctx)
+	if err != nil {
+		return nil, err
 	}
-	encodedDelete := ber.NewString(ber.ClassApplication, ber.TypePrimitive, ber.Tag(ApplicationDelRequest), delReq.DN, ApplicationDelRequest.String())
+	defer release()
+	encodedDelete := encodeDeleteRequest(delReq)
 
 	packet, err := requestBuildPacket(messageID, encodedDelete, delReq.Controls)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return l.sendReqRespPacket(messageID, packet)
+	return l.sendReqRespResult(ctx, messageID, packet)
+}
+
+func encodeDeleteRequest(delReq *DeleteRequest) *ber.Packet {
+	return ber.NewString(ber.ClassApplication, ber.TypePrimitive, ber.Tag(ApplicationDelRequest), delReq.DN, ApplicationDelRequest.String())
 }
 
 func NewDeleteRequest(dn string) (delReq *DeleteRequest) {
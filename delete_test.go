@@ -0,0 +1,48 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"testing"
+)
+
+func TestDeleteEncodeDeleteRequest(t *testing.T) {
+	delReq := NewDeleteRequest("cn=bob,o=bigcorp")
+	delReq.AddControl(NewControlSubtreeDeleteRequest(true))
+
+	packet, err := requestBuildPacket(3, encodeDeleteRequest(delReq), delReq.Controls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := ber.DecodePacket(packet.Bytes())
+	if decoded.Children[1].Tag != ber.Tag(ApplicationDelRequest) {
+		t.Errorf("Expected tag %d, got %d", ApplicationDelRequest, decoded.Children[1].Tag)
+	}
+	if dn := string(decoded.Children[1].Data.Bytes()); dn != delReq.DN {
+		t.Errorf("Expected DN %q, got %q", delReq.DN, dn)
+	}
+	if len(decoded.Children) != 3 {
+		t.Errorf("Expected controls in the request")
+	}
+}
+
+func TestDecodeLDAPResult(t *testing.T) {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 3, "MessageID"))
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(ApplicationDelResponse), nil, "Delete Response")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, uint64(ResultNoSuchObject), "resultCode"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "o=bigcorp", "matchedDN"))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "no such entry", "diagnosticMessage"))
+	p.AppendChild(response)
+
+	result, err := decodeLDAPResult(ber.DecodePacket(p.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ResultCode != ResultNoSuchObject || result.MatchedDN != "o=bigcorp" || result.DiagnosticMessage != "no such entry" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	lerr, ok := result.err().(*Error)
+	if !ok || lerr.ResultCode != ResultNoSuchObject {
+		t.Errorf("Expected *Error with ResultNoSuchObject, got %v", result.err())
+	}
+}
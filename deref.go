@@ -1,10 +1,37 @@
 package ldap
 
+import (
+	"strings"
+)
+
+// Deref is the dereferencing of aliases [https://tools.ietf.org/html/rfc4511#section-4.5.1.3]
+// in a search, see SearchRequest.DerefAliases.
 type Deref uint8
 
 const (
-	NeverDerefAliases   Deref = 0
-	DerefInSearching    Deref = 1
+	// aliases are returned as the alias entries
+	NeverDerefAliases Deref = 0
+	// aliases below the base object are dereferenced, the base object not
+	DerefInSearching Deref = 1
+	// only an alias as the base object is dereferenced
 	DerefFindingBaseObj Deref = 2
-	DerefAlways         Deref = 3
+	// aliases are dereferenced as the base object and below
+	DerefAlways Deref = 3
 )
+
+// ParseDeref returns the Deref of the names of the -a option of ldapsearch,
+// "never", "search", "find" or "always", also in the long forms
+// "searching" and "finding".
+func ParseDeref(name string) (Deref, error) {
+	switch strings.ToLower(name) {
+	case "never":
+		return NeverDerefAliases, nil
+	case "search", "searching":
+		return DerefInSearching, nil
+	case "find", "finding":
+		return DerefFindingBaseObj, nil
+	case "always":
+		return DerefAlways, nil
+	}
+	return 0, newError(ErrorInvalidArgument, "Unknown alias dereferencing "+name)
+}
@@ -0,0 +1,104 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Default ports of the ldap:// and ldaps:// schemes and the default socket of
+// the ldapi:// scheme
+const (
+	DefaultLdapPort    = "389"
+	DefaultLdapsPort   = "636"
+	DefaultLdapiSocket = "/var/run/ldapi"
+)
+
+// DialURL connects to the server of an LDAP URL [https://tools.ietf.org/html/rfc4516],
+// ldap://host:port without TLS and ldaps://host:port with TLS from the start,
+// the port defaults to 389 and 636. tlsConfig is used for ldaps://, nil for
+// the default configuration verifying the host, use StartTLS on the returned
+// connection for TLS with ldap://. ldapi://path connects to the unix domain
+// socket at the URL-encoded path, e.g. ldapi://%2Fvar%2Frun%2Fslapd%2Fldapi,
+// or at DefaultLdapiSocket for ldapi:///. IPv6 literals are bracketed, with
+// the zone of link-local addresses escaped as in RFC 6874, e.g.
+// ldap://[fe80::1%25eth0], or not, e.g. ldap://[fe80::1%eth0]. Everything
+// after the host is ignored, see ParseURL and SearchFromURL.
+func DialURL(ldapURL string, tlsConfig *tls.Config) (*Connection, error) {
+	l, err := newURLConnection(ldapURL, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.Connect(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// newURLConnection returns the not yet connected Connection for ldapURL.
+func newURLConnection(ldapURL string, tlsConfig *tls.Config) (*Connection, error) {
+	if len(ldapURL) >= len("ldapi://") && strings.EqualFold(ldapURL[:len("ldapi://")], "ldapi://") {
+		// url.Parse rejects the escaped slashes of the socket path in the host
+		path := ldapURL[len("ldapi://"):]
+		if end := strings.IndexAny(path, "/?"); end != -1 {
+			path = path[:end]
+		}
+		path, err := url.PathUnescape(path)
+		if err != nil {
+			return nil, newError(ErrorInvalidArgument, "Invalid LDAP URL: "+err.Error())
+		}
+		if path == "" {
+			path = DefaultLdapiSocket
+		}
+		return NewUnixConnection(path), nil
+	}
+
+	u, err := url.Parse(escapeZone(ldapURL))
+	if err != nil {
+		return nil, newError(ErrorInvalidArgument, "Invalid LDAP URL: "+err.Error())
+	}
+
+	port := u.Port()
+	switch strings.ToLower(u.Scheme) {
+	case "ldap":
+		if port == "" {
+			port = DefaultLdapPort
+		}
+		return NewConnection(net.JoinHostPort(u.Hostname(), port)), nil
+	case "ldaps":
+		if port == "" {
+			port = DefaultLdapsPort
+		}
+		return NewSSLConnection(net.JoinHostPort(u.Hostname(), port), tlsConfig), nil
+	}
+	return nil, newError(ErrorInvalidArgument, "Unsupported LDAP URL scheme: "+u.Scheme)
+}
+
+// escapeZone escapes the % of an unescaped zone in the IPv6 literal of
+// ldapURL, which url.Parse rejects.
+func escapeZone(ldapURL string) string {
+	start := strings.Index(ldapURL, "://[")
+	if start == -1 {
+		return ldapURL
+	}
+	end := strings.IndexByte(ldapURL[start:], ']')
+	if end == -1 {
+		return ldapURL
+	}
+	host := ldapURL[start : start+end]
+	zone := strings.IndexByte(host, '%')
+	if zone == -1 || strings.HasPrefix(host[zone:], "%25") {
+		return ldapURL
+	}
+	return ldapURL[:start+zone] + "%25" + ldapURL[start+zone+1:]
+}
+
+// stripZone returns host without the zone if it is an IPv6 literal with one,
+// e.g. fe80::1 for fe80::1%eth0.
+func stripZone(host string) string {
+	if i := strings.LastIndexByte(host, '%'); i != -1 && net.ParseIP(host[:i]) != nil {
+		return host[:i]
+	}
+	return host
+}
@@ -0,0 +1,117 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"github.com/eaciit/asn1-ber"
+	"net"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewURLConnection(t *testing.T) {
+	tests := []struct {
+		url        string
+		addr       string
+		ssl        bool
+		serverName string
+	}{
+		{"ldap://ldap.example.com", "ldap.example.com:389", false, ""},
+		{"LDAP://ldap.example.com:1389/o=bigcorp??sub", "ldap.example.com:1389", false, ""},
+		{"ldaps://ldap.example.com", "ldap.example.com:636", true, "ldap.example.com"},
+		{"ldaps://[::1]:1636", "[::1]:1636", true, "::1"},
+		{"ldap://[fe80::1%25eth0]:1389", "[fe80::1%eth0]:1389", false, ""},
+		{"ldaps://[fe80::1%eth0]/o=bigcorp", "[fe80::1%eth0]:636", true, "fe80::1"},
+		{"ldapi://%2Fvar%2Frun%2Fslapd%2Fldapi/o=bigcorp", "/var/run/slapd/ldapi", false, ""},
+		{"LDAPI:///", DefaultLdapiSocket, false, ""},
+	}
+	for _, test := range tests {
+		l, err := newURLConnection(test.url, nil)
+		if err != nil {
+			t.Errorf("%s: %s", test.url, err)
+			continue
+		}
+		if (l.Network == "unix") != (test.addr[0] == '/') {
+			t.Errorf("%s: unexpected network %q", test.url, l.Network)
+		}
+		if l.Addr != test.addr || l.IsSSL != test.ssl || l.IsTLS {
+			t.Errorf("%s: unexpected address %s, SSL %t, TLS %t", test.url, l.Addr, l.IsSSL, l.IsTLS)
+		}
+		if test.ssl && l.clientTLSConfig(l.TlsConfig).ServerName != test.serverName {
+			t.Errorf("%s: unexpected ServerName %q", test.url, l.clientTLSConfig(l.TlsConfig).ServerName)
+		}
+	}
+
+	tlsConfig := &tls.Config{ServerName: "ldap.example.com"}
+	if l, _ := newURLConnection("ldaps://10.0.0.1", tlsConfig); l.TlsConfig != tlsConfig {
+		t.Error("tlsConfig with a ServerName was not used as is")
+	}
+
+	for _, ldapURL := range []string{"http://ldap.example.com", "ldap://ldap.example.com:port", "ldap.example.com:389", "ldapi://%2Fvar%zz"} {
+		if _, err := newURLConnection(ldapURL, nil); err == nil {
+			t.Errorf("%s: expected an error", ldapURL)
+		} else if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ErrorInvalidArgument {
+			t.Errorf("%s: expected ErrorInvalidArgument, got %v", ldapURL, err)
+		}
+	}
+}
+
+func TestDialURL(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			conn.Read(make([]byte, 1))
+		}
+	}()
+
+	l, err := DialURL("ldap://"+listener.Addr().String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+}
+
+func TestDialURLLdapi(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "ldapi")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	requests := make(chan *ber.Packet, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s := &mockServer{conn: conn}
+		request, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		requests <- request
+		s.respondResult(mockMessageID(request), ApplicationBindResponse, ResultSuccess, "")
+		ber.ReadPacket(conn)
+	}()
+
+	l, err := DialURL("ldapi://"+url.PathEscape(socket), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if _, err := l.ExternalBind(""); err != nil {
+		t.Fatal(err)
+	}
+
+	sasl := (<-requests).Children[1].Children[2]
+	if sasl.ClassType != ber.ClassContext || sasl.Tag != 3 || len(sasl.Children) != 1 || packetString(sasl.Children[0]) != "EXTERNAL" {
+		t.Errorf("Unexpected SASL credentials %v", sasl)
+	}
+}
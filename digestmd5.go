@@ -0,0 +1,373 @@
+package ldap
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DigestMD5Mechanism is the SASL DIGEST-MD5 mechanism
+// [https://tools.ietf.org/html/rfc2831]. Without Layers the quality of
+// protection is "auth", authentication without a security layer.
+type DigestMD5Mechanism struct {
+	Username string
+	Password string
+	// AuthzID to authorize as, empty for the identity of Username
+	AuthzID string
+	// Realm, empty for the first realm offered by the server
+	Realm string
+	// Host of the digest-uri "ldap/<host>", empty for the host of the
+	// connection
+	Host string
+	// Layers the client accepts, e.g. SASLLayerIntegrity for "auth-int" or
+	// SASLLayerConfidentiality for "auth-conf" with one of the RC4 ciphers,
+	// the strongest one offered by the server is used; SASLLayerNone if 0
+	Layers int
+
+	digestURI string
+	rspauth   string
+	layer     *digestMD5Layer
+	pending   *digestMD5Layer
+	// verified is set once the response-auth of the server was verified
+	verified bool
+}
+
+// DigestMD5Bind binds with DIGEST-MD5 as username with password, the password
+// is not sent over the connection.
+func (l *Connection) DigestMD5Bind(username, password string) (*LDAPResult, error) {
+	return l.DigestMD5BindContext(context.Background(), username, password)
+}
+
+// DigestMD5BindContext is DigestMD5Bind with ctx.
+func (l *Connection) DigestMD5BindContext(ctx context.Context, username, password string) (*LDAPResult, error) {
+	return l.SASLBindContext(ctx, &DigestMD5Mechanism{Username: username, Password: password})
+}
+
+func (m *DigestMD5Mechanism) Name() string {
+	return "DIGEST-MD5"
+}
+
+func (m *DigestMD5Mechanism) Start(server *SASLServerInfo) ([]byte, error) {
+	m.digestURI = "ldap/" + server.Host
+	if m.Host != "" {
+		m.digestURI = "ldap/" + m.Host
+	}
+	m.rspauth, m.layer, m.pending, m.verified = "", nil, nil, false
+	// the server starts with the digest-challenge
+	return nil, nil
+}
+
+func (m *DigestMD5Mechanism) Next(challenge []byte, more bool) ([]byte, error) {
+	directives, err := parseDigestChallenge(string(challenge))
+	if err != nil {
+		return nil, err
+	}
+	if rspauth, ok := directives["rspauth"]; ok {
+		if m.rspauth == "" || rspauth[0] != m.rspauth {
+			return nil, newError(ErrorUnknown, "DIGEST-MD5: the server failed to authenticate")
+		}
+		m.layer, m.verified = m.pending, true
+		// the response-auth is acknowledged with an empty response
+		return []byte{}, nil
+	}
+	if !more {
+		if m.verified && len(directives) == 0 {
+			return nil, nil
+		}
+		return nil, newError(ErrorUnknown, "DIGEST-MD5: the server didn't authenticate")
+	}
+	if m.rspauth != "" {
+		return nil, newError(ErrorUnknown, "DIGEST-MD5: unexpected second digest-challenge")
+	}
+
+	if len(directives["nonce"]) != 1 {
+		return nil, newError(ErrorUnknown, "DIGEST-MD5: no nonce in the digest-challenge")
+	}
+	if algorithm := directives["algorithm"]; len(algorithm) != 1 || algorithm[0] != "md5-sess" {
+		return nil, newError(ErrorUnknown, "DIGEST-MD5: unsupported algorithm")
+	}
+	offered := digestOptions(directives["qop"])
+	if directives["qop"] == nil {
+		offered["auth"] = true
+	}
+	ciphers := digestOptions(directives["cipher"])
+	layers := m.Layers
+	if layers == 0 {
+		layers = SASLLayerNone
+	}
+	qop, cipher := "", ""
+	switch {
+	case layers&SASLLayerConfidentiality != 0 && offered["auth-conf"] && (ciphers["rc4"] || ciphers["rc4-56"] || ciphers["rc4-40"]):
+		qop = "auth-conf"
+		for _, c := range []string{"rc4-40", "rc4-56", "rc4"} {
+			if ciphers[c] {
+				cipher = c
+			}
+		}
+	case layers&SASLLayerIntegrity != 0 && offered["auth-int"]:
+		qop = "auth-int"
+	case layers&SASLLayerNone != 0 && offered["auth"]:
+		qop = "auth"
+	default:
+		return nil, newError(ResultConfidentialityRequired, "DIGEST-MD5: no quality of protection of the server is accepted, use TLS or Layers")
+	}
+	maxSize := 65536
+	if maxbuf := directives["maxbuf"]; len(maxbuf) == 1 {
+		if size, err := strconv.Atoi(maxbuf[0]); err == nil && size > 16 {
+			maxSize = size
+		}
+	}
+	utf8Charset := len(directives["charset"]) == 1 && strings.EqualFold(directives["charset"][0], "utf-8")
+
+	realm := m.Realm
+	if realm == "" && len(directives["realm"]) > 0 {
+		realm = directives["realm"][0]
+	}
+	nonce := directives["nonce"][0]
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	cnonce := base64.RawStdEncoding.EncodeToString(random)
+
+	username, password, hashRealm := m.Username, m.Password, realm
+	if utf8Charset {
+		// hashed in ISO 8859-1 if all of them can be converted
+		u, uok := digestLatin1(username)
+		p, pok := digestLatin1(password)
+		r, rok := digestLatin1(hashRealm)
+		if uok && pok && rok {
+			username, password, hashRealm = u, p, r
+		}
+	}
+	ha1 := digestMD5HA1(username, hashRealm, password, m.AuthzID, nonce, cnonce)
+	a2 := m.digestURI
+	if qop != "auth" {
+		a2 += ":00000000000000000000000000000000"
+	}
+	response := digestMD5Response(ha1, nonce, cnonce, qop, "AUTHENTICATE:"+a2)
+	m.rspauth = digestMD5Response(ha1, nonce, cnonce, qop, ":"+a2)
+	if qop != "auth" {
+		m.pending = newDigestMD5Layer(ha1, cipher, maxSize, true)
+	}
+
+	fields := []string{
+		"username=" + digestQuote(m.Username),
+		"realm=" + digestQuote(realm),
+		"nonce=" + digestQuote(nonce),
+		"cnonce=" + digestQuote(cnonce),
+		"nc=00000001",
+		"qop=" + qop,
+		"digest-uri=" + digestQuote(m.digestURI),
+		"response=" + response,
+	}
+	if cipher != "" {
+		fields = append(fields, "cipher="+cipher)
+	}
+	if qop != "auth" {
+		fields = append(fields, "maxbuf="+strconv.Itoa(saslMaxBuffer))
+	}
+	if utf8Charset {
+		fields = append(fields, "charset=utf-8")
+	}
+	if m.AuthzID != "" {
+		fields = append(fields, "authzid="+digestQuote(m.AuthzID))
+	}
+	return []byte(strings.Join(fields, ",")), nil
+}
+
+// digestMD5HA1 returns H(A1), the hash the response and the keys of the
+// security layers are derived from.
+func digestMD5HA1(username, realm, password, authzID, nonce, cnonce string) []byte {
+	secret := md5.Sum([]byte(username + ":" + realm + ":" + password))
+	a1 := string(secret[:]) + ":" + nonce + ":" + cnonce
+	if authzID != "" {
+		a1 += ":" + authzID
+	}
+	ha1 := md5.Sum([]byte(a1))
+	return ha1[:]
+}
+
+// digestMD5Response returns the response-value, or the rspauth of the server
+// for a2 ":digest-uri", for the nonce count 1.
+func digestMD5Response(ha1 []byte, nonce, cnonce, qop, a2 string) string {
+	ha2 := md5.Sum([]byte(a2))
+	kd := md5.Sum([]byte(hex.EncodeToString(ha1) + ":" + nonce + ":00000001:" + cnonce + ":" + qop + ":" + hex.EncodeToString(ha2[:])))
+	return hex.EncodeToString(kd[:])
+}
+
+// digestOptions returns the comma separated options of the directives.
+func digestOptions(directives []string) map[string]bool {
+	options := make(map[string]bool)
+	for _, directive := range directives {
+		for _, option := range strings.Split(directive, ",") {
+			options[strings.TrimSpace(option)] = true
+		}
+	}
+	return options
+}
+
+// SecurityLayer returns the layer negotiated by the last bind.
+func (m *DigestMD5Mechanism) SecurityLayer() SASLSecurityLayer {
+	if m.layer == nil {
+		return nil
+	}
+	return m.layer
+}
+
+// digestMD5Layer is the security layer of the qop auth-int, and of auth-conf
+// with RC4 [https://tools.ietf.org/html/rfc2831#section-2.3].
+type digestMD5Layer struct {
+	sendKey, receiveKey       []byte
+	sendCipher, receiveCipher *rc4.Cipher
+	sendSeq, receiveSeq       uint32
+	// maxSize of the buffers received by the peer
+	maxSize int
+}
+
+// newDigestMD5Layer returns the layer of the client, or of the server for
+// tests, for H(A1) with cipher, integrity only without.
+func newDigestMD5Layer(ha1 []byte, cipher string, maxSize int, client bool) *digestMD5Layer {
+	key := func(secret []byte, constant string) []byte {
+		sum := md5.Sum(append(append([]byte(nil), secret...), constant...))
+		return sum[:]
+	}
+	l := &digestMD5Layer{
+		sendKey:    key(ha1, "Digest session key to client-to-server signing key magic constant"),
+		receiveKey: key(ha1, "Digest session key to server-to-client signing key magic constant"),
+		maxSize:    maxSize,
+	}
+	if cipher != "" {
+		n := map[string]int{"rc4-40": 5, "rc4-56": 7, "rc4": 16}[cipher]
+		l.sendCipher, _ = rc4.NewCipher(key(ha1[:n], "Digest H(A1) to client-to-server sealing key magic constant"))
+		l.receiveCipher, _ = rc4.NewCipher(key(ha1[:n], "Digest H(A1) to server-to-client sealing key magic constant"))
+	}
+	if !client {
+		l.sendKey, l.receiveKey = l.receiveKey, l.sendKey
+		l.sendCipher, l.receiveCipher = l.receiveCipher, l.sendCipher
+	}
+	return l
+}
+
+// mac returns the first 10 bytes of the HMAC-MD5 of the sequence number and
+// the message.
+func (l *digestMD5Layer) mac(key []byte, seq uint32, message []byte) []byte {
+	prefix := make([]byte, 4)
+	binary.BigEndian.PutUint32(prefix, seq)
+	return ntlmHMAC(key, prefix, message)[:10]
+}
+
+// Wrap returns the message, encrypted with the cipher, and its MAC followed
+// by the message type 1 and the sequence number.
+func (l *digestMD5Layer) Wrap(message []byte) ([]byte, error) {
+	buffer := append(append([]byte(nil), message...), l.mac(l.sendKey, l.sendSeq, message)...)
+	if l.sendCipher != nil {
+		l.sendCipher.XORKeyStream(buffer, buffer)
+	}
+	buffer = append(buffer, 0, 1, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(buffer[len(buffer)-4:], l.sendSeq)
+	l.sendSeq++
+	return buffer, nil
+}
+
+func (l *digestMD5Layer) Unwrap(buffer []byte) ([]byte, error) {
+	if len(buffer) < 16 || buffer[len(buffer)-6] != 0 || buffer[len(buffer)-5] != 1 {
+		return nil, newError(ErrorDecoding, "DIGEST-MD5: invalid security layer buffer")
+	}
+	if seq := binary.BigEndian.Uint32(buffer[len(buffer)-4:]); seq != l.receiveSeq {
+		return nil, newError(ErrorDecoding, fmt.Sprintf("DIGEST-MD5: unexpected sequence number %d", seq))
+	}
+	data := append([]byte(nil), buffer[:len(buffer)-6]...)
+	if l.receiveCipher != nil {
+		l.receiveCipher.XORKeyStream(data, data)
+	}
+	message, mac := data[:len(data)-10], data[len(data)-10:]
+	if !hmac.Equal(mac, l.mac(l.receiveKey, l.receiveSeq, message)) {
+		return nil, newError(ErrorDecoding, "DIGEST-MD5: MAC of the security layer buffer doesn't match")
+	}
+	l.receiveSeq++
+	return message, nil
+}
+
+// encrypts returns whether the layer negotiated confidentiality, auth-conf.
+func (l *digestMD5Layer) encrypts() bool {
+	return l.sendCipher != nil
+}
+
+// MaxMessageSize leaves room for the MAC, message type and sequence number.
+func (l *digestMD5Layer) MaxMessageSize() int {
+	return l.maxSize - 16
+}
+
+// digestLatin1 returns value in ISO 8859-1, false if it can't be converted.
+func digestLatin1(value string) (string, bool) {
+	latin1 := make([]byte, 0, len(value))
+	for _, r := range value {
+		if r > 0xff {
+			return value, false
+		}
+		latin1 = append(latin1, byte(r))
+	}
+	return string(latin1), true
+}
+
+// digestQuote returns value as a quoted-string.
+func digestQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// parseDigestChallenge parses the directives of a digest-challenge, the
+// values of a directive given several times, like realm, are kept in order.
+func parseDigestChallenge(challenge string) (map[string][]string, error) {
+	if !utf8.ValidString(challenge) {
+		return nil, newError(ErrorDecoding, "DIGEST-MD5: invalid digest-challenge")
+	}
+	directives := make(map[string][]string)
+	for i := 0; i < len(challenge); {
+		for i < len(challenge) && (challenge[i] == ',' || challenge[i] == ' ' || challenge[i] == '\t') {
+			i++
+		}
+		if i == len(challenge) {
+			break
+		}
+		eq := strings.IndexByte(challenge[i:], '=')
+		if eq <= 0 {
+			return nil, newError(ErrorDecoding, "DIGEST-MD5: invalid digest-challenge")
+		}
+		name := strings.ToLower(strings.TrimSpace(challenge[i : i+eq]))
+		i += eq + 1
+		var value strings.Builder
+		if i < len(challenge) && challenge[i] == '"' {
+			for i++; ; i++ {
+				if i >= len(challenge) {
+					return nil, newError(ErrorDecoding, "DIGEST-MD5: unterminated quoted-string")
+				}
+				if challenge[i] == '\\' && i+1 < len(challenge) {
+					i++
+				} else if challenge[i] == '"' {
+					i++
+					break
+				}
+				value.WriteByte(challenge[i])
+			}
+		} else {
+			end := strings.IndexByte(challenge[i:], ',')
+			if end < 0 {
+				end = len(challenge) - i
+			}
+			value.WriteString(strings.TrimSpace(challenge[i : i+end]))
+			i += end
+		}
+		directives[name] = append(directives[name], value.String())
+	}
+	return directives, nil
+}
@@ -0,0 +1,160 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"strings"
+	"testing"
+)
+
+func TestDigestMD5Response(t *testing.T) {
+	// example of RFC 2831 section 4
+	ha1 := digestMD5HA1("chris", "elwood.innosoft.com", "secret", "", "OA6MG9tEQGm2hh", "OA6MHXh6VqTrRk")
+	response := digestMD5Response(ha1, "OA6MG9tEQGm2hh", "OA6MHXh6VqTrRk", "auth", "AUTHENTICATE:imap/elwood.innosoft.com")
+	if response != "d388dad90d4bbd760a152321f2143af7" {
+		t.Errorf("Unexpected response %s", response)
+	}
+	rspauth := digestMD5Response(ha1, "OA6MG9tEQGm2hh", "OA6MHXh6VqTrRk", "auth", ":imap/elwood.innosoft.com")
+	if rspauth != "ea40f60335c427b5527b84dbabcdfffd" {
+		t.Errorf("Unexpected rspauth %s", rspauth)
+	}
+}
+
+func TestParseDigestChallenge(t *testing.T) {
+	directives, err := parseDigestChallenge(`realm="a",realm="b\"c", nonce="OA6MG9tEQGm2hh",qop="auth,auth-int",charset=utf-8,algorithm=md5-sess`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if realm := directives["realm"]; len(realm) != 2 || realm[0] != "a" || realm[1] != `b"c` {
+		t.Errorf("Unexpected realms %q", realm)
+	}
+	if directives["qop"][0] != "auth,auth-int" || directives["charset"][0] != "utf-8" || directives["algorithm"][0] != "md5-sess" {
+		t.Errorf("Unexpected directives %q", directives)
+	}
+	if _, err := parseDigestChallenge(`nonce="abc`); err == nil {
+		t.Error("Expected an error for an unterminated quoted-string")
+	}
+}
+
+func TestDigestMD5Bind(t *testing.T) {
+	var rspauth string
+	noRspauth := false
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		messageID := mockMessageID(request)
+		sasl := request.Children[1].Children[2]
+		if len(sasl.Children) == 1 {
+			s.respond(messageID, mockSASLBindResponse(ResultSaslBindInProgress, `realm="example.com",nonce="OA6MG9tEQGm2hh",qop="auth",charset=utf-8,algorithm=md5-sess`))
+			return
+		}
+		directives, err := parseDigestChallenge(packetString(sasl.Children[1]))
+		if err != nil || directives["digest-uri"][0] != "ldap/ldap.example.com" || directives["realm"][0] != "example.com" {
+			s.respondResult(messageID, ApplicationBindResponse, ResultInvalidCredentials, "")
+			return
+		}
+		ha1 := digestMD5HA1("user", "example.com", "secret", "", "OA6MG9tEQGm2hh", directives["cnonce"][0])
+		if directives["response"][0] != digestMD5Response(ha1, "OA6MG9tEQGm2hh", directives["cnonce"][0], "auth", "AUTHENTICATE:ldap/ldap.example.com") {
+			s.respondResult(messageID, ApplicationBindResponse, ResultInvalidCredentials, "")
+			return
+		}
+		if noRspauth {
+			s.respondResult(messageID, ApplicationBindResponse, ResultSuccess, "")
+			return
+		}
+		if rspauth == "" {
+			rspauth = digestMD5Response(ha1, "OA6MG9tEQGm2hh", directives["cnonce"][0], "auth", ":ldap/ldap.example.com")
+		}
+		s.respond(messageID, mockSASLBindResponse(ResultSuccess, "rspauth="+rspauth))
+	})
+	defer l.Close()
+
+	mechanism := &DigestMD5Mechanism{Username: "user", Password: "secret", Host: "ldap.example.com"}
+	if _, err := l.SASLBind(mechanism); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.SASLBind(&DigestMD5Mechanism{Username: "user", Password: "wrong", Host: "ldap.example.com"}); err == nil {
+		t.Error("Expected an error for a wrong password")
+	}
+	// the rspauth of the first bind doesn't match the second one
+	if _, err := l.SASLBind(mechanism); err == nil {
+		t.Error("Expected an error for a wrong rspauth")
+	}
+	noRspauth = true
+	if _, err := l.SASLBind(&DigestMD5Mechanism{Username: "user", Password: "secret", Host: "ldap.example.com"}); err == nil {
+		t.Error("Expected an error for a success without rspauth")
+	}
+}
+
+func TestDigestMD5BindSecurityLayer(t *testing.T) {
+	noRspauth := true
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		messageID := mockMessageID(request)
+		if request.Children[1].Tag == ber.Tag(ApplicationExtendedRequest) {
+			s.respond(messageID, mockExtendedResponse(ResultSuccess, "", []byte("u:user")))
+			return
+		}
+		sasl := request.Children[1].Children[2]
+		if len(sasl.Children) == 1 {
+			s.respond(messageID, mockSASLBindResponse(ResultSaslBindInProgress, `realm="example.com",nonce="OA6MG9tEQGm2hh",qop="auth,auth-int,auth-conf",cipher="rc4-40,rc4,des",maxbuf=4096,charset=utf-8,algorithm=md5-sess`))
+			return
+		}
+		directives, _ := parseDigestChallenge(packetString(sasl.Children[1]))
+		ha1 := digestMD5HA1("user", "example.com", "secret", "", "OA6MG9tEQGm2hh", directives["cnonce"][0])
+		a2 := "ldap/ldap.example.com:00000000000000000000000000000000"
+		if directives["qop"][0] != "auth-conf" || directives["cipher"][0] != "rc4" ||
+			directives["response"][0] != digestMD5Response(ha1, "OA6MG9tEQGm2hh", directives["cnonce"][0], "auth-conf", "AUTHENTICATE:"+a2) {
+			t.Errorf("Unexpected digest-response %q", directives)
+			s.respondResult(messageID, ApplicationBindResponse, ResultInvalidCredentials, "")
+			return
+		}
+		if noRspauth {
+			// the layer is never started
+			s.respondResult(messageID, ApplicationBindResponse, ResultSuccess, "")
+			return
+		}
+		s.respond(messageID, mockSASLBindResponse(ResultSuccess, "rspauth="+digestMD5Response(ha1, "OA6MG9tEQGm2hh", directives["cnonce"][0], "auth-conf", ":"+a2)))
+		s.conn = &saslConn{Conn: s.conn, layer: newDigestMD5Layer(ha1, "rc4", saslMaxBuffer, false)}
+	})
+	defer l.Close()
+
+	mechanism := &DigestMD5Mechanism{Username: "user", Password: "secret", Host: "ldap.example.com", Layers: SASLLayerConfidentiality}
+	if _, err := l.SASLBind(mechanism); err == nil {
+		t.Error("Expected an error for a success without rspauth")
+	}
+	if mechanism.SecurityLayer() != nil || l.encrypted() {
+		t.Fatal("Expected no security layer")
+	}
+
+	noRspauth = false
+	if _, err := l.SASLBind(mechanism); err != nil {
+		t.Fatal(err)
+	}
+	if mechanism.SecurityLayer() == nil || mechanism.SecurityLayer().MaxMessageSize() != 4080 {
+		t.Fatalf("Unexpected security layer %v", mechanism.SecurityLayer())
+	}
+	// the requests and responses are encrypted now
+	for i := 0; i < 2; i++ {
+		if authzID, err := l.WhoAmI(); err != nil || authzID != "u:user" {
+			t.Fatalf("Unexpected authzID %q: %v", authzID, err)
+		}
+	}
+}
+
+func TestDigestMD5Layer(t *testing.T) {
+	ha1 := digestMD5HA1("user", "example.com", "secret", "", "nonce", "cnonce")
+	for _, cipher := range []string{"", "rc4-40", "rc4-56", "rc4"} {
+		client, server := newDigestMD5Layer(ha1, cipher, 65536, true), newDigestMD5Layer(ha1, cipher, 65536, false)
+		for _, message := range []string{"first", "second"} {
+			buffer, _ := client.Wrap([]byte(message))
+			if cipher != "" && strings.Contains(string(buffer), message) {
+				t.Errorf("Message not encrypted with %s", cipher)
+			}
+			if unwrapped, err := server.Unwrap(buffer); err != nil || string(unwrapped) != message {
+				t.Errorf("Unexpected message %q with %s: %v", unwrapped, cipher, err)
+			}
+		}
+		buffer, _ := server.Wrap([]byte("reply"))
+		buffer[0] ^= 1
+		if _, err := client.Unwrap(buffer); err == nil {
+			t.Errorf("Expected a MAC error with %s", cipher)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package ldap
+
+import (
+	"strings"
+)
+
+// Attributes returned with the GetEffectiveRights control
+const (
+	AttributeEntryLevelRights     = "entryLevelRights"
+	AttributeAttributeLevelRights = "attributeLevelRights"
+	// aclRights;entryLevel and aclRights;attributeLevel;<attribute>
+	AttributeAclRights = "aclRights"
+)
+
+// Letters of the rights in entryLevelRights and attributeLevelRights, named
+// as in aclRights. obliterate is the right to delete values.
+var (
+	entryRightsLetters = map[rune]string{
+		'v': "read",
+		'a': "add",
+		'd': "delete",
+		'n': "rename",
+	}
+	attributeRightsLetters = map[rune]string{
+		'r': "read",
+		's': "search",
+		'c': "compare",
+		'w': "write",
+		'o': "obliterate",
+		'W': "selfwrite_add",
+		'O': "selfwrite_delete",
+		'p': "proxy",
+	}
+)
+
+// EffectiveRights are the rights on an entry returned with the
+// GetEffectiveRights control.
+type EffectiveRights struct {
+	// rights on the entry, e.g. Entry["delete"]
+	Entry map[string]bool
+	// rights on the attributes by lower case attribute name, e.g.
+	// Attributes["cn"]["write"]
+	Attributes map[string]map[string]bool
+}
+
+// Can reports whether the rights include right on attribute, or on the entry
+// for an empty attribute.
+func (r *EffectiveRights) Can(right, attribute string) bool {
+	if attribute == "" {
+		return r.Entry[right]
+	}
+	return r.Attributes[strings.ToLower(attribute)][right]
+}
+
+// ParseEffectiveRights parses the entryLevelRights and attributeLevelRights
+// attributes ("vadn" and "cn:rscwo, sn:rsc") and the aclRights;entryLevel and
+// aclRights;attributeLevel;<attribute> attributes ("add:1,delete:0,...") of
+// an entry returned with the GetEffectiveRights control.
+func ParseEffectiveRights(entry *Entry) (*EffectiveRights, error) {
+	rights := &EffectiveRights{
+		Entry:      make(map[string]bool),
+		Attributes: make(map[string]map[string]bool),
+	}
+	for _, attr := range entry.Attributes {
+		if len(attr.Values) == 0 {
+			continue
+		}
+		name := strings.ToLower(attr.Name)
+		value := attr.Values[0]
+		var err error
+		switch {
+		case name == strings.ToLower(AttributeEntryLevelRights):
+			err = parseRightsLetters(rights.Entry, value, entryRightsLetters)
+		case name == strings.ToLower(AttributeAttributeLevelRights):
+			for _, attributeRights := range strings.Split(value, ",") {
+				colon := strings.Index(attributeRights, ":")
+				if colon == -1 {
+					return nil, newError(ErrorDecoding, "Invalid attributeLevelRights: "+value)
+				}
+				attribute := strings.ToLower(strings.TrimSpace(attributeRights[:colon]))
+				if err = parseRightsLetters(rights.attribute(attribute), attributeRights[colon+1:], attributeRightsLetters); err != nil {
+					break
+				}
+			}
+		case name == "aclrights;entrylevel":
+			err = parseRightsList(rights.Entry, value)
+		case strings.HasPrefix(name, "aclrights;attributelevel;"):
+			err = parseRightsList(rights.attribute(strings.TrimPrefix(name, "aclrights;attributelevel;")), value)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rights, nil
+}
+
+func (r *EffectiveRights) attribute(name string) map[string]bool {
+	rights, ok := r.Attributes[name]
+	if !ok {
+		rights = make(map[string]bool)
+		r.Attributes[name] = rights
+	}
+	return rights
+}
+
+// parseRightsLetters parses rights like "rscwo", "none" for no rights.
+func parseRightsLetters(rights map[string]bool, s string, letters map[rune]string) error {
+	s = strings.TrimSpace(s)
+	if s == "none" {
+		return nil
+	}
+	for _, letter := range s {
+		right, ok := letters[letter]
+		if !ok {
+			return newError(ErrorDecoding, "Unknown right "+string(letter)+" in "+s)
+		}
+		rights[right] = true
+	}
+	return nil
+}
+
+// parseRightsList parses rights like "search:1,read:1,write:0".
+func parseRightsList(rights map[string]bool, s string) error {
+	for _, right := range strings.Split(s, ",") {
+		colon := strings.Index(right, ":")
+		if colon == -1 {
+			return newError(ErrorDecoding, "Invalid rights: "+s)
+		}
+		rights[strings.TrimSpace(right[:colon])] = strings.TrimSpace(right[colon+1:]) == "1"
+	}
+	return nil
+}
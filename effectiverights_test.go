@@ -0,0 +1,55 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"testing"
+)
+
+func TestControlGetEffectiveRightsRequest(t *testing.T) {
+	p, err := NewControlGetEffectiveRightsRequest("dn:uid=bob,o=bigcorp").Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = ber.DecodePacket(p.Bytes())
+	if len(p.Children) != 2 {
+		t.Fatalf("Expected control type and value, got %d children", len(p.Children))
+	}
+	if authzID := packetString(ber.DecodePacket(p.Children[1].Data.Bytes())); authzID != "dn:uid=bob,o=bigcorp" {
+		t.Errorf("Unexpected authzId %q", authzID)
+	}
+}
+
+func TestParseEffectiveRights(t *testing.T) {
+	entry := &Entry{DN: "uid=alice,o=bigcorp"}
+	entry.AddAttributeValue("entryLevelRights", "vn")
+	entry.AddAttributeValue("attributeLevelRights", "cn:rscwo, userPassword:wo, sn:none")
+	entry.AddAttributeValue("aclRights;attributeLevel;mail", "search:1,read:1,compare:1,write:0")
+
+	rights, err := ParseEffectiveRights(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		right, attribute string
+		expected         bool
+	}{
+		{"read", "", true},
+		{"rename", "", true},
+		{"delete", "", false},
+		{"write", "cn", true},
+		{"obliterate", "CN", true},
+		{"read", "userPassword", false},
+		{"write", "userpassword", true},
+		{"read", "sn", false},
+		{"read", "mail", true},
+		{"write", "mail", false},
+	} {
+		if rights.Can(test.right, test.attribute) != test.expected {
+			t.Errorf("Expected %s on %q to be %t", test.right, test.attribute, test.expected)
+		}
+	}
+
+	if _, err := ParseEffectiveRights(&Entry{Attributes: []*EntryAttribute{{Name: "entryLevelRights", Values: []string{"vx"}}}}); err == nil {
+		t.Error("Expected an error for an unknown right")
+	}
+}
@@ -7,6 +7,10 @@ import (
 type Entry struct {
 	DN         string
 	Attributes []*EntryAttribute
+
+	// objectGUID and objectSid, set if searched with the ExtendedDN control
+	GUID string
+	SID  string
 }
 
 type EntryAttribute struct {
@@ -77,6 +81,16 @@ func (e *Entry) GetAttributeValue(attributeName string) string {
 	return values[0]
 }
 
+// AttributeNames returns the names of the attributes of the entry, e.g. of
+// a search with TypesOnly returning no values.
+func (e *Entry) AttributeNames() []string {
+	names := make([]string, len(e.Attributes))
+	for i, attr := range e.Attributes {
+		names[i] = attr.Name
+	}
+	return names
+}
+
 func (e *Entry) GetAttributeIndex(attributeName string) int {
 	for i, attr := range e.Attributes {
 		if strings.EqualFold(attr.Name, attributeName) {
@@ -0,0 +1,114 @@
+package ldap
+
+import (
+	"context"
+	"github.com/eaciit/asn1-ber"
+)
+
+/*
+ExtendedRequest ::= [APPLICATION 23] SEQUENCE {
+     requestName      [0] LDAPOID,
+     requestValue     [1] OCTET STRING OPTIONAL }
+
+ExtendedResponse ::= [APPLICATION 24] SEQUENCE {
+     COMPONENTS OF LDAPResult,
+     responseName     [10] LDAPOID OPTIONAL,
+     responseValue    [11] OCTET STRING OPTIONAL }
+*/
+
+// LDAP extended request [https://tools.ietf.org/html/rfc4511#section-4.12]
+type ExtendedRequest struct {
+	// OID of the extended operation
+	Name string
+
+	// Request value, encoded into the requestValue OCTET STRING, optional
+	Value *ber.Packet
+
+	// Server controls
+	Controls []Control
+
+	// Called with the intermediate responses of the operation, optional
+	IntermediateHandler IntermediateResponseHandler
+}
+
+// ExtendedResponse is the LDAPResult of an extended operation together with
+// the optional response name and the raw response value.
+type ExtendedResponse struct {
+	LDAPResult
+	Name  string
+	Value []byte
+}
+
+func NewExtendedRequest(name string, value *ber.Packet) *ExtendedRequest {
+	return &ExtendedRequest{Name: name, Value: value, Controls: make([]Control, 0)}
+}
+
+func (req *ExtendedRequest) AddControl(control Control) {
+	if req.Controls == nil {
+		req.Controls = make([]Control, 0)
+	}
+	req.Controls = append(req.Controls, control)
+}
+
+// Extended sends the extended request and returns the response of the server,
+// on a result code other than success the response is returned with the error.
+func (l *Connection) Extended(req *ExtendedRequest) (*ExtendedResponse, error) {
+	return l.ExtendedContext(context.Background(), req)
+}
+
+// ExtendedContext is Extended with ctx, the request is abandoned when ctx is
+// done before the response arrived.
+func (l *Connection) ExtendedContext(ctx context.Context, req *ExtendedRequest) (*ExtendedResponse, error) {
+	messageID, release, err := l.nextRequestID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	packet, err := requestBuildPacket(messageID, encodeExtendedRequest(req), req.Controls)
+	if err != nil {
+		return nil, err
+	}
+
+	responsePacket, err := l.sendReqRespIntermediate(ctx, messageID, packet, req.IntermediateHandler)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := decodeExtendedResponse(responsePacket)
+	if err != nil {
+		return nil, err
+	}
+	return response, response.err()
+}
+
+func encodeExtendedRequest(req *ExtendedRequest) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(ApplicationExtendedRequest), nil, ApplicationExtendedRequest.String())
+	p.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, req.Name, "Request Name"))
+	if req.Value != nil {
+		value := ber.Encode(ber.ClassContext, ber.TypePrimitive, 1, nil, "Request Value")
+		value.AppendChild(req.Value)
+		p.AppendChild(value)
+	}
+	return p
+}
+
+func decodeExtendedResponse(p *ber.Packet) (*ExtendedResponse, error) {
+	result, err := decodeLDAPResult(p)
+	if err != nil {
+		return nil, err
+	}
+	response := &ExtendedResponse{LDAPResult: *result}
+	for _, child := range p.Children[1].Children[3:] {
+		if child.ClassType != ber.ClassContext {
+			continue
+		}
+		switch child.Tag {
+		case 10:
+			response.Name = packetString(child)
+		case 11:
+			response.Value = child.Data.Bytes()
+		}
+	}
+	return response, nil
+}
@@ -0,0 +1,152 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"testing"
+)
+
+func mockExtendedResponse(resultCode ResultCode, name string, value []byte) *ber.Packet {
+	response := mockLDAPResult(ApplicationExtendedResponse, resultCode, "")
+	if len(name) > 0 {
+		response.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 10, name, "responseName"))
+	}
+	if value != nil {
+		responseValue := ber.Encode(ber.ClassContext, ber.TypePrimitive, 11, nil, "responseValue")
+		responseValue.Data.Write(value)
+		response.AppendChild(responseValue)
+	}
+	return response
+}
+
+func TestWhoAmI(t *testing.T) {
+	l, s := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		s.respond(mockMessageID(request), mockExtendedResponse(ResultSuccess, "", []byte("dn:cn=bob,o=bigcorp")))
+	})
+	defer l.Close()
+
+	authzID, err := l.WhoAmI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authzID != "dn:cn=bob,o=bigcorp" {
+		t.Errorf("Unexpected authzId %q", authzID)
+	}
+
+	request := <-s.requests
+	if name := packetString(request.Children[1].Children[0]); name != ExtendedOperationWhoAmI {
+		t.Errorf("Unexpected request name %q", name)
+	}
+}
+
+func TestPasswordModifyGeneratedPassword(t *testing.T) {
+	l, s := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		value := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "PasswdModifyResponseValue")
+		value.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, "s3cr3t", "genPasswd"))
+		s.respond(mockMessageID(request), mockExtendedResponse(ResultSuccess, "", value.Bytes()))
+	})
+	defer l.Close()
+
+	result, err := l.PasswordModify(NewPasswordModifyRequest("uid=bob,o=bigcorp", "old", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.GeneratedPassword != "s3cr3t" {
+		t.Errorf("Unexpected generated password %q", result.GeneratedPassword)
+	}
+
+	request := <-s.requests
+	value := ber.DecodePacket(request.Children[1].Children[1].Data.Bytes())
+	if len(value.Children) != 2 {
+		t.Errorf("Expected userIdentity and oldPasswd, got %d components", len(value.Children))
+	}
+}
+
+func TestTurnHandlesPeerRequests(t *testing.T) {
+	turnedResponse := make(chan *ber.Packet, 1)
+	l, s := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		switch ApplicationCode(request.Children[1].Tag) {
+		case ApplicationExtendedRequest:
+			s.respond(mockMessageID(request), mockExtendedResponse(ResultSuccess, "", nil))
+			// act as client now
+			del := ber.NewString(ber.ClassApplication, ber.TypePrimitive, ber.Tag(ApplicationDelRequest), "cn=x", "Delete")
+			packet, _ := requestBuildPacket(1, del, nil)
+			s.conn.Write(packet.Bytes())
+		case ApplicationDelResponse:
+			turnedResponse <- request
+		}
+	})
+	defer l.Close()
+
+	handler := func(messageID int64, request *ber.Packet) *ber.Packet {
+		return NewLDAPResultPacket(ApplicationDelResponse, &LDAPResult{ResultCode: ResultUnwillingToPerform})
+	}
+	if err := l.Turn(false, "replica-1", handler); err != nil {
+		t.Fatal(err)
+	}
+	response := <-turnedResponse
+	if mockMessageID(response) != 1 {
+		t.Errorf("Response sent with messageID %d", mockMessageID(response))
+	}
+	<-s.requests
+}
+
+func TestRefresh(t *testing.T) {
+	l, s := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		value := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "RefreshResponseValue")
+		value.AppendChild(ber.NewInteger(ber.ClassContext, ber.TypePrimitive, 1, 86400, "responseTtl"))
+		s.respond(mockMessageID(request), mockExtendedResponse(ResultSuccess, ExtendedOperationRefresh, value.Bytes()))
+	})
+	defer l.Close()
+
+	ttl, err := l.Refresh("cn=presence,o=bigcorp", 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl != 86400 {
+		t.Errorf("Expected responseTtl 86400, got %d", ttl)
+	}
+
+	request := <-s.requests
+	value := ber.DecodePacket(request.Children[1].Children[1].Data.Bytes())
+	if requestTtl, _ := packetInt64(value.Children[1]); requestTtl != 3600 {
+		t.Errorf("Expected requestTtl 3600, got %d", requestTtl)
+	}
+}
+
+func mockIntermediateResponse(name string, value []byte) *ber.Packet {
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(ApplicationIntermediateResponse), nil, ApplicationIntermediateResponse.String())
+	response.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, name, "responseName"))
+	responseValue := ber.Encode(ber.ClassContext, ber.TypePrimitive, 1, nil, "responseValue")
+	responseValue.Data.Write(value)
+	response.AppendChild(responseValue)
+	return response
+}
+
+func TestExtendedIntermediateResponses(t *testing.T) {
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		messageID := mockMessageID(request)
+		s.respond(messageID, mockIntermediateResponse("1.2.3.4", []byte("first")))
+		s.respond(messageID, mockIntermediateResponse("1.2.3.4", []byte("second")))
+		s.respond(messageID, mockExtendedResponse(ResultSuccess, "", []byte("done")))
+	})
+	defer l.Close()
+
+	var values []string
+	req := NewExtendedRequest("1.2.3.4", nil)
+	req.IntermediateHandler = func(response *IntermediateResponse) {
+		if response.Name != "1.2.3.4" {
+			t.Errorf("Unexpected intermediate response name %q", response.Name)
+		}
+		values = append(values, string(response.Value))
+	}
+	response, err := l.Extended(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(response.Value) != "done" {
+		t.Errorf("Intermediate response returned as final response: %q", response.Value)
+	}
+	if len(values) != 2 || values[0] != "first" || values[1] != "second" {
+		t.Errorf("Unexpected intermediate responses %q", values)
+	}
+}
@@ -0,0 +1,102 @@
+package ldap
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ExtendedDN is a DN returned with the ExtendedDN control, split into the DN
+// and the objectGUID and objectSid of the entry in their standard string form.
+type ExtendedDN struct {
+	DN   string
+	GUID string
+	SID  string
+}
+
+// ParseExtendedDN parses the <GUID=...>;<SID=...>;dn form returned by Active
+// Directory for the ExtendedDN control, in either format of the control. It
+// also parses the values of DN attributes like member searched with the control.
+func ParseExtendedDN(s string) (*ExtendedDN, error) {
+	extended := new(ExtendedDN)
+	for strings.HasPrefix(s, "<") {
+		end := strings.Index(s, ">")
+		if end == -1 {
+			return nil, newError(ErrorDecoding, "Unterminated extended DN component: "+s)
+		}
+		component := s[1:end]
+		s = strings.TrimPrefix(s[end+1:], ";")
+
+		equals := strings.Index(component, "=")
+		if equals == -1 {
+			return nil, newError(ErrorDecoding, "Invalid extended DN component: "+component)
+		}
+		name, value := component[:equals], component[equals+1:]
+		var err error
+		switch strings.ToUpper(name) {
+		case "GUID":
+			extended.GUID, err = parseExtendedGUID(value)
+		case "SID":
+			extended.SID, err = parseExtendedSID(value)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	extended.DN = s
+	return extended, nil
+}
+
+func parseExtendedGUID(value string) (string, error) {
+	if strings.Contains(value, "-") {
+		return strings.ToLower(value), nil
+	}
+	b, err := hex.DecodeString(value)
+	if err != nil {
+		return "", newError(ErrorDecoding, "Invalid GUID in extended DN: "+value)
+	}
+	return FormatGUID(b)
+}
+
+func parseExtendedSID(value string) (string, error) {
+	if strings.HasPrefix(value, "S-") {
+		return value, nil
+	}
+	b, err := hex.DecodeString(value)
+	if err != nil {
+		return "", newError(ErrorDecoding, "Invalid SID in extended DN: "+value)
+	}
+	return FormatSID(b)
+}
+
+// FormatGUID returns the binary objectGUID b in the standard string form,
+// e.g. "edb8e3f1-7dd4-4a4f-a5a5-ec5e6670cbbc".
+func FormatGUID(b []byte) (string, error) {
+	if len(b) != 16 {
+		return "", newError(ErrorDecoding, fmt.Sprintf("GUID has %d bytes instead of 16", len(b)))
+	}
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(b[0:4]),
+		binary.LittleEndian.Uint16(b[4:6]),
+		binary.LittleEndian.Uint16(b[6:8]),
+		b[8:10],
+		b[10:16]), nil
+}
+
+// FormatSID returns the binary objectSid b in the standard string form,
+// e.g. "S-1-5-21-2562418665-3218585558-1813906818-1576".
+func FormatSID(b []byte) (string, error) {
+	if len(b) < 8 || len(b) != 8+4*int(b[1]) {
+		return "", newError(ErrorDecoding, "Invalid SID")
+	}
+	var authority uint64
+	for _, octet := range b[2:8] {
+		authority = authority<<8 | uint64(octet)
+	}
+	sid := fmt.Sprintf("S-%d-%d", b[0], authority)
+	for i := 8; i < len(b); i += 4 {
+		sid += fmt.Sprintf("-%d", binary.LittleEndian.Uint32(b[i:i+4]))
+	}
+	return sid, nil
+}
@@ -0,0 +1,53 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"testing"
+)
+
+var extendedDNTests = []string{
+	"<GUID=f1e3b8edd47d4f4aa5a5ec5e6670cbbc>;<SID=010400000000000515000000010000000200000003000000>;CN=Bob,DC=example,DC=com",
+	"<GUID=EDB8E3F1-7DD4-4A4F-A5A5-EC5E6670CBBC>;<SID=S-1-5-21-1-2-3>;CN=Bob,DC=example,DC=com",
+}
+
+func TestParseExtendedDN(t *testing.T) {
+	for _, s := range extendedDNTests {
+		extended, err := ParseExtendedDN(s)
+		if err != nil {
+			t.Errorf("%s: %s", s, err)
+			continue
+		}
+		if extended.DN != "CN=Bob,DC=example,DC=com" || extended.GUID != "edb8e3f1-7dd4-4a4f-a5a5-ec5e6670cbbc" || extended.SID != "S-1-5-21-1-2-3" {
+			t.Errorf("%s: unexpected %+v", s, extended)
+		}
+	}
+
+	// no SID for entries without objectSid
+	extended, err := ParseExtendedDN("<GUID=f1e3b8edd47d4f4aa5a5ec5e6670cbbc>;OU=Staff,DC=example,DC=com")
+	if err != nil || extended.SID != "" || extended.DN != "OU=Staff,DC=example,DC=com" {
+		t.Errorf("Unexpected %+v, %v", extended, err)
+	}
+
+	if _, err := ParseExtendedDN("<GUID=f1e3b8ed;CN=Bob"); err == nil {
+		t.Error("Expected an error for an unterminated component")
+	}
+}
+
+func TestSearchExtendedDN(t *testing.T) {
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		s.respond(mockMessageID(request), mockSearchEntry(extendedDNTests[0], nil))
+		s.respondResult(mockMessageID(request), ApplicationSearchResultDone, ResultSuccess, "")
+	})
+	defer l.Close()
+
+	searchRequest := NewSimpleSearchRequest("DC=example,DC=com", ScopeWholeSubtree, "(cn=Bob)", nil)
+	searchRequest.AddControl(NewControlExtendedDNRequest(ExtendedDNHexString))
+	result, err := l.Search(searchRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := result.Entries[0]
+	if entry.DN != "CN=Bob,DC=example,DC=com" || entry.GUID != "edb8e3f1-7dd4-4a4f-a5a5-ec5e6670cbbc" || entry.SID != "S-1-5-21-1-2-3" {
+		t.Errorf("Unexpected entry %q %q %q", entry.DN, entry.GUID, entry.SID)
+	}
+}
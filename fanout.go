@@ -0,0 +1,85 @@
+package ldap
+
+import (
+	"context"
+	"sync"
+)
+
+// AttributeNamingContexts is the attribute of the RootDSE listing the base
+// DNs of the naming contexts of the server [https://tools.ietf.org/html/rfc4512#section-5.1.2]
+const AttributeNamingContexts = "namingContexts"
+
+// BaseSearchResult is the search of one base DN of SearchBases.
+type BaseSearchResult struct {
+	BaseDN string
+	// Result of the search, with the entries received before an error
+	Result *SearchResult
+	Err    error
+}
+
+// MultiSearchResult holds the searches of SearchBases.
+type MultiSearchResult struct {
+	// Entries and Referrals of all searches, in the order of the base DNs
+	Entries   []*Entry
+	Referrals []string
+	// Bases holds the searches of the base DNs in their order
+	Bases []*BaseSearchResult
+}
+
+// Err returns the error of the first base DN whose search failed, nil if
+// all succeeded.
+func (r *MultiSearchResult) Err() error {
+	for _, base := range r.Bases {
+		if base.Err != nil {
+			return base.Err
+		}
+	}
+	return nil
+}
+
+// SearchBases runs searchRequest with each of baseDNs as the base DN
+// concurrently, or with the naming contexts of the RootDSE if none are
+// given, e.g. to search all partitions of a server. The searches failing
+// don't stop the others, see BaseSearchResult.Err and MultiSearchResult.Err;
+// the error returned is the one of reading the naming contexts. Entries
+// below several of the base DNs are returned for each, see
+// MultiSearchResult.Dedupe.
+func (l *Connection) SearchBases(searchRequest *SearchRequest, baseDNs ...string) (*MultiSearchResult, error) {
+	return l.SearchBasesContext(context.Background(), searchRequest, baseDNs...)
+}
+
+// SearchBasesContext is SearchBases with ctx.
+func (l *Connection) SearchBasesContext(ctx context.Context, searchRequest *SearchRequest, baseDNs ...string) (*MultiSearchResult, error) {
+	if len(baseDNs) == 0 {
+		rootDSE, err := l.ReadEntryContext(ctx, "", AttributeNamingContexts)
+		if err != nil {
+			return nil, err
+		}
+		baseDNs = rootDSE.GetAttributeValues(AttributeNamingContexts)
+	}
+
+	result := &MultiSearchResult{
+		Entries:   make([]*Entry, 0),
+		Referrals: make([]string, 0),
+		Bases:     make([]*BaseSearchResult, len(baseDNs)),
+	}
+	var wg sync.WaitGroup
+	for i, baseDN := range baseDNs {
+		base := &BaseSearchResult{BaseDN: baseDN}
+		result.Bases[i] = base
+		req := *searchRequest
+		req.BaseDN = baseDN
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			base.Result, base.Err = l.SearchContext(ctx, &req)
+		}()
+	}
+	wg.Wait()
+
+	for _, base := range result.Bases {
+		result.Entries = append(result.Entries, base.Result.Entries...)
+		result.Referrals = append(result.Referrals, base.Result.Referrals...)
+	}
+	return result, nil
+}
@@ -0,0 +1,49 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"testing"
+)
+
+func TestSearchBases(t *testing.T) {
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		messageID := mockMessageID(request)
+		switch baseDN := packetString(request.Children[1].Children[0]); baseDN {
+		case "":
+			s.respond(messageID, mockSearchEntry("", map[string][]string{AttributeNamingContexts: {"o=east", "o=west"}}))
+			s.respondResult(messageID, ApplicationSearchResultDone, ResultSuccess, "")
+		case "o=missing":
+			s.respondResult(messageID, ApplicationSearchResultDone, ResultNoSuchObject, "")
+		default:
+			s.respond(messageID, mockSearchEntry("cn=bob,"+baseDN, map[string][]string{"cn": {"bob"}}))
+			s.respondResult(messageID, ApplicationSearchResultDone, ResultSuccess, "")
+		}
+	})
+	defer l.Close()
+
+	searchRequest := NewSearchRequest("", ScopeWholeSubtree, NeverDerefAliases, 0, 0, false, "(cn=bob)", nil, nil)
+	result, err := l.SearchBases(searchRequest, "o=north", "o=missing", "o=south")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 2 || result.Entries[0].DN != "cn=bob,o=north" || result.Entries[1].DN != "cn=bob,o=south" {
+		t.Errorf("Unexpected entries %v", result.Entries)
+	}
+	if lerr, ok := result.Err().(*Error); !ok || lerr.ResultCode != ResultNoSuchObject || result.Bases[1].BaseDN != "o=missing" || result.Bases[1].Err == nil {
+		t.Errorf("Expected the error of o=missing, got %v", result.Err())
+	}
+	if result.Bases[0].Err != nil || len(result.Bases[2].Result.Entries) != 1 {
+		t.Errorf("Unexpected searches %v %v", result.Bases[0], result.Bases[2])
+	}
+	if searchRequest.BaseDN != "" {
+		t.Error("The search request was changed")
+	}
+
+	result, err = l.SearchBases(searchRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Bases) != 2 || result.Bases[1].BaseDN != "o=west" || len(result.Entries) != 2 || result.Err() != nil {
+		t.Errorf("Expected the searches of the naming contexts, got %v", result.Bases)
+	}
+}
@@ -0,0 +1,37 @@
+package ldap
+
+import (
+	"context"
+)
+
+// ExtendedOperationFastBind is LDAP_SERVER_FAST_BIND_OID of Active Directory.
+const ExtendedOperationFastBind = "1.2.840.113556.1.4.1781"
+
+// FastBind puts the connection into the fast concurrent bind mode of Active
+// Directory [https://docs.microsoft.com/en-us/windows/win32/ad/fast-concurrent-binds].
+// Simple binds then only verify the username and password, without
+// building the security token of the user, and the connection stays
+// anonymous, so a single connection validates many credentials
+// concurrently, e.g. for an authentication gateway. The mode has to be
+// entered before the first bind and can't be left, SASL binds are refused
+// by the server. After a reconnect FastBind is sent again instead of the
+// last bind, unless there is a RebindHandler.
+func (l *Connection) FastBind() error {
+	return l.FastBindContext(context.Background())
+}
+
+// FastBindContext is FastBind with ctx.
+func (l *Connection) FastBindContext(ctx context.Context) error {
+	_, err := l.ExtendedContext(ctx, NewExtendedRequest(ExtendedOperationFastBind, nil))
+	if err != nil {
+		return err
+	}
+	l.lastBindLock.Lock()
+	defer l.lastBindLock.Unlock()
+	l.fastBind = true
+	l.lastBind = func(ctx context.Context) error {
+		_, err := l.ExtendedContext(ctx, NewExtendedRequest(ExtendedOperationFastBind, nil))
+		return err
+	}
+	return nil
+}
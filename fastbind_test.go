@@ -0,0 +1,66 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFastBind(t *testing.T) {
+	var searches int32
+	listener, servers := mockListener(t, func(s *mockServer, request *ber.Packet) {
+		messageID := mockMessageID(request)
+		switch request.Children[1].Tag {
+		case ber.Tag(ApplicationExtendedRequest):
+			s.respond(messageID, mockExtendedResponse(ResultSuccess, "", nil))
+		case ber.Tag(ApplicationBindRequest):
+			if packetString(request.Children[1].Children[2]) != "secret" {
+				s.respondResult(messageID, ApplicationBindResponse, ResultInvalidCredentials, "")
+				return
+			}
+			s.respondResult(messageID, ApplicationBindResponse, ResultSuccess, "")
+		case ber.Tag(ApplicationSearchRequest):
+			if atomic.AddInt32(&searches, 1) == 1 {
+				s.conn.Close()
+				return
+			}
+			s.respondResult(messageID, ApplicationSearchResultDone, ResultSuccess, "")
+		}
+	})
+	defer listener.Close()
+	l := NewConnection(listener.Addr().String())
+	l.AutoReconnect = true
+	if err := l.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if err := l.FastBind(); err != nil {
+		t.Fatal(err)
+	}
+	s := <-servers
+	if request := <-s.requests; packetString(request.Children[1].Children[0]) != ExtendedOperationFastBind {
+		t.Errorf("Expected the fast bind request, got %v", request.Children[1])
+	}
+	if err := l.Bind("cn=alice,o=bigcorp", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	err := l.Bind("cn=bob,o=bigcorp", "wrong")
+	if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ResultInvalidCredentials {
+		t.Errorf("Expected invalid credentials, got %v", err)
+	}
+
+	// the fast bind mode is entered again after a reconnect, without a bind
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", nil)
+	if _, err := l.Search(searchRequest); err != nil {
+		t.Fatal(err)
+	}
+	s = <-servers
+	request, search := <-s.requests, <-s.requests
+	if packetString(request.Children[1].Children[0]) != ExtendedOperationFastBind {
+		t.Errorf("Expected the fast bind request to be replayed, got %v", request.Children[1])
+	}
+	if search.Children[1].Tag != ber.Tag(ApplicationSearchRequest) {
+		t.Errorf("Expected the search to be retried, got %v", search.Children[1])
+	}
+}
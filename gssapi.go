@@ -0,0 +1,183 @@
+package ldap
+
+import (
+	"context"
+)
+
+// GSSAPIClient establishes the GSS-API [https://tools.ietf.org/html/rfc2743]
+// security contexts of GSSAPIMechanism, e.g. a KerberosClient.
+type GSSAPIClient interface {
+	// NewSecContext returns a new security context with the service
+	// principal target, e.g. "ldap/ldap.example.com". channelBinding is the
+	// application data of the channel bindings, nil without TLS.
+	NewSecContext(target string, channelBinding []byte) (GSSAPIContext, error)
+}
+
+// GSSAPIContext is a security context of a GSSAPIClient.
+type GSSAPIContext interface {
+	// Step returns the next token for the token of the service, nil for
+	// the first one, and whether the context is established.
+	Step(input []byte) (output []byte, done bool, err error)
+	// Wrap returns the token of message protected for integrity, and for
+	// confidentiality if confidential.
+	Wrap(message []byte, confidential bool) ([]byte, error)
+	// Unwrap returns the message of a token of the service.
+	Unwrap(token []byte) ([]byte, error)
+}
+
+// GSSAPIMechanism is the SASL GSSAPI mechanism [https://tools.ietf.org/html/rfc4752].
+// Without Layers no security layer is negotiated and the connection should
+// be protected with TLS, servers requiring signing or sealing, like Active
+// Directory with a LDAPServerIntegrity of 2, reject the bind then.
+type GSSAPIMechanism struct {
+	Client GSSAPIClient
+	// Target service principal, empty for "ldap/<host>"
+	Target string
+	// AuthzID to authorize as, empty for the identity of the client
+	AuthzID string
+	// Layers the client accepts, e.g. SASLLayerIntegrity|SASLLayerConfidentiality,
+	// the strongest one offered by the server is used; SASLLayerNone if 0
+	Layers int
+	// ChannelBinding type sent with TLS, e.g. ChannelBindingTLSUnique or
+	// ChannelBindingNone; empty for tls-server-end-point if available
+	ChannelBinding string
+
+	context     GSSAPIContext
+	established bool
+	negotiated  bool
+	layer       SASLSecurityLayer
+}
+
+// GSSAPIBind binds with the GSSAPI mechanism as the principal of client,
+// e.g. a KerberosClient.
+func (l *Connection) GSSAPIBind(client GSSAPIClient) (*LDAPResult, error) {
+	return l.GSSAPIBindContext(context.Background(), client)
+}
+
+// GSSAPIBindContext is GSSAPIBind with ctx.
+func (l *Connection) GSSAPIBindContext(ctx context.Context, client GSSAPIClient) (*LDAPResult, error) {
+	return l.SASLBindContext(ctx, &GSSAPIMechanism{Client: client})
+}
+
+func (m *GSSAPIMechanism) Name() string {
+	return "GSSAPI"
+}
+
+func (m *GSSAPIMechanism) Start(server *SASLServerInfo) ([]byte, error) {
+	channelBinding, err := gssapiChannelBinding(m.ChannelBinding, server)
+	if err != nil {
+		return nil, err
+	}
+	m.layer, m.negotiated = nil, false
+	if m.context, err = m.Client.NewSecContext(gssapiTarget(m.Target, server), channelBinding); err != nil {
+		return nil, err
+	}
+	token, done, err := m.context.Step(nil)
+	m.established = done
+	return token, err
+}
+
+func (m *GSSAPIMechanism) Next(challenge []byte, more bool) ([]byte, error) {
+	if !m.established {
+		token, done, err := m.context.Step(challenge)
+		if err != nil {
+			return nil, err
+		}
+		m.established = done
+		if more {
+			if token == nil {
+				token = []byte{}
+			}
+			return token, nil
+		}
+	}
+	if !more {
+		// the security layer is negotiated after the security context
+		if !m.negotiated {
+			return nil, newError(ErrorUnknown, "GSSAPI: the bind completed without the security layer negotiation")
+		}
+		return nil, nil
+	}
+
+	// the security layers offered by the server and its maximum message
+	// size, answered with the layer chosen and the authorization identity
+	offer, err := m.context.Unwrap(challenge)
+	if err != nil {
+		return nil, err
+	}
+	if len(offer) != 4 {
+		return nil, newError(ErrorDecoding, "GSSAPI: invalid security layer negotiation")
+	}
+	layers := m.Layers
+	if layers == 0 {
+		layers = SASLLayerNone
+	}
+	var chosen byte
+	for _, layer := range []byte{SASLLayerConfidentiality, SASLLayerIntegrity, SASLLayerNone} {
+		if chosen == 0 && offer[0]&layer != 0 && layers&int(layer) != 0 {
+			chosen = layer
+		}
+	}
+	switch chosen {
+	case 0:
+		return nil, newError(ResultConfidentialityRequired, "GSSAPI: the server requires a security layer, use TLS or Layers")
+	case SASLLayerNone:
+		// the maximum size is 0 without a layer
+		m.negotiated = true
+		return m.context.Wrap(append([]byte{SASLLayerNone, 0, 0, 0}, m.AuthzID...), false)
+	}
+	m.layer = &gssapiLayer{
+		context:      m.context,
+		confidential: chosen == SASLLayerConfidentiality,
+		maxSize:      int(offer[1])<<16 | int(offer[2])<<8 | int(offer[3]),
+	}
+	// with saslMaxBuffer as the maximum size of the client
+	m.negotiated = true
+	response := []byte{chosen, 0xff, 0xff, 0xff}
+	return m.context.Wrap(append(response, m.AuthzID...), false)
+}
+
+// SecurityLayer returns the layer negotiated by the last bind.
+func (m *GSSAPIMechanism) SecurityLayer() SASLSecurityLayer {
+	return m.layer
+}
+
+// gssapiLayer is the security layer of a GSSAPI security context.
+type gssapiLayer struct {
+	context      GSSAPIContext
+	confidential bool
+	// maxSize of the buffers received by the server
+	maxSize int
+}
+
+func (g *gssapiLayer) encrypts() bool {
+	return g.confidential
+}
+
+func (g *gssapiLayer) Wrap(message []byte) ([]byte, error) {
+	return g.context.Wrap(message, g.confidential)
+}
+
+func (g *gssapiLayer) Unwrap(buffer []byte) ([]byte, error) {
+	return g.context.Unwrap(buffer)
+}
+
+// MaxMessageSize leaves room for the header, confounder and checksum of the
+// tokens, 60 bytes of a sealed token with AES.
+func (g *gssapiLayer) MaxMessageSize() int {
+	if g.maxSize == 0 {
+		return 0
+	}
+	if g.maxSize <= 64 {
+		return 1
+	}
+	return g.maxSize - 64
+}
+
+// gssapiTarget returns target, "ldap/<host>" if empty.
+func gssapiTarget(target string, server *SASLServerInfo) string {
+	if target == "" {
+		return "ldap/" + server.Host
+	}
+	return target
+}
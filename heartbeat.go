@@ -0,0 +1,86 @@
+package ldap
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// HealthCheck probes whether the server of l still answers before ctx is
+// done, see Connection.HealthCheck.
+type HealthCheck func(ctx context.Context, l *Connection) error
+
+// RootDSEHealthCheck searches the RootDSE for no attributes, the default
+// HealthCheck. Any response of the server counts, also an error result like
+// insufficientAccessRights.
+func RootDSEHealthCheck(ctx context.Context, l *Connection) error {
+	searchRequest := NewSearchRequest("", ScopeBaseObject, NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{NoAttributes}, nil)
+	_, err := l.SearchContext(ctx, searchRequest)
+	return healthCheckError(err)
+}
+
+// WhoAmIHealthCheck sends a WhoAmI extended request, for servers that log or
+// restrict searches of the RootDSE. Any response of the server counts.
+func WhoAmIHealthCheck(ctx context.Context, l *Connection) error {
+	_, err := l.ExtendedContext(ctx, NewExtendedRequest(ExtendedOperationWhoAmI, nil))
+	return healthCheckError(err)
+}
+
+// healthCheckError returns nil for the error results of the server, only
+// errors of the connection fail a health check: no response in time, a
+// broken or closing connection. Other client-side errors, e.g. of decoding
+// the response, don't either.
+func healthCheckError(err error) error {
+	lerr, ok := err.(*Error)
+	if !ok {
+		return err
+	}
+	switch lerr.ResultCode {
+	case ErrorNetwork, ErrorClosing, ErrorTimeout, ErrorAbandoned:
+		return err
+	}
+	return nil
+}
+
+// heartbeatKey marks the context of a Heartbeat, which doesn't count as use
+// of the connection for MaxIdleTime
+type heartbeatKey struct{}
+
+// Heartbeat runs the HealthCheck, RootDSEHealthCheck if not set, to check
+// that the server is still answering, waiting at most HeartbeatInterval if
+// set.
+func (l *Connection) Heartbeat() error {
+	ctx := context.WithValue(context.Background(), heartbeatKey{}, true)
+	if l.HeartbeatInterval > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.HeartbeatInterval)
+		defer cancel()
+	}
+	healthCheck := l.HealthCheck
+	if healthCheck == nil {
+		healthCheck = RootDSEHealthCheck
+	}
+	return healthCheck(ctx, l)
+}
+
+// heartbeat runs Heartbeat every HeartbeatInterval until done is closed and
+// closes the connection on the first failure.
+func (l *Connection) heartbeat(done <-chan struct{}) {
+	ticker := time.NewTicker(l.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		if err := l.Heartbeat(); err != nil {
+			if l.Debug {
+				log.Printf("Heartbeat failed, closing the connection: %s", err)
+			}
+			l.setCloseError("Heartbeat failed: ", err)
+			l.disconnectSession(done)
+			return
+		}
+	}
+}
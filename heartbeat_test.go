@@ -0,0 +1,85 @@
+package ldap
+
+import (
+	"context"
+	"github.com/eaciit/asn1-ber"
+	"testing"
+	"time"
+)
+
+func TestHeartbeat(t *testing.T) {
+	l, s := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		if request.Children[1].Tag == ber.Tag(ApplicationSearchRequest) {
+			// the RootDSE isn't readable anonymously, still an answer
+			s.respondResult(mockMessageID(request), ApplicationSearchResultDone, ResultInsufficientAccessRights, "")
+		}
+	})
+	defer l.Close()
+	l.HeartbeatInterval = 10 * time.Millisecond
+	go l.heartbeat(l.chanDone)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case request := <-s.requests:
+			if request.Children[1].Tag != ber.Tag(ApplicationSearchRequest) || packetString(request.Children[1].Children[0]) != "" {
+				t.Fatalf("Unexpected heartbeat request %v", request.Children[1])
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("No heartbeat sent")
+		}
+	}
+	if err := l.Heartbeat(); err != nil {
+		t.Errorf("Heartbeat failed: %s", err)
+	}
+}
+
+func TestHeartbeatClosesDeadConnection(t *testing.T) {
+	// the server never answers
+	l, _ := newMockConnection(t, nil)
+	defer l.Close()
+	l.HeartbeatInterval = 10 * time.Millisecond
+	go l.heartbeat(l.chanDone)
+
+	select {
+	case <-l.chanDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Connection was not closed after a failed heartbeat")
+	}
+	if _, err := l.Delete(NewDeleteRequest("cn=bob,o=bigcorp")); err == nil {
+		t.Error("Expected operations to fail on the closed connection")
+	}
+}
+
+func TestWhoAmIHealthCheck(t *testing.T) {
+	l, s := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		if request.Children[1].Tag == ber.Tag(ApplicationExtendedRequest) {
+			s.respond(mockMessageID(request), mockExtendedResponse(ResultSuccess, "", nil))
+		}
+	})
+	defer l.Close()
+	l.HealthCheck = WhoAmIHealthCheck
+
+	if err := l.Heartbeat(); err != nil {
+		t.Fatalf("Heartbeat failed: %s", err)
+	}
+	request := <-s.requests
+	if request.Children[1].Tag != ber.Tag(ApplicationExtendedRequest) || packetString(request.Children[1].Children[0]) != ExtendedOperationWhoAmI {
+		t.Errorf("Unexpected health check request %v", request.Children[1])
+	}
+}
+
+func TestHealthCheckError(t *testing.T) {
+	for _, code := range []ResultCode{ResultInsufficientAccessRights, ResultBusy, ErrorDecoding, ResultNoOperation} {
+		if err := healthCheckError(newError(code, "")); err != nil {
+			t.Errorf("Expected %s to pass the health check", code)
+		}
+	}
+	for _, code := range []ResultCode{ErrorNetwork, ErrorClosing, ErrorTimeout, ErrorAbandoned} {
+		if err := healthCheckError(newError(code, "")); err == nil {
+			t.Errorf("Expected %s to fail the health check", code)
+		}
+	}
+	if err := healthCheckError(context.Canceled); err == nil {
+		t.Error("Expected a canceled health check to fail")
+	}
+}
@@ -0,0 +1,57 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+)
+
+/*
+IntermediateResponse ::= [APPLICATION 25] SEQUENCE {
+     responseName     [0] LDAPOID OPTIONAL,
+     responseValue    [1] OCTET STRING OPTIONAL }
+*/
+
+// IntermediateResponse is sent by the server before the final response of an
+// operation [https://tools.ietf.org/html/rfc4511#section-4.13], e.g. by
+// syncrepl or for transaction notices.
+type IntermediateResponse struct {
+	Name  string
+	Value []byte
+
+	// Response controls
+	Controls []Control
+}
+
+// IntermediateResponseHandler is called with the intermediate responses of an
+// operation in the order they arrive, before the operation returns.
+type IntermediateResponseHandler func(response *IntermediateResponse)
+
+func isIntermediateResponse(p *ber.Packet) bool {
+	return len(p.Children) >= 2 && p.Children[1].ClassType == ber.ClassApplication &&
+		p.Children[1].Tag == ber.Tag(ApplicationIntermediateResponse)
+}
+
+func decodeIntermediateResponse(p *ber.Packet) (*IntermediateResponse, error) {
+	if !isIntermediateResponse(p) {
+		return nil, newError(ErrorDecoding, "Invalid packet format")
+	}
+	response := new(IntermediateResponse)
+	for _, child := range p.Children[1].Children {
+		if child.ClassType != ber.ClassContext {
+			continue
+		}
+		switch child.Tag {
+		case 0:
+			response.Name = packetString(child)
+		case 1:
+			response.Value = child.Data.Bytes()
+		}
+	}
+	if len(p.Children) == 3 {
+		controls, err := decodeControls(p.Children[2])
+		if err != nil {
+			return nil, err
+		}
+		response.Controls = controls
+	}
+	return response, nil
+}
@@ -0,0 +1,1133 @@
+package ldap
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"github.com/eaciit/asn1-ber"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kerberos V5 [https://tools.ietf.org/html/rfc4120] client for GSSAPI binds,
+// only the AES encryption types are supported (Windows Server 2008 and
+// later, MIT Kerberos 1.4 and later).
+
+// DefaultKerberosTimeout is the time an exchange with a KDC may take if
+// KerberosClient has no Timeout.
+const DefaultKerberosTimeout = 10 * time.Second
+
+// name types of principals
+const (
+	kerberosNTPrincipal = 1
+	kerberosNTSrvInst   = 2
+	kerberosNTSrvHst    = 3
+)
+
+// message types, also the application tags of the messages
+const (
+	kerberosASReq    = 10
+	kerberosASRep    = 11
+	kerberosTGSReq   = 12
+	kerberosTGSRep   = 13
+	kerberosAPReq    = 14
+	kerberosAPRep    = 15
+	kerberosKRBError = 30
+)
+
+// application tags of the encrypted parts
+const (
+	kerberosTicket        = 1
+	kerberosAuthenticator = 2
+	kerberosEncASRepPart  = 25
+	kerberosEncTGSRepPart = 26
+	kerberosEncAPRepPart  = 27
+)
+
+// preauthentication data types
+const (
+	kerberosPATGSReq       = 1
+	kerberosPAEncTimestamp = 2
+	kerberosPAETypeInfo2   = 19
+)
+
+// key usages of RFC 4120 section 7.5.1 and RFC 4121 section 2
+const (
+	kerberosUsageASReqTimestamp = 1
+	kerberosUsageASRepEncPart   = 3
+	kerberosUsageTGSReqChecksum = 6
+	kerberosUsageTGSReqAuth     = 7
+	kerberosUsageTGSRepEncPart  = 8
+	kerberosUsageAPReqAuth      = 11
+	kerberosUsageAPRepEncPart   = 12
+	kerberosUsageAcceptorSeal   = 22
+	kerberosUsageAcceptorSign   = 23
+	kerberosUsageInitiatorSeal  = 24
+	kerberosUsageInitiatorSign  = 25
+)
+
+// KDC options: forwardable and canonicalize
+const kerberosKDCOptions = 0x40010000
+
+// AP options: mutual-required
+const kerberosAPOptions = 0x20000000
+
+var kerberosErrors = map[int64]string{
+	6:  "client not found in the database",
+	7:  "server not found in the database",
+	12: "policy rejects the request",
+	14: "encryption type not supported",
+	18: "client's credentials have been revoked",
+	23: "password has expired",
+	24: "preauthentication failed",
+	25: "additional preauthentication required",
+	31: "integrity check on decrypted field failed",
+	32: "ticket expired",
+	37: "clock skew too great",
+	41: "message stream modified",
+	68: "wrong realm",
+}
+
+// kerberosError returns the error of a KRB-ERROR, codes of failed
+// authentications return ResultInvalidCredentials.
+func kerberosError(code int64, text string) error {
+	reason, ok := kerberosErrors[code]
+	if !ok {
+		reason = fmt.Sprintf("error %d", code)
+	}
+	if text != "" {
+		reason += " (" + text + ")"
+	}
+	switch code {
+	case 6, 18, 23, 24:
+		return newError(ResultInvalidCredentials, "Kerberos: "+reason)
+	}
+	return newError(ErrorUnknown, "Kerberos: "+reason)
+}
+
+// kerberosPrincipal is a PrincipalName with its realm.
+type kerberosPrincipal struct {
+	nameType int32
+	names    []string
+	realm    string
+}
+
+// parseKerberosPrincipal parses name like "ldap/host.example.com", with the
+// realm defaulting to realm unless the name ends in "@REALM".
+func parseKerberosPrincipal(name string, nameType int32, realm string) kerberosPrincipal {
+	if at := strings.LastIndexByte(name, '@'); at >= 0 {
+		name, realm = name[:at], name[at+1:]
+	}
+	return kerberosPrincipal{nameType: nameType, names: strings.Split(name, "/"), realm: realm}
+}
+
+func (p kerberosPrincipal) equal(other kerberosPrincipal) bool {
+	if p.realm != other.realm || len(p.names) != len(other.names) {
+		return false
+	}
+	for i := range p.names {
+		if p.names[i] != other.names[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p kerberosPrincipal) String() string {
+	return strings.Join(p.names, "/") + "@" + p.realm
+}
+
+// principal returns the client of the ticket, client if unknown.
+func (c *kerberosCredential) principal(client kerberosPrincipal) kerberosPrincipal {
+	if len(c.client.names) == 0 {
+		return client
+	}
+	return c.client
+}
+
+// KerberosClient authenticates a principal with a password, a Keytab or the
+// tickets of a CCache and establishes Kerberos security contexts as the
+// GSSAPIClient of GSSAPIMechanism. The tickets are cached, a KerberosClient
+// can be shared by several connections.
+type KerberosClient struct {
+	// Username and Realm of the principal, e.g. "alice" and "EXAMPLE.COM",
+	// taken from CCache if empty
+	Username string
+	Realm    string
+	// credentials, one of them is required
+	Password string
+	Keytab   *Keytab
+	CCache   *CCache
+	// KDCs as host or host:port, empty for the servers announced by the
+	// SRV records _kerberos._tcp.<Realm>
+	KDCs []string
+	// Timeout of an exchange with a KDC, DefaultKerberosTimeout if 0
+	Timeout time.Duration
+	// DialContext dials the KDCs, a net.Dialer if nil
+	DialContext DialContextFunc
+
+	lock    sync.Mutex
+	tgt     *kerberosCredential
+	tickets map[string]*kerberosCredential
+}
+
+// NewKerberosClientWithPassword returns a KerberosClient of username in realm
+// authenticating with password.
+func NewKerberosClientWithPassword(username, realm, password string) *KerberosClient {
+	return &KerberosClient{Username: username, Realm: realm, Password: password}
+}
+
+// NewKerberosClientWithKeytab returns a KerberosClient of username in realm
+// authenticating with its key in keytab.
+func NewKerberosClientWithKeytab(username, realm string, keytab *Keytab) *KerberosClient {
+	return &KerberosClient{Username: username, Realm: realm, Keytab: keytab}
+}
+
+// NewKerberosClientWithCCache returns a KerberosClient using the tickets of
+// ccache, e.g. of kinit.
+func NewKerberosClientWithCCache(ccache *CCache) *KerberosClient {
+	return &KerberosClient{CCache: ccache}
+}
+
+// NewSecContext returns a new Kerberos security context with the service
+// principal target, e.g. "ldap/ldap.example.com".
+func (c *KerberosClient) NewSecContext(target string, channelBinding []byte) (GSSAPIContext, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout())
+	defer cancel()
+	client := c.principal()
+	ticket, err := c.serviceTicket(ctx, parseKerberosPrincipal(target, kerberosNTSrvHst, client.realm))
+	if err != nil {
+		return nil, err
+	}
+	return &kerberosContext{
+		client:         ticket.principal(client),
+		ticket:         ticket,
+		channelBinding: channelBinding,
+		flags:          gssMutualFlag | gssSequenceFlag | gssConfFlag | gssIntegFlag,
+	}, nil
+}
+
+func (c *KerberosClient) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return DefaultKerberosTimeout
+}
+
+// principal returns the client principal.
+func (c *KerberosClient) principal() kerberosPrincipal {
+	if c.Username == "" && c.CCache != nil {
+		return c.CCache.principal
+	}
+	return parseKerberosPrincipal(c.Username, kerberosNTPrincipal, c.Realm)
+}
+
+// serviceTicket returns the cached or a new ticket for server.
+func (c *KerberosClient) serviceTicket(ctx context.Context, server kerberosPrincipal) (*kerberosCredential, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if ticket := c.tickets[server.String()]; ticket != nil && time.Now().Before(ticket.endTime) {
+		return ticket, nil
+	}
+	if c.CCache != nil {
+		if ticket := c.CCache.credential(server); ticket != nil {
+			return ticket, nil
+		}
+	}
+
+	client := c.principal()
+	if c.tgt == nil || !time.Now().Before(c.tgt.endTime) {
+		krbtgt := kerberosPrincipal{kerberosNTSrvInst, []string{"krbtgt", client.realm}, client.realm}
+		if c.CCache != nil && c.CCache.credential(krbtgt) != nil {
+			c.tgt = c.CCache.credential(krbtgt)
+		} else if c.Password != "" || c.Keytab != nil {
+			tgt, err := c.asExchange(ctx, client, krbtgt)
+			if err != nil {
+				return nil, err
+			}
+			c.tgt = tgt
+		} else {
+			return nil, newError(ErrorInvalidArgument, "Kerberos: no password, keytab or ticket granting ticket for "+client.String())
+		}
+	}
+
+	ticket, err := c.tgsExchange(ctx, client, server)
+	if err != nil {
+		return nil, err
+	}
+	if c.tickets == nil {
+		c.tickets = make(map[string]*kerberosCredential)
+	}
+	c.tickets[server.String()] = ticket
+	return ticket, nil
+}
+
+// etypes returns the supported encryption types, with a keytab those of the
+// keys of client.
+func (c *KerberosClient) etypes(client kerberosPrincipal) []int32 {
+	etypes := []int32{KerberosAES256CTSHMACSHA196, KerberosAES128CTSHMACSHA196}
+	if c.Password != "" || c.Keytab == nil {
+		return etypes
+	}
+	var keys []int32
+	for _, etype := range etypes {
+		if _, ok := c.Keytab.key(client, etype); ok {
+			keys = append(keys, etype)
+		}
+	}
+	return keys
+}
+
+// clientKey returns the key of client for etype from the password or the
+// keytab.
+func (c *KerberosClient) clientKey(client kerberosPrincipal, etype int32, salt string, params []byte) (kerberosKey, error) {
+	if c.Password != "" {
+		return kerberosStringToKey(etype, c.Password, salt, params)
+	}
+	if key, ok := c.Keytab.key(client, etype); ok {
+		return key, nil
+	}
+	return kerberosKey{}, newError(ErrorInvalidArgument, fmt.Sprintf("Kerberos: no key of encryption type %d for %s in the keytab", etype, client))
+}
+
+// asExchange requests a ticket granting ticket for client, with the
+// encrypted timestamp if the KDC requires preauthentication.
+func (c *KerberosClient) asExchange(ctx context.Context, client, krbtgt kerberosPrincipal) (*kerberosCredential, error) {
+	etypes := c.etypes(client)
+	if len(etypes) == 0 {
+		return nil, newError(ErrorInvalidArgument, "Kerberos: no AES key for "+client.String()+" in the keytab")
+	}
+	nonce := kerberosNonce()
+	body := encodeKDCReqBody(&client, krbtgt, nonce, etypes)
+	reply, err := c.exchange(ctx, client.realm, encodeKDCReq(kerberosASReq, nil, body))
+	if err != nil {
+		return nil, err
+	}
+
+	etype, salt, params := etypes[0], client.realm+strings.Join(client.names, ""), []byte(nil)
+	if reply.Tag == kerberosKRBError {
+		code, _ := packetInt64(krbField(reply.Children[0], 6))
+		if code != 25 {
+			return nil, decodeKRBError(reply)
+		}
+		// the salt and iterations of the key from the ETYPE-INFO2
+		if etype, salt, params, err = decodePreauthRequired(reply, etypes, salt); err != nil {
+			return nil, err
+		}
+		key, err := c.clientKey(client, etype, salt, params)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now().UTC()
+		timestamp := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "PA-ENC-TS-ENC")
+		timestamp.AppendChild(krbTag(0, krbTime(now)))
+		timestamp.AppendChild(krbTag(1, krbInt(int64(now.Nanosecond()/1000))))
+		encrypted, err := key.encrypt(kerberosUsageASReqTimestamp, timestamp.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		padata := encodePAData(kerberosPAEncTimestamp, encodeEncryptedData(etype, encrypted).Bytes())
+		if reply, err = c.exchange(ctx, client.realm, encodeKDCReq(kerberosASReq, padata, body)); err != nil {
+			return nil, err
+		}
+		if reply.Tag == kerberosKRBError {
+			return nil, decodeKRBError(reply)
+		}
+	} else if reply.Tag == kerberosASRep {
+		// without preauthentication
+		rep := reply.Children[0]
+		if padata := krbField(rep, 2); padata != nil {
+			etype, salt, params, _ = decodeETypeInfo2(padata, etypes, salt)
+		}
+		if encPart := krbField(rep, 6); encPart != nil {
+			if e, ok := packetInt64(krbField(encPart, 0)); ok {
+				etype = int32(e)
+			}
+		}
+	}
+	if reply.Tag != kerberosASRep {
+		return nil, newError(ErrorDecoding, fmt.Sprintf("Kerberos: unexpected reply %d to AS-REQ", reply.Tag))
+	}
+	key, err := c.clientKey(client, etype, salt, params)
+	if err != nil {
+		return nil, err
+	}
+	return decodeKDCRep(reply, key, kerberosUsageASRepEncPart, nonce)
+}
+
+// tgsExchange requests a ticket for server with the ticket granting ticket.
+func (c *KerberosClient) tgsExchange(ctx context.Context, client, server kerberosPrincipal) (*kerberosCredential, error) {
+	nonce := kerberosNonce()
+	body := encodeKDCReqBody(nil, server, nonce, []int32{KerberosAES256CTSHMACSHA196, KerberosAES128CTSHMACSHA196})
+	checksum := encodeChecksum(c.tgt.key.checksumType(), c.tgt.key.checksum(kerberosUsageTGSReqChecksum, body.Bytes()))
+	authenticator := encodeAuthenticator(c.tgt.principal(client), checksum, time.Now().UTC(), nil, 0)
+	apReq, err := encodeAPReq(c.tgt, 0, authenticator, kerberosUsageTGSReqAuth)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.exchange(ctx, client.realm, encodeKDCReq(kerberosTGSReq, encodePAData(kerberosPATGSReq, apReq.Bytes()), body))
+	if err != nil {
+		return nil, err
+	}
+	switch reply.Tag {
+	case kerberosKRBError:
+		return nil, decodeKRBError(reply)
+	case kerberosTGSRep:
+		return decodeKDCRep(reply, c.tgt.key, kerberosUsageTGSRepEncPart, nonce)
+	}
+	return nil, newError(ErrorDecoding, fmt.Sprintf("Kerberos: unexpected reply %d to TGS-REQ", reply.Tag))
+}
+
+// exchange sends request to the KDCs of realm over TCP and returns the reply
+// of the first KDC answering.
+func (c *KerberosClient) exchange(ctx context.Context, realm string, request *ber.Packet) (*ber.Packet, error) {
+	kdcs := c.KDCs
+	if len(kdcs) == 0 {
+		_, records, err := lookupSRV("kerberos", "tcp", realm)
+		if err != nil {
+			return nil, newError(ErrorNetwork, "Kerberos: SRV lookup of the KDCs failed: "+err.Error())
+		}
+		for _, record := range records {
+			if target := strings.TrimSuffix(record.Target, "."); target != "" {
+				kdcs = append(kdcs, net.JoinHostPort(target, strconv.Itoa(int(record.Port))))
+			}
+		}
+	}
+	if len(kdcs) == 0 {
+		return nil, newError(ErrorNetwork, "Kerberos: no KDC of "+realm)
+	}
+
+	message := make([]byte, 4, 4+request.Data.Len()+8)
+	message = append(message, request.Bytes()...)
+	binary.BigEndian.PutUint32(message, uint32(len(message)-4))
+	var lastErr error
+	for _, kdc := range kdcs {
+		if _, _, err := net.SplitHostPort(kdc); err != nil {
+			kdc = net.JoinHostPort(kdc, "88")
+		}
+		reply, err := c.exchangeKDC(ctx, kdc, message)
+		if err == nil {
+			return reply, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, newError(ErrorNetwork, "Kerberos: "+lastErr.Error())
+}
+
+func (c *KerberosClient) exchangeKDC(ctx context.Context, kdc string, message []byte) (*ber.Packet, error) {
+	dial := c.DialContext
+	if dial == nil {
+		var dialer net.Dialer
+		dial = dialer.DialContext
+	}
+	conn, err := dial(ctx, "tcp", kdc)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write(message); err != nil {
+		return nil, err
+	}
+	length := make([]byte, 4)
+	if _, err := io.ReadFull(conn, length); err != nil {
+		return nil, err
+	}
+	if binary.BigEndian.Uint32(length) > 1<<20 {
+		return nil, fmt.Errorf("reply of %s too large", kdc)
+	}
+	reply := make([]byte, binary.BigEndian.Uint32(length))
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return nil, err
+	}
+	packet, err := ber.ReadPacket(bytes.NewReader(reply))
+	if err != nil {
+		return nil, err
+	}
+	if packet.ClassType != ber.ClassApplication || len(packet.Children) != 1 {
+		return nil, fmt.Errorf("invalid reply of %s", kdc)
+	}
+	return packet, nil
+}
+
+/*
+KDC-REQ ::= SEQUENCE {
+        pvno            [1] INTEGER (5) ,
+        msg-type        [2] INTEGER (10 -- AS -- | 12 -- TGS --),
+        padata          [3] SEQUENCE OF PA-DATA OPTIONAL,
+        req-body        [4] KDC-REQ-BODY }
+
+KDC-REQ-BODY ::= SEQUENCE {
+        kdc-options             [0] KDCOptions,
+        cname                   [1] PrincipalName OPTIONAL,
+        realm                   [2] Realm,
+        sname                   [3] PrincipalName OPTIONAL,
+        till                    [5] KerberosTime,
+        nonce                   [7] UInt32,
+        etype                   [8] SEQUENCE OF Int32 }
+*/
+
+func encodeKDCReq(msgType int, padata *ber.Packet, body *ber.Packet) *ber.Packet {
+	request := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(msgType), nil, "KDC-REQ")
+	sequence := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "KDC-REQ")
+	sequence.AppendChild(krbTag(1, krbInt(5)))
+	sequence.AppendChild(krbTag(2, krbInt(int64(msgType))))
+	if padata != nil {
+		padatas := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "PA-DATA")
+		padatas.AppendChild(padata)
+		sequence.AppendChild(krbTag(3, padatas))
+	}
+	sequence.AppendChild(krbTag(4, body))
+	request.AppendChild(sequence)
+	return request
+}
+
+func encodeKDCReqBody(client *kerberosPrincipal, server kerberosPrincipal, nonce int64, etypes []int32) *ber.Packet {
+	body := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "KDC-REQ-BODY")
+	body.AppendChild(krbTag(0, krbBitString(kerberosKDCOptions)))
+	if client != nil {
+		body.AppendChild(krbTag(1, encodePrincipalName(*client)))
+	}
+	body.AppendChild(krbTag(2, krbString(server.realm)))
+	body.AppendChild(krbTag(3, encodePrincipalName(server)))
+	body.AppendChild(krbTag(5, krbTime(time.Now().Add(24*time.Hour))))
+	body.AppendChild(krbTag(7, krbInt(nonce)))
+	list := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "etype")
+	for _, etype := range etypes {
+		list.AppendChild(krbInt(int64(etype)))
+	}
+	body.AppendChild(krbTag(8, list))
+	return body
+}
+
+func encodePAData(padataType int, value []byte) *ber.Packet {
+	padata := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "PA-DATA")
+	padata.AppendChild(krbTag(1, krbInt(int64(padataType))))
+	padata.AppendChild(krbTag(2, krbOctets(value)))
+	return padata
+}
+
+/*
+KRB-ERROR       ::= [APPLICATION 30] SEQUENCE {
+        ...
+        error-code      [6] Int32,
+        e-text          [11] KerberosString OPTIONAL,
+        e-data          [12] OCTET STRING OPTIONAL }
+*/
+
+func decodeKRBError(reply *ber.Packet) error {
+	code, _ := packetInt64(krbField(reply.Children[0], 6))
+	var text string
+	if eText := krbField(reply.Children[0], 11); eText != nil {
+		text = packetString(eText)
+	}
+	return kerberosError(code, text)
+}
+
+// decodePreauthRequired returns the encryption type, salt and parameters of
+// the ETYPE-INFO2 in the METHOD-DATA of the KDC_ERR_PREAUTH_REQUIRED.
+func decodePreauthRequired(reply *ber.Packet, etypes []int32, salt string) (int32, string, []byte, error) {
+	eData := krbField(reply.Children[0], 12)
+	if eData == nil {
+		return 0, "", nil, newError(ErrorDecoding, "Kerberos: no METHOD-DATA in KDC_ERR_PREAUTH_REQUIRED")
+	}
+	methods, err := ber.ReadPacket(bytes.NewReader(eData.Data.Bytes()))
+	if err != nil {
+		return 0, "", nil, newError(ErrorDecoding, "Kerberos: invalid METHOD-DATA: "+err.Error())
+	}
+	return decodeETypeInfo2(methods, etypes, salt)
+}
+
+/*
+ETYPE-INFO2-ENTRY       ::= SEQUENCE {
+        etype           [0] Int32,
+        salt            [1] KerberosString OPTIONAL,
+        s2kparams       [2] OCTET STRING OPTIONAL }
+*/
+
+// decodeETypeInfo2 returns the first entry of the PA-ETYPE-INFO2 in padata
+// of a supported encryption type.
+func decodeETypeInfo2(padatas *ber.Packet, etypes []int32, salt string) (int32, string, []byte, error) {
+	for _, padata := range padatas.Children {
+		if typ, _ := packetInt64(krbField(padata, 1)); typ != kerberosPAETypeInfo2 || krbField(padata, 2) == nil {
+			continue
+		}
+		info, err := ber.ReadPacket(bytes.NewReader(krbField(padata, 2).Data.Bytes()))
+		if err != nil {
+			return 0, "", nil, newError(ErrorDecoding, "Kerberos: invalid ETYPE-INFO2: "+err.Error())
+		}
+		for _, entry := range info.Children {
+			etype, _ := packetInt64(krbField(entry, 0))
+			for _, supported := range etypes {
+				if int32(etype) != supported {
+					continue
+				}
+				if s := krbField(entry, 1); s != nil {
+					salt = packetString(s)
+				}
+				var params []byte
+				if p := krbField(entry, 2); p != nil {
+					params = p.Data.Bytes()
+				}
+				return supported, salt, params, nil
+			}
+		}
+	}
+	return 0, "", nil, newError(ErrorUnknown, "Kerberos: the KDC offers no supported encryption type")
+}
+
+/*
+KDC-REP         ::= SEQUENCE {
+        pvno            [0] INTEGER (5),
+        msg-type        [1] INTEGER (11 -- AS -- | 13 -- TGS --),
+        padata          [2] SEQUENCE OF PA-DATA OPTIONAL,
+        crealm          [3] Realm,
+        cname           [4] PrincipalName,
+        ticket          [5] Ticket,
+        enc-part        [6] EncryptedData }
+
+EncKDCRepPart   ::= SEQUENCE {
+        key             [0] EncryptionKey,
+        nonce           [2] UInt32,
+        endtime         [7] KerberosTime,
+        srealm          [9] Realm,
+        sname           [10] PrincipalName,
+        ... }
+*/
+
+// decodeKDCRep decrypts the KDC-REP with key and returns the ticket with its
+// session key.
+func decodeKDCRep(reply *ber.Packet, key kerberosKey, usage uint32, nonce int64) (*kerberosCredential, error) {
+	rep := reply.Children[0]
+	ticket, encPart := krbField(rep, 5), krbField(rep, 6)
+	if ticket == nil || encPart == nil || krbField(rep, 4) == nil {
+		return nil, newError(ErrorDecoding, "Kerberos: invalid KDC-REP")
+	}
+	part, err := decryptEncryptedData(encPart, key, usage)
+	if err != nil {
+		return nil, err
+	}
+	if part.ClassType != ber.ClassApplication || (part.Tag != kerberosEncASRepPart && part.Tag != kerberosEncTGSRepPart) || len(part.Children) != 1 {
+		return nil, newError(ErrorDecoding, "Kerberos: invalid EncKDCRepPart")
+	}
+	encRep := part.Children[0]
+	if n, _ := packetInt64(krbField(encRep, 2)); n != nonce {
+		return nil, newError(ErrorDecoding, "Kerberos: nonce of the KDC-REP doesn't match")
+	}
+	credential := &kerberosCredential{
+		client: decodePrincipalName(krbField(rep, 4), packetString(krbField(rep, 3))),
+		ticket: ticket.Bytes(),
+	}
+	if credential.key, err = decodeEncryptionKey(krbField(encRep, 0)); err != nil {
+		return nil, err
+	}
+	if sname := krbField(encRep, 10); sname != nil {
+		credential.server = decodePrincipalName(sname, packetString(krbField(encRep, 9)))
+	}
+	endTime := krbField(encRep, 7)
+	if endTime == nil {
+		return nil, newError(ErrorDecoding, "Kerberos: no endtime in EncKDCRepPart")
+	}
+	if credential.endTime, err = time.Parse("20060102150405Z", packetString(endTime)); err != nil {
+		return nil, newError(ErrorDecoding, "Kerberos: invalid endtime "+packetString(endTime))
+	}
+	return credential, nil
+}
+
+/*
+AP-REQ          ::= [APPLICATION 14] SEQUENCE {
+        pvno            [0] INTEGER (5),
+        msg-type        [1] INTEGER (14),
+        ap-options      [2] APOptions,
+        ticket          [3] Ticket,
+        authenticator   [4] EncryptedData -- Authenticator }
+
+Authenticator   ::= [APPLICATION 2] SEQUENCE  {
+        authenticator-vno       [0] INTEGER (5),
+        crealm                  [1] Realm,
+        cname                   [2] PrincipalName,
+        cksum                   [3] Checksum OPTIONAL,
+        cusec                   [4] Microseconds,
+        ctime                   [5] KerberosTime,
+        subkey                  [6] EncryptionKey OPTIONAL,
+        seq-number              [7] UInt32 OPTIONAL }
+*/
+
+func encodeAPReq(credential *kerberosCredential, options uint32, authenticator *ber.Packet, usage uint32) (*ber.Packet, error) {
+	ticket, err := ber.ReadPacket(bytes.NewReader(credential.ticket))
+	if err != nil {
+		return nil, newError(ErrorDecoding, "Kerberos: invalid ticket: "+err.Error())
+	}
+	encrypted, err := credential.key.encrypt(usage, authenticator.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	apReq := ber.Encode(ber.ClassApplication, ber.TypeConstructed, kerberosAPReq, nil, "AP-REQ")
+	sequence := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "AP-REQ")
+	sequence.AppendChild(krbTag(0, krbInt(5)))
+	sequence.AppendChild(krbTag(1, krbInt(kerberosAPReq)))
+	sequence.AppendChild(krbTag(2, krbBitString(options)))
+	sequence.AppendChild(krbTag(3, ticket))
+	sequence.AppendChild(krbTag(4, encodeEncryptedData(credential.key.etype, encrypted)))
+	apReq.AppendChild(sequence)
+	return apReq, nil
+}
+
+// encodeAuthenticator returns the Authenticator of client at now, checksum
+// and subkey are left out if nil.
+func encodeAuthenticator(client kerberosPrincipal, checksum *ber.Packet, now time.Time, subkey *kerberosKey, seqNumber uint32) *ber.Packet {
+	authenticator := ber.Encode(ber.ClassApplication, ber.TypeConstructed, kerberosAuthenticator, nil, "Authenticator")
+	sequence := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Authenticator")
+	sequence.AppendChild(krbTag(0, krbInt(5)))
+	sequence.AppendChild(krbTag(1, krbString(client.realm)))
+	sequence.AppendChild(krbTag(2, encodePrincipalName(client)))
+	if checksum != nil {
+		sequence.AppendChild(krbTag(3, checksum))
+	}
+	sequence.AppendChild(krbTag(4, krbInt(int64(now.Nanosecond()/1000))))
+	sequence.AppendChild(krbTag(5, krbTime(now)))
+	if subkey != nil {
+		sequence.AppendChild(krbTag(6, encodeEncryptionKey(*subkey)))
+		sequence.AppendChild(krbTag(7, krbInt(int64(seqNumber))))
+	}
+	authenticator.AppendChild(sequence)
+	return authenticator
+}
+
+func encodeChecksum(checksumType int32, checksum []byte) *ber.Packet {
+	sequence := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Checksum")
+	sequence.AppendChild(krbTag(0, krbInt(int64(checksumType))))
+	sequence.AppendChild(krbTag(1, krbOctets(checksum)))
+	return sequence
+}
+
+func encodePrincipalName(principal kerberosPrincipal) *ber.Packet {
+	sequence := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "PrincipalName")
+	sequence.AppendChild(krbTag(0, krbInt(int64(principal.nameType))))
+	names := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "name-string")
+	for _, name := range principal.names {
+		names.AppendChild(krbString(name))
+	}
+	sequence.AppendChild(krbTag(1, names))
+	return sequence
+}
+
+func decodePrincipalName(p *ber.Packet, realm string) kerberosPrincipal {
+	principal := kerberosPrincipal{realm: realm}
+	if nameType, ok := packetInt64(krbField(p, 0)); ok {
+		principal.nameType = int32(nameType)
+	}
+	if names := krbField(p, 1); names != nil {
+		for _, name := range names.Children {
+			principal.names = append(principal.names, packetString(name))
+		}
+	}
+	return principal
+}
+
+func encodeEncryptionKey(key kerberosKey) *ber.Packet {
+	sequence := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "EncryptionKey")
+	sequence.AppendChild(krbTag(0, krbInt(int64(key.etype))))
+	sequence.AppendChild(krbTag(1, krbOctets(key.value)))
+	return sequence
+}
+
+func decodeEncryptionKey(p *ber.Packet) (kerberosKey, error) {
+	if p == nil || krbField(p, 0) == nil || krbField(p, 1) == nil {
+		return kerberosKey{}, newError(ErrorDecoding, "Kerberos: invalid EncryptionKey")
+	}
+	etype, _ := packetInt64(krbField(p, 0))
+	key := kerberosKey{int32(etype), krbField(p, 1).Data.Bytes()}
+	return key, key.check()
+}
+
+func encodeEncryptedData(etype int32, cipher []byte) *ber.Packet {
+	sequence := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "EncryptedData")
+	sequence.AppendChild(krbTag(0, krbInt(int64(etype))))
+	sequence.AppendChild(krbTag(2, krbOctets(cipher)))
+	return sequence
+}
+
+// decryptEncryptedData decrypts the EncryptedData p with key and decodes the
+// plaintext.
+func decryptEncryptedData(p *ber.Packet, key kerberosKey, usage uint32) (*ber.Packet, error) {
+	if etype, _ := packetInt64(krbField(p, 0)); int32(etype) != key.etype || krbField(p, 2) == nil {
+		return nil, newError(ErrorDecoding, fmt.Sprintf("Kerberos: unexpected encryption type %d", etype))
+	}
+	plaintext, err := key.decrypt(usage, krbField(p, 2).Data.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	packet, err := ber.ReadPacket(bytes.NewReader(plaintext))
+	if err != nil {
+		return nil, newError(ErrorDecoding, "Kerberos: invalid encrypted part: "+err.Error())
+	}
+	return packet, nil
+}
+
+// krbField returns the value of the field [tag] of the sequence p, nil if
+// absent.
+func krbField(p *ber.Packet, tag int) *ber.Packet {
+	if p == nil {
+		return nil
+	}
+	for _, child := range p.Children {
+		if child.ClassType == ber.ClassContext && int(child.Tag) == tag && len(child.Children) == 1 {
+			return child.Children[0]
+		}
+	}
+	return nil
+}
+
+func krbTag(tag int, p *ber.Packet) *ber.Packet {
+	field := ber.Encode(ber.ClassContext, ber.TypeConstructed, ber.Tag(tag), nil, "")
+	field.AppendChild(p)
+	return field
+}
+
+func krbInt(value int64) *ber.Packet {
+	return ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, value, "")
+}
+
+// krbString returns a KerberosString, a GeneralString
+func krbString(value string) *ber.Packet {
+	return ber.NewString(ber.ClassUniversal, ber.TypePrimitive, 27, value, "")
+}
+
+func krbOctets(value []byte) *ber.Packet {
+	return ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(value), "")
+}
+
+// krbTime returns a KerberosTime, a GeneralizedTime without fractions
+func krbTime(t time.Time) *ber.Packet {
+	return ber.NewString(ber.ClassUniversal, ber.TypePrimitive, 24, t.UTC().Format("20060102150405Z"), "")
+}
+
+// krbBitString returns the 32 bit flags as a BIT STRING
+func krbBitString(flags uint32) *ber.Packet {
+	value := make([]byte, 5)
+	binary.BigEndian.PutUint32(value[1:], flags)
+	return ber.NewString(ber.ClassUniversal, ber.TypePrimitive, 3, string(value), "")
+}
+
+// kerberosNonce returns a random UInt32 below 2^31 for the KDCs not treating
+// it as unsigned.
+func kerberosNonce() int64 {
+	n, err := rand.Int(rand.Reader, big.NewInt(1<<31))
+	if err != nil {
+		return time.Now().UnixNano() & 0x7fffffff
+	}
+	return n.Int64()
+}
+
+// GSS-API tokens of the Kerberos mechanism [https://tools.ietf.org/html/rfc4121]
+
+// kerberosMechanismOID is the DER of the OID 1.2.840.113554.1.2.2
+var kerberosMechanismOID = []byte{0x06, 0x09, 0x2a, 0x86, 0x48, 0x86, 0xf7, 0x12, 0x01, 0x02, 0x02}
+
+// flags of the GSS checksum
+const (
+	gssMutualFlag   = 2
+	gssSequenceFlag = 8
+	gssConfFlag     = 16
+	gssIntegFlag    = 32
+)
+
+// flags of wrap tokens
+const (
+	gssSentByAcceptor = 1
+	gssSealed         = 2
+	gssAcceptorSubkey = 4
+)
+
+// kerberosContext is the GSSAPIContext of a KerberosClient.
+type kerberosContext struct {
+	client         kerberosPrincipal
+	ticket         *kerberosCredential
+	channelBinding []byte
+	// flags of the GSS checksum requested from the service
+	flags uint32
+
+	state          int
+	ctime          time.Time
+	subkey         kerberosKey
+	acceptorSubkey *kerberosKey
+	sendSeq        uint64
+	recvSeq        uint64
+}
+
+func (k *kerberosContext) Step(input []byte) ([]byte, bool, error) {
+	switch k.state {
+	case 0:
+		token, err := k.initialToken()
+		if err != nil {
+			return nil, false, err
+		}
+		k.state = 1
+		return token, false, nil
+	case 1:
+		if err := k.verifyAPRep(input); err != nil {
+			return nil, false, err
+		}
+		k.state = 2
+		return nil, true, nil
+	}
+	return nil, true, newError(ErrorUnknown, "Kerberos: security context already established")
+}
+
+// initialToken returns the InitialContextToken with the AP-REQ for the
+// service, with a new subkey and the GSS checksum of the flags and the
+// channel bindings.
+func (k *kerberosContext) initialToken() ([]byte, error) {
+	k.subkey = kerberosKey{k.ticket.key.etype, make([]byte, kerberosKeySize(k.ticket.key.etype))}
+	if err := k.subkey.check(); err != nil {
+		return nil, err
+	}
+	seq := make([]byte, 4)
+	if _, err := rand.Read(k.subkey.value); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(seq); err != nil {
+		return nil, err
+	}
+	k.sendSeq = uint64(binary.BigEndian.Uint32(seq) & 0x3fffffff)
+
+	// RFC 4121 section 4.1.1, the little endian length of the MD5 of the
+	// channel bindings, the MD5 and the flags
+	gssChecksum := make([]byte, 24)
+	binary.LittleEndian.PutUint32(gssChecksum, 16)
+	if k.channelBinding != nil {
+		copy(gssChecksum[4:20], gssChannelBindingsHash(k.channelBinding))
+	}
+	binary.LittleEndian.PutUint32(gssChecksum[20:], k.flags)
+
+	k.ctime = time.Now().UTC().Truncate(time.Microsecond)
+	authenticator := encodeAuthenticator(k.client, encodeChecksum(0x8003, gssChecksum), k.ctime, &k.subkey, uint32(k.sendSeq))
+	apReq, err := encodeAPReq(k.ticket, kerberosAPOptions, authenticator, kerberosUsageAPReqAuth)
+	if err != nil {
+		return nil, err
+	}
+	return encodeGSSToken([]byte{0x01, 0x00}, apReq.Bytes()), nil
+}
+
+/*
+AP-REP          ::= [APPLICATION 15] SEQUENCE {
+        pvno            [0] INTEGER (5),
+        msg-type        [1] INTEGER (15),
+        enc-part        [2] EncryptedData -- EncAPRepPart }
+
+EncAPRepPart    ::= [APPLICATION 27] SEQUENCE {
+        ctime           [0] KerberosTime,
+        cusec           [1] Microseconds,
+        subkey          [2] EncryptionKey OPTIONAL,
+        seq-number      [3] UInt32 OPTIONAL }
+*/
+
+// verifyAPRep verifies the AP-REP of the mutual authentication and takes
+// the subkey and sequence number of the service.
+func (k *kerberosContext) verifyAPRep(token []byte) error {
+	tokID, inner, err := decodeGSSToken(token)
+	if err != nil {
+		return err
+	}
+	packet, err := ber.ReadPacket(bytes.NewReader(inner))
+	if err != nil {
+		return newError(ErrorDecoding, "Kerberos: invalid AP-REP: "+err.Error())
+	}
+	if tokID == 0x0300 && packet.Tag == kerberosKRBError && len(packet.Children) == 1 {
+		return decodeKRBError(packet)
+	}
+	if tokID != 0x0200 || packet.ClassType != ber.ClassApplication || packet.Tag != kerberosAPRep || len(packet.Children) != 1 {
+		return newError(ErrorDecoding, "Kerberos: expected an AP-REP")
+	}
+	encPart := krbField(packet.Children[0], 2)
+	if encPart == nil {
+		return newError(ErrorDecoding, "Kerberos: invalid AP-REP")
+	}
+	part, err := decryptEncryptedData(encPart, k.ticket.key, kerberosUsageAPRepEncPart)
+	if err != nil {
+		return err
+	}
+	if part.Tag != kerberosEncAPRepPart || len(part.Children) != 1 {
+		return newError(ErrorDecoding, "Kerberos: invalid EncAPRepPart")
+	}
+	rep := part.Children[0]
+	cusec, _ := packetInt64(krbField(rep, 1))
+	if ctime := krbField(rep, 0); ctime == nil || packetString(ctime) != k.ctime.Format("20060102150405Z") || cusec != int64(k.ctime.Nanosecond()/1000) {
+		return newError(ErrorUnknown, "Kerberos: the service failed the mutual authentication")
+	}
+	if subkey := krbField(rep, 2); subkey != nil {
+		key, err := decodeEncryptionKey(subkey)
+		if err != nil {
+			return err
+		}
+		k.acceptorSubkey = &key
+	}
+	if seq, ok := packetInt64(krbField(rep, 3)); ok {
+		k.recvSeq = uint64(uint32(seq))
+	}
+	return nil
+}
+
+// tokenKey returns the key and flags of wrap tokens, the subkey of the
+// acceptor if it sent one.
+func (k *kerberosContext) tokenKey() (kerberosKey, byte) {
+	if k.acceptorSubkey != nil {
+		return *k.acceptorSubkey, gssAcceptorSubkey
+	}
+	return k.subkey, 0
+}
+
+// Wrap returns the wrap token of message protected for integrity, and
+// encrypted if confidential.
+func (k *kerberosContext) Wrap(message []byte, confidential bool) ([]byte, error) {
+	if k.state != 2 {
+		return nil, newError(ErrorUnknown, "Kerberos: security context not established")
+	}
+	key, flags := k.tokenKey()
+	token := wrapToken(key, kerberosUsageInitiatorSign, flags, k.sendSeq, message)
+	if confidential {
+		var err error
+		if token, err = sealToken(key, kerberosUsageInitiatorSeal, flags, k.sendSeq, message); err != nil {
+			return nil, err
+		}
+	}
+	k.sendSeq++
+	return token, nil
+}
+
+// Unwrap returns the message of the wrap token of the service.
+func (k *kerberosContext) Unwrap(token []byte) ([]byte, error) {
+	if k.state != 2 {
+		return nil, newError(ErrorUnknown, "Kerberos: security context not established")
+	}
+	key, _ := k.tokenKey()
+	message, seq, err := unwrapToken(key, kerberosUsageAcceptorSeal, kerberosUsageAcceptorSign, gssSentByAcceptor, token)
+	if err != nil {
+		return nil, err
+	}
+	if seq != k.recvSeq {
+		return nil, newError(ErrorUnknown, fmt.Sprintf("Kerberos: unexpected sequence number %d of the wrap token", seq))
+	}
+	k.recvSeq++
+	return message, nil
+}
+
+// wrapToken returns the wrap token of message without confidentiality, the
+// checksum covers the message and the header with EC and RRC zero.
+func wrapToken(key kerberosKey, usage uint32, flags byte, seq uint64, message []byte) []byte {
+	header := []byte{0x05, 0x04, flags, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint64(header[8:], seq)
+	checksum := key.checksum(usage, append(append([]byte(nil), message...), header...))
+	binary.BigEndian.PutUint16(header[4:], uint16(len(checksum)))
+	token := append(header, message...)
+	return append(token, checksum...)
+}
+
+// sealToken returns the wrap token of message with confidentiality, the
+// encryption of the message followed by the header without filler and
+// rotation.
+func sealToken(key kerberosKey, usage uint32, flags byte, seq uint64, message []byte) ([]byte, error) {
+	header := []byte{0x05, 0x04, flags | gssSealed, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint64(header[8:], seq)
+	ciphertext, err := key.encrypt(usage, append(append([]byte(nil), message...), header...))
+	if err != nil {
+		return nil, err
+	}
+	return append(header, ciphertext...), nil
+}
+
+// unwrapToken verifies the wrap token and returns its message and sequence
+// number, the token has to have the flag sender set.
+func unwrapToken(key kerberosKey, sealUsage, signUsage uint32, sender byte, token []byte) ([]byte, uint64, error) {
+	if len(token) < 16 || token[0] != 0x05 || token[1] != 0x04 || token[3] != 0xff {
+		return nil, 0, newError(ErrorDecoding, "Kerberos: invalid wrap token")
+	}
+	flags := token[2]
+	if flags&gssSentByAcceptor != sender {
+		return nil, 0, newError(ErrorDecoding, "Kerberos: wrap token of the wrong direction")
+	}
+	ec, rrc := int(binary.BigEndian.Uint16(token[4:])), int(binary.BigEndian.Uint16(token[6:]))
+	seq := binary.BigEndian.Uint64(token[8:])
+	header := append([]byte(nil), token[:16]...)
+	data := token[16:]
+	if len(data) > 0 && rrc%len(data) != 0 {
+		// undo the right rotation
+		rrc %= len(data)
+		data = append(append([]byte(nil), data[rrc:]...), data[:rrc]...)
+	}
+
+	if flags&gssSealed != 0 {
+		plaintext, err := key.decrypt(sealUsage, data)
+		if err != nil {
+			return nil, 0, err
+		}
+		// the plaintext is followed by EC bytes of filler and the header
+		// with RRC zero
+		if len(plaintext) < ec+16 {
+			return nil, 0, newError(ErrorDecoding, "Kerberos: invalid sealed wrap token")
+		}
+		header[6], header[7] = 0, 0
+		if !bytes.Equal(plaintext[len(plaintext)-16:], header) {
+			return nil, 0, newError(ErrorDecoding, "Kerberos: header of the sealed wrap token modified")
+		}
+		return plaintext[:len(plaintext)-16-ec], seq, nil
+	}
+
+	if ec != 12 || len(data) < ec {
+		return nil, 0, newError(ErrorDecoding, "Kerberos: invalid checksum of the wrap token")
+	}
+	message, checksum := data[:len(data)-ec], data[len(data)-ec:]
+	header[4], header[5], header[6], header[7] = 0, 0, 0, 0
+	if !bytes.Equal(key.checksum(signUsage, append(append([]byte(nil), message...), header...)), checksum) {
+		return nil, 0, newError(ErrorDecoding, "Kerberos: checksum of the wrap token doesn't match")
+	}
+	return message, seq, nil
+}
+
+// encodeGSSToken returns the InitialContextToken framing of the Kerberos
+// mechanism of the inner token with tokID [https://tools.ietf.org/html/rfc2743#section-3.1].
+func encodeGSSToken(tokID []byte, inner []byte) []byte {
+	token := ber.Encode(ber.ClassApplication, ber.TypeConstructed, 0, nil, "InitialContextToken")
+	token.Data.Write(kerberosMechanismOID)
+	token.Data.Write(tokID)
+	token.Data.Write(inner)
+	return token.Bytes()
+}
+
+// decodeGSSToken returns the token ID and the inner token of a token of the
+// Kerberos mechanism.
+func decodeGSSToken(token []byte) (int, []byte, error) {
+	if len(token) < 2 || token[0] != 0x60 {
+		return 0, nil, newError(ErrorDecoding, "Kerberos: invalid GSS-API token")
+	}
+	length, offset := int(token[1]), 2
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n > 4 || len(token) < 2+n {
+			return 0, nil, newError(ErrorDecoding, "Kerberos: invalid GSS-API token")
+		}
+		length = 0
+		for _, b := range token[2 : 2+n] {
+			length = length<<8 | int(b)
+		}
+		offset += n
+	}
+	if offset+length != len(token) || !bytes.HasPrefix(token[offset:], kerberosMechanismOID) || length < len(kerberosMechanismOID)+2 {
+		return 0, nil, newError(ErrorDecoding, "Kerberos: invalid GSS-API token of the Kerberos mechanism")
+	}
+	inner := token[offset+len(kerberosMechanismOID):]
+	return int(binary.BigEndian.Uint16(inner)), inner[2:], nil
+}
@@ -0,0 +1,533 @@
+package ldap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/eaciit/asn1-ber"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestKerberosCrypto(t *testing.T) {
+	// RFC 3961 appendix A.1
+	for _, test := range []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"012345", 64, "be072631276b1955"},
+		{"password", 168, "59e4a8ca7c0385c3c37b3f6d2000247cb6e6bd5b3e"},
+		{"kerberos", 256, "6b65726265726f737b9b5b2b93132b935c9bdcdad95c9899c4cae4dee6d6cae4"},
+	} {
+		if got := hex.EncodeToString(nfold([]byte(test.in), test.n)); got != test.want {
+			t.Errorf("%d-fold(%q) = %s, expected %s", test.n, test.in, got, test.want)
+		}
+	}
+
+	// RFC 3962 appendix B
+	key, err := kerberosStringToKey(KerberosAES256CTSHMACSHA196, "password", "ATHENA.MIT.EDUraeburn", []byte{0, 0, 0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hex.EncodeToString(key.value); got != "fe697b52bc0d3ce14432ba036a92e65bbb52280990a2fa27883998d72af30161" {
+		t.Errorf("Unexpected key %s", got)
+	}
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{"I would like the ", "c6353568f2bf8cb4d8a580362da7ff7f97"},
+		{"I would like the General Gau's ", "fc00783e0efdb2c1d445d4c8eff7ed2297687268d6ecccc0c07b25e25ecfe5"},
+		{"I would like the General Gau's C", "39312523a78662d5be7fcbcc98ebf5a897687268d6ecccc0c07b25e25ecfe584"},
+	} {
+		ciphertext := ctsEncrypt([]byte("chicken teriyaki"), []byte(test.in))
+		if got := hex.EncodeToString(ciphertext); got != test.want {
+			t.Errorf("Unexpected ciphertext %s of %q", got, test.in)
+		}
+		if got := string(ctsDecrypt([]byte("chicken teriyaki"), ciphertext)); got != test.in {
+			t.Errorf("Unexpected plaintext %q", got)
+		}
+	}
+
+	ciphertext, err := key.encrypt(3, []byte("EncASRepPart"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := key.decrypt(3, ciphertext); err != nil || string(plaintext) != "EncASRepPart" {
+		t.Errorf("Unexpected plaintext %q: %v", plaintext, err)
+	}
+	if _, err := key.decrypt(8, ciphertext); err == nil {
+		t.Error("Expected an error decrypting with another usage")
+	}
+}
+
+// kerberosFileWriter writes the fields of keytabs and credential caches,
+// strings are counted with 32 bits.
+type kerberosFileWriter struct {
+	bytes.Buffer
+}
+
+func (w *kerberosFileWriter) put(values ...interface{}) {
+	for _, value := range values {
+		switch v := value.(type) {
+		case string:
+			binary.Write(w, binary.BigEndian, uint32(len(v)))
+			w.WriteString(v)
+		case []byte:
+			w.Write(v)
+		default:
+			binary.Write(w, binary.BigEndian, v)
+		}
+	}
+}
+
+func TestParseKeytab(t *testing.T) {
+	entry := func(kvno uint8, etype uint16, key []byte) []byte {
+		var e kerberosFileWriter
+		e.put(uint16(1), uint16(11), []byte("EXAMPLE.COM"), uint16(5), []byte("alice"), uint32(kerberosNTPrincipal), uint32(0), kvno, etype, uint16(len(key)), key)
+		return e.Bytes()
+	}
+	var w kerberosFileWriter
+	w.Write([]byte{5, 2})
+	for _, e := range [][]byte{entry(1, KerberosAES256CTSHMACSHA196, bytes.Repeat([]byte{1}, 32)), entry(2, KerberosAES256CTSHMACSHA196, bytes.Repeat([]byte{2}, 32))} {
+		w.put(uint32(len(e)), e)
+	}
+	// a hole
+	w.put(int32(-4), uint32(0))
+
+	keytab, err := ParseKeytab(w.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := kerberosPrincipal{kerberosNTPrincipal, []string{"alice"}, "EXAMPLE.COM"}
+	if key, ok := keytab.key(alice, KerberosAES256CTSHMACSHA196); !ok || key.value[0] != 2 {
+		t.Errorf("Expected the key of the newest version, got %v", key)
+	}
+	if _, ok := keytab.key(alice, KerberosAES128CTSHMACSHA196); ok {
+		t.Error("Unexpected key of another encryption type")
+	}
+	if _, err := ParseKeytab(w.Bytes()[:30]); err == nil {
+		t.Error("Expected an error for a truncated keytab")
+	}
+}
+
+func TestParseCCache(t *testing.T) {
+	var w kerberosFileWriter
+	w.Write([]byte{5, 4})
+	w.put(uint16(0))
+	principal := func(realm string, names ...string) {
+		w.put(uint32(1), uint32(len(names)), realm)
+		for _, name := range names {
+			w.put(name)
+		}
+	}
+	principal("EXAMPLE.COM", "alice")
+	credential := func(realm string, names []string, end time.Time, ticket string) {
+		principal("EXAMPLE.COM", "alice")
+		principal(realm, names...)
+		w.put(uint16(KerberosAES128CTSHMACSHA196), string(bytes.Repeat([]byte{3}, 16)))
+		w.put(uint32(0), uint32(0), uint32(end.Unix()), uint32(0), uint8(0), uint32(0))
+		w.put(uint32(0), uint32(0), ticket, "")
+	}
+	credential("X-CACHECONF:", []string{"krb5_ccache_conf_data", "pa_type"}, time.Unix(0, 0), "2")
+	credential("EXAMPLE.COM", []string{"krbtgt", "EXAMPLE.COM"}, time.Now().Add(time.Hour), "tgt")
+	credential("EXAMPLE.COM", []string{"ldap", "expired.example.com"}, time.Now().Add(-time.Hour), "expired")
+
+	ccache, err := ParseCCache(w.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ccache.principal.String() != "alice@EXAMPLE.COM" || len(ccache.credentials) != 2 {
+		t.Errorf("Unexpected principal %s or credentials %d", ccache.principal, len(ccache.credentials))
+	}
+	tgt := ccache.credential(kerberosPrincipal{kerberosNTSrvInst, []string{"krbtgt", "EXAMPLE.COM"}, "EXAMPLE.COM"})
+	if tgt == nil || string(tgt.ticket) != "tgt" || tgt.key.etype != KerberosAES128CTSHMACSHA196 {
+		t.Errorf("Unexpected ticket granting ticket %+v", tgt)
+	}
+	if ccache.credential(parseKerberosPrincipal("ldap/expired.example.com", kerberosNTSrvHst, "EXAMPLE.COM")) != nil {
+		t.Error("Unexpected expired ticket")
+	}
+}
+
+// mockKDC is a KDC of EXAMPLE.COM issuing tickets to alice with the
+// password "secret", the tickets only hold their session key.
+type mockKDC struct {
+	t         *testing.T
+	addr      string
+	userKey   kerberosKey
+	tgsKey    kerberosKey
+	serverKey kerberosKey
+	requests  chan int
+	services  chan string
+}
+
+func newMockKDC(t *testing.T) *mockKDC {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	userKey, err := kerberosStringToKey(KerberosAES256CTSHMACSHA196, "secret", "EXAMPLE.COMalice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kdc := &mockKDC{t: t, addr: listener.Addr().String(), userKey: userKey,
+		tgsKey:    kerberosKey{KerberosAES256CTSHMACSHA196, bytes.Repeat([]byte{4}, 32)},
+		serverKey: kerberosKey{KerberosAES256CTSHMACSHA196, bytes.Repeat([]byte{5}, 32)},
+		requests:  make(chan int, 16), services: make(chan string, 16)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go kdc.serve(conn)
+		}
+	}()
+	return kdc
+}
+
+func (k *mockKDC) serve(conn net.Conn) {
+	defer conn.Close()
+	length := make([]byte, 4)
+	if _, err := io.ReadFull(conn, length); err != nil {
+		return
+	}
+	data := make([]byte, binary.BigEndian.Uint32(length))
+	if _, err := io.ReadFull(conn, data); err != nil {
+		return
+	}
+	request := ber.DecodePacket(data)
+	k.requests <- int(request.Tag)
+	reply := k.reply(request)
+	message := make([]byte, 4, 4+len(reply))
+	binary.BigEndian.PutUint32(message, uint32(len(reply)))
+	conn.Write(append(message, reply...))
+}
+
+func (k *mockKDC) reply(request *ber.Packet) []byte {
+	req := request.Children[0]
+	body := krbField(req, 4)
+	nonce, _ := packetInt64(krbField(body, 7))
+	sname := decodePrincipalName(krbField(body, 3), "EXAMPLE.COM")
+	var padata *ber.Packet
+	if padatas := krbField(req, 3); padatas != nil {
+		padata = padatas.Children[0]
+	}
+
+	if request.Tag == kerberosASReq {
+		if padata == nil {
+			info := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "ETYPE-INFO2")
+			entry := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "ETYPE-INFO2-ENTRY")
+			entry.AppendChild(krbTag(0, krbInt(KerberosAES256CTSHMACSHA196)))
+			entry.AppendChild(krbTag(1, krbString("EXAMPLE.COMalice")))
+			info.AppendChild(entry)
+			methods := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "METHOD-DATA")
+			methods.AppendChild(encodePAData(kerberosPAETypeInfo2, info.Bytes()))
+			return mockKRBError(25, methods.Bytes())
+		}
+		encrypted := ber.DecodePacket(krbField(padata, 2).Data.Bytes())
+		if _, err := decryptEncryptedData(encrypted, k.userKey, kerberosUsageASReqTimestamp); err != nil {
+			return mockKRBError(24, nil)
+		}
+		return k.kdcRep(kerberosASRep, kerberosEncASRepPart, k.userKey, kerberosUsageASRepEncPart, k.tgsKey, sname, nonce)
+	}
+
+	// the authenticator of the AP-REQ with the ticket granting ticket
+	apReq := ber.DecodePacket(krbField(padata, 2).Data.Bytes()).Children[0]
+	sessionKey, authenticator := k.decryptAPReq(apReq, k.tgsKey, kerberosUsageTGSReqAuth)
+	if authenticator == nil {
+		return mockKRBError(31, nil)
+	}
+	checksum := krbField(authenticator, 3)
+	if !bytes.Equal(krbField(checksum, 1).Data.Bytes(), sessionKey.checksum(kerberosUsageTGSReqChecksum, body.Bytes())) {
+		return mockKRBError(41, nil)
+	}
+	k.services <- sname.String()
+	return k.kdcRep(kerberosTGSRep, kerberosEncTGSRepPart, sessionKey, kerberosUsageTGSRepEncPart, k.serverKey, sname, nonce)
+}
+
+// decryptAPReq returns the session key of the ticket of apReq encrypted with
+// ticketKey and the authenticator.
+func (k *mockKDC) decryptAPReq(apReq *ber.Packet, ticketKey kerberosKey, usage uint32) (kerberosKey, *ber.Packet) {
+	ticket := krbField(apReq, 3).Children[0]
+	encTicketPart, err := decryptEncryptedData(krbField(ticket, 3), ticketKey, 2)
+	if err != nil {
+		k.t.Error(err)
+		return kerberosKey{}, nil
+	}
+	sessionKey, _ := decodeEncryptionKey(krbField(encTicketPart.Children[0], 1))
+	authenticator, err := decryptEncryptedData(krbField(apReq, 4), sessionKey, usage)
+	if err != nil {
+		k.t.Error(err)
+		return kerberosKey{}, nil
+	}
+	return sessionKey, authenticator.Children[0]
+}
+
+func (k *mockKDC) kdcRep(msgType, partTag int, replyKey kerberosKey, usage uint32, ticketKey kerberosKey, sname kerberosPrincipal, nonce int64) []byte {
+	sessionKey := kerberosKey{KerberosAES256CTSHMACSHA196, make([]byte, 32)}
+	copy(sessionKey.value, sname.String())
+
+	encTicketPart := ber.Encode(ber.ClassApplication, ber.TypeConstructed, 3, nil, "EncTicketPart")
+	ticketSequence := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "EncTicketPart")
+	ticketSequence.AppendChild(krbTag(1, encodeEncryptionKey(sessionKey)))
+	encTicketPart.AppendChild(ticketSequence)
+	ticketCipher, _ := ticketKey.encrypt(2, encTicketPart.Bytes())
+	ticket := ber.Encode(ber.ClassApplication, ber.TypeConstructed, kerberosTicket, nil, "Ticket")
+	ticketFields := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Ticket")
+	ticketFields.AppendChild(krbTag(0, krbInt(5)))
+	ticketFields.AppendChild(krbTag(1, krbString("EXAMPLE.COM")))
+	ticketFields.AppendChild(krbTag(2, encodePrincipalName(sname)))
+	ticketFields.AppendChild(krbTag(3, encodeEncryptedData(ticketKey.etype, ticketCipher)))
+	ticket.AppendChild(ticketFields)
+
+	encPart := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(partTag), nil, "EncKDCRepPart")
+	part := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "EncKDCRepPart")
+	part.AppendChild(krbTag(0, encodeEncryptionKey(sessionKey)))
+	part.AppendChild(krbTag(1, ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LastReq")))
+	part.AppendChild(krbTag(2, krbInt(nonce)))
+	part.AppendChild(krbTag(4, krbBitString(0)))
+	part.AppendChild(krbTag(5, krbTime(time.Now())))
+	part.AppendChild(krbTag(7, krbTime(time.Now().Add(time.Hour))))
+	part.AppendChild(krbTag(9, krbString("EXAMPLE.COM")))
+	part.AppendChild(krbTag(10, encodePrincipalName(sname)))
+	encPart.AppendChild(part)
+	cipher, _ := replyKey.encrypt(usage, encPart.Bytes())
+
+	rep := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(msgType), nil, "KDC-REP")
+	fields := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "KDC-REP")
+	fields.AppendChild(krbTag(0, krbInt(5)))
+	fields.AppendChild(krbTag(1, krbInt(int64(msgType))))
+	fields.AppendChild(krbTag(3, krbString("EXAMPLE.COM")))
+	fields.AppendChild(krbTag(4, encodePrincipalName(kerberosPrincipal{kerberosNTPrincipal, []string{"alice"}, "EXAMPLE.COM"})))
+	fields.AppendChild(krbTag(5, ticket))
+	fields.AppendChild(krbTag(6, encodeEncryptedData(replyKey.etype, cipher)))
+	rep.AppendChild(fields)
+	return rep.Bytes()
+}
+
+func mockKRBError(code int, eData []byte) []byte {
+	krbError := ber.Encode(ber.ClassApplication, ber.TypeConstructed, kerberosKRBError, nil, "KRB-ERROR")
+	fields := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "KRB-ERROR")
+	fields.AppendChild(krbTag(0, krbInt(5)))
+	fields.AppendChild(krbTag(1, krbInt(kerberosKRBError)))
+	fields.AppendChild(krbTag(6, krbInt(int64(code))))
+	if eData != nil {
+		fields.AppendChild(krbTag(12, krbOctets(eData)))
+	}
+	krbError.AppendChild(fields)
+	return krbError.Bytes()
+}
+
+// mockGSSAPIServer answers the GSSAPI binds of alice with tickets of kdc,
+// offering the security layers, none skips their negotiation.
+// mockAPRep returns the GSS-API token of the AP-REP to the authenticator
+// with the subkey of the service.
+func mockAPRep(sessionKey kerberosKey, authenticator *ber.Packet, acceptorSubkey kerberosKey) []byte {
+	encPart := ber.Encode(ber.ClassApplication, ber.TypeConstructed, kerberosEncAPRepPart, nil, "EncAPRepPart")
+	part := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "EncAPRepPart")
+	part.AppendChild(krbTag(0, krbField(authenticator, 5)))
+	part.AppendChild(krbTag(1, krbField(authenticator, 4)))
+	part.AppendChild(krbTag(2, encodeEncryptionKey(acceptorSubkey)))
+	part.AppendChild(krbTag(3, krbInt(1000)))
+	encPart.AppendChild(part)
+	cipher, _ := sessionKey.encrypt(kerberosUsageAPRepEncPart, encPart.Bytes())
+	apRep := ber.Encode(ber.ClassApplication, ber.TypeConstructed, kerberosAPRep, nil, "AP-REP")
+	fields := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "AP-REP")
+	fields.AppendChild(krbTag(0, krbInt(5)))
+	fields.AppendChild(krbTag(1, krbInt(kerberosAPRep)))
+	fields.AppendChild(krbTag(2, encodeEncryptedData(sessionKey.etype, cipher)))
+	apRep.AppendChild(fields)
+	return encodeGSSToken([]byte{0x02, 0x00}, apRep.Bytes())
+}
+
+func mockGSSAPIServer(t *testing.T, kdc *mockKDC, layers byte, authzIDs chan<- string) *Connection {
+	var sessionKey, acceptorSubkey kerberosKey
+	var clientSeq uint64
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		messageID := mockMessageID(request)
+		if request.Children[1].Tag == ber.Tag(ApplicationExtendedRequest) {
+			s.respond(messageID, mockExtendedResponse(ResultSuccess, "", []byte("u:alice")))
+			return
+		}
+		sasl := request.Children[1].Children[2]
+		if packetString(sasl.Children[0]) != "GSSAPI" || len(sasl.Children) != 2 {
+			s.respondResult(messageID, ApplicationBindResponse, ResultAuthMethodNotSupported, "")
+			return
+		}
+		credentials := sasl.Children[1].Data.Bytes()
+		switch {
+		case len(credentials) > 0 && credentials[0] == 0x60:
+			tokID, inner, err := decodeGSSToken(credentials)
+			var authenticator *ber.Packet
+			if err == nil && tokID == 0x0100 {
+				sessionKey, authenticator = kdc.decryptAPReq(ber.DecodePacket(inner).Children[0], kdc.serverKey, kerberosUsageAPReqAuth)
+			}
+			if authenticator == nil {
+				t.Errorf("Unexpected token %x: %v", credentials, err)
+				s.respondResult(messageID, ApplicationBindResponse, ResultInvalidCredentials, "")
+				return
+			}
+			gssChecksum := krbField(krbField(authenticator, 3), 1).Data.Bytes()
+			if binary.LittleEndian.Uint32(gssChecksum[20:])&gssMutualFlag == 0 {
+				t.Errorf("Expected mutual authentication, got flags %x", gssChecksum[20:])
+			}
+			seq, _ := packetInt64(krbField(authenticator, 7))
+			clientSeq = uint64(seq)
+			acceptorSubkey = kerberosKey{KerberosAES256CTSHMACSHA196, bytes.Repeat([]byte{6}, 32)}
+
+			s.respond(messageID, mockSASLBindResponse(ResultSaslBindInProgress, string(mockAPRep(sessionKey, authenticator, acceptorSubkey))))
+		case len(credentials) == 0 && layers == 0:
+			s.respondResult(messageID, ApplicationBindResponse, ResultSuccess, "")
+		case len(credentials) == 0:
+			offer := wrapToken(acceptorSubkey, kerberosUsageAcceptorSign, gssSentByAcceptor|gssAcceptorSubkey, 1000, []byte{layers, 0, 0x10, 0})
+			s.respond(messageID, mockSASLBindResponse(ResultSaslBindInProgress, string(offer)))
+		default:
+			message, seq, err := unwrapToken(acceptorSubkey, kerberosUsageInitiatorSeal, kerberosUsageInitiatorSign, 0, credentials)
+			if err != nil || seq != clientSeq || len(message) < 4 || message[0]&layers == 0 {
+				t.Errorf("Unexpected security layer %x, sequence number %d: %v", message, seq, err)
+				s.respondResult(messageID, ApplicationBindResponse, ResultInvalidCredentials, "")
+				return
+			}
+			authzIDs <- string(message[4:])
+			s.respondResult(messageID, ApplicationBindResponse, ResultSuccess, "")
+			if message[0] != SASLLayerNone {
+				layer := &mockGSSAPILayer{t: t, key: acceptorSubkey, sealed: message[0] == SASLLayerConfidentiality, sendSeq: 1001, receiveSeq: clientSeq + 1}
+				s.conn = &saslConn{Conn: s.conn, layer: layer}
+			}
+		}
+	})
+	return l
+}
+
+// mockGSSAPILayer is the security layer of the service of mockGSSAPIServer.
+type mockGSSAPILayer struct {
+	t                   *testing.T
+	key                 kerberosKey
+	sealed              bool
+	sendSeq, receiveSeq uint64
+}
+
+func (m *mockGSSAPILayer) Wrap(message []byte) ([]byte, error) {
+	token := wrapToken(m.key, kerberosUsageAcceptorSign, gssSentByAcceptor|gssAcceptorSubkey, m.sendSeq, message)
+	if m.sealed {
+		token, _ = sealToken(m.key, kerberosUsageAcceptorSeal, gssSentByAcceptor|gssAcceptorSubkey, m.sendSeq, message)
+	}
+	m.sendSeq++
+	return token, nil
+}
+
+func (m *mockGSSAPILayer) Unwrap(buffer []byte) ([]byte, error) {
+	if sealed := buffer[2]&gssSealed != 0; sealed != m.sealed {
+		m.t.Errorf("Expected a token sealed %v", m.sealed)
+	}
+	message, seq, err := unwrapToken(m.key, kerberosUsageInitiatorSeal, kerberosUsageInitiatorSign, 0, buffer)
+	if err == nil && seq != m.receiveSeq {
+		m.t.Errorf("Expected sequence number %d, got %d", m.receiveSeq, seq)
+	}
+	m.receiveSeq++
+	return message, err
+}
+
+func (m *mockGSSAPILayer) MaxMessageSize() int {
+	return 0
+}
+
+func TestGSSAPIBind(t *testing.T) {
+	kdc := newMockKDC(t)
+	authzIDs := make(chan string, 4)
+	l := mockGSSAPIServer(t, kdc, 0x07, authzIDs)
+	defer l.Close()
+
+	client := NewKerberosClientWithPassword("alice", "EXAMPLE.COM", "secret")
+	client.KDCs = []string{kdc.addr}
+	mechanism := &GSSAPIMechanism{Client: client, Target: "ldap/ldap.example.com", AuthzID: "dn:cn=admin"}
+	if _, err := l.SASLBind(mechanism); err != nil {
+		t.Fatal(err)
+	}
+	if authzID := <-authzIDs; authzID != "dn:cn=admin" {
+		t.Errorf("Unexpected authzID %q", authzID)
+	}
+	if service := <-kdc.services; service != "ldap/ldap.example.com@EXAMPLE.COM" {
+		t.Errorf("Unexpected service principal %s", service)
+	}
+	// AS-REQ without and with preauthentication, TGS-REQ
+	if len(kdc.requests) != 3 {
+		t.Errorf("Expected 3 requests to the KDC, got %d", len(kdc.requests))
+	}
+
+	// the service ticket is cached
+	if _, err := l.SASLBind(mechanism); err != nil {
+		t.Fatal(err)
+	}
+	<-authzIDs
+	if len(kdc.requests) != 3 {
+		t.Errorf("Expected no more requests to the KDC, got %d", len(kdc.requests))
+	}
+
+	wrong := NewKerberosClientWithPassword("alice", "EXAMPLE.COM", "wrong")
+	wrong.KDCs = []string{kdc.addr}
+	_, err := l.SASLBind(&GSSAPIMechanism{Client: wrong, Target: "ldap/ldap.example.com"})
+	if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ResultInvalidCredentials {
+		t.Errorf("Expected ResultInvalidCredentials for a wrong password, got %v", err)
+	}
+}
+
+func TestGSSAPIBindSecurityLayerRequired(t *testing.T) {
+	kdc := newMockKDC(t)
+	l := mockGSSAPIServer(t, kdc, 0x06, make(chan string, 1))
+	defer l.Close()
+
+	client := NewKerberosClientWithPassword("alice", "EXAMPLE.COM", "secret")
+	client.KDCs = []string{kdc.addr}
+	_, err := l.SASLBind(&GSSAPIMechanism{Client: client, Target: "ldap/ldap.example.com"})
+	if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ResultConfidentialityRequired {
+		t.Errorf("Expected ResultConfidentialityRequired, got %v", err)
+	}
+}
+
+func TestGSSAPIBindWithoutNegotiation(t *testing.T) {
+	kdc := newMockKDC(t)
+	l := mockGSSAPIServer(t, kdc, 0, make(chan string, 1))
+	defer l.Close()
+
+	client := NewKerberosClientWithPassword("alice", "EXAMPLE.COM", "secret")
+	client.KDCs = []string{kdc.addr}
+	mechanism := &GSSAPIMechanism{Client: client, Target: "ldap/ldap.example.com", Layers: SASLLayerIntegrity}
+	if _, err := l.SASLBind(mechanism); err == nil {
+		t.Error("Expected an error for the success before the security layer negotiation")
+	}
+	if mechanism.SecurityLayer() != nil || l.encrypted() {
+		t.Error("Expected no security layer")
+	}
+}
+
+func TestGSSAPIBindSecurityLayer(t *testing.T) {
+	for _, layers := range []int{SASLLayerIntegrity, SASLLayerIntegrity | SASLLayerConfidentiality} {
+		kdc := newMockKDC(t)
+		l := mockGSSAPIServer(t, kdc, 0x06, make(chan string, 1))
+
+		client := NewKerberosClientWithPassword("alice", "EXAMPLE.COM", "secret")
+		client.KDCs = []string{kdc.addr}
+		mechanism := &GSSAPIMechanism{Client: client, Target: "ldap/ldap.example.com", Layers: layers}
+		if _, err := l.SASLBind(mechanism); err != nil {
+			t.Fatal(err)
+		}
+		if mechanism.SecurityLayer() == nil {
+			t.Fatal("Expected a security layer")
+		}
+		if encrypted := layers&SASLLayerConfidentiality != 0; l.encrypted() != encrypted {
+			t.Errorf("Expected the connection to be encrypted %v", encrypted)
+		}
+		for i := 0; i < 2; i++ {
+			if authzID, err := l.WhoAmI(); err != nil || authzID != "u:alice" {
+				t.Errorf("Unexpected authzID %q: %v", authzID, err)
+			}
+		}
+		l.Close()
+	}
+}
@@ -0,0 +1,255 @@
+package ldap
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"hash"
+)
+
+// Encryption types of Kerberos [https://tools.ietf.org/html/rfc3962], only
+// AES is supported
+const (
+	KerberosAES128CTSHMACSHA196 = 17
+	KerberosAES256CTSHMACSHA196 = 18
+)
+
+// checksum types of the AES encryption types
+const (
+	kerberosHMACSHA196AES128 = 15
+	kerberosHMACSHA196AES256 = 16
+)
+
+// kerberosKey is an EncryptionKey, the key of an encryption type.
+type kerberosKey struct {
+	etype int32
+	value []byte
+}
+
+// kerberosKeySize returns the size of the keys of etype, 0 if it is not
+// supported.
+func kerberosKeySize(etype int32) int {
+	switch etype {
+	case KerberosAES128CTSHMACSHA196:
+		return 16
+	case KerberosAES256CTSHMACSHA196:
+		return 32
+	}
+	return 0
+}
+
+// checksumType returns the checksum type of the encryption type of key.
+func (k kerberosKey) checksumType() int32 {
+	if k.etype == KerberosAES128CTSHMACSHA196 {
+		return kerberosHMACSHA196AES128
+	}
+	return kerberosHMACSHA196AES256
+}
+
+func (k kerberosKey) check() error {
+	if size := kerberosKeySize(k.etype); size == 0 || len(k.value) != size {
+		return newError(ErrorInvalidArgument, fmt.Sprintf("Kerberos: unsupported encryption type %d", k.etype))
+	}
+	return nil
+}
+
+// kerberosStringToKey derives the key of etype from password with salt and
+// the iteration count in params, 4096 without params.
+func kerberosStringToKey(etype int32, password, salt string, params []byte) (kerberosKey, error) {
+	size := kerberosKeySize(etype)
+	if size == 0 {
+		return kerberosKey{}, newError(ErrorInvalidArgument, fmt.Sprintf("Kerberos: unsupported encryption type %d", etype))
+	}
+	iterations := 4096
+	if len(params) == 4 {
+		iterations = int(binary.BigEndian.Uint32(params))
+	}
+	if iterations <= 0 || iterations > 1<<24 {
+		return kerberosKey{}, newError(ErrorInvalidArgument, fmt.Sprintf("Kerberos: invalid iteration count %d", iterations))
+	}
+	tkey := pbkdf2(sha1.New, []byte(password), []byte(salt), iterations, size)
+	return kerberosKey{etype, deriveKerberosKey(tkey, []byte("kerberos"))}, nil
+}
+
+// pbkdf2 is PBKDF2 [https://tools.ietf.org/html/rfc2898] with the HMAC of
+// newHash, also the Hi of SCRAM.
+func pbkdf2(newHash func() hash.Hash, password, salt []byte, iterations, size int) []byte {
+	mac := hmac.New(newHash, password)
+	var key []byte
+	for block := uint32(1); len(key) < size; block++ {
+		mac.Reset()
+		mac.Write(salt)
+		binary.Write(mac, binary.BigEndian, block)
+		u := mac.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			mac.Reset()
+			mac.Write(u)
+			u = mac.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:size]
+}
+
+// deriveKerberosKey is DK(key, constant) of RFC 3961, random-to-key is the
+// identity for AES.
+func deriveKerberosKey(key, constant []byte) []byte {
+	block, _ := aes.NewCipher(key)
+	derived := make([]byte, 0, len(key)+aes.BlockSize)
+	in := nfold(constant, aes.BlockSize*8)
+	for len(derived) < len(key) {
+		out := make([]byte, aes.BlockSize)
+		block.Encrypt(out, in)
+		derived = append(derived, out...)
+		in = out
+	}
+	return derived[:len(key)]
+}
+
+// usageKey derives the key of the usage with the suffix 0x99 (checksum), 0xaa
+// (encryption) or 0x55 (integrity).
+func (k kerberosKey) usageKey(usage uint32, suffix byte) []byte {
+	constant := make([]byte, 5)
+	binary.BigEndian.PutUint32(constant, usage)
+	constant[4] = suffix
+	return deriveKerberosKey(k.value, constant)
+}
+
+// encrypt returns the ciphertext of plaintext for usage, with a random
+// confounder and the HMAC-SHA1-96 of the plaintext.
+func (k kerberosKey) encrypt(usage uint32, plaintext []byte) ([]byte, error) {
+	if err := k.check(); err != nil {
+		return nil, err
+	}
+	data := make([]byte, aes.BlockSize, aes.BlockSize+len(plaintext))
+	if _, err := rand.Read(data); err != nil {
+		return nil, err
+	}
+	data = append(data, plaintext...)
+	mac := hmac.New(sha1.New, k.usageKey(usage, 0x55))
+	mac.Write(data)
+	return append(ctsEncrypt(k.usageKey(usage, 0xaa), data), mac.Sum(nil)[:12]...), nil
+}
+
+// decrypt returns the plaintext of ciphertext encrypted for usage.
+func (k kerberosKey) decrypt(usage uint32, ciphertext []byte) ([]byte, error) {
+	if err := k.check(); err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aes.BlockSize+12 {
+		return nil, newError(ErrorDecoding, "Kerberos: ciphertext too short")
+	}
+	data := ctsDecrypt(k.usageKey(usage, 0xaa), ciphertext[:len(ciphertext)-12])
+	mac := hmac.New(sha1.New, k.usageKey(usage, 0x55))
+	mac.Write(data)
+	if !hmac.Equal(mac.Sum(nil)[:12], ciphertext[len(ciphertext)-12:]) {
+		return nil, newError(ErrorDecoding, "Kerberos: integrity check of the ciphertext failed")
+	}
+	return data[aes.BlockSize:], nil
+}
+
+// checksum returns the HMAC-SHA1-96 of data for usage.
+func (k kerberosKey) checksum(usage uint32, data []byte) []byte {
+	mac := hmac.New(sha1.New, k.usageKey(usage, 0x99))
+	mac.Write(data)
+	return mac.Sum(nil)[:12]
+}
+
+// ctsEncrypt encrypts data of at least one block with AES in CBC mode with
+// ciphertext stealing and a zero IV, the last two blocks are swapped.
+func ctsEncrypt(key, data []byte) []byte {
+	block, _ := aes.NewCipher(key)
+	blocks := (len(data) + aes.BlockSize - 1) / aes.BlockSize
+	padded := make([]byte, blocks*aes.BlockSize)
+	copy(padded, data)
+	cipher.NewCBCEncrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(padded, padded)
+	if blocks == 1 {
+		return padded
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, padded[:(blocks-2)*aes.BlockSize]...)
+	out = append(out, padded[(blocks-1)*aes.BlockSize:]...)
+	return append(out, padded[(blocks-2)*aes.BlockSize:len(data)-aes.BlockSize]...)
+}
+
+// ctsDecrypt decrypts data encrypted by ctsEncrypt.
+func ctsDecrypt(key, data []byte) []byte {
+	block, _ := aes.NewCipher(key)
+	blocks := (len(data) + aes.BlockSize - 1) / aes.BlockSize
+	if blocks == 1 {
+		out := make([]byte, aes.BlockSize)
+		cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(out, data)
+		return out
+	}
+	// restore the CBC ciphertext, the tail of the second to last block is
+	// the decryption of the last one as the plaintext was padded with zeros
+	last := data[(blocks-2)*aes.BlockSize : (blocks-1)*aes.BlockSize]
+	partial := data[(blocks-1)*aes.BlockSize:]
+	decrypted := make([]byte, aes.BlockSize)
+	block.Decrypt(decrypted, last)
+	cbc := make([]byte, 0, blocks*aes.BlockSize)
+	cbc = append(cbc, data[:(blocks-2)*aes.BlockSize]...)
+	cbc = append(cbc, partial...)
+	cbc = append(cbc, decrypted[len(partial):]...)
+	cbc = append(cbc, last...)
+	cipher.NewCBCDecrypter(block, make([]byte, aes.BlockSize)).CryptBlocks(cbc, cbc)
+	return cbc[:len(data)]
+}
+
+// nfold stretches or folds in to n bits [https://tools.ietf.org/html/rfc3961#section-5.1].
+func nfold(in []byte, n int) []byte {
+	inBits, size := len(in)*8, n/8
+	lcm := inBits
+	for lcm%n != 0 {
+		lcm += inBits
+	}
+	// the one's complement sum of the n-bit blocks of the input repeated and
+	// rotated right by 13 bits more each time
+	sum := make([]int, size)
+	offset := 0
+	for i := 0; i < lcm/inBits; i++ {
+		for _, b := range rotateRight(in, 13*i) {
+			sum[offset%size] += int(b)
+			offset++
+		}
+	}
+	for carry := true; carry; {
+		carry = false
+		for i := size - 1; i >= 0; i-- {
+			if sum[i] > 0xff {
+				if i > 0 {
+					sum[i-1] += sum[i] >> 8
+				} else {
+					sum[size-1] += sum[i] >> 8
+				}
+				sum[i] &= 0xff
+				carry = true
+			}
+		}
+	}
+	out := make([]byte, size)
+	for i := range sum {
+		out[i] = byte(sum[i])
+	}
+	return out
+}
+
+func rotateRight(in []byte, bits int) []byte {
+	n := len(in) * 8
+	out := make([]byte, len(in))
+	for i := 0; i < n; i++ {
+		src := ((i-bits)%n + n) % n
+		if in[src/8]&(0x80>>uint(src%8)) != 0 {
+			out[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	return out
+}
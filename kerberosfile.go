@@ -0,0 +1,234 @@
+package ldap
+
+import (
+	"encoding/binary"
+	"os"
+	"strings"
+	"time"
+)
+
+// Keytab holds the keys of principals as in the keytab files of MIT Kerberos
+// and ktpass [https://web.mit.edu/kerberos/krb5-latest/doc/formats/keytab_file_format.html].
+type Keytab struct {
+	entries []keytabEntry
+}
+
+type keytabEntry struct {
+	principal kerberosPrincipal
+	kvno      uint32
+	key       kerberosKey
+}
+
+// LoadKeytab reads the keytab file at path.
+func LoadKeytab(path string) (*Keytab, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeytab(data)
+}
+
+// ParseKeytab decodes a keytab of version 1 or 2.
+func ParseKeytab(data []byte) (*Keytab, error) {
+	if len(data) < 2 || data[0] != 5 || (data[1] != 1 && data[1] != 2) {
+		return nil, newError(ErrorDecoding, "Kerberos: unsupported keytab version")
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if data[1] == 1 {
+		order = binary.LittleEndian
+	}
+	keytab := &Keytab{}
+	r := &kerberosFileReader{data: data[2:], order: order}
+	for len(r.data) > 0 {
+		size := int32(r.uint32())
+		if r.err != nil {
+			return nil, r.err
+		}
+		if size < 0 {
+			// a hole left by a deleted entry
+			r.bytes(int(-size))
+			continue
+		}
+		entry := &kerberosFileReader{data: r.bytes(int(size)), order: order}
+		if r.err != nil {
+			return nil, r.err
+		}
+		var e keytabEntry
+		components := int(entry.uint16())
+		if data[1] == 1 {
+			// the count included the realm
+			components--
+		}
+		e.principal.realm = string(entry.bytes(int(entry.uint16())))
+		for i := 0; i < components && entry.err == nil; i++ {
+			e.principal.names = append(e.principal.names, string(entry.bytes(int(entry.uint16()))))
+		}
+		e.principal.nameType = kerberosNTPrincipal
+		if data[1] == 2 {
+			e.principal.nameType = int32(entry.uint32())
+		}
+		entry.uint32() // timestamp
+		e.kvno = uint32(entry.uint8())
+		e.key.etype = int32(entry.uint16())
+		e.key.value = entry.bytes(int(entry.uint16()))
+		if len(entry.data) >= 4 {
+			if kvno := entry.uint32(); kvno != 0 {
+				e.kvno = kvno
+			}
+		}
+		if entry.err != nil {
+			return nil, entry.err
+		}
+		keytab.entries = append(keytab.entries, e)
+	}
+	return keytab, nil
+}
+
+// key returns the key of principal with etype of the newest version in the
+// keytab.
+func (k *Keytab) key(principal kerberosPrincipal, etype int32) (kerberosKey, bool) {
+	var key kerberosKey
+	var kvno uint32
+	found := false
+	for _, entry := range k.entries {
+		if entry.key.etype == etype && entry.principal.equal(principal) && (!found || entry.kvno > kvno) {
+			key, kvno, found = entry.key, entry.kvno, true
+		}
+	}
+	return key, found
+}
+
+// CCache holds the tickets of a credential cache as written by kinit
+// [https://web.mit.edu/kerberos/krb5-latest/doc/formats/ccache_file_format.html].
+type CCache struct {
+	principal   kerberosPrincipal
+	credentials []*kerberosCredential
+}
+
+// kerberosCredential is a ticket with its session key.
+type kerberosCredential struct {
+	client  kerberosPrincipal
+	server  kerberosPrincipal
+	key     kerberosKey
+	endTime time.Time
+	// encoded Ticket
+	ticket []byte
+}
+
+// LoadCCache reads the credential cache file at path, e.g. the file of
+// KRB5CCNAME without the prefix "FILE:".
+func LoadCCache(path string) (*CCache, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCCache(data)
+}
+
+// ParseCCache decodes a credential cache of version 3 or 4.
+func ParseCCache(data []byte) (*CCache, error) {
+	if len(data) < 2 || data[0] != 5 || (data[1] != 3 && data[1] != 4) {
+		return nil, newError(ErrorDecoding, "Kerberos: unsupported credential cache version")
+	}
+	r := &kerberosFileReader{data: data[2:], order: binary.BigEndian}
+	if data[1] == 4 {
+		// header tags, like the offset to the time of the KDC
+		r.bytes(int(r.uint16()))
+	}
+	ccache := &CCache{principal: r.principal()}
+	for len(r.data) > 0 && r.err == nil {
+		credential := &kerberosCredential{client: r.principal(), server: r.principal()}
+		credential.key.etype = int32(r.uint16())
+		if data[1] == 3 {
+			// the encryption type is repeated
+			r.uint16()
+		}
+		credential.key.value = r.bytes(int(r.uint32()))
+		r.uint32() // authtime
+		r.uint32() // starttime
+		credential.endTime = time.Unix(int64(r.uint32()), 0)
+		r.uint32() // renew till
+		r.uint8()  // is_skey
+		r.uint32() // ticket flags
+		for i := r.uint32(); i > 0 && r.err == nil; i-- {
+			// addresses
+			r.uint16()
+			r.bytes(int(r.uint32()))
+		}
+		for i := r.uint32(); i > 0 && r.err == nil; i-- {
+			// authorization data
+			r.uint16()
+			r.bytes(int(r.uint32()))
+		}
+		credential.ticket = r.bytes(int(r.uint32()))
+		r.bytes(int(r.uint32())) // second ticket
+		if r.err == nil && !strings.HasPrefix(credential.server.realm, "X-CACHECONF:") {
+			ccache.credentials = append(ccache.credentials, credential)
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return ccache, nil
+}
+
+// credential returns the unexpired ticket for server.
+func (c *CCache) credential(server kerberosPrincipal) *kerberosCredential {
+	for _, credential := range c.credentials {
+		if credential.server.equal(server) && time.Now().Before(credential.endTime) {
+			return credential
+		}
+	}
+	return nil
+}
+
+// kerberosFileReader reads the fields of keytabs and credential caches, err
+// is set once data is exhausted.
+type kerberosFileReader struct {
+	data  []byte
+	order binary.ByteOrder
+	err   error
+}
+
+func (r *kerberosFileReader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || n > len(r.data) {
+		r.err = newError(ErrorDecoding, "Kerberos: file truncated")
+		r.data = nil
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *kerberosFileReader) uint8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *kerberosFileReader) uint16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return r.order.Uint16(b)
+	}
+	return 0
+}
+
+func (r *kerberosFileReader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return r.order.Uint32(b)
+	}
+	return 0
+}
+
+// principal reads a principal of a credential cache.
+func (r *kerberosFileReader) principal() kerberosPrincipal {
+	p := kerberosPrincipal{nameType: int32(r.uint32())}
+	components := r.uint32()
+	p.realm = string(r.bytes(int(r.uint32())))
+	for i := uint32(0); i < components && r.err == nil; i++ {
+		p.names = append(p.names, string(r.bytes(int(r.uint32()))))
+	}
+	return p
+}
@@ -15,8 +15,10 @@ import (
 )
 
 const (
-	DefaultTimeout       = 60 * time.Minute
-	ResultChanBufferSize = 5 // buffer items in each chanResults default: 5
+	DefaultTimeout = 60 * time.Minute
+	// Deprecated: ignored, the responses of a message are queued up to
+	// MaxQueuedResponses
+	ResultChanBufferSize = 5
 )
 
 // Adds descriptions to an LDAP Response packet for debugging
@@ -78,6 +80,10 @@ func addLDAPDescriptions(packet *ber.Packet) (err error) {
 	case ApplicationExtendedRequest:
 		addRequestDescriptions(packet)
 	case ApplicationExtendedResponse:
+	case ApplicationIntermediateResponse:
+		if len(packet.Children) == 3 {
+			addControlDescriptions(packet.Children[2])
+		}
 	}
 
 	return nil
@@ -170,61 +176,82 @@ func DebugBinaryFile(FileName string) error {
 type Error struct {
 	sText      string
 	ResultCode ResultCode
+	// cause of the error, context.DeadlineExceeded for an ErrorTimeout
+	err error
 }
 
 func (e *Error) Error() string {
 	return fmt.Sprintf("LDAP Result Code %d %q: %s", e.ResultCode, e.ResultCode.String(), e.sText)
 }
 
+// Unwrap returns the cause of the error, so errors.Is(err,
+// context.DeadlineExceeded) holds for an ErrorTimeout.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Timeout reports whether the operation timed out, like the Timeout of a
+// net.Error.
+func (e *Error) Timeout() bool {
+	return e.ResultCode == ErrorTimeout
+}
+
+// LimitExceeded reports whether a search exceeded the SizeLimit or TimeLimit
+// of the request, or of the server, the entries returned until then are
+// returned with the error.
+func (e *Error) LimitExceeded() bool {
+	return e.ResultCode == ResultSizeLimitExceeded || e.ResultCode == ResultTimeLimitExceeded
+}
+
 func newError(resultCode ResultCode, sText string) error {
 	return &Error{ResultCode: resultCode, sText: sText}
 }
 
-func getResultCode(p *ber.Packet) (ResultCode, string) {
-	var code ResultCode
-	var description string
-	if len(p.Children) >= 2 {
-		response := p.Children[1]
-		if response.ClassType == ber.ClassApplication && response.TagType == ber.TypeConstructed {
-			switch {
-			case len(response.Children) == 3:
-				code, ok := response.Children[0].Value.(int64)
-				if !ok {
-					log.Println("type assertion failed in ldap.go 174")
-					code = 212
-				}
-				resultCode := ResultCode(code)
-
-				switch t := response.Children[2].Value.(type) {
-				case string:
-					description = t
-				case []byte:
-					description = string(t)
-				default:
-					description = ""
-				}
-
-				return resultCode, description
-
-			case len(response.Children) == 4 && ResultCode(response.Children[0].Value.(uint64)) == ResultReferral:
-				response = response.Children[3]
-				if response.ClassType == ber.ClassContext && response.TagType == ber.TypeConstructed && len(response.Children) == 1 {
-					switch t := response.Children[0].Value.(type) {
-					case string:
-						description = t
-					case []byte:
-						description = string(t)
-					default:
-						description = ""
-					}
-
-					return ResultReferral, description
-				}
-			}
+// packetString returns the value of a primitive string packet. Packets that are
+// not of the universal class don't carry a decoded value, their raw data is used.
+func packetString(p *ber.Packet) string {
+	switch t := p.Value.(type) {
+	case string:
+		return t
+	case []byte:
+		return string(t)
+	}
+	return string(p.Data.Bytes())
+}
+
+// packetInt64 returns the value of a primitive integer or enumerated packet.
+func packetInt64(p *ber.Packet) (int64, bool) {
+	switch t := p.Value.(type) {
+	case int64:
+		return t, true
+	case uint64:
+		return int64(t), true
+	case int:
+		return int64(t), true
+	case nil:
+		if p.TagType == ber.TypePrimitive && p.Data.Len() > 0 && p.Data.Len() <= 8 {
+			return decodeInteger(p.Data.Bytes()), true
 		}
 	}
+	return 0, false
+}
 
-	code = ErrorNetwork
-	description = "Invalid packet format"
-	return code, description
+// packetBool returns the value of a BOOLEAN packet, also of other classes than
+// the universal class.
+func packetBool(p *ber.Packet) bool {
+	if b, ok := p.Value.(bool); ok {
+		return b
+	}
+	return p.Data.Len() > 0 && p.Data.Bytes()[0] != 0
+}
+
+// decodeInteger decodes a two's complement big endian integer
+func decodeInteger(data []byte) (ret int64) {
+	for i, b := range data {
+		if i == 0 && b&0x80 != 0 {
+			ret = -1
+		}
+		ret = ret<<8 | int64(b)
+	}
+	return
 }
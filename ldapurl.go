@@ -0,0 +1,247 @@
+package ldap
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// URL is an LDAP URL [https://tools.ietf.org/html/rfc4516] of the form
+// scheme://host/dn?attributes?scope?filter?extensions, e.g.
+// ldap://ldap.example.com/o=bigcorp?cn,mail?sub?(uid=bob).
+type URL struct {
+	// Scheme is ldap, ldaps or ldapi
+	Scheme string
+	// Host with the port as in the URL, empty for the default server and
+	// the URL-encoded socket path with ldapi
+	Host string
+	// BaseDN of the search, empty for the RootDSE or, in referrals, the
+	// base DN of the original search
+	BaseDN string
+	// Attributes the search returns, none for all user attributes
+	Attributes []string
+	// Scope of the search, ScopeBaseObject if the URL has none
+	Scope Scope
+	// Filter of the search, empty for "(objectClass=*)" or, in referrals,
+	// the filter of the original search
+	Filter     string
+	Extensions []URLExtension
+
+	// hasScope is set if the parsed URL has a scope
+	hasScope bool
+}
+
+// URLExtension is an extension of an URL, e.g. bindname=cn=admin.
+type URLExtension struct {
+	Type  string
+	Value string
+	// Critical extensions, marked with "!", have to be supported to
+	// process the URL
+	Critical bool
+}
+
+// ParseURL parses an LDAP URL. The components are URL-decoded, the DN is
+// not validated, the filter is.
+func ParseURL(ldapURL string) (*URL, error) {
+	i := strings.Index(ldapURL, "://")
+	if i == -1 {
+		return nil, newError(ErrorInvalidArgument, "Invalid LDAP URL: missing scheme in "+ldapURL)
+	}
+	u := &URL{Scheme: strings.ToLower(ldapURL[:i])}
+	switch u.Scheme {
+	case "ldap", "ldaps", "ldapi":
+	default:
+		return nil, newError(ErrorInvalidArgument, "Unsupported LDAP URL scheme: "+ldapURL[:i])
+	}
+	rest := ldapURL[i+len("://"):]
+	if end := strings.IndexAny(rest, "/?"); end != -1 {
+		u.Host, rest = rest[:end], rest[end:]
+	} else {
+		u.Host, rest = rest, ""
+	}
+	if rest == "" {
+		return u, nil
+	}
+	if rest[0] != '/' {
+		return nil, newError(ErrorInvalidArgument, "Invalid LDAP URL: missing / before "+rest)
+	}
+
+	parts := strings.Split(rest[1:], "?")
+	if len(parts) > 5 {
+		return nil, newError(ErrorInvalidArgument, "Invalid LDAP URL: too many components in "+ldapURL)
+	}
+	var err error
+	if u.BaseDN, err = unescapeURLComponent(parts[0]); err != nil {
+		return nil, err
+	}
+	if len(parts) > 1 && parts[1] != "" {
+		for _, attribute := range strings.Split(parts[1], ",") {
+			attribute, err := unescapeURLComponent(attribute)
+			if err != nil {
+				return nil, err
+			}
+			u.Attributes = append(u.Attributes, attribute)
+		}
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		if u.Scope, u.hasScope = parseURLScope(parts[2]); !u.hasScope {
+			return nil, newError(ErrorInvalidArgument, "Invalid LDAP URL: unknown scope "+parts[2])
+		}
+	}
+	if len(parts) > 3 && parts[3] != "" {
+		if u.Filter, err = unescapeURLComponent(parts[3]); err != nil {
+			return nil, err
+		}
+		if _, err := CompileFilter(u.Filter); err != nil {
+			return nil, err
+		}
+	}
+	if len(parts) > 4 && parts[4] != "" {
+		for _, extension := range strings.Split(parts[4], ",") {
+			var ext URLExtension
+			if strings.HasPrefix(extension, "!") {
+				ext.Critical, extension = true, extension[1:]
+			}
+			value := ""
+			if eq := strings.IndexByte(extension, '='); eq != -1 {
+				extension, value = extension[:eq], extension[eq+1:]
+			}
+			if ext.Type, err = unescapeURLComponent(extension); err != nil {
+				return nil, err
+			}
+			if ext.Value, err = unescapeURLComponent(value); err != nil {
+				return nil, err
+			}
+			u.Extensions = append(u.Extensions, ext)
+		}
+	}
+	return u, nil
+}
+
+// String returns the URL with the components URL-encoded, leaving out the
+// empty trailing ones.
+func (u *URL) String() string {
+	attributes := make([]string, len(u.Attributes))
+	for i, attribute := range u.Attributes {
+		attributes[i] = escapeURLComponent(attribute, true)
+	}
+	scope := ""
+	switch {
+	case u.Scope == ScopeSingleLevel:
+		scope = "one"
+	case u.Scope == ScopeWholeSubtree:
+		scope = "sub"
+	case u.hasScope:
+		scope = "base"
+	}
+	extensions := make([]string, len(u.Extensions))
+	for i, ext := range u.Extensions {
+		extensions[i] = escapeURLComponent(ext.Type, true)
+		if ext.Critical {
+			extensions[i] = "!" + extensions[i]
+		}
+		if ext.Value != "" {
+			extensions[i] += "=" + escapeURLComponent(ext.Value, true)
+		}
+	}
+	parts := []string{
+		escapeURLComponent(u.BaseDN, false),
+		strings.Join(attributes, ","),
+		scope,
+		escapeURLComponent(u.Filter, false),
+		strings.Join(extensions, ","),
+	}
+	for len(parts) > 1 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	s := u.Scheme + "://" + u.Host
+	if len(parts) > 1 || parts[0] != "" {
+		s += "/" + strings.Join(parts, "?")
+	}
+	return s
+}
+
+// SearchRequest returns the search of the URL with controls.
+func (u *URL) SearchRequest(controls []Control) *SearchRequest {
+	filter := u.Filter
+	if filter == "" {
+		filter = "(objectClass=*)"
+	}
+	return NewSearchRequest(u.BaseDN, u.Scope, NeverDerefAliases, 0, 0, false, filter, u.Attributes, controls)
+}
+
+// ReferralSearchRequest returns the search continuing req at the server of
+// the URL of a referral or search result reference
+// [https://tools.ietf.org/html/rfc4511#section-4.5.3]: the base DN, scope
+// and filter of the URL replace the ones of req if present, the other
+// fields are kept. Referrals may return entries found before, see
+// SearchResult.Dedupe.
+func (u *URL) ReferralSearchRequest(req *SearchRequest) *SearchRequest {
+	referral := *req
+	if u.BaseDN != "" {
+		referral.BaseDN = u.BaseDN
+	}
+	if u.hasScope || u.Scope != ScopeBaseObject {
+		referral.Scope = u.Scope
+	}
+	if u.Filter != "" {
+		referral.Filter = u.Filter
+	}
+	return &referral
+}
+
+// criticalExtension returns an error for the first critical extension of
+// the URL, none of which is supported.
+func (u *URL) criticalExtension() error {
+	for _, ext := range u.Extensions {
+		if ext.Critical {
+			return newError(ErrorInvalidArgument, "Unsupported critical LDAP URL extension "+ext.Type)
+		}
+	}
+	return nil
+}
+
+// SearchFromURL runs the search of ldapURL, the base DN, attributes, scope
+// and filter, on the connection; the host of the URL is ignored, connect to
+// it with DialURL. URLs with critical extensions are rejected.
+func (l *Connection) SearchFromURL(ldapURL string, controls []Control) (*SearchResult, error) {
+	return l.SearchFromURLContext(context.Background(), ldapURL, controls)
+}
+
+// SearchFromURLContext is SearchFromURL with ctx.
+func (l *Connection) SearchFromURLContext(ctx context.Context, ldapURL string, controls []Control) (*SearchResult, error) {
+	u, err := ParseURL(ldapURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.criticalExtension(); err != nil {
+		return nil, err
+	}
+	return l.SearchContext(ctx, u.SearchRequest(controls))
+}
+
+func unescapeURLComponent(s string) (string, error) {
+	s, err := url.PathUnescape(s)
+	if err != nil {
+		return "", newError(ErrorInvalidArgument, "Invalid LDAP URL: "+err.Error())
+	}
+	return s, nil
+}
+
+// escapeURLComponent percent-encodes the characters of s not allowed in
+// the components of an LDAP URL, ? always and , if comma is set.
+func escapeURLComponent(s string, comma bool) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c >= 0x7f || strings.IndexByte("%?\"#<>[\\]^`{|}", c) != -1 || comma && c == ',' {
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
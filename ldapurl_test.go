@@ -0,0 +1,91 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"reflect"
+	"testing"
+)
+
+func TestParseURL(t *testing.T) {
+	u, err := ParseURL("LDAP://ldap.example.com:389/o=big%20corp?cn,mail?SUB?(uid=bob%3F)?!bindname=cn=admin%2Co=bigcorp,x-foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &URL{
+		Scheme:     "ldap",
+		Host:       "ldap.example.com:389",
+		BaseDN:     "o=big corp",
+		Attributes: []string{"cn", "mail"},
+		Scope:      ScopeWholeSubtree,
+		Filter:     "(uid=bob?)",
+		Extensions: []URLExtension{{Type: "bindname", Value: "cn=admin,o=bigcorp", Critical: true}, {Type: "x-foo"}},
+		hasScope:   true,
+	}
+	if !reflect.DeepEqual(u, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, u)
+	}
+	if s := u.String(); s != "ldap://ldap.example.com:389/o=big%20corp?cn,mail?sub?(uid=bob%3F)?!bindname=cn=admin%2Co=bigcorp,x-foo" {
+		t.Errorf("Unexpected URL %s", s)
+	}
+
+	for ldapURL, expected := range map[string]string{
+		"ldap://":                      "ldap://",
+		"ldaps://host/":                "ldaps://host",
+		"ldap:///o=bigcorp??base":      "ldap:///o=bigcorp??base",
+		"ldap://host/??one":            "ldap://host/??one",
+		"ldapi://%2Fvar%2Frun%2Fldapi": "ldapi://%2Fvar%2Frun%2Fldapi",
+	} {
+		u, err := ParseURL(ldapURL)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %v", ldapURL, err)
+		} else if s := u.String(); s != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, ldapURL, s)
+		}
+	}
+	for _, ldapURL := range []string{"http://host/", "ldap:host", "ldap://host?cn", "ldap://host/o=bigcorp??children", "ldap://host/o=bigcorp??subtree", "ldap://host/???(cn=*", "ldap://host/?????", "ldap://host/o=%zz"} {
+		if _, err := ParseURL(ldapURL); err == nil {
+			t.Errorf("Expected an error for %s", ldapURL)
+		}
+	}
+}
+
+func TestURLReferralSearchRequest(t *testing.T) {
+	req := NewSearchRequest("o=bigcorp", ScopeWholeSubtree, DerefAlways, 10, 0, false, "(cn=bob)", []string{"mail"}, nil)
+	u, _ := ParseURL("ldap://east.example.com/ou=east,o=bigcorp")
+	referral := u.ReferralSearchRequest(req)
+	if referral.BaseDN != "ou=east,o=bigcorp" || referral.Scope != ScopeWholeSubtree || referral.Filter != "(cn=bob)" || referral.SizeLimit != 10 || referral.DerefAliases != DerefAlways {
+		t.Errorf("Unexpected referral search %+v", referral)
+	}
+	if req.BaseDN != "o=bigcorp" {
+		t.Error("The original search was changed")
+	}
+	u, _ = ParseURL("ldap://east.example.com/ou=east,o=bigcorp??base")
+	if referral := u.ReferralSearchRequest(req); referral.Scope != ScopeBaseObject {
+		t.Errorf("Expected the base scope of the URL, got %s", referral.Scope)
+	}
+}
+
+func TestSearchFromURL(t *testing.T) {
+	l, s := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		messageID := mockMessageID(request)
+		s.respond(messageID, mockSearchEntry("cn=bob,o=bigcorp", map[string][]string{"mail": {"bob@bigcorp.com"}}))
+		s.respondResult(messageID, ApplicationSearchResultDone, ResultSuccess, "")
+	})
+	defer l.Close()
+
+	result, err := l.SearchFromURL("ldap://other.example.com/o=bigcorp?mail?one?(cn=bob)", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 1 || result.Entries[0].GetAttributeValue("mail") != "bob@bigcorp.com" {
+		t.Errorf("Unexpected entries %v", result.Entries)
+	}
+	request := (<-s.requests).Children[1]
+	if scope, _ := packetInt64(request.Children[1]); packetString(request.Children[0]) != "o=bigcorp" || Scope(scope) != ScopeSingleLevel || packetString(request.Children[7].Children[0]) != "mail" {
+		t.Errorf("Unexpected search %v", request)
+	}
+
+	if _, err := l.SearchFromURL("ldap:///o=bigcorp????!bindname=cn=admin", nil); err == nil {
+		t.Error("Expected an error for a critical extension")
+	}
+}
@@ -0,0 +1,98 @@
+package ldap
+
+import (
+	"context"
+	"sync"
+)
+
+// requestLimiter limits the requests in flight, waiting requests get a free
+// slot in the order they arrived.
+type requestLimiter struct {
+	lock    sync.Mutex
+	active  int
+	waiters []chan struct{}
+}
+
+// acquire waits for a slot of max until ctx is done and returns whether a slot
+// was taken, nothing is limited for a max of 0.
+func (r *requestLimiter) acquire(ctx context.Context, max int) (bool, error) {
+	if max <= 0 {
+		return false, nil
+	}
+	r.lock.Lock()
+	if r.active < max && len(r.waiters) == 0 {
+		r.active++
+		r.lock.Unlock()
+		return true, nil
+	}
+	wait := make(chan struct{})
+	r.waiters = append(r.waiters, wait)
+	r.lock.Unlock()
+
+	select {
+	case <-wait:
+		return true, nil
+	case <-ctx.Done():
+	}
+	r.lock.Lock()
+	for i, waiter := range r.waiters {
+		if waiter == wait {
+			r.waiters = append(r.waiters[:i], r.waiters[i+1:]...)
+			r.lock.Unlock()
+			return false, doneError(ctx)
+		}
+	}
+	r.lock.Unlock()
+	// the slot was handed over in the meantime
+	r.release()
+	return false, doneError(ctx)
+}
+
+// release hands the slot over to the first waiting request or frees it.
+func (r *requestLimiter) release() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if len(r.waiters) > 0 {
+		close(r.waiters[0])
+		r.waiters = r.waiters[1:]
+		return
+	}
+	r.active--
+}
+
+// acquireRequest waits until less than MaxOutstanding requests are in flight,
+// the returned function releases the slot once the request finished.
+func (l *Connection) acquireRequest(ctx context.Context) (func(), error) {
+	acquired, err := l.limiter.acquire(ctx, l.MaxOutstanding)
+	if !acquired {
+		return func() {}, err
+	}
+	return l.limiter.release, nil
+}
+
+// nextRequestID waits for a slot like acquireRequest before it takes the next
+// messageID, so no messageID is held by a request waiting for a slot, and
+// counts the request as a use of the connection. The returned function
+// releases both once the request finished.
+func (l *Connection) nextRequestID(ctx context.Context) (int64, func(), error) {
+	if ctx.Err() != nil {
+		return 0, nil, doneError(ctx)
+	}
+	release, err := l.acquireRequest(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	// an expired connection is replaced before the use keeps it
+	l.refreshExpired()
+	unuse := l.use()
+	messageID, ok := l.nextMessageID()
+	if !ok {
+		unuse()
+		release()
+		return 0, nil, l.messageIDError()
+	}
+	return messageID, func() {
+		unuse()
+		release()
+	}, nil
+}
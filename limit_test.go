@@ -0,0 +1,94 @@
+package ldap
+
+import (
+	"context"
+	"github.com/eaciit/asn1-ber"
+	"testing"
+	"time"
+)
+
+func TestRequestLimiterOrder(t *testing.T) {
+	var r requestLimiter
+	if acquired, _ := r.acquire(context.Background(), 1); !acquired {
+		t.Fatal("Expected the free slot to be taken")
+	}
+	order := make(chan int, 3)
+	for i := 0; i < 3; i++ {
+		go func(i int) {
+			r.acquire(context.Background(), 1)
+			order <- i
+			r.release()
+		}(i)
+		// queue the waiters in order
+		for {
+			r.lock.Lock()
+			waiting := len(r.waiters)
+			r.lock.Unlock()
+			if waiting == i+1 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	r.release()
+	for i := 0; i < 3; i++ {
+		if got := <-order; got != i {
+			t.Fatalf("Waiter %d got the slot before waiter %d", got, i)
+		}
+	}
+}
+
+func TestMaxOutstanding(t *testing.T) {
+	respond := make(chan struct{})
+	l, s := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		if request.Children[1].Tag == ber.Tag(ApplicationDelRequest) {
+			<-respond
+			s.respondResult(mockMessageID(request), ApplicationDelResponse, ResultSuccess, "")
+		}
+	})
+	defer l.Close()
+	l.MaxOutstanding = 1
+
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := l.Delete(NewDeleteRequest("cn=bob,o=bigcorp"))
+			done <- err
+		}()
+	}
+	first := mockMessageID(<-s.requests)
+	select {
+	case <-s.requests:
+		t.Fatal("The second delete was sent while the first was outstanding")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// a waiting operation is released by its context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.DeleteContext(ctx, NewDeleteRequest("cn=bob,o=bigcorp")); err == nil || !err.(*Error).Timeout() {
+		t.Errorf("Expected a timeout waiting for a slot, got %v", err)
+	}
+
+	respond <- struct{}{}
+	<-s.requests
+	respond <- struct{}{}
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+
+	// the messageID is taken once a slot is free
+	go func() {
+		_, err := l.Delete(NewDeleteRequest("cn=bob,o=bigcorp"))
+		done <- err
+	}()
+	if messageID := mockMessageID(<-s.requests); messageID != first+2 {
+		t.Errorf("Expected messageID %d after the timed out delete, got %d", first+2, messageID)
+	}
+	respond <- struct{}{}
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
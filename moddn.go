@@ -1,7 +1,9 @@
 package ldap
 
 import (
+	"context"
 	"github.com/eaciit/asn1-ber"
+	"strings"
 )
 
 //ModifyDNRequest ::= [APPLICATION 12] SEQUENCE {
@@ -12,40 +14,101 @@ import (
 //
 //ModifyDNResponse ::= [APPLICATION 13] LDAPResult
 
-type ModDnRequest struct {
-	DN            string
-	NewRDN        string
-	DeleteOldDn   bool
-	NewSuperiorDN string
-	Controls      []Control
+// LDAP modify DN request [https://tools.ietf.org/html/rfc4511#section-4.9]
+type ModifyDNRequest struct {
+	// DN of entry that is renamed or moved
+	DN string
+
+	// New RDN of the entry, see NewRDN to build it from an attribute value
+	NewRDN string
+
+	// Delete the attribute values of the old RDN from the entry
+	DeleteOldRDN bool
+
+	// DN of the new parent entry, empty to keep the entry below its current parent
+	NewSuperior string
+
+	// Server controls
+	Controls []Control
 }
 
-//Untested.
-func (l *Connection) ModDn(req *ModDnRequest) error {
-	messageID, ok := l.nextMessageID()
-	if !ok {
-		return newError(ErrorClosing, "MessageID channel is closed.")
+func NewModifyDNRequest(dn, newRDN string, deleteOldRDN bool, newSuperior string) *ModifyDNRequest {
+	return &ModifyDNRequest{
+		DN:           dn,
+		NewRDN:       newRDN,
+		DeleteOldRDN: deleteOldRDN,
+		NewSuperior:  newSuperior,
+		Controls:     make([]Control, 0),
 	}
+}
 
-	encodedModDn := encodeModDnRequest(req)
+// ModifyDN renames the entry and/or moves it below NewSuperior.
+func (l *Connection) ModifyDN(req *ModifyDNRequest) (*LDAPResult, error) {
+	return l.ModifyDNContext(context.Background(), req)
+}
+
+// ModifyDNContext is ModifyDN with ctx, the request is abandoned when ctx is
+// done before the response arrived.
+func (l *Connection) ModifyDNContext(ctx context.Context, req *ModifyDNRequest) (*LDAPResult, error) {
+	messageID, release, err := l.nextRequestID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	encodedModDn, err := encodeModifyDNRequest(req)
+	if err != nil {
+		return nil, err
+	}
 
 	packet, err := requestBuildPacket(messageID, encodedModDn, req.Controls)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return l.sendReqRespPacket(messageID, packet)
+	return l.sendReqRespResult(ctx, messageID, packet)
 }
 
-func encodeModDnRequest(req *ModDnRequest) (p *ber.Packet) {
-	p = ber.Encode(ber.ClassApplication, ber.TypeConstructed,
+func encodeModifyDNRequest(req *ModifyDNRequest) (*ber.Packet, error) {
+	if len(req.NewRDN) == 0 {
+		return nil, newError(ErrorEncoding, "ModifyDNRequest without NewRDN.")
+	}
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed,
 		ber.Tag(ApplicationModifyDNRequest), nil, ApplicationModifyDNRequest.String())
 	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, req.DN, "LDAPDN"))
 	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, req.NewRDN, "NewRDN"))
-	p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, req.DeleteOldDn, "deleteoldrdn"))
-	if len(req.NewSuperiorDN) > 0 {
+	p.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, req.DeleteOldRDN, "deleteoldrdn"))
+	if len(req.NewSuperior) > 0 {
 		p.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive,
-			ber.TagEOC, req.NewSuperiorDN, "NewSuperiorDN"))
+			0, req.NewSuperior, "NewSuperior"))
+	}
+	return p, nil
+}
+
+func (req *ModifyDNRequest) AddControl(control Control) {
+	if req.Controls == nil {
+		req.Controls = make([]Control, 0)
+	}
+	req.Controls = append(req.Controls, control)
+}
+
+// NewRDN returns the RDN attributeType=value, value is escaped with DnReplace.
+func NewRDN(attributeType, value string) string {
+	return attributeType + "=" + DnReplace(value)
+}
+
+// NewMultiValuedRDN returns a multi-valued RDN like cn=Bob+uid=bob built from
+// the pairs of attributeTypes and values, the values are escaped with DnReplace.
+func NewMultiValuedRDN(attributeTypes, values []string) (string, error) {
+	if len(attributeTypes) == 0 || len(attributeTypes) != len(values) {
+		return "", newError(ErrorInvalidArgument, "Number of attribute types and values of RDN differ.")
+	}
+	avas := make([]string, len(attributeTypes))
+	for i, attributeType := range attributeTypes {
+		if len(attributeType) == 0 {
+			return "", newError(ErrorInvalidArgument, "Empty attribute type in RDN.")
+		}
+		avas[i] = NewRDN(attributeType, values[i])
 	}
-	return
+	return strings.Join(avas, "+"), nil
 }
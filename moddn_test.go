@@ -0,0 +1,43 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"testing"
+)
+
+func TestNewRDN(t *testing.T) {
+	if rdn := NewRDN("cn", "Smith, James"); rdn != `cn=Smith\, James` {
+		t.Errorf("Unexpected RDN %q", rdn)
+	}
+	rdn, err := NewMultiValuedRDN([]string{"cn", "uid"}, []string{"Bob", "b+b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rdn != `cn=Bob+uid=b\+b` {
+		t.Errorf("Unexpected RDN %q", rdn)
+	}
+	if _, err := NewMultiValuedRDN([]string{"cn"}, nil); err == nil {
+		t.Errorf("Expected error for missing value")
+	}
+}
+
+func TestModifyDNEncodeRequest(t *testing.T) {
+	req := NewModifyDNRequest("cn=bob,ou=people,o=bigcorp", NewRDN("cn", "robert"), true, "ou=staff,o=bigcorp")
+	p, err := encodeModifyDNRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := ber.DecodePacket(p.Bytes())
+	if len(decoded.Children) != 4 {
+		t.Fatalf("Expected 4 components, got %d", len(decoded.Children))
+	}
+	newSuperior := decoded.Children[3]
+	if newSuperior.ClassType != ber.ClassContext || newSuperior.Tag != 0 || string(newSuperior.Data.Bytes()) != req.NewSuperior {
+		t.Errorf("Unexpected newSuperior encoding")
+	}
+
+	req.NewSuperior = ""
+	if p, _ = encodeModifyDNRequest(req); len(p.Children) != 3 {
+		t.Errorf("newSuperior should be omitted")
+	}
+}
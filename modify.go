@@ -1,6 +1,7 @@
 package ldap
 
 import (
+	"context"
 	"fmt"
 	"github.com/eaciit/asn1-ber"
 )
@@ -22,19 +23,28 @@ type ModifyRequest struct {
 	Controls []Control
 }
 
-func (l *Connection) Modify(modReq *ModifyRequest) error {
-	messageID, ok := l.nextMessageID()
-	if !ok {
-		return newError(ErrorClosing, "MessageID channel is closed.")
+// Modify applies the changes of the ModifyRequest and returns the result with
+// the response controls, also if the modify failed.
+func (l *Connection) Modify(modReq *ModifyRequest) (*LDAPResult, error) {
+	return l.ModifyContext(context.Background(), modReq)
+}
+
+// ModifyContext is Modify with ctx, the request is abandoned when ctx is done
+// before the response arrived.
+func (l *Connection) ModifyContext(ctx context.Context, modReq *ModifyRequest) (*LDAPResult, error) {
+	messageID, release, err := l.nextRequestID(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer release()
 	encodedModify := encodeModifyRequest(modReq)
 
 	packet, err := requestBuildPacket(messageID, encodedModify, modReq.Controls)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return l.sendReqRespPacket(messageID, packet)
+	return l.sendReqRespResult(ctx, messageID, packet)
 }
 
 func (req *ModifyRequest) Bytes() []byte {
@@ -47,3 +47,105 @@ func TestModifyEncodeModifyRequest(t *testing.T) {
 	p := encodeModifyRequest(modreq)
 	ber.PrintPacket(p)
 }
+
+func TestModifyResponseControls(t *testing.T) {
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		policy := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "PasswordPolicyResponseValue")
+		policy.AppendChild(ber.NewInteger(ber.ClassContext, ber.TypePrimitive, 1, PasswordPolicyTooShort, "error"))
+		s.respond(mockMessageID(request),
+			mockLDAPResult(ApplicationModifyResponse, ResultConstraintViolation, "password too short"),
+			mockControl(ControlTypePasswordPolicy, policy))
+	})
+	defer l.Close()
+
+	modreq := NewModifyRequest(modDNs[0])
+	modreq.AddMod(NewMod(ModReplace, "userPassword", []string{"x"}))
+	modreq.AddControl(NewControlPasswordPolicyRequest())
+	result, err := l.Modify(modreq)
+	if err == nil {
+		t.Fatal("Expected modify to fail")
+	}
+	if result == nil || result.DiagnosticMessage != "password too short" {
+		t.Fatalf("Unexpected result %v", result)
+	}
+	if _, control := FindControl(result.Controls, ControlTypePasswordPolicy); control == nil {
+		t.Errorf("Response control missing: %v", result.Controls)
+	}
+}
+
+func TestModifyNoOp(t *testing.T) {
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		resultCode := ResultSuccess
+		if len(request.Children) == 3 {
+			resultCode = ResultNoOperation
+		}
+		s.respondResult(mockMessageID(request), ApplicationModifyResponse, resultCode, "")
+	})
+	defer l.Close()
+
+	modreq := NewModifyRequest(modDNs[0])
+	modreq.AddMod(NewMod(ModReplace, "cn", []string{"bob"}))
+	modreq.AddControl(NewControlNoOpRequest())
+	result, err := l.Modify(modreq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ResultCode != ResultNoOperation {
+		t.Errorf("Expected ResultNoOperation, got %s", result.ResultCode)
+	}
+
+	// noOperation without the control is an error
+	l2, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		s.respondResult(mockMessageID(request), ApplicationModifyResponse, ResultNoOperation, "")
+	})
+	defer l2.Close()
+	if _, err := l2.Modify(NewModifyRequest(modDNs[0])); err == nil {
+		t.Error("Expected an error for noOperation without the NoOp control")
+	}
+}
+
+func TestUnicodePwdResetRequest(t *testing.T) {
+	req := NewUnicodePwdResetRequest("cn=bob,o=bigcorp", "Sécret1", true)
+	if len(req.Mods) != 1 || req.Mods[0].ModOperation != ModReplace || req.Mods[0].Modification.Name != AttributeUnicodePwd {
+		t.Fatalf("Unexpected mods %v", req.Mods)
+	}
+	expected := "\"\x00S\x00\xe9\x00c\x00r\x00e\x00t\x001\x00\"\x00"
+	if value := req.Mods[0].Modification.Values[0]; value != expected {
+		t.Errorf("Unexpected unicodePwd %q", value)
+	}
+	if _, control := FindControl(req.Controls, ControlTypePolicyHintsRequest); control == nil || !control.Criticality() {
+		t.Errorf("Expected a critical PolicyHints control, got %v", req.Controls)
+	}
+}
+
+func TestChangeUnicodePwd(t *testing.T) {
+	l, s := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		s.respondResult(mockMessageID(request), ApplicationModifyResponse, ResultSuccess, "")
+	})
+	defer l.Close()
+
+	_, err := l.ChangeUnicodePwd("cn=bob,o=bigcorp", "old", "new")
+	if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ResultConfidentialityRequired {
+		t.Fatalf("Expected ResultConfidentialityRequired without TLS, got %v", err)
+	}
+	if _, err := l.ResetUnicodePwd("cn=bob,o=bigcorp", "new", false); err == nil {
+		t.Fatal("Expected the reset to be refused without TLS")
+	}
+
+	l.IsSSL = true
+	if _, err := l.ChangeUnicodePwd("cn=bob,o=bigcorp", "old", "new"); err != nil {
+		t.Fatal(err)
+	}
+	changes := (<-s.requests).Children[1].Children[1].Children
+	if len(changes) != 2 {
+		t.Fatalf("Expected two changes, got %v", changes)
+	}
+	first, _ := packetInt64(changes[0].Children[0])
+	second, _ := packetInt64(changes[1].Children[0])
+	if first != int64(ModDelete) || second != int64(ModAdd) {
+		t.Fatalf("Expected the old password to be deleted and the new one added, got %v", changes)
+	}
+	if value := packetString(changes[1].Children[1].Children[1].Children[0]); value != EncodeUnicodePwd("new") {
+		t.Errorf("Unexpected unicodePwd %q", value)
+	}
+}
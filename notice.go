@@ -0,0 +1,45 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+)
+
+// Notice of Disconnection [https://tools.ietf.org/html/rfc4511#section-4.4.1]
+const ExtendedOperationNoticeOfDisconnection = "1.3.6.1.4.1.1466.20036"
+
+// UnsolicitedNotificationHandler is called with the notifications the server
+// sends without a request, i.e. the ExtendedResponses with messageID 0.
+// Handlers run in their own goroutine.
+type UnsolicitedNotificationHandler func(notification *ExtendedResponse)
+
+// handleUnsolicitedNotification passes an unsolicited notification to the
+// NotificationHandler. A Notice of Disconnection closes the connection,
+// operations still waiting for a response fail with the error of the notice.
+func (l *Connection) handleUnsolicitedNotification(p *ber.Packet) {
+	notification, err := decodeExtendedResponse(p)
+	if err != nil {
+		if l.Debug {
+			ber.PrintPacket(p)
+		}
+		return
+	}
+
+	if handler := l.NotificationHandler; handler != nil {
+		go handler(notification)
+	}
+
+	if notification.Name == ExtendedOperationNoticeOfDisconnection {
+		description := "Notice of Disconnection"
+		if len(notification.DiagnosticMessage) > 0 {
+			description += ": " + notification.DiagnosticMessage
+		}
+		l.setDisconnectError(newError(notification.ResultCode, description))
+		l.disconnect()
+	}
+}
+
+func (l *Connection) setDisconnectError(err error) {
+	l.lockResponses.Lock()
+	defer l.lockResponses.Unlock()
+	l.disconnectError = err
+}
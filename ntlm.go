@@ -0,0 +1,429 @@
+package ldap
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"math/bits"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// NTLMClient authenticates with NTLMv2 [https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-nlmp]
+// as the GSSAPIClient of SPNEGOMechanism, the fallback of Active Directory
+// when Kerberos isn't available. NTLM neither signs nor seals the
+// connection, it should be protected with TLS. The AUTHENTICATE_MESSAGE
+// carries a MIC if the server sends its time, SPNEGOMechanism then protects
+// the mechanism list with a mechListMIC.
+type NTLMClient struct {
+	// Username and Domain of the account, e.g. "alice" and "EXAMPLE", the
+	// domain of the server if Domain is empty
+	Username string
+	Domain   string
+	// Password, or its NT hash as returned by NTLMHash
+	Password string
+	Hash     []byte
+	// Workstation name sent to the server, optional
+	Workstation string
+}
+
+// NewNTLMClient returns a NTLMClient of username in domain authenticating
+// with password.
+func NewNTLMClient(domain, username, password string) *NTLMClient {
+	return &NTLMClient{Username: username, Domain: domain, Password: password}
+}
+
+// NewNTLMClientWithHash returns a NTLMClient of username in domain
+// authenticating with the NT hash of its password.
+func NewNTLMClientWithHash(domain, username string, hash []byte) *NTLMClient {
+	return &NTLMClient{Username: username, Domain: domain, Hash: hash}
+}
+
+// NTLMHash returns the NT hash of password, the MD4 of its UTF-16LE
+// encoding.
+func NTLMHash(password string) []byte {
+	return md4([]byte(encodeUTF16LE(password)))
+}
+
+// NewSecContext returns a new NTLM security context with the service
+// principal target, e.g. "ldap/dc.example.com", sent to the server with
+// the channel binding.
+func (c *NTLMClient) NewSecContext(target string, channelBinding []byte) (GSSAPIContext, error) {
+	hash := c.Hash
+	if hash == nil {
+		hash = NTLMHash(c.Password)
+	}
+	if len(hash) != 16 {
+		return nil, newError(ErrorInvalidArgument, "NTLM: invalid NT hash")
+	}
+	return &ntlmContext{client: c, hash: hash, target: target, channelBinding: channelBinding}, nil
+}
+
+// flags of NTLM messages
+const (
+	ntlmNegotiateUnicode                 = 0x00000001
+	ntlmRequestTarget                    = 0x00000004
+	ntlmNegotiateNTLM                    = 0x00000200
+	ntlmNegotiateAlwaysSign              = 0x00008000
+	ntlmNegotiateExtendedSessionSecurity = 0x00080000
+	ntlmNegotiateTargetInfo              = 0x00800000
+	ntlmNegotiateVersion                 = 0x02000000
+	ntlmNegotiate128                     = 0x20000000
+	ntlmNegotiate56                      = 0x80000000
+)
+
+// AV_PAIR IDs of the target info
+const (
+	ntlmAvEOL             = 0
+	ntlmAvFlags           = 6
+	ntlmAvTimestamp       = 7
+	ntlmAvTargetName      = 9
+	ntlmAvChannelBindings = 10
+)
+
+// ntlmFlags are the flags requested by the client, without signing and
+// sealing as those would require them for all LDAP messages after the bind.
+const ntlmFlags = ntlmNegotiateUnicode | ntlmRequestTarget | ntlmNegotiateNTLM | ntlmNegotiateAlwaysSign |
+	ntlmNegotiateExtendedSessionSecurity | ntlmNegotiateTargetInfo | ntlmNegotiateVersion | ntlmNegotiate128 | ntlmNegotiate56
+
+// ntlmAvFlagMIC is the flag of MsvAvFlags for an AUTHENTICATE_MESSAGE with
+// a MIC.
+const ntlmAvFlagMIC = 0x00000002
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// ntlmVersion is the VERSION of the messages, Windows 10 with NTLM revision 15.
+var ntlmVersion = []byte{10, 0, 0x61, 0x4a, 0, 0, 0, 15}
+
+// ntlmContext is the GSSAPIContext of a NTLMClient.
+type ntlmContext struct {
+	client         *NTLMClient
+	hash           []byte
+	target         string
+	channelBinding []byte
+	state          int
+
+	// negotiate is the NEGOTIATE_MESSAGE sent, covered by the MIC
+	negotiate []byte
+	// flags negotiated and the ExportedSessionKey, nil without a MIC
+	flags      uint32
+	sessionKey []byte
+	// sequence numbers of the signatures sent and received
+	sendSeq, receiveSeq uint32
+}
+
+func (n *ntlmContext) Step(input []byte) ([]byte, bool, error) {
+	switch n.state {
+	case 0:
+		n.state = 1
+		return n.negotiateMessage(), false, nil
+	case 1:
+		message, err := n.authenticateMessage(input)
+		if err != nil {
+			return nil, false, err
+		}
+		n.state = 2
+		return message, true, nil
+	}
+	return nil, true, newError(ErrorUnknown, "NTLM: security context already established")
+}
+
+// Wrap is not supported, NTLM is used without a security layer.
+func (n *ntlmContext) Wrap(message []byte, confidential bool) ([]byte, error) {
+	return nil, newError(ErrorUnknown, "NTLM: no security layer")
+}
+
+// Unwrap is not supported, NTLM is used without a security layer.
+func (n *ntlmContext) Unwrap(token []byte) ([]byte, error) {
+	return nil, newError(ErrorUnknown, "NTLM: no security layer")
+}
+
+// negotiateMessage returns the NEGOTIATE_MESSAGE without domain and
+// workstation.
+func (n *ntlmContext) negotiateMessage() []byte {
+	message := make([]byte, 32, 40)
+	copy(message, ntlmSignature)
+	binary.LittleEndian.PutUint32(message[8:], 1)
+	binary.LittleEndian.PutUint32(message[12:], ntlmFlags)
+	n.negotiate = append(message, ntlmVersion...)
+	return n.negotiate
+}
+
+// authenticateMessage returns the AUTHENTICATE_MESSAGE with the NTLMv2
+// response to the CHALLENGE_MESSAGE of the server.
+func (n *ntlmContext) authenticateMessage(challenge []byte) ([]byte, error) {
+	if len(challenge) < 48 || !bytes.Equal(challenge[:8], ntlmSignature) || binary.LittleEndian.Uint32(challenge[8:]) != 2 {
+		return nil, newError(ErrorDecoding, "NTLM: invalid CHALLENGE_MESSAGE")
+	}
+	targetName, ok := ntlmField(challenge, 12)
+	if !ok {
+		return nil, newError(ErrorDecoding, "NTLM: invalid CHALLENGE_MESSAGE")
+	}
+	flags := binary.LittleEndian.Uint32(challenge[20:])
+	serverChallenge := challenge[24:32]
+	targetInfo, ok := ntlmField(challenge, 40)
+	if !ok || flags&ntlmNegotiateTargetInfo == 0 {
+		return nil, newError(ErrorDecoding, "NTLM: the server sent no target info, NTLMv2 is required")
+	}
+	if flags&ntlmNegotiateUnicode == 0 {
+		return nil, newError(ErrorUnknown, "NTLM: the server doesn't support Unicode")
+	}
+	pairs, err := parseNTLMTargetInfo(targetInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	domain := n.client.Domain
+	if domain == "" {
+		domain = decodeUTF16LE(targetName)
+	}
+	clientChallenge := make([]byte, 8)
+	if _, err := rand.Read(clientChallenge); err != nil {
+		return nil, err
+	}
+	var timestamp []byte
+	for _, pair := range pairs {
+		if pair.id == ntlmAvTimestamp {
+			timestamp = pair.value
+		}
+	}
+	serverTime := timestamp != nil
+	if !serverTime {
+		timestamp = make([]byte, 8)
+		binary.LittleEndian.PutUint64(timestamp, ntlmFileTime(time.Now()))
+	}
+
+	// the target info of the server with the service principal and the
+	// channel binding, which servers enforcing the extended protection
+	// require over TLS, and the MIC flag if the server sent its time
+	var info []byte
+	avFlags := uint32(0)
+	for _, pair := range pairs {
+		switch {
+		case pair.id == ntlmAvFlags && len(pair.value) == 4:
+			avFlags = binary.LittleEndian.Uint32(pair.value)
+		case pair.id != ntlmAvTargetName && pair.id != ntlmAvChannelBindings && pair.id != ntlmAvFlags:
+			info = appendNTLMAvPair(info, pair.id, pair.value)
+		}
+	}
+	if serverTime {
+		avFlags |= ntlmAvFlagMIC
+	}
+	if avFlags != 0 {
+		info = appendNTLMAvPair(info, ntlmAvFlags, binary.LittleEndian.AppendUint32(nil, avFlags))
+	}
+	info = appendNTLMAvPair(info, ntlmAvTargetName, []byte(encodeUTF16LE(n.target)))
+	bindings := make([]byte, 16)
+	if n.channelBinding != nil {
+		bindings = gssChannelBindingsHash(n.channelBinding)
+	}
+	info = appendNTLMAvPair(info, ntlmAvChannelBindings, bindings)
+	info = appendNTLMAvPair(info, ntlmAvEOL, nil)
+
+	ntowf := ntowfv2(n.hash, n.client.Username, domain)
+	ntResponse := ntlmv2Response(ntowf, serverChallenge, clientChallenge, timestamp, info)
+	lmResponse := make([]byte, 24)
+	if !serverTime {
+		// LMv2 is only sent without the time of the server
+		lmResponse = append(ntlmHMAC(ntowf, serverChallenge, clientChallenge), clientChallenge...)
+	}
+
+	fields := [][]byte{
+		lmResponse,
+		ntResponse,
+		[]byte(encodeUTF16LE(domain)),
+		[]byte(encodeUTF16LE(n.client.Username)),
+		[]byte(encodeUTF16LE(n.client.Workstation)),
+		nil, // no session key exchange
+	}
+	// the MIC follows the version in the header
+	header := 72
+	if serverTime {
+		header += 16
+	}
+	message := make([]byte, header)
+	copy(message, ntlmSignature)
+	binary.LittleEndian.PutUint32(message[8:], 3)
+	binary.LittleEndian.PutUint32(message[60:], flags&ntlmFlags)
+	copy(message[64:], ntlmVersion)
+	for i, field := range fields {
+		binary.LittleEndian.PutUint16(message[12+8*i:], uint16(len(field)))
+		binary.LittleEndian.PutUint16(message[14+8*i:], uint16(len(field)))
+		binary.LittleEndian.PutUint32(message[16+8*i:], uint32(len(message)))
+		message = append(message, field...)
+	}
+	if serverTime {
+		// without key exchange the ExportedSessionKey is the
+		// SessionBaseKey, the MIC covers the messages with a zero MIC
+		n.flags = flags & ntlmFlags
+		n.sessionKey = ntlmHMAC(ntowf, ntResponse[:16])
+		copy(message[72:], ntlmHMAC(n.sessionKey, n.negotiate, challenge, message))
+	}
+	return message, nil
+}
+
+// getMIC returns the signature of message, the mechListMIC of SPNEGO, and
+// false if the context has no session key for it.
+func (n *ntlmContext) getMIC(message []byte) ([]byte, bool) {
+	if n.sessionKey == nil || n.flags&ntlmNegotiateExtendedSessionSecurity == 0 {
+		return nil, false
+	}
+	signature := ntlmSign(n.signingKey("client-to-server"), n.sendSeq, message)
+	n.sendSeq++
+	return signature, true
+}
+
+// verifyMIC verifies the signature mic of message sent by the server.
+func (n *ntlmContext) verifyMIC(message, mic []byte) error {
+	if n.sessionKey == nil || n.flags&ntlmNegotiateExtendedSessionSecurity == 0 {
+		return newError(ErrorUnknown, "NTLM: no session key to verify the MIC")
+	}
+	if !hmac.Equal(ntlmSign(n.signingKey("server-to-client"), n.receiveSeq, message), mic) {
+		return newError(ErrorUnknown, "NTLM: invalid MIC")
+	}
+	n.receiveSeq++
+	return nil
+}
+
+// signingKey returns the signing key of the direction, "client-to-server"
+// or "server-to-client", for extended session security.
+func (n *ntlmContext) signingKey(direction string) []byte {
+	sum := md5.Sum(append(append([]byte(nil), n.sessionKey...), "session key to "+direction+" signing key magic constant\x00"...))
+	return sum[:]
+}
+
+// ntlmSign returns the NTLMSSP_MESSAGE_SIGNATURE of message with extended
+// session security and without key exchange.
+func ntlmSign(key []byte, seq uint32, message []byte) []byte {
+	seqNum := binary.LittleEndian.AppendUint32(nil, seq)
+	signature := binary.LittleEndian.AppendUint32(nil, 1)
+	signature = append(signature, ntlmHMAC(key, seqNum, message)[:8]...)
+	return append(signature, seqNum...)
+}
+
+// ntowfv2 returns the NTOWFv2 key of the NT hash for user in domain.
+func ntowfv2(hash []byte, user, domain string) []byte {
+	return ntlmHMAC(hash, []byte(encodeUTF16LE(strings.ToUpper(user)+domain)))
+}
+
+// ntlmv2Response returns the NTProofStr followed by the NTLMv2_CLIENT_CHALLENGE
+// of the target info.
+func ntlmv2Response(ntowf, serverChallenge, clientChallenge, timestamp, targetInfo []byte) []byte {
+	temp := []byte{1, 1, 0, 0, 0, 0, 0, 0}
+	temp = append(temp, timestamp...)
+	temp = append(temp, clientChallenge...)
+	temp = append(temp, 0, 0, 0, 0)
+	temp = append(temp, targetInfo...)
+	temp = append(temp, 0, 0, 0, 0)
+	return append(ntlmHMAC(ntowf, serverChallenge, temp), temp...)
+}
+
+func ntlmHMAC(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(md5.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+// ntlmFileTime returns t as FILETIME, 100 nanoseconds since 1601.
+func ntlmFileTime(t time.Time) uint64 {
+	return uint64(t.UnixNano()/100 + 116444736000000000)
+}
+
+// ntlmField returns the payload of the field with the length and offset at
+// start in message.
+func ntlmField(message []byte, start int) ([]byte, bool) {
+	length := int(binary.LittleEndian.Uint16(message[start:]))
+	offset := int(binary.LittleEndian.Uint32(message[start+4:]))
+	if offset > len(message) || length > len(message)-offset {
+		return nil, false
+	}
+	return message[offset : offset+length], true
+}
+
+// ntlmAvPair is an AV_PAIR of a target info.
+type ntlmAvPair struct {
+	id    uint16
+	value []byte
+}
+
+// parseNTLMTargetInfo returns the AV_PAIRs of a target info without the EOL.
+func parseNTLMTargetInfo(data []byte) ([]ntlmAvPair, error) {
+	var pairs []ntlmAvPair
+	for {
+		if len(data) < 4 {
+			return nil, newError(ErrorDecoding, "NTLM: invalid target info")
+		}
+		id, length := binary.LittleEndian.Uint16(data), int(binary.LittleEndian.Uint16(data[2:]))
+		if len(data) < 4+length {
+			return nil, newError(ErrorDecoding, "NTLM: invalid target info")
+		}
+		if id == ntlmAvEOL {
+			return pairs, nil
+		}
+		pairs = append(pairs, ntlmAvPair{id, data[4 : 4+length]})
+		data = data[4+length:]
+	}
+}
+
+func appendNTLMAvPair(info []byte, id uint16, value []byte) []byte {
+	info = binary.LittleEndian.AppendUint16(info, id)
+	info = binary.LittleEndian.AppendUint16(info, uint16(len(value)))
+	return append(info, value...)
+}
+
+// decodeUTF16LE decodes the UTF-16LE s.
+func decodeUTF16LE(s []byte) string {
+	encoded := make([]uint16, len(s)/2)
+	for i := range encoded {
+		encoded[i] = binary.LittleEndian.Uint16(s[2*i:])
+	}
+	return string(utf16.Decode(encoded))
+}
+
+// md4 returns the MD4 [https://tools.ietf.org/html/rfc1320] of data, only
+// used for the NT hash.
+func md4(data []byte) []byte {
+	message := append([]byte(nil), data...)
+	message = append(message, 0x80)
+	for len(message)%64 != 56 {
+		message = append(message, 0)
+	}
+	message = binary.LittleEndian.AppendUint64(message, uint64(len(data))*8)
+
+	state := [4]uint32{0x67452301, 0xefcdab89, 0x98badcfe, 0x10325476}
+	var x [16]uint32
+	for ; len(message) > 0; message = message[64:] {
+		for i := range x {
+			x[i] = binary.LittleEndian.Uint32(message[4*i:])
+		}
+		a, b, c, d := state[0], state[1], state[2], state[3]
+		// each step updates a and rotates the roles of the words
+		for i := 0; i < 16; i++ {
+			a = bits.RotateLeft32(a+(b&c|^b&d)+x[i], []int{3, 7, 11, 19}[i%4])
+			a, b, c, d = d, a, b, c
+		}
+		for i := 0; i < 16; i++ {
+			k := i/4 + i%4*4
+			a = bits.RotateLeft32(a+(b&c|b&d|c&d)+x[k]+0x5a827999, []int{3, 5, 9, 13}[i%4])
+			a, b, c, d = d, a, b, c
+		}
+		for i, k := range []int{0, 8, 4, 12, 2, 10, 6, 14, 1, 9, 5, 13, 3, 11, 7, 15} {
+			a = bits.RotateLeft32(a+(b^c^d)+x[k]+0x6ed9eba1, []int{3, 9, 11, 15}[i%4])
+			a, b, c, d = d, a, b, c
+		}
+		state[0] += a
+		state[1] += b
+		state[2] += c
+		state[3] += d
+	}
+	sum := make([]byte, 0, 16)
+	for _, word := range state {
+		sum = binary.LittleEndian.AppendUint32(sum, word)
+	}
+	return sum
+}
@@ -0,0 +1,60 @@
+package ldap
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestNTLMv2(t *testing.T) {
+	for input, expected := range map[string]string{
+		"":                           "31d6cfe0d16ae931b73c59d7e0c089c0",
+		"abc":                        "a448017aaf21d8525fc10ae87aa6729d",
+		"abcdefghijklmnopqrstuvwxyz": "d79e1c308aa5bbcdeea8ed63df412da9",
+		"12345678901234567890123456789012345678901234567890123456789012345678901234567890": "e33b4ddc9c38f2199c3e7b164fcc0536",
+	} {
+		if sum := hex.EncodeToString(md4([]byte(input))); sum != expected {
+			t.Errorf("MD4 of %q: expected %s, got %s", input, expected, sum)
+		}
+	}
+
+	// MS-NLMP section 4.2.4
+	hash := NTLMHash("Password")
+	if hex.EncodeToString(hash) != "a4f49c406510bdcab6824ee7c30fd852" {
+		t.Errorf("Unexpected NT hash %x", hash)
+	}
+	ntowf := ntowfv2(hash, "User", "Domain")
+	if hex.EncodeToString(ntowf) != "0c868a403bfd7a93a3001ef22ef02e3f" {
+		t.Errorf("Unexpected NTOWFv2 %x", ntowf)
+	}
+	serverChallenge, _ := hex.DecodeString("0123456789abcdef")
+	clientChallenge := bytes.Repeat([]byte{0xaa}, 8)
+	targetInfo, _ := hex.DecodeString("02000c0044006f006d00610069006e0001000c0053006500720076006500720000000000")
+	response := ntlmv2Response(ntowf, serverChallenge, clientChallenge, make([]byte, 8), targetInfo)
+	if hex.EncodeToString(response[:16]) != "68cd0ab851e51c96aabc927bebef6a1c" {
+		t.Errorf("Unexpected NTProofStr %x", response[:16])
+	}
+	if key := ntlmHMAC(ntowf, response[:16]); hex.EncodeToString(key) != "8de40ccadbc14a82f15cb0ad0de95ca3" {
+		t.Errorf("Unexpected session base key %x", key)
+	}
+	if lm := ntlmHMAC(ntowf, serverChallenge, clientChallenge); hex.EncodeToString(lm) != "86c35097ac9cec102554764a57cccc19" {
+		t.Errorf("Unexpected LMv2 response %x", lm)
+	}
+
+	// signatures verify only with the key of their direction
+	client := &ntlmContext{flags: ntlmFlags, sessionKey: ntlmHMAC(ntowf, response[:16])}
+	mic, ok := client.getMIC([]byte("message"))
+	if !ok || len(mic) != 16 {
+		t.Fatalf("Unexpected MIC %x", mic)
+	}
+	if err := client.verifyMIC([]byte("message"), mic); err == nil {
+		t.Error("Expected the MIC of the client to be rejected as the one of the server")
+	}
+	server := ntlmSign(client.signingKey("server-to-client"), 0, []byte("message"))
+	if err := client.verifyMIC([]byte("message"), server); err != nil {
+		t.Error(err)
+	}
+	if _, ok := (&ntlmContext{sessionKey: client.sessionKey}).getMIC(nil); ok {
+		t.Error("Expected no MIC without extended session security")
+	}
+}
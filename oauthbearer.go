@@ -0,0 +1,113 @@
+package ldap
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+)
+
+// OAuthBearerMechanism is the SASL OAUTHBEARER mechanism
+// [https://tools.ietf.org/html/rfc7628] binding with an OAuth 2.0 bearer
+// token. The token is sent as is, the connection should be protected with
+// TLS.
+type OAuthBearerMechanism struct {
+	// Token, or TokenFunc returning a fresh token for every bind, e.g. for a
+	// rebind after a reconnect with the first token expired
+	Token     string
+	TokenFunc func() (string, error)
+	// AuthzID to authorize as, empty for the identity of the token
+	AuthzID string
+	// Host and Port sent to the server, empty for those of the connection
+	Host string
+	Port int
+
+	// ServerError of the last bind refused by the server
+	ServerError *OAuthBearerError
+}
+
+// OAuthBearerError is the error of the server refusing a token, with the
+// scope required and the OpenID Connect discovery document of the issuer
+// of the tokens it accepts.
+type OAuthBearerError struct {
+	Status              string `json:"status"`
+	Scope               string `json:"scope,omitempty"`
+	OpenIDConfiguration string `json:"openid-configuration,omitempty"`
+}
+
+func (e *OAuthBearerError) Error() string {
+	text := "OAUTHBEARER: the server refused the token: " + e.Status
+	if e.Scope != "" {
+		text += ", scope " + e.Scope
+	}
+	return text
+}
+
+// OAuthBearerBind binds with OAUTHBEARER and the bearer token. The *Error
+// of a refused token wraps the OAuthBearerError of the server, see
+// errors.As.
+func (l *Connection) OAuthBearerBind(token string) (*LDAPResult, error) {
+	return l.OAuthBearerBindContext(context.Background(), token)
+}
+
+// OAuthBearerBindContext is OAuthBearerBind with ctx.
+func (l *Connection) OAuthBearerBindContext(ctx context.Context, token string) (*LDAPResult, error) {
+	mechanism := &OAuthBearerMechanism{Token: token}
+	result, err := l.SASLBindContext(ctx, mechanism)
+	if lerr, ok := err.(*Error); ok && lerr.err == nil && mechanism.ServerError != nil {
+		lerr.err = mechanism.ServerError
+	}
+	return result, err
+}
+
+func (m *OAuthBearerMechanism) Name() string {
+	return "OAUTHBEARER"
+}
+
+// Cleartext is true, the token is sent as is.
+func (m *OAuthBearerMechanism) Cleartext() bool {
+	return true
+}
+
+func (m *OAuthBearerMechanism) Start(server *SASLServerInfo) ([]byte, error) {
+	m.ServerError = nil
+	token := m.Token
+	if m.TokenFunc != nil {
+		var err error
+		if token, err = m.TokenFunc(); err != nil {
+			return nil, err
+		}
+	}
+	if token == "" {
+		return nil, newError(ErrorInvalidArgument, "OAUTHBEARER: no token")
+	}
+	host, port := m.Host, m.Port
+	if host == "" {
+		host = server.Host
+	}
+	if port == 0 {
+		port = server.Port
+	}
+
+	// the GS2 header and the key/value pairs separated by 0x01
+	response := "n,"
+	if m.AuthzID != "" {
+		response += "a=" + gs2Name(m.AuthzID)
+	}
+	response += ",\x01host=" + host + "\x01"
+	if port != 0 {
+		response += "port=" + strconv.Itoa(port) + "\x01"
+	}
+	return []byte(response + "auth=Bearer " + token + "\x01\x01"), nil
+}
+
+func (m *OAuthBearerMechanism) Next(challenge []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	// the error of the server, acknowledged with 0x01 to fail the bind
+	m.ServerError = &OAuthBearerError{}
+	if err := json.Unmarshal(challenge, m.ServerError); err != nil || m.ServerError.Status == "" {
+		m.ServerError.Status = "unknown error"
+	}
+	return []byte{0x01}, nil
+}
@@ -0,0 +1,63 @@
+package ldap
+
+import (
+	"errors"
+	"github.com/eaciit/asn1-ber"
+	"testing"
+)
+
+// mockOAuthBearerServer accepts the token "valid" and refuses others with
+// the error of RFC 7628.
+func mockOAuthBearerServer(t *testing.T) *Connection {
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		messageID := mockMessageID(request)
+		sasl := request.Children[1].Children[2]
+		if packetString(sasl.Children[0]) != "OAUTHBEARER" || len(sasl.Children) != 2 {
+			s.respondResult(messageID, ApplicationBindResponse, ResultAuthMethodNotSupported, "")
+			return
+		}
+		switch response := packetString(sasl.Children[1]); response {
+		case "n,a=dn:cn=3Dadmin=2Co=3Dexample,\x01host=ldap.example.com\x01port=636\x01auth=Bearer valid\x01\x01":
+			s.respondResult(messageID, ApplicationBindResponse, ResultSuccess, "")
+		case "\x01":
+			s.respondResult(messageID, ApplicationBindResponse, ResultInvalidCredentials, "")
+		default:
+			s.respond(messageID, mockSASLBindResponse(ResultSaslBindInProgress, `{"status":"invalid_token","scope":"ldap"}`))
+		}
+	})
+	return l
+}
+
+func TestOAuthBearerBind(t *testing.T) {
+	l := mockOAuthBearerServer(t)
+	defer l.Close()
+
+	tokens := 0
+	mechanism := &OAuthBearerMechanism{
+		TokenFunc: func() (string, error) {
+			tokens++
+			return "valid", nil
+		},
+		AuthzID: "dn:cn=admin,o=example",
+		Host:    "ldap.example.com",
+		Port:    636,
+	}
+	if _, err := l.SASLBind(mechanism); err != nil {
+		t.Fatal(err)
+	}
+	if tokens != 1 {
+		t.Errorf("Expected one token, got %d", tokens)
+	}
+
+	result, err := l.OAuthBearerBind("expired")
+	if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ResultInvalidCredentials {
+		t.Errorf("Expected the error of the server, got %v", err)
+	}
+	var serverError *OAuthBearerError
+	if !errors.As(err, &serverError) || serverError.Status != "invalid_token" || serverError.Scope != "ldap" {
+		t.Errorf("Unexpected error %v", err)
+	}
+	if result == nil || result.ResultCode != ResultInvalidCredentials {
+		t.Errorf("Unexpected result %v", result)
+	}
+}
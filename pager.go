@@ -0,0 +1,215 @@
+package ldap
+
+import (
+	"fmt"
+)
+
+// Pager pages through the result of a search sorted by the server, e.g. for
+// listing a large container page by page. It uses the VirtualListView and
+// ServerSideSort controls and falls back to simple paged results if the server
+// doesn't support them, then pages can only be read in order: Prev and Seek
+// search again from the first page and the entries are only sorted if the
+// server supports ServerSideSort without VirtualListView. A sortResult of the
+// server other than success is returned as the error of the page.
+type Pager struct {
+	conn     *Connection
+	request  *SearchRequest
+	sortKeys []ServerSideSortAttrRuleOrder
+	pageSize int
+
+	vlv          bool
+	contextID    string
+	offset       int
+	contentCount int
+
+	paging *ControlPaging
+	page   int
+	done   bool
+}
+
+// NewPager returns a Pager over the result of searchRequest sorted by sortKeys
+// with pageSize entries per page. No search is sent before the first Next or
+// Seek.
+func (l *Connection) NewPager(searchRequest *SearchRequest, sortKeys []ServerSideSortAttrRuleOrder, pageSize int) *Pager {
+	if pageSize < 1 {
+		pageSize = 1
+	}
+	return &Pager{
+		conn:     l,
+		request:  searchRequest,
+		sortKeys: sortKeys,
+		pageSize: pageSize,
+		vlv:      true,
+	}
+}
+
+// Offset returns the position of the first entry of the current page,
+// starting at 1, 0 before the first page was read.
+func (p *Pager) Offset() int {
+	return p.offset
+}
+
+// ContentCount returns the number of entries of the result as estimated by
+// the server, 0 if unknown.
+func (p *Pager) ContentCount() int {
+	return p.contentCount
+}
+
+// VLV reports whether the pager uses the VirtualListView control, false after
+// it fell back to simple paged results.
+func (p *Pager) VLV() bool {
+	return p.vlv
+}
+
+// Next returns the page after the current one, the first page on the first
+// call. No entries are returned after the last page.
+func (p *Pager) Next() ([]*Entry, error) {
+	if p.offset == 0 {
+		return p.Seek(1)
+	}
+	offset := p.offset + p.pageSize
+	if (p.vlv && p.contentCount > 0 && offset > p.contentCount) || (!p.vlv && p.done) {
+		return []*Entry{}, nil
+	}
+	return p.Seek(offset)
+}
+
+// Prev returns the page before the current one, the first page if the
+// current page is the first one.
+func (p *Pager) Prev() ([]*Entry, error) {
+	offset := p.offset - p.pageSize
+	if offset < 1 {
+		offset = 1
+	}
+	return p.Seek(offset)
+}
+
+// Seek returns the page starting at the entry at offset, starting at 1. With
+// simple paged results offset is rounded down to the start of its page.
+func (p *Pager) Seek(offset int) ([]*Entry, error) {
+	if offset < 1 {
+		offset = 1
+	}
+	if p.vlv {
+		entries, err := p.seekVlv(offset)
+		if !isVlvUnsupported(err) {
+			return entries, err
+		}
+		if p.conn.Debug {
+			fmt.Println("VirtualListView unsupported, falling back to paged results.")
+		}
+		p.vlv = false
+		p.contextID = ""
+		p.contentCount = 0
+	}
+	return p.seekPaging(offset)
+}
+
+func (p *Pager) seekVlv(offset int) ([]*Entry, error) {
+	vlv := NewControlVlvRequestByOffset(0, int32(p.pageSize-1), int32(offset), int32(p.contentCount))
+	vlv.ContextID = []byte(p.contextID)
+	request := p.request.withControl(NewControlServerSideSortRequest(p.sortKeys, true)).withControl(vlv)
+
+	result, err := p.conn.Search(request)
+	if err != nil {
+		return nil, err
+	}
+	_, control := FindControl(result.Controls, ControlTypeVlvResponse)
+	response, ok := control.(*ControlVlvResponse)
+	if !ok {
+		return nil, newError(ErrorMissingControl, "Expected VLV Control, it was not found.")
+	}
+	if response.Err != nil {
+		return nil, response.Err
+	}
+	if err := sortError(result); err != nil {
+		return nil, err
+	}
+	p.contextID = response.ContextID
+	p.contentCount = int(response.ContentCount)
+	p.offset = offset
+	if response.TargetPosition > 0 {
+		p.offset = int(response.TargetPosition)
+	}
+	return result.Entries, nil
+}
+
+// sortError returns the error of the ServerSideSortResponse of result, nil if
+// the entries are sorted or the server sent none.
+func sortError(result *SearchResult) error {
+	_, control := FindControl(result.Controls, ControlTypeServerSideSortResponse)
+	if response, ok := control.(*ControlServerSideSortResponse); ok {
+		return response.Err
+	}
+	return nil
+}
+
+// isVlvUnsupported reports whether err shows that the server doesn't support
+// VirtualListView with ServerSideSort.
+func isVlvUnsupported(err error) bool {
+	lerr, ok := err.(*Error)
+	if !ok {
+		return false
+	}
+	switch lerr.ResultCode {
+	case ResultUnavailableCriticalExtension, ErrorMissingControl:
+		return true
+	}
+	return false
+}
+
+func (p *Pager) seekPaging(offset int) ([]*Entry, error) {
+	page := (offset - 1) / p.pageSize
+	if p.paging == nil || page <= p.page {
+		// searching from the first page again
+		p.paging = NewControlPaging(uint32(p.pageSize))
+		p.page = -1
+		p.done = false
+	}
+
+	entries := []*Entry{}
+	for p.page < page {
+		if p.done {
+			return []*Entry{}, nil
+		}
+		var err error
+		entries, err = p.nextPage()
+		if err != nil {
+			return nil, err
+		}
+	}
+	p.offset = page*p.pageSize + 1
+	return entries, nil
+}
+
+func (p *Pager) nextPage() ([]*Entry, error) {
+	request := p.request.withControl(NewControlServerSideSortRequest(p.sortKeys, false)).withControl(p.paging)
+	result, err := p.conn.Search(request)
+	if err != nil {
+		return nil, err
+	}
+	if err := sortError(result); err != nil {
+		return nil, err
+	}
+	p.page++
+
+	_, control := FindControl(result.Controls, ControlTypePaging)
+	response, ok := control.(*ControlPaging)
+	if !ok && p.page == 0 {
+		// paging unsupported, the server returned the whole result
+		p.done = true
+		p.contentCount = len(result.Entries)
+		return result.Entries, nil
+	} else if !ok {
+		return nil, newError(ErrorMissingControl, "Expected paging Control, it was not found.")
+	}
+	p.paging.SetCookie(response.Cookie)
+	if response.PagingSize > 0 {
+		p.contentCount = int(response.PagingSize)
+	}
+	if len(response.Cookie) == 0 {
+		p.done = true
+		p.contentCount = p.page*p.pageSize + len(result.Entries)
+	}
+	return result.Entries, nil
+}
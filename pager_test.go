@@ -0,0 +1,131 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"strconv"
+	"testing"
+)
+
+const mockPagerEntries = 25
+
+func mockVlvResponse(targetPosition, contentCount int) *ber.Packet {
+	value := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "VirtualListViewResponse")
+	value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, targetPosition, "targetPosition"))
+	value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, contentCount, "contentCount"))
+	value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, 0, "virtualListViewResult"))
+	value.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "ctx", "contextID"))
+	return mockControl(ControlTypeVlvResponse, value)
+}
+
+// mockPagerEntry returns the entry at offset, starting at 1.
+func mockPagerEntry(offset int) *ber.Packet {
+	return mockSearchEntry("cn=user"+strconv.Itoa(offset)+",o=bigcorp", nil)
+}
+
+func TestPagerVlv(t *testing.T) {
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		messageID := mockMessageID(request)
+		vlv, ok := mockRequestControl(t, request, ControlTypeVlvRequest).(*RawControl)
+		if !ok || mockRequestControl(t, request, ControlTypeServerSideSortRequest) == nil {
+			s.respondResult(messageID, ApplicationSearchResultDone, ResultSortControlMissing, "")
+			return
+		}
+		value := ber.DecodePacket(vlv.Value)
+		afterCount, _ := packetInt64(value.Children[1])
+		offset, _ := packetInt64(value.Children[2].Children[0])
+		for i := int(offset); i <= int(offset+afterCount) && i <= mockPagerEntries; i++ {
+			s.respond(messageID, mockPagerEntry(i))
+		}
+		s.respond(messageID, mockLDAPResult(ApplicationSearchResultDone, ResultSuccess, ""), mockVlvResponse(int(offset), mockPagerEntries))
+	})
+	defer l.Close()
+
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", []string{"cn"})
+	pager := l.NewPager(searchRequest, []ServerSideSortAttrRuleOrder{{AttributeName: "cn"}}, 10)
+
+	expectPage(t, pager, pager.Next, 1, 10)
+	if !pager.VLV() || pager.ContentCount() != mockPagerEntries {
+		t.Errorf("Unexpected pager state, VLV: %t, ContentCount: %d", pager.VLV(), pager.ContentCount())
+	}
+	expectPage(t, pager, pager.Next, 11, 10)
+	expectPage(t, pager, pager.Next, 21, 5)
+	if entries, err := pager.Next(); err != nil || len(entries) != 0 {
+		t.Errorf("Expected no entries after the last page, got %d, %v", len(entries), err)
+	}
+	expectPage(t, pager, pager.Prev, 11, 10)
+	expectPage(t, pager, func() ([]*Entry, error) { return pager.Seek(5) }, 5, 10)
+}
+
+func TestPagerFallsBackToPaging(t *testing.T) {
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		messageID := mockMessageID(request)
+		if mockRequestControl(t, request, ControlTypeVlvRequest) != nil {
+			s.respondResult(messageID, ApplicationSearchResultDone, ResultUnavailableCriticalExtension, "")
+			return
+		}
+		paging := mockRequestControl(t, request, ControlTypePaging).(*ControlPaging)
+		offset := 1
+		if len(paging.Cookie) > 0 {
+			offset, _ = strconv.Atoi(string(paging.Cookie))
+		}
+		next := offset + int(paging.PagingSize)
+		for i := offset; i < next && i <= mockPagerEntries; i++ {
+			s.respond(messageID, mockPagerEntry(i))
+		}
+		response := NewControlPaging(0)
+		if next <= mockPagerEntries {
+			response.SetCookie([]byte(strconv.Itoa(next)))
+		}
+		control, _ := response.Encode()
+		s.respond(messageID, mockLDAPResult(ApplicationSearchResultDone, ResultSuccess, ""), control)
+	})
+	defer l.Close()
+
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", []string{"cn"})
+	pager := l.NewPager(searchRequest, []ServerSideSortAttrRuleOrder{{AttributeName: "cn"}}, 10)
+
+	expectPage(t, pager, pager.Next, 1, 10)
+	if pager.VLV() {
+		t.Error("Pager didn't fall back to paged results")
+	}
+	expectPage(t, pager, pager.Next, 11, 10)
+	expectPage(t, pager, pager.Prev, 1, 10)
+	expectPage(t, pager, func() ([]*Entry, error) { return pager.Seek(25) }, 21, 5)
+	if pager.ContentCount() != mockPagerEntries {
+		t.Errorf("Unexpected ContentCount %d", pager.ContentCount())
+	}
+	if entries, err := pager.Next(); err != nil || len(entries) != 0 {
+		t.Errorf("Expected no entries after the last page, got %d, %v", len(entries), err)
+	}
+}
+
+func TestPagerSortFailure(t *testing.T) {
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		value := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "SortResult")
+		value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(ResultNoSuchAttribute), "sortResult"))
+		value.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, "cn", "attributeType"))
+		sort := mockControl(ControlTypeServerSideSortResponse, value)
+		s.respond(mockMessageID(request), mockLDAPResult(ApplicationSearchResultDone, ResultSuccess, ""), mockVlvResponse(1, mockPagerEntries), sort)
+	})
+	defer l.Close()
+
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", []string{"cn"})
+	pager := l.NewPager(searchRequest, []ServerSideSortAttrRuleOrder{{AttributeName: "cn"}}, 10)
+	_, err := pager.Next()
+	if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ResultNoSuchAttribute {
+		t.Errorf("Expected the sortResult noSuchAttribute, got %v", err)
+	}
+}
+
+func expectPage(t *testing.T, pager *Pager, read func() ([]*Entry, error), offset, count int) {
+	entries, err := read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pager.Offset() != offset || len(entries) != count {
+		t.Fatalf("Expected %d entries at offset %d, got %d at %d", count, offset, len(entries), pager.Offset())
+	}
+	if entries[0].DN != "cn=user"+strconv.Itoa(offset)+",o=bigcorp" {
+		t.Errorf("Unexpected first entry %s at offset %d", entries[0].DN, offset)
+	}
+}
@@ -4,20 +4,55 @@ import (
 	"github.com/eaciit/asn1-ber"
 )
 
+const ExtendedOperationPasswordModify = "1.3.6.1.4.1.4203.1.11.1"
+
+/*
+PasswdModifyRequestValue ::= SEQUENCE {
+     userIdentity    [0]  OCTET STRING OPTIONAL
+     oldPasswd       [1]  OCTET STRING OPTIONAL
+     newPasswd       [2]  OCTET STRING OPTIONAL }
+
+PasswdModifyResponseValue ::= SEQUENCE {
+     genPasswd       [0]     OCTET STRING OPTIONAL }
+*/
+
 // PasswordModifyRequest implements the payload and encoding specified in
 // https://tools.ietf.org/html/rfc3062
 type PasswordModifyRequest struct {
+	// Identity of the user whose password is changed, empty for the bound user
 	UserIdentity string
 	OldPasswd    string
-	NewPasswd    string
+	// New password, when empty the server generates one
+	NewPasswd string
+
+	// Server controls
+	Controls []Control
 }
 
-// Encode the PasswordModifyRequest into a ber.Packet
-func (r *PasswordModifyRequest) Encode() (*ber.Packet, error) {
-	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(ApplicationExtendedRequest), nil, "PasswordModifyRequest")
-	p.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, "1.3.6.1.4.1.4203.1.11.1", "Password Modify Request"))
+// PasswordModifyResult is returned by PasswordModify, GeneratedPassword is
+// set if the server generated the new password.
+type PasswordModifyResult struct {
+	ExtendedResponse
+	GeneratedPassword string
+}
+
+func NewPasswordModifyRequest(userIdentity, oldPasswd, newPasswd string) *PasswordModifyRequest {
+	return &PasswordModifyRequest{
+		UserIdentity: userIdentity,
+		OldPasswd:    oldPasswd,
+		NewPasswd:    newPasswd,
+		Controls:     make([]Control, 0),
+	}
+}
+
+func (r *PasswordModifyRequest) AddControl(control Control) {
+	if r.Controls == nil {
+		r.Controls = make([]Control, 0)
+	}
+	r.Controls = append(r.Controls, control)
+}
 
-	octetString := ber.Encode(ber.ClassContext, ber.TypePrimitive, 1, nil, "Octet String")
+func (r *PasswordModifyRequest) extendedRequest() *ExtendedRequest {
 	value := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "PasswordModifyRequestValue")
 
 	if r.UserIdentity != "" {
@@ -35,24 +70,45 @@ func (r *PasswordModifyRequest) Encode() (*ber.Packet, error) {
 		value.AppendChild(newPasswd)
 	}
 
-	octetString.AppendChild(value)
-	p.AppendChild(octetString)
+	return &ExtendedRequest{Name: ExtendedOperationPasswordModify, Value: value, Controls: r.Controls}
+}
 
-	return p, nil
+// Encode the PasswordModifyRequest into a ber.Packet
+func (r *PasswordModifyRequest) Encode() (*ber.Packet, error) {
+	return encodeExtendedRequest(r.extendedRequest()), nil
 }
 
-func (l *Connection) Passwd(req *PasswordModifyRequest) error {
-	messageID, ok := l.nextMessageID()
-	if !ok {
-		return newError(ErrorClosing, "MessageID channel is closed.")
+// PasswordModify changes the password and returns the password generated by
+// the server, if any.
+func (l *Connection) PasswordModify(req *PasswordModifyRequest) (*PasswordModifyResult, error) {
+	if req.OldPasswd != "" || req.NewPasswd != "" {
+		if err := l.credentialsAllowed(); err != nil {
+			return nil, err
+		}
+	}
+	response, err := l.Extended(req.extendedRequest())
+	if response == nil {
+		return nil, err
 	}
 
-	encodedReq, err := req.Encode()
-	if err != nil {
-		return err
+	result := &PasswordModifyResult{ExtendedResponse: *response}
+	if err != nil || len(response.Value) == 0 {
+		return result, err
 	}
 
-	packet, err := requestBuildPacket(messageID, encodedReq, nil)
+	value := ber.DecodePacket(response.Value)
+	if value == nil {
+		return result, newError(ErrorDecoding, "Couldn't decode PasswdModifyResponseValue.")
+	}
+	for _, child := range value.Children {
+		if child.ClassType == ber.ClassContext && child.Tag == 0 {
+			result.GeneratedPassword = packetString(child)
+		}
+	}
+	return result, nil
+}
 
-	return l.sendReqRespPacket(messageID, packet)
+func (l *Connection) Passwd(req *PasswordModifyRequest) error {
+	_, err := l.PasswordModify(req)
+	return err
 }
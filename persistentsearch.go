@@ -0,0 +1,116 @@
+package ldap
+
+import (
+	"context"
+	"sync"
+)
+
+// PersistentSearchEvent is an entry returned by a PersistentSearch or a
+// Notification. For the entries matching when the search started ChangeType
+// is 0.
+type PersistentSearchEvent struct {
+	// ChangeType* of the change
+	ChangeType int
+	Entry      *Entry
+	// DN of the entry before a ModifyDN
+	PreviousDN string
+	// -1 if not supported by the server
+	ChangeNumber int64
+}
+
+// PersistentSearch is a running persistent or notification search. Events has
+// to be read until it is closed, the search blocks the connection while an
+// event waits to be read.
+type PersistentSearch struct {
+	Events <-chan *PersistentSearchEvent
+
+	conn      *Connection
+	messageID int64
+	events    chan *PersistentSearchEvent
+	stop      chan struct{}
+	stopOnce  sync.Once
+	err       error
+}
+
+// PersistentSearch starts searchRequest with a PersistentSearch control and
+// returns the changes of the changeTypes (ChangeType* flags) of matching
+// entries as events until Stop is called. With changesOnly unset the entries
+// matching initially are returned first.
+func (l *Connection) PersistentSearch(searchRequest *SearchRequest, changeTypes int, changesOnly bool) (*PersistentSearch, error) {
+	return l.startPersistentSearch(searchRequest.withControl(NewControlPersistentSearchRequest(changeTypes, changesOnly, true)))
+}
+
+// Notification starts searchRequest with the Active Directory notification
+// control and returns the entries changed afterwards as events until Stop is
+// called. The events carry the whole entry but no ChangeType, deleted entries
+// are only returned together with NewControlShowDeletedRequest. Active
+// Directory only accepts the filter (objectClass=*) and a base or single level
+// scope.
+func (l *Connection) Notification(searchRequest *SearchRequest) (*PersistentSearch, error) {
+	return l.startPersistentSearch(searchRequest.withControl(NewControlNotificationRequest()))
+}
+
+func (l *Connection) startPersistentSearch(searchRequest *SearchRequest) (*PersistentSearch, error) {
+	messageID, release, err := l.nextRequestID(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	ps := &PersistentSearch{
+		conn:      l,
+		messageID: messageID,
+		events:    make(chan *PersistentSearchEvent),
+		stop:      make(chan struct{}),
+	}
+	ps.Events = ps.events
+
+	go func() {
+		defer close(ps.events)
+		defer release()
+		err := l.searchWithHandler(context.Background(), messageID, searchRequest, ps, nil)
+		select {
+		case <-ps.stop:
+			// abandoned by Stop
+		default:
+			ps.err = err
+		}
+	}()
+	return ps, nil
+}
+
+// ProcessDiscreteResult passes the entries of the search on as events.
+func (ps *PersistentSearch) ProcessDiscreteResult(dsr *DiscreteSearchResult, connInfo *ConnectionInfo) (bool, error) {
+	if dsr.SearchResultType != SearchResultEntry {
+		return false, nil
+	}
+	event := &PersistentSearchEvent{Entry: dsr.Entry, ChangeNumber: -1}
+	if _, control := FindControl(dsr.Controls, ControlTypeEntryChangeNotification); control != nil {
+		if notification, ok := control.(*ControlEntryChangeNotification); ok {
+			event.ChangeType = notification.ChangeType
+			event.PreviousDN = notification.PreviousDN
+			event.ChangeNumber = notification.ChangeNumber
+		}
+	}
+	select {
+	case ps.events <- event:
+		return false, nil
+	case <-ps.stop:
+		return true, nil
+	}
+}
+
+// Stop abandons the search, Events is closed afterwards.
+func (ps *PersistentSearch) Stop() error {
+	var err error
+	ps.stopOnce.Do(func() {
+		close(ps.stop)
+		err = ps.conn.Abandon(ps.messageID)
+	})
+	return err
+}
+
+// Err returns the error that ended the search once Events is closed, nil if
+// it was stopped.
+func (ps *PersistentSearch) Err() error {
+	return ps.err
+}
@@ -0,0 +1,96 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"testing"
+	"time"
+)
+
+func mockEntryChangeNotification(changeType int, previousDN string) *ber.Packet {
+	value := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "EntryChangeNotification")
+	value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, changeType, "changeType"))
+	if len(previousDN) > 0 {
+		value.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, previousDN, "previousDN"))
+	}
+	value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 42, "changeNumber"))
+	return mockControl(ControlTypeEntryChangeNotification, value)
+}
+
+func TestPersistentSearch(t *testing.T) {
+	l, s := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		if request.Children[1].Tag != ber.Tag(ApplicationSearchRequest) {
+			return
+		}
+		messageID := mockMessageID(request)
+		s.respond(messageID, mockSearchEntry("cn=new,o=bigcorp", nil), mockEntryChangeNotification(ChangeTypeAdd, ""))
+		s.respond(messageID, mockSearchEntry("cn=renamed,o=bigcorp", nil), mockEntryChangeNotification(ChangeTypeModDN, "cn=old,o=bigcorp"))
+	})
+	defer l.Close()
+
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(objectClass=*)", nil)
+	ps, err := l.PersistentSearch(searchRequest, ChangeTypeAny, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := <-s.requests
+	if len(request.Children) != 3 || packetString(request.Children[2].Children[0].Children[0]) != string(ControlTypePersistentSearchRequest) {
+		t.Fatal("PersistentSearch control missing from the search request")
+	}
+
+	event := <-ps.Events
+	if event.ChangeType != ChangeTypeAdd || event.Entry.DN != "cn=new,o=bigcorp" || event.ChangeNumber != 42 {
+		t.Errorf("Unexpected event %+v", event)
+	}
+	event = <-ps.Events
+	if event.ChangeType != ChangeTypeModDN || event.PreviousDN != "cn=old,o=bigcorp" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+
+	if err := ps.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case _, ok := <-ps.Events:
+		if ok {
+			t.Error("Unexpected event after Stop")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Events not closed after Stop")
+	}
+	if ps.Err() != nil {
+		t.Errorf("Unexpected error %v", ps.Err())
+	}
+}
+
+func TestNotification(t *testing.T) {
+	l, s := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		if request.Children[1].Tag != ber.Tag(ApplicationSearchRequest) {
+			return
+		}
+		s.respond(mockMessageID(request), mockSearchEntry("cn=changed,o=bigcorp", map[string][]string{"cn": {"changed"}}))
+	})
+	defer l.Close()
+
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeSingleLevel, "(objectClass=*)", nil)
+	ps, err := l.Notification(searchRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := <-s.requests
+	if len(request.Children) != 3 || packetString(request.Children[2].Children[0].Children[0]) != string(ControlTypeNotificationRequest) {
+		t.Fatal("Notification control missing from the search request")
+	}
+
+	event := <-ps.Events
+	if event.Entry.DN != "cn=changed,o=bigcorp" || event.Entry.GetAttributeValue("cn") != "changed" || event.ChangeType != 0 {
+		t.Errorf("Unexpected event %+v", event)
+	}
+	if err := ps.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	for range ps.Events {
+		t.Error("Unexpected event after Stop")
+	}
+}
@@ -0,0 +1,231 @@
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DialContextFunc dials a connection like the DialContext of a net.Dialer, see
+// Connection.DialContext.
+type DialContextFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// SOCKS5Dialer returns a DialContext for Connection that connects through the
+// SOCKS5 proxy [https://tools.ietf.org/html/rfc1928] at proxyAddr,
+// authenticating with username and password [https://tools.ietf.org/html/rfc1929]
+// if username isn't empty. The host is resolved by the proxy.
+func SOCKS5Dialer(proxyAddr, username, password string) DialContextFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dialProxy(ctx, network, proxyAddr)
+		if err != nil {
+			return nil, err
+		}
+		if err := socks5Connect(conn, address, username, password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return clearProxyDeadline(conn)
+	}
+}
+
+// HTTPProxyDialer returns a DialContext for Connection that connects through
+// the HTTP proxy at proxyAddr with a CONNECT request. header is sent along
+// with the request, e.g. with the Proxy-Authorization of ProxyBasicAuth.
+func HTTPProxyDialer(proxyAddr string, header http.Header) DialContextFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dialProxy(ctx, network, proxyAddr)
+		if err != nil {
+			return nil, err
+		}
+		tunnel, err := httpConnect(conn, address, header)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return clearProxyDeadline(tunnel)
+	}
+}
+
+// ProxyBasicAuth returns the header of HTTPProxyDialer authenticating with
+// username and password.
+func ProxyBasicAuth(username, password string) http.Header {
+	request := &http.Request{Header: http.Header{}}
+	request.SetBasicAuth(username, password)
+	return http.Header{"Proxy-Authorization": request.Header["Authorization"]}
+}
+
+func httpConnect(conn net.Conn, address string, header http.Header) (net.Conn, error) {
+	request := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: header,
+	}
+	if request.Header == nil {
+		request.Header = http.Header{}
+	}
+	if err := request.Write(conn); err != nil {
+		return nil, newError(ErrorNetwork, "HTTP proxy: "+err.Error())
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		return nil, newError(ErrorNetwork, "HTTP proxy: "+err.Error())
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, newError(ErrorNetwork, "HTTP proxy: CONNECT failed with "+response.Status)
+	}
+	if reader.Buffered() > 0 {
+		// data of the server sent along with the response
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn reads the data already buffered by reader before the rest of
+// the connection.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// dialProxy connects to the proxy at proxyAddr with the deadline of ctx set on
+// the connection for the handshake.
+func dialProxy(ctx context.Context, network, proxyAddr string) (net.Conn, error) {
+	if network != "tcp" {
+		return nil, newError(ErrorNetwork, "Proxies only support tcp, not "+network)
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	return conn, nil
+}
+
+func clearProxyDeadline(conn net.Conn) (net.Conn, error) {
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+var socks5Errors = map[byte]string{
+	1: "general SOCKS server failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+func socks5Connect(conn net.Conn, address, username, password string) error {
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return newError(ErrorInvalidArgument, "Invalid address "+address)
+	}
+	port, err := strconv.ParseUint(portString, 10, 16)
+	if err != nil {
+		return newError(ErrorInvalidArgument, "Invalid port in "+address)
+	}
+
+	// method negotiation, without authentication or with username/password
+	methods := []byte{5, 1, 0}
+	if username != "" {
+		methods = []byte{5, 2, 0, 2}
+	}
+	reply := make([]byte, 2)
+	if _, err := conn.Write(methods); err != nil {
+		return newError(ErrorNetwork, "SOCKS5 proxy: "+err.Error())
+	}
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return newError(ErrorNetwork, "SOCKS5 proxy: "+err.Error())
+	}
+	if reply[0] != 5 {
+		return newError(ErrorNetwork, fmt.Sprintf("SOCKS5 proxy: unexpected version %d", reply[0]))
+	}
+	switch reply[1] {
+	case 0:
+	case 2:
+		if len(username) > 255 || len(password) > 255 {
+			return newError(ErrorInvalidArgument, "SOCKS5 proxy: username or password too long")
+		}
+		auth := append([]byte{1, byte(len(username))}, username...)
+		auth = append(append(auth, byte(len(password))), password...)
+		if _, err := conn.Write(auth); err != nil {
+			return newError(ErrorNetwork, "SOCKS5 proxy: "+err.Error())
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return newError(ErrorNetwork, "SOCKS5 proxy: "+err.Error())
+		}
+		if reply[1] != 0 {
+			return newError(ErrorNetwork, "SOCKS5 proxy: authentication failed")
+		}
+	default:
+		return newError(ErrorNetwork, "SOCKS5 proxy: no acceptable authentication method")
+	}
+
+	request := []byte{5, 1, 0}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+		request = append(append(request, 1), ip.To4()...)
+	} else if ip != nil {
+		request = append(append(request, 4), ip.To16()...)
+	} else if len(host) > 255 {
+		return newError(ErrorInvalidArgument, "SOCKS5 proxy: host name too long")
+	} else {
+		request = append(append(request, 3, byte(len(host))), host...)
+	}
+	request = append(request, byte(port>>8), byte(port))
+	if _, err := conn.Write(request); err != nil {
+		return newError(ErrorNetwork, "SOCKS5 proxy: "+err.Error())
+	}
+
+	// VER REP RSV ATYP BND.ADDR BND.PORT
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return newError(ErrorNetwork, "SOCKS5 proxy: "+err.Error())
+	}
+	if header[1] != 0 {
+		reason, ok := socks5Errors[header[1]]
+		if !ok {
+			reason = fmt.Sprintf("error %d", header[1])
+		}
+		return newError(ErrorNetwork, "SOCKS5 proxy: "+reason)
+	}
+	var bound int
+	switch header[3] {
+	case 1:
+		bound = net.IPv4len
+	case 4:
+		bound = net.IPv6len
+	case 3:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return newError(ErrorNetwork, "SOCKS5 proxy: "+err.Error())
+		}
+		bound = int(length[0])
+	default:
+		return newError(ErrorNetwork, fmt.Sprintf("SOCKS5 proxy: unexpected address type %d", header[3]))
+	}
+	if _, err := io.ReadFull(conn, make([]byte, bound+2)); err != nil {
+		return newError(ErrorNetwork, "SOCKS5 proxy: "+err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package ldap
+
+import (
+	"bufio"
+	"github.com/eaciit/asn1-ber"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// mockProxy accepts one connection on a new listener, runs handshake on it and
+// then answers the delete requests of the LDAP session tunneled through it.
+// The target connected to is sent on targets.
+func mockProxy(t *testing.T, handshake func(conn net.Conn) (string, bool)) (net.Listener, chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		target, ok := handshake(conn)
+		targets <- target
+		if !ok {
+			return
+		}
+		s := &mockServer{conn: conn}
+		for {
+			request, err := ber.ReadPacket(conn)
+			if err != nil {
+				return
+			}
+			s.respondResult(mockMessageID(request), ApplicationDelResponse, ResultSuccess, "")
+		}
+	}()
+	return listener, targets
+}
+
+func mockSOCKS5Handshake(conn net.Conn) (string, bool) {
+	greeting := make([]byte, 4)
+	if _, err := io.ReadFull(conn, greeting); err != nil || greeting[1] != 2 || greeting[3] != 2 {
+		return "", false
+	}
+	conn.Write([]byte{5, 2})
+	auth := make([]byte, 2)
+	io.ReadFull(conn, auth)
+	username := make([]byte, auth[1])
+	io.ReadFull(conn, username)
+	io.ReadFull(conn, auth[:1])
+	password := make([]byte, auth[0])
+	io.ReadFull(conn, password)
+	if string(username) != "bob" || string(password) != "secret" {
+		conn.Write([]byte{1, 1})
+		return "", false
+	}
+	conn.Write([]byte{1, 0})
+
+	request := make([]byte, 5)
+	io.ReadFull(conn, request)
+	host := make([]byte, request[4]+2)
+	io.ReadFull(conn, host)
+	conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+	port := int(host[request[4]])<<8 | int(host[request[4]+1])
+	return net.JoinHostPort(string(host[:request[4]]), strconv.Itoa(port)), request[3] == 3
+}
+
+func TestSOCKS5Dialer(t *testing.T) {
+	listener, targets := mockProxy(t, mockSOCKS5Handshake)
+	defer listener.Close()
+
+	l := NewConnection("ldap.example.com:389")
+	l.DialContext = SOCKS5Dialer(listener.Addr().String(), "bob", "secret")
+	if err := l.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if target := <-targets; target != "ldap.example.com:389" {
+		t.Errorf("Unexpected target %s", target)
+	}
+	if _, err := l.Delete(NewDeleteRequest("cn=bob,o=bigcorp")); err != nil {
+		t.Errorf("Delete through the proxy failed: %s", err)
+	}
+
+	listener, _ = mockProxy(t, mockSOCKS5Handshake)
+	defer listener.Close()
+	l = NewConnection("ldap.example.com:389")
+	l.DialContext = SOCKS5Dialer(listener.Addr().String(), "bob", "wrong")
+	if err := l.Connect(); err == nil {
+		t.Error("Expected the proxy authentication to fail")
+	}
+}
+
+func mockHTTPConnectHandshake(conn net.Conn) (string, bool) {
+	request, err := http.ReadRequest(bufio.NewReader(conn))
+	if err != nil || request.Method != http.MethodConnect {
+		return "", false
+	}
+	if request.Header.Get("Proxy-Authorization") != "Basic Ym9iOnNlY3JldA==" {
+		io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+		return request.Host, false
+	}
+	io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+	return request.Host, true
+}
+
+func TestHTTPProxyDialer(t *testing.T) {
+	listener, targets := mockProxy(t, mockHTTPConnectHandshake)
+	defer listener.Close()
+
+	l := NewConnection("ldap.example.com:636")
+	l.DialContext = HTTPProxyDialer(listener.Addr().String(), ProxyBasicAuth("bob", "secret"))
+	if err := l.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if target := <-targets; target != "ldap.example.com:636" {
+		t.Errorf("Unexpected target %s", target)
+	}
+	if _, err := l.Delete(NewDeleteRequest("cn=bob,o=bigcorp")); err != nil {
+		t.Errorf("Delete through the proxy failed: %s", err)
+	}
+
+	listener, _ = mockProxy(t, mockHTTPConnectHandshake)
+	defer listener.Close()
+	l = NewConnection("ldap.example.com:636")
+	l.DialContext = HTTPProxyDialer(listener.Addr().String(), nil)
+	if err := l.Connect(); err == nil || !strings.Contains(err.Error(), "407") {
+		t.Errorf("Expected the CONNECT to fail with 407, got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"sync"
+)
+
+// DefaultMaxQueuedResponses is the number of responses to one message queued
+// until the reader pauses, see Connection.MaxQueuedResponses.
+const DefaultMaxQueuedResponses = 1000
+
+// responseQueue holds the responses to a message until its operation takes
+// them, so the reader routes the responses of all outstanding messages by
+// messageID without waiting for a slow operation and never sends to a closed
+// channel. Multi-message responses like search entries and intermediate
+// responses are queued in the order they arrived. Once limit responses are
+// queued push waits until the operation took one, so a slow operation
+// pauses the reader instead of piling up the responses in memory.
+type responseQueue struct {
+	lock    sync.Mutex
+	packets []*ber.Packet
+	closed  bool
+	limit   int
+	// signaled when a response was taken or the queue was closed
+	space *sync.Cond
+	// signaled when a response arrived or the queue was closed
+	ready chan struct{}
+}
+
+// newResponseQueue returns a queue of up to limit responses, unlimited if
+// limit isn't positive.
+func newResponseQueue(limit int) *responseQueue {
+	q := &responseQueue{limit: limit, ready: make(chan struct{}, 1)}
+	q.space = sync.NewCond(&q.lock)
+	return q
+}
+
+// push adds p to the queue, waiting while it is full. Responses arriving
+// after close are dropped.
+func (q *responseQueue) push(p *ber.Packet) {
+	q.lock.Lock()
+	for q.limit > 0 && len(q.packets) >= q.limit && !q.closed {
+		q.space.Wait()
+	}
+	if q.closed {
+		q.lock.Unlock()
+		return
+	}
+	q.packets = append(q.packets, p)
+	q.lock.Unlock()
+	q.signal()
+}
+
+// close ends the queue, responses already queued are still taken by pop.
+func (q *responseQueue) close() {
+	q.lock.Lock()
+	q.closed = true
+	q.space.Broadcast()
+	q.lock.Unlock()
+	q.signal()
+}
+
+func (q *responseQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// pop takes the next response, nil if none arrived yet, wait on ready for
+// it. false is returned once the queue is closed and empty.
+func (q *responseQueue) pop() (*ber.Packet, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if len(q.packets) > 0 {
+		p := q.packets[0]
+		q.packets[0] = nil
+		q.packets = q.packets[1:]
+		q.space.Signal()
+		return p, true
+	}
+	return nil, !q.closed
+}
@@ -0,0 +1,123 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"testing"
+	"time"
+)
+
+func TestResponseQueueLimit(t *testing.T) {
+	q := newResponseQueue(2)
+	q.push(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "1", ""))
+	q.push(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "2", ""))
+	pushed := make(chan struct{})
+	go func() {
+		q.push(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "3", ""))
+		close(pushed)
+	}()
+	select {
+	case <-pushed:
+		t.Fatal("Expected push to wait while the queue is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if p, ok := q.pop(); !ok || packetString(p) != "1" {
+		t.Fatalf("Unexpected response %v", p)
+	}
+	select {
+	case <-pushed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("push was not released by pop")
+	}
+
+	// close releases a waiting push, dropping its response
+	pushed = make(chan struct{})
+	go func() {
+		q.push(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "4", ""))
+		close(pushed)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	q.close()
+	select {
+	case <-pushed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("push was not released by close")
+	}
+	for _, expected := range []string{"2", "3"} {
+		if p, ok := q.pop(); !ok || packetString(p) != expected {
+			t.Errorf("Expected %s, got %v", expected, p)
+		}
+	}
+	if _, ok := q.pop(); ok {
+		t.Error("Expected the closed queue to be empty")
+	}
+}
+
+func TestSearchSlowHandlerPausesReader(t *testing.T) {
+	const entries = 50
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		if request.Children[1].Tag != ber.Tag(ApplicationSearchRequest) {
+			return
+		}
+		messageID := mockMessageID(request)
+		for i := 0; i < entries; i++ {
+			s.respond(messageID, mockSearchEntry("cn=bob,o=bigcorp", nil))
+		}
+		s.respondResult(messageID, ApplicationSearchResultDone, ResultSuccess, "")
+	})
+	defer l.Close()
+	l.MaxQueuedResponses = 5
+
+	release := make(chan struct{})
+	received := 0
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", nil)
+	done := make(chan error, 1)
+	go func() {
+		done <- l.SearchFunc(searchRequest, func(entry *Entry) (bool, error) {
+			if received == 0 {
+				<-release
+			}
+			received++
+			return false, nil
+		})
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	l.lockResponses.RLock()
+	for _, queue := range l.responses {
+		queue.lock.Lock()
+		if len(queue.packets) > 5 {
+			t.Errorf("Expected at most 5 queued responses, got %d", len(queue.packets))
+		}
+		queue.lock.Unlock()
+	}
+	l.lockResponses.RUnlock()
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if received != entries {
+		t.Errorf("Expected %d entries, got %d", entries, received)
+	}
+}
+
+func TestCloseFullQueue(t *testing.T) {
+	l, _ := mockStreamServer(t, 50)
+	l.MaxQueuedResponses = 5
+
+	release := make(chan struct{})
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", nil)
+	go l.SearchFunc(searchRequest, func(entry *Entry) (bool, error) {
+		<-release
+		return false, nil
+	})
+	time.Sleep(50 * time.Millisecond)
+
+	stopped := l.chanStopped
+	l.Close()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The connection waited for the reader blocked on the full queue")
+	}
+	close(release)
+}
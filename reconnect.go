@@ -0,0 +1,248 @@
+package ldap
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// reconnect connects again once the connection broke or expired, to the next
+// of Servers or to Addr with AutoReconnect, and replays the last bind. The
+// server of a broken connection is put into cooldown. Connections closed with
+// Close or Unbind stay closed. The operations of the rebind don't reconnect,
+// they fail if the new connection broke and so does the rebind.
+func (l *Connection) reconnect() {
+	if (l.Servers == nil && !l.AutoReconnect) || l.isRebinding() || !l.broken() {
+		return
+	}
+	l.reconnectLock.Lock()
+	defer l.reconnectLock.Unlock()
+	if !l.broken() {
+		return
+	}
+	// the old goroutines must be done with the state connect resets
+	l.closeLock.RLock()
+	stopped, expiring := l.chanStopped, l.expiring
+	l.closeLock.RUnlock()
+	<-stopped
+	l.conn = nil
+	var err error
+	if l.Servers != nil {
+		if !expiring {
+			l.Servers.MarkFailed(l.server)
+		}
+		err = l.connectServers(context.Background())
+	} else {
+		err = l.connectClean(context.Background())
+	}
+	if err == nil {
+		l.setRebinding(true)
+		err = l.rebind()
+		l.setRebinding(false)
+		if err != nil {
+			// don't go on with the wrong identity, the failure is counted
+			// with the next reconnect
+			l.setCloseError("Rebind failed: ", err)
+			l.stop()
+		} else {
+			if l.Servers != nil {
+				l.Servers.MarkSucceeded(l.server)
+			}
+			if l.OnReconnect != nil {
+				l.OnReconnect(l)
+			}
+		}
+	}
+	if err != nil && l.Debug {
+		log.Printf("Reconnecting failed: %s", err)
+	}
+}
+
+// connectClean is connect closing the connection again if it failed after
+// the start, e.g. in StartTLS.
+func (l *Connection) connectClean(ctx context.Context) error {
+	err := l.connect(ctx)
+	if err != nil && l.conn != nil {
+		l.stop()
+		l.conn = nil
+	}
+	return err
+}
+
+// broken returns whether the connection closed without Close or Unbind.
+func (l *Connection) broken() bool {
+	l.closeLock.RLock()
+	defer l.closeLock.RUnlock()
+	return (!l.connected || l.expiring) && !l.closed && l.chanDone != nil
+}
+
+// setRebinding marks the reconnect holding reconnectLock as rebinding.
+func (l *Connection) setRebinding(rebinding bool) {
+	l.closeLock.Lock()
+	defer l.closeLock.Unlock()
+	l.rebinding = rebinding
+}
+
+// isRebinding returns whether the operation is part of a rebind in reconnect,
+// which holds reconnectLock.
+func (l *Connection) isRebinding() bool {
+	l.closeLock.RLock()
+	defer l.closeLock.RUnlock()
+	return l.rebinding
+}
+
+// setLastBind records bind as the bind to replay after a reconnect. In the
+// fast bind mode binds don't change the identity, FastBind is replayed.
+func (l *Connection) setLastBind(bind func(ctx context.Context) error) {
+	l.lastBindLock.Lock()
+	defer l.lastBindLock.Unlock()
+	if !l.fastBind {
+		l.lastBind = bind
+	}
+}
+
+// rebind binds the reconnected connection with the RebindHandler or the last
+// successful bind.
+func (l *Connection) rebind() error {
+	if l.RebindHandler != nil {
+		return l.RebindHandler(l)
+	}
+	l.lastBindLock.Lock()
+	bind := l.lastBind
+	l.lastBindLock.Unlock()
+	if bind == nil {
+		return nil
+	}
+	return bind(context.Background())
+}
+
+// retryable returns whether an idempotent operation failing with err is
+// retried because the connection broke and reconnects. Operations running
+// concurrently all fail with the broken connection, when they retry it may
+// already be reconnected by another one.
+func (l *Connection) retryable(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil || (l.Servers == nil && !l.AutoReconnect) {
+		return false
+	}
+	lerr, ok := err.(*Error)
+	if !ok || (lerr.ResultCode != ErrorClosing && lerr.ResultCode != ErrorNetwork) {
+		return false
+	}
+	l.closeLock.RLock()
+	defer l.closeLock.RUnlock()
+	return !l.closed
+}
+
+// processedHandler records whether a search passed on a result.
+type processedHandler struct {
+	SearchResultHandler
+	processed bool
+}
+
+func (h *processedHandler) ProcessDiscreteResult(dsr *DiscreteSearchResult, connInfo *ConnectionInfo) (bool, error) {
+	h.processed = true
+	return h.SearchResultHandler.ProcessDiscreteResult(dsr, connInfo)
+}
+
+// markUsed records the end of an operation for MaxIdleTime, unless it is a
+// Heartbeat.
+func (l *Connection) markUsed(ctx context.Context) {
+	if ctx.Value(heartbeatKey{}) != nil {
+		return
+	}
+	l.lockResponses.Lock()
+	defer l.lockResponses.Unlock()
+	l.lastUsed = time.Now()
+}
+
+// use counts an operation from before it takes its messageID until it
+// finished, the returned function ends the use. A connection in use doesn't
+// expire.
+func (l *Connection) use() func() {
+	l.closeLock.Lock()
+	l.inUse++
+	l.closeLock.Unlock()
+	return func() {
+		l.closeLock.Lock()
+		l.inUse--
+		l.closeLock.Unlock()
+	}
+}
+
+// expired returns whether the connection exceeded MaxIdleTime or MaxLifetime
+// and has no outstanding operations.
+func (l *Connection) expired() bool {
+	if l.MaxIdleTime <= 0 && l.MaxLifetime <= 0 {
+		return false
+	}
+	l.closeLock.RLock()
+	defer l.closeLock.RUnlock()
+	if !l.connected || l.closed || l.inUse > 0 {
+		return false
+	}
+	l.lockResponses.RLock()
+	defer l.lockResponses.RUnlock()
+	if len(l.responses) > 0 {
+		return false
+	}
+	now := time.Now()
+	return (l.MaxIdleTime > 0 && now.Sub(l.lastUsed) >= l.MaxIdleTime) ||
+		(l.MaxLifetime > 0 && now.Sub(l.connectedAt) >= l.MaxLifetime)
+}
+
+// expire closes the expired connection without putting its server into
+// cooldown and waits until it is closed. It returns false if an operation
+// was started in the meantime, the operations started afterwards wait in
+// reconnect.
+func (l *Connection) expire() bool {
+	l.closeLock.Lock()
+	if l.inUse > 0 {
+		l.closeLock.Unlock()
+		return false
+	}
+	l.expiring = true
+	l.closeLock.Unlock()
+	if l.Debug {
+		log.Println("Closing the expired connection")
+	}
+	l.stop()
+	return true
+}
+
+// refreshExpired closes the expired connection before an operation, so it
+// reconnects instead of running into a connection dropped by the server,
+// except in a rebind.
+func (l *Connection) refreshExpired() {
+	if !l.isRebinding() && l.expired() {
+		l.reconnectLock.Lock()
+		defer l.reconnectLock.Unlock()
+		if l.expired() {
+			l.expire()
+		}
+	}
+}
+
+// reap closes the connection once it expired, until done is closed.
+func (l *Connection) reap(done <-chan struct{}) {
+	interval := l.MaxIdleTime
+	if interval <= 0 || (l.MaxLifetime > 0 && l.MaxLifetime < interval) {
+		interval = l.MaxLifetime
+	}
+	ticker := time.NewTicker(interval / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		if l.expired() {
+			l.reconnectLock.Lock()
+			expired := l.expired() && l.expire()
+			l.reconnectLock.Unlock()
+			if expired {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"github.com/eaciit/asn1-ber"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// mockReconnectServer answers binds and searches, the connection is dropped
+// on the first search instead of answering it.
+func mockReconnectServer(t *testing.T) (*Connection, chan *mockServer) {
+	var searches int32
+	listener, servers := mockListener(t, func(s *mockServer, request *ber.Packet) {
+		switch request.Children[1].Tag {
+		case ber.Tag(ApplicationBindRequest):
+			s.respondResult(mockMessageID(request), ApplicationBindResponse, ResultSuccess, "")
+		case ber.Tag(ApplicationSearchRequest):
+			if atomic.AddInt32(&searches, 1) == 1 {
+				s.conn.Close()
+				return
+			}
+			s.respondResult(mockMessageID(request), ApplicationSearchResultDone, ResultSuccess, "")
+		}
+	})
+	t.Cleanup(func() { listener.Close() })
+	l := NewConnection(listener.Addr().String())
+	l.AutoReconnect = true
+	if err := l.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l, servers
+}
+
+func TestAutoReconnectRebind(t *testing.T) {
+	l, servers := mockReconnectServer(t)
+	if err := l.Bind("cn=admin,o=bigcorp", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	<-servers
+
+	// the search is retried on the new connection after the bind
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", nil)
+	if _, err := l.Search(searchRequest); err != nil {
+		t.Fatal(err)
+	}
+	s := <-servers
+	bind, search := <-s.requests, <-s.requests
+	if bind.Children[1].Tag != ber.Tag(ApplicationBindRequest) || packetString(bind.Children[1].Children[1]) != "cn=admin,o=bigcorp" {
+		t.Errorf("Expected the bind to be replayed, got %v", bind.Children[1])
+	}
+	if search.Children[1].Tag != ber.Tag(ApplicationSearchRequest) {
+		t.Errorf("Expected the search to be retried, got %v", search.Children[1])
+	}
+}
+
+func TestAutoReconnectRebindHandler(t *testing.T) {
+	l, servers := mockReconnectServer(t)
+	rebinds := 0
+	l.RebindHandler = func(l *Connection) error {
+		rebinds++
+		return l.Bind("cn=rotated,o=bigcorp", "secret")
+	}
+	<-servers
+
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", nil)
+	if _, err := l.Search(searchRequest); err != nil {
+		t.Fatal(err)
+	}
+	if rebinds != 1 {
+		t.Errorf("RebindHandler called %d times", rebinds)
+	}
+	if bind := <-(<-servers).requests; packetString(bind.Children[1].Children[1]) != "cn=rotated,o=bigcorp" {
+		t.Errorf("Unexpected bind %v", bind.Children[1])
+	}
+}
+
+func TestAutoReconnectRebindDropped(t *testing.T) {
+	var binds int32
+	listener, _ := mockListener(t, func(s *mockServer, request *ber.Packet) {
+		switch request.Children[1].Tag {
+		case ber.Tag(ApplicationBindRequest):
+			// the first replayed bind loses the connection
+			if atomic.AddInt32(&binds, 1) == 1 {
+				s.conn.Close()
+				return
+			}
+			s.respondResult(mockMessageID(request), ApplicationBindResponse, ResultSuccess, "")
+		case ber.Tag(ApplicationSearchRequest):
+			s.respondResult(mockMessageID(request), ApplicationSearchResultDone, ResultSuccess, "")
+		}
+	})
+	defer listener.Close()
+	l := NewConnection(listener.Addr().String())
+	l.AutoReconnect = true
+	rebinds := int32(0)
+	l.RebindHandler = func(l *Connection) error {
+		atomic.AddInt32(&rebinds, 1)
+		// binding again on the dropped connection must not reconnect from
+		// within the reconnect
+		if err := l.Bind("cn=admin,o=bigcorp", "secret"); err == nil {
+			return nil
+		}
+		return l.Bind("cn=admin,o=bigcorp", "secret")
+	}
+	if err := l.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	l.stop()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := l.Search(NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", nil))
+		done <- err
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The reconnect hung in the rebind")
+	}
+	// the connection broken in the rebind reconnects with the next operation
+	if _, err := l.Search(NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&rebinds); n < 2 {
+		t.Errorf("RebindHandler called %d times", n)
+	}
+}
+
+func TestMaxIdleTime(t *testing.T) {
+	listener, servers := mockListener(t, func(s *mockServer, request *ber.Packet) {
+		if request.Children[1].Tag == ber.Tag(ApplicationSearchRequest) {
+			s.respondResult(mockMessageID(request), ApplicationSearchResultDone, ResultSuccess, "")
+		}
+	})
+	defer listener.Close()
+	l := NewConnection(listener.Addr().String())
+	l.AutoReconnect = true
+	l.MaxIdleTime = 20 * time.Millisecond
+	if err := l.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	<-servers
+
+	done := l.chanDone
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The idle connection was not closed")
+	}
+
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", nil)
+	if _, err := l.Search(searchRequest); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-servers:
+	default:
+		t.Error("The search didn't reconnect")
+	}
+}
+
+func TestMaxLifetime(t *testing.T) {
+	listener, servers := mockListener(t, nil)
+	defer listener.Close()
+	l := NewConnection(listener.Addr().String())
+	l.MaxLifetime = time.Hour
+	if err := l.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	<-servers
+	if l.expired() {
+		t.Error("The new connection expired")
+	}
+	l.connectedAt = time.Now().Add(-2 * time.Hour)
+	if !l.expired() {
+		t.Error("Expected the connection to expire after MaxLifetime")
+	}
+
+	// an operation that took its messageID but isn't sent yet
+	unuse := l.use()
+	if l.expired() || l.expire() {
+		t.Error("Expected the connection in use not to expire")
+	}
+	unuse()
+	if !l.expired() {
+		t.Error("Expected the connection to expire once no longer used")
+	}
+}
+
+func TestAutoReconnectConcurrentSearches(t *testing.T) {
+	l, servers := mockReconnectServer(t)
+	<-servers
+
+	// the searches fail together with the first one dropping the connection
+	// and are all retried on the same new connection
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", nil)
+			if _, err := l.Search(searchRequest); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if len(servers) != 1 {
+		t.Errorf("Expected one reconnect, got %d", len(servers))
+	}
+}
+
+func TestAutoReconnectStartTLS(t *testing.T) {
+	serverConfig := &tls.Config{Certificates: []tls.Certificate{mockCertificate(t)}}
+	startTLS := mockStartTLS(serverConfig)
+	listener, servers := mockListener(t, func(s *mockServer, request *ber.Packet) {
+		switch request.Children[1].Tag {
+		case ber.Tag(ApplicationExtendedRequest):
+			startTLS(s, request)
+		case ber.Tag(ApplicationDelRequest):
+			s.respondResult(mockMessageID(request), ApplicationDelResponse, ResultSuccess, "")
+		}
+	})
+	defer listener.Close()
+	l := NewTLSConnection(listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	l.AutoReconnect = true
+	if err := l.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if !l.tlsActive() || l.IsSSL {
+		t.Fatal("Expected the connection to be upgraded with StartTLS")
+	}
+	stopped := l.chanStopped
+	(<-servers).conn.Close()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The dropped connection was not closed")
+	}
+
+	if _, err := l.Delete(NewDeleteRequest("cn=bob,o=bigcorp")); err != nil {
+		t.Fatal(err)
+	}
+	// the new connection starts in plaintext with StartTLS, not a ClientHello
+	if extended := <-(<-servers).requests; extended.Children[1].Tag != ber.Tag(ApplicationExtendedRequest) {
+		t.Errorf("Expected StartTLS, got %v", extended.Children[1])
+	}
+	if !l.tlsActive() {
+		t.Error("Expected the reconnected connection to be upgraded with StartTLS")
+	}
+}
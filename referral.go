@@ -0,0 +1,20 @@
+package ldap
+
+// Named Subordinate References [https://tools.ietf.org/html/rfc3296]
+const (
+	// objectClass of referral objects
+	ObjectClassReferral = "referral"
+	// attribute holding the LDAP URLs of a referral object
+	AttributeRef = "ref"
+)
+
+// NewReferralAddRequest returns an AddRequest for a referral object pointing
+// to urls. The server only creates it as a regular entry if the request has a
+// ManageDsaIT control, which is added critical.
+func NewReferralAddRequest(dn string, urls []string) *AddRequest {
+	req := NewAddRequest(dn)
+	req.Entry.AddAttributeValues("objectClass", []string{ObjectClassReferral, "extensibleObject"})
+	req.Entry.AddAttributeValues(AttributeRef, urls)
+	req.AddControl(NewControlManageDsaITRequest(true))
+	return req
+}
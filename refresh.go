@@ -0,0 +1,75 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+)
+
+// Dynamic Directory Services [https://tools.ietf.org/html/rfc2589]
+const (
+	ExtendedOperationRefresh = "1.3.6.1.4.1.1466.101.119.1"
+
+	// objectClass of dynamic entries
+	ObjectClassDynamicObject = "dynamicObject"
+
+	// operational attribute holding the remaining time to live in seconds
+	AttributeEntryTtl = "entryTtl"
+	// operational attribute of the root DSE listing the dynamic subtrees
+	AttributeDynamicSubtrees = "dynamicSubtrees"
+)
+
+/*
+SEQUENCE {
+     entryName  [0] LDAPDN,
+     requestTtl [1] INTEGER }
+
+SEQUENCE {
+     responseTtl [1] INTEGER }
+*/
+
+// NewDynamicAddRequest returns an AddRequest for a dynamic entry, the entry has
+// to be kept alive with Refresh, otherwise the server deletes it after its
+// entryTtl expired.
+func NewDynamicAddRequest(dn string) *AddRequest {
+	req := NewAddRequest(dn)
+	req.Entry.AddAttributeValue("objectClass", ObjectClassDynamicObject)
+	return req
+}
+
+// Refresh asks the server to keep the dynamic entry dn alive for requestTtl
+// seconds and returns the time to live granted by the server, which may be
+// longer or shorter than requested.
+func (l *Connection) Refresh(dn string, requestTtl int) (int, error) {
+	value := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "RefreshRequestValue")
+	value.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, dn, "entryName"))
+	value.AppendChild(ber.NewInteger(ber.ClassContext, ber.TypePrimitive, 1, requestTtl, "requestTtl"))
+
+	response, err := l.Extended(NewExtendedRequest(ExtendedOperationRefresh, value))
+	if err != nil {
+		return 0, err
+	}
+	return decodeRefreshResponseValue(response.Value)
+}
+
+func decodeRefreshResponseValue(data []byte) (int, error) {
+	value := ber.DecodePacket(data)
+	if value == nil {
+		return 0, newError(ErrorDecoding, "Couldn't decode refresh response value.")
+	}
+	ttlPacket := value
+	if value.TagType == ber.TypeConstructed {
+		ttlPacket = nil
+		for _, child := range value.Children {
+			if child.ClassType == ber.ClassContext && child.Tag == 1 {
+				ttlPacket = child
+			}
+		}
+	}
+	if ttlPacket == nil {
+		return 0, newError(ErrorDecoding, "Refresh response without responseTtl.")
+	}
+	ttl, ok := packetInt64(ttlPacket)
+	if !ok {
+		return 0, NewValueMismatchError(ttlPacket.Value)
+	}
+	return int(ttl), nil
+}
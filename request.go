@@ -1,6 +1,7 @@
 package ldap
 
 import (
+	"context"
 	"fmt"
 	"github.com/eaciit/asn1-ber"
 	"time"
@@ -26,20 +27,68 @@ func requestBuildPacket(messageID int64, opPacket *ber.Packet, controls []Contro
 	return
 }
 
-func (l *Connection) sendReqRespPacket(messageID int64, packet *ber.Packet) error {
+// sendReqRespResult sends the request and decodes the LDAPResult of the response.
+// On a result code other than success the result is returned along with the error.
+func (l *Connection) sendReqRespResult(ctx context.Context, messageID int64, packet *ber.Packet) (*LDAPResult, error) {
+	responsePacket, err := l.sendReqResp(ctx, messageID, packet)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := decodeLDAPResult(responsePacket)
+	if err != nil {
+		return nil, err
+	}
+	if result.ResultCode == ResultNoOperation && requestHasControl(packet, ControlTypeNoOpRequest) {
+		// the request would have succeeded
+		return result, nil
+	}
+	return result, result.err()
+}
+
+// requestHasControl reports whether the LDAPMessage packet has a control of
+// controlType.
+func requestHasControl(packet *ber.Packet, controlType ControlType) bool {
+	if len(packet.Children) < 3 {
+		return false
+	}
+	for _, control := range packet.Children[2].Children {
+		if len(control.Children) > 0 && packetString(control.Children[0]) == string(controlType) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendReqResp sends the request and waits for the response packet.
+func (l *Connection) sendReqResp(ctx context.Context, messageID int64, packet *ber.Packet) (*ber.Packet, error) {
+	return l.sendReqRespIntermediate(ctx, messageID, packet, nil)
+}
+
+// sendReqRespIntermediate sends the request and waits for the response packet,
+// intermediate responses arriving before it are passed to handler. When ctx is
+// done or the RequestTimeout passed before the response arrived the request is
+// abandoned, see doneError for the error returned.
+func (l *Connection) sendReqRespIntermediate(ctx context.Context, messageID int64, packet *ber.Packet, handler IntermediateResponseHandler) (*ber.Packet, error) {
+	ctx, cancel := l.requestContext(ctx)
+	defer cancel()
+	defer l.markUsed(ctx)
+	if ctx.Err() != nil {
+		return nil, doneError(ctx)
+	}
 
 	if l.Debug {
 		ber.PrintPacket(packet)
 	}
 
-	channel, err := l.sendMessage(packet)
+	queue, err := l.sendMessage(packet)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if channel == nil {
-		return newError(ErrorNetwork, "Could not send message")
+	if queue == nil {
+		return nil, newError(ErrorNetwork, "Could not send message")
 	}
 
 	defer l.finishMessage(messageID)
@@ -55,20 +104,50 @@ func (l *Connection) sendReqRespPacket(messageID int64, packet *ber.Packet) erro
 	if uint64(timeout) == 0 {
 		timeout = DefaultTimeout
 	}
-	select {
-	case responsePacket, ok = <-channel:
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		responsePacket, ok = queue.pop()
+		if ok && responsePacket == nil {
+			select {
+			case <-queue.ready:
+				continue
+			case <-timer.C:
+				if isBindRequest(packet) {
+					l.interruptBind(context.DeadlineExceeded)
+				} else if l.AbandonMessageOnReadTimeout {
+					err = l.Abandon(messageID)
+					if err != nil {
+						return nil, &Error{ResultCode: ErrorTimeout, sText: "Timeout waiting for Message and error on Abandon", err: context.DeadlineExceeded}
+					}
+				}
+				return nil, &Error{ResultCode: ErrorTimeout, sText: "Timeout waiting for Message", err: context.DeadlineExceeded}
+			case <-ctx.Done():
+				if isBindRequest(packet) {
+					l.interruptBind(ctx.Err())
+				} else {
+					l.abandonOnDone(messageID)
+				}
+				return nil, doneError(ctx)
+			}
+		}
 		if !ok {
-			return newError(ErrorClosing, "Response Channel Closed")
+			return nil, l.closedQueueError(messageID)
 		}
-	case <-time.After(timeout):
-		if l.AbandonMessageOnReadTimeout {
-			err = l.Abandon(messageID)
+
+		if responsePacket == nil || !isIntermediateResponse(responsePacket) {
+			break
+		}
+		if l.Debug {
+			fmt.Printf("%d: got intermediate response\n", messageID)
+		}
+		if handler != nil {
+			intermediate, err := decodeIntermediateResponse(responsePacket)
 			if err != nil {
-				return newError(ErrorNetwork,
-					"Timeout waiting for Message and error on Abandon")
+				return nil, err
 			}
+			handler(intermediate)
 		}
-		return newError(ErrorNetwork, "Timeout waiting for Message")
 	}
 
 	if l.Debug {
@@ -76,24 +155,65 @@ func (l *Connection) sendReqRespPacket(messageID int64, packet *ber.Packet) erro
 	}
 
 	if responsePacket == nil {
-		return newError(ErrorNetwork, "Could not retrieve message")
+		return nil, newError(ErrorNetwork, "Could not retrieve message")
 	}
 
 	if l.Debug {
 		if err := addLDAPDescriptions(responsePacket); err != nil {
-			return err
+			return nil, err
 		}
 		ber.PrintPacket(responsePacket)
 	}
 
-	result_code, result_description := getResultCode(responsePacket)
+	if l.Debug {
+		fmt.Printf("%d: returning\n", messageID)
+	}
+	return responsePacket, nil
+}
 
-	if result_code != 0 {
-		return newError(result_code, result_description)
+// requestContext returns ctx with the RequestTimeout of the connection as
+// deadline, an earlier deadline of ctx still applies.
+func (l *Connection) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if l.RequestTimeout <= 0 {
+		return ctx, func() {}
 	}
+	return context.WithTimeout(ctx, l.RequestTimeout)
+}
 
-	if l.Debug {
-		fmt.Printf("%d: returning\n", messageID)
+// doneError returns the error of an operation whose ctx is done, an
+// ErrorTimeout *Error wrapping context.DeadlineExceeded if the deadline passed,
+// otherwise context.Canceled.
+func doneError(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return &Error{ResultCode: ErrorTimeout, sText: "Timeout waiting for Message", err: ctx.Err()}
+	}
+	return ctx.Err()
+}
+
+// isBindRequest reports whether the LDAPMessage packet is a bind request.
+func isBindRequest(packet *ber.Packet) bool {
+	return len(packet.Children) > 1 && packet.Children[1].Tag == ber.Tag(ApplicationBindRequest)
+}
+
+// interruptBind closes the connection of a bind whose response didn't arrive
+// in time. A bind can't be abandoned [https://tools.ietf.org/html/rfc4511#section-4.11],
+// the connection would be left in an unknown authentication state. With
+// AutoReconnect the next operation reconnects with the last bind.
+func (l *Connection) interruptBind(err error) {
+	l.setCloseError("Bind interrupted: ", err)
+	l.stop()
+}
+
+// abandonOnDone abandons the request of messageID after its context is done
+// or its SearchResultHandler stopped, or cancels it with CancelOnDone. The
+// server doesn't respond to the abandon and errors are only logged.
+func (l *Connection) abandonOnDone(messageID int64) {
+	if l.CancelOnDone {
+		l.abandonMessage(messageID)
+		go l.cancelOnDone(messageID)
+		return
+	}
+	if err := l.Abandon(messageID); err != nil && l.Debug {
+		fmt.Printf("%d: error abandoning the request: %v\n", messageID, err)
 	}
-	return nil
 }
@@ -0,0 +1,77 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+)
+
+/*
+LDAPResult ::= SEQUENCE {
+     resultCode         ENUMERATED { ... },
+     matchedDN          LDAPDN,
+     diagnosticMessage  LDAPString,
+     referral           [3] Referral OPTIONAL }
+
+Referral ::= SEQUENCE SIZE (1..MAX) OF uri URI
+*/
+
+// LDAPResult is the result returned by the server for the operations that
+// don't return anything else [https://tools.ietf.org/html/rfc4511#section-4.1.9]
+type LDAPResult struct {
+	ResultCode        ResultCode
+	MatchedDN         string
+	DiagnosticMessage string
+	Referrals         []string
+
+	// Response controls
+	Controls []Control
+}
+
+// decodeLDAPResult decodes the LDAPResult components and the response controls
+// of an LDAP response message.
+func decodeLDAPResult(p *ber.Packet) (*LDAPResult, error) {
+	if len(p.Children) < 2 {
+		return nil, newError(ErrorDecoding, "Invalid packet format")
+	}
+	response := p.Children[1]
+	if response.ClassType != ber.ClassApplication || response.TagType != ber.TypeConstructed || len(response.Children) < 3 {
+		return nil, newError(ErrorDecoding, "Invalid packet format")
+	}
+
+	result := new(LDAPResult)
+	code, ok := packetInt64(response.Children[0])
+	if !ok {
+		return nil, NewValueMismatchError(response.Children[0].Value)
+	}
+	result.ResultCode = ResultCode(code)
+	result.MatchedDN = packetString(response.Children[1])
+	result.DiagnosticMessage = packetString(response.Children[2])
+
+	for _, child := range response.Children[3:] {
+		if child.ClassType == ber.ClassContext && child.Tag == 3 {
+			for _, uri := range child.Children {
+				result.Referrals = append(result.Referrals, packetString(uri))
+			}
+		}
+	}
+
+	if len(p.Children) == 3 {
+		controls, err := decodeControls(p.Children[2])
+		if err != nil {
+			return nil, err
+		}
+		result.Controls = controls
+	}
+	return result, nil
+}
+
+// err returns the result as an *Error, nil if the result code indicates success.
+func (r *LDAPResult) err() error {
+	if r.ResultCode == ResultSuccess {
+		return nil
+	}
+	description := r.DiagnosticMessage
+	if r.ResultCode == ResultReferral && len(description) == 0 && len(r.Referrals) > 0 {
+		description = r.Referrals[0]
+	}
+	return newError(r.ResultCode, description)
+}
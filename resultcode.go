@@ -1,7 +1,7 @@
 package ldap
 
 // LDAP Result Codes
-type ResultCode uint8
+type ResultCode uint16
 
 // go:generate stringer -type=ResultCode
 const (
@@ -36,6 +36,8 @@ const (
 	ResultUnavailable                  ResultCode = 52
 	ResultUnwillingToPerform           ResultCode = 53
 	ResultLoopDetect                   ResultCode = 54
+	ResultSortControlMissing           ResultCode = 60
+	ResultOffsetRangeError             ResultCode = 61
 	ResultNamingViolation              ResultCode = 64
 	ResultObjectClassViolation         ResultCode = 65
 	ResultNotAllowedOnNonLeaf          ResultCode = 66
@@ -44,6 +46,13 @@ const (
 	ResultObjectClassModsProhibited    ResultCode = 69
 	ResultAffectsMultipleDSAs          ResultCode = 71
 	ResultOther                        ResultCode = 80
+	// Cancel Operation [https://tools.ietf.org/html/rfc3909]
+	ResultCanceled            ResultCode = 118
+	ResultNoSuchOperation     ResultCode = 119
+	ResultTooLate             ResultCode = 120
+	ResultCannotCancel        ResultCode = 121
+	ResultSyncRefreshRequired ResultCode = 4096
+	ResultNoOperation         ResultCode = 16654
 
 	ErrorNetwork         = 201
 	ErrorFilterCompile   = 202
@@ -57,4 +66,7 @@ const (
 	ErrorLDIFWrite       = 210
 	ErrorClosing         = 211
 	ErrorUnknown         = 212
+	ErrorAbandoned       = 213
+	ErrorTimeout         = 214
+	ErrorEmptyPassword   = 215
 )
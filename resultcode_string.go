@@ -4,56 +4,61 @@ package ldap
 
 import "fmt"
 
-const (
-	_ResultCode_name_0 = "ResultSuccessResultOperationsErrorResultProtocolErrorResultTimeLimitExceededResultSizeLimitExceededResultCompareFalseResultCompareTrueResultAuthMethodNotSupportedResultStrongAuthRequired"
-	_ResultCode_name_1 = "ResultReferralResultAdminLimitExceededResultUnavailableCriticalExtensionResultConfidentialityRequiredResultSaslBindInProgress"
-	_ResultCode_name_2 = "ResultNoSuchAttributeResultUndefinedAttributeTypeResultInappropriateMatchingResultConstraintViolationResultAttributeOrValueExistsResultInvalidAttributeSyntax"
-	_ResultCode_name_3 = "ResultNoSuchObjectResultAliasProblemResultInvalidDNSyntax"
-	_ResultCode_name_4 = "ResultAliasDereferencingProblem"
-	_ResultCode_name_5 = "ResultInappropriateAuthenticationResultInvalidCredentialsResultInsufficientAccessRightsResultBusyResultUnavailableResultUnwillingToPerformResultLoopDetect"
-	_ResultCode_name_6 = "ResultNamingViolationResultObjectClassViolationResultNotAllowedOnNonLeafResultNotAllowedOnRDNResultEntryAlreadyExistsResultObjectClassModsProhibited"
-	_ResultCode_name_7 = "ResultAffectsMultipleDSAs"
-	_ResultCode_name_8 = "ResultOther"
-)
+const _ResultCode_name = "ResultSuccessResultOperationsErrorResultProtocolErrorResultTimeLimitExceededResultSizeLimitExceededResultCompareFalseResultCompareTrueResultAuthMethodNotSupportedResultStrongAuthRequiredResultReferralResultAdminLimitExceededResultUnavailableCriticalExtensionResultConfidentialityRequiredResultSaslBindInProgressResultNoSuchAttributeResultUndefinedAttributeTypeResultInappropriateMatchingResultConstraintViolationResultAttributeOrValueExistsResultInvalidAttributeSyntaxResultNoSuchObjectResultAliasProblemResultInvalidDNSyntaxResultAliasDereferencingProblemResultInappropriateAuthenticationResultInvalidCredentialsResultInsufficientAccessRightsResultBusyResultUnavailableResultUnwillingToPerformResultLoopDetectResultSortControlMissingResultOffsetRangeErrorResultNamingViolationResultObjectClassViolationResultNotAllowedOnNonLeafResultNotAllowedOnRDNResultEntryAlreadyExistsResultObjectClassModsProhibitedResultAffectsMultipleDSAsResultOtherResultCanceledResultNoSuchOperationResultTooLateResultCannotCancelResultSyncRefreshRequiredResultNoOperation"
 
-var (
-	_ResultCode_index_0 = [...]uint8{0, 13, 34, 53, 76, 99, 117, 134, 162, 186}
-	_ResultCode_index_1 = [...]uint8{0, 14, 38, 72, 101, 125}
-	_ResultCode_index_2 = [...]uint8{0, 21, 49, 76, 101, 129, 157}
-	_ResultCode_index_3 = [...]uint8{0, 18, 36, 57}
-	_ResultCode_index_4 = [...]uint8{0, 31}
-	_ResultCode_index_5 = [...]uint8{0, 33, 57, 87, 97, 114, 138, 154}
-	_ResultCode_index_6 = [...]uint8{0, 21, 47, 72, 93, 117, 148}
-	_ResultCode_index_7 = [...]uint8{0, 25}
-	_ResultCode_index_8 = [...]uint8{0, 11}
-)
+var _ResultCode_map = map[ResultCode]string{
+	0:     _ResultCode_name[0:13],
+	1:     _ResultCode_name[13:34],
+	2:     _ResultCode_name[34:53],
+	3:     _ResultCode_name[53:76],
+	4:     _ResultCode_name[76:99],
+	5:     _ResultCode_name[99:117],
+	6:     _ResultCode_name[117:134],
+	7:     _ResultCode_name[134:162],
+	8:     _ResultCode_name[162:186],
+	10:    _ResultCode_name[186:200],
+	11:    _ResultCode_name[200:224],
+	12:    _ResultCode_name[224:258],
+	13:    _ResultCode_name[258:287],
+	14:    _ResultCode_name[287:311],
+	16:    _ResultCode_name[311:332],
+	17:    _ResultCode_name[332:360],
+	18:    _ResultCode_name[360:387],
+	19:    _ResultCode_name[387:412],
+	20:    _ResultCode_name[412:440],
+	21:    _ResultCode_name[440:468],
+	32:    _ResultCode_name[468:486],
+	33:    _ResultCode_name[486:504],
+	34:    _ResultCode_name[504:525],
+	36:    _ResultCode_name[525:556],
+	48:    _ResultCode_name[556:589],
+	49:    _ResultCode_name[589:613],
+	50:    _ResultCode_name[613:643],
+	51:    _ResultCode_name[643:653],
+	52:    _ResultCode_name[653:670],
+	53:    _ResultCode_name[670:694],
+	54:    _ResultCode_name[694:710],
+	60:    _ResultCode_name[710:734],
+	61:    _ResultCode_name[734:756],
+	64:    _ResultCode_name[756:777],
+	65:    _ResultCode_name[777:803],
+	66:    _ResultCode_name[803:828],
+	67:    _ResultCode_name[828:849],
+	68:    _ResultCode_name[849:873],
+	69:    _ResultCode_name[873:904],
+	71:    _ResultCode_name[904:929],
+	80:    _ResultCode_name[929:940],
+	118:   _ResultCode_name[940:954],
+	119:   _ResultCode_name[954:975],
+	120:   _ResultCode_name[975:988],
+	121:   _ResultCode_name[988:1006],
+	4096:  _ResultCode_name[1006:1031],
+	16654: _ResultCode_name[1031:1048],
+}
 
 func (i ResultCode) String() string {
-	switch {
-	case 0 <= i && i <= 8:
-		return _ResultCode_name_0[_ResultCode_index_0[i]:_ResultCode_index_0[i+1]]
-	case 10 <= i && i <= 14:
-		i -= 10
-		return _ResultCode_name_1[_ResultCode_index_1[i]:_ResultCode_index_1[i+1]]
-	case 16 <= i && i <= 21:
-		i -= 16
-		return _ResultCode_name_2[_ResultCode_index_2[i]:_ResultCode_index_2[i+1]]
-	case 32 <= i && i <= 34:
-		i -= 32
-		return _ResultCode_name_3[_ResultCode_index_3[i]:_ResultCode_index_3[i+1]]
-	case i == 36:
-		return _ResultCode_name_4
-	case 48 <= i && i <= 54:
-		i -= 48
-		return _ResultCode_name_5[_ResultCode_index_5[i]:_ResultCode_index_5[i+1]]
-	case 64 <= i && i <= 69:
-		i -= 64
-		return _ResultCode_name_6[_ResultCode_index_6[i]:_ResultCode_index_6[i+1]]
-	case i == 71:
-		return _ResultCode_name_7
-	case i == 80:
-		return _ResultCode_name_8
-	default:
-		return fmt.Sprintf("ResultCode(%d)", i)
+	if str, ok := _ResultCode_map[i]; ok {
+		return str
 	}
+	return fmt.Sprintf("ResultCode(%d)", i)
 }
@@ -0,0 +1,225 @@
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/eaciit/asn1-ber"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// SASLMechanism is a SASL mechanism [https://tools.ietf.org/html/rfc4422] of
+// SASLBind, mechanisms are added by implementing it without changes to the
+// bind itself.
+type SASLMechanism interface {
+	// Name of the mechanism as registered with IANA, e.g. "EXTERNAL"
+	Name() string
+	// Start begins a new exchange and returns the initial response, nil to
+	// send none. Start is called again for a rebind after a reconnect.
+	Start(server *SASLServerInfo) ([]byte, error)
+	// Next returns the response to the challenge of the server. more is
+	// false for the successful result with its credentials, nil if it has
+	// none, e.g. for the mechanism to verify the server; the response is not
+	// sent then.
+	Next(challenge []byte, more bool) ([]byte, error)
+}
+
+// SASLCleartextMechanism is a SASLMechanism sending its secret in the clear,
+// e.g. a bearer token or a PLAIN password, refused without TLS with
+// RequireTLS like a simple bind.
+type SASLCleartextMechanism interface {
+	SASLMechanism
+	// Cleartext returns whether the initial response carries the secret
+	Cleartext() bool
+}
+
+// SASLServerInfo describes the connection a SASLMechanism authenticates.
+type SASLServerInfo struct {
+	// Host and Port of Addr, e.g. for the service principal of GSSAPI, Port
+	// is 0 if Addr has none
+	Host string
+	Port int
+	// State of TLS, nil without TLS, e.g. for channel bindings
+	TLS *tls.ConnectionState
+}
+
+// AttributeSupportedSASLMechanisms is the attribute of the RootDSE listing
+// the SASL mechanisms of the server.
+const AttributeSupportedSASLMechanisms = "supportedSASLMechanisms"
+
+// SASLBind binds with mechanism, looping over the challenges of the server
+// until the bind completed, and returns the result including the response
+// controls, also if the bind failed.
+func (l *Connection) SASLBind(mechanism SASLMechanism) (*LDAPResult, error) {
+	return l.SASLBindContext(context.Background(), mechanism)
+}
+
+// SASLBindContext is SASLBind with ctx, see SimpleBindContext.
+func (l *Connection) SASLBindContext(ctx context.Context, mechanism SASLMechanism) (*LDAPResult, error) {
+	result, err := l.saslBind(ctx, mechanism)
+	if err == nil {
+		l.setLastBind(func(ctx context.Context) error {
+			_, err := l.SASLBindContext(ctx, mechanism)
+			return err
+		})
+	}
+	return result, err
+}
+
+// NegotiateSASLBind binds with the first of mechanisms, in the order of
+// preference of the client, that the server lists in the
+// supportedSASLMechanisms of its RootDSE.
+func (l *Connection) NegotiateSASLBind(mechanisms ...SASLMechanism) (*LDAPResult, error) {
+	return l.NegotiateSASLBindContext(context.Background(), mechanisms...)
+}
+
+// NegotiateSASLBindContext is NegotiateSASLBind with ctx.
+func (l *Connection) NegotiateSASLBindContext(ctx context.Context, mechanisms ...SASLMechanism) (*LDAPResult, error) {
+	supported, err := l.SupportedSASLMechanismsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, mechanism := range mechanisms {
+		for _, name := range supported {
+			if strings.EqualFold(name, mechanism.Name()) {
+				return l.SASLBindContext(ctx, mechanism)
+			}
+		}
+	}
+	return nil, newError(ResultAuthMethodNotSupported, "None of the SASL mechanisms is supported by the server: "+strings.Join(supported, ", "))
+}
+
+// SupportedSASLMechanisms returns the supportedSASLMechanisms of the RootDSE.
+func (l *Connection) SupportedSASLMechanisms() ([]string, error) {
+	return l.SupportedSASLMechanismsContext(context.Background())
+}
+
+// SupportedSASLMechanismsContext is SupportedSASLMechanisms with ctx.
+func (l *Connection) SupportedSASLMechanismsContext(ctx context.Context) ([]string, error) {
+	searchRequest := NewSimpleSearchRequest("", ScopeBaseObject, "(objectClass=*)", []string{AttributeSupportedSASLMechanisms})
+	result, err := l.SearchContext(ctx, searchRequest)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Entries) == 0 {
+		return nil, newError(ErrorNetwork, "No RootDSE returned.")
+	}
+	return result.Entries[0].GetAttributeValues(AttributeSupportedSASLMechanisms), nil
+}
+
+// saslBind is SASLBindContext without replaying the bind after a reconnect.
+func (l *Connection) saslBind(ctx context.Context, mechanism SASLMechanism) (*LDAPResult, error) {
+	if cleartext, ok := mechanism.(SASLCleartextMechanism); ok && cleartext.Cleartext() {
+		if err := l.credentialsAllowed(); err != nil {
+			return nil, err
+		}
+	}
+	credentials, err := mechanism.Start(l.saslServerInfo())
+	if err != nil {
+		return nil, err
+	}
+	// the server starts the security layer right after the last response
+	_, layered := mechanism.(SASLLayerMechanism)
+	defer l.resumeReader()
+	for {
+		messageID, release, err := l.nextRequestID(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		packet, err := requestBuildPacket(messageID, encodeSaslBindRequest(mechanism.Name(), credentials), nil)
+		if err != nil {
+			release()
+			return nil, err
+		}
+
+		if layered {
+			l.pauseReader(messageID)
+		}
+		responsePacket, err := l.sendReqResp(ctx, messageID, packet)
+		release()
+		if err != nil {
+			return nil, err
+		}
+		result, err := decodeLDAPResult(responsePacket)
+		if err != nil {
+			return nil, err
+		}
+		challenge := serverSaslCreds(responsePacket)
+
+		switch result.ResultCode {
+		case ResultSaslBindInProgress:
+			if credentials, err = mechanism.Next(challenge, true); err != nil {
+				// the server gives up the bind with the next request
+				return result, err
+			}
+			l.resumeReader()
+		case ResultSuccess:
+			// also without credentials, so the mechanism rejects a server
+			// that didn't authenticate itself
+			if _, err := mechanism.Next(challenge, false); err != nil {
+				return result, err
+			}
+			l.startSecurityLayer(mechanism)
+			return result, nil
+		default:
+			return result, result.err()
+		}
+	}
+}
+
+// saslServerInfo returns the SASLServerInfo of the connection.
+func (l *Connection) saslServerInfo() *SASLServerInfo {
+	host, port, err := net.SplitHostPort(l.Addr)
+	if err != nil {
+		host = l.Addr
+	}
+	info := &SASLServerInfo{Host: stripZone(host)}
+	info.Port, _ = strconv.Atoi(port)
+	if conn, ok := l.currentConn().(*tls.Conn); ok {
+		state := conn.ConnectionState()
+		info.TLS = &state
+	}
+	return info
+}
+
+/*
+BindResponse ::= [APPLICATION 1] SEQUENCE {
+     COMPONENTS OF LDAPResult,
+     serverSaslCreds    [7] OCTET STRING OPTIONAL }
+*/
+
+// serverSaslCreds returns the serverSaslCreds of the BindResponse packet, nil
+// if there are none.
+func serverSaslCreds(p *ber.Packet) []byte {
+	for _, child := range p.Children[1].Children[3:] {
+		if child.ClassType == ber.ClassContext && child.Tag == 7 {
+			return child.Data.Bytes()
+		}
+	}
+	return nil
+}
+
+// externalMechanism is the SASL EXTERNAL mechanism of ExternalBind.
+type externalMechanism struct {
+	authzID string
+}
+
+func (m *externalMechanism) Name() string {
+	return "EXTERNAL"
+}
+
+func (m *externalMechanism) Start(server *SASLServerInfo) ([]byte, error) {
+	if m.authzID == "" {
+		return nil, nil
+	}
+	return []byte(m.authzID), nil
+}
+
+func (m *externalMechanism) Next(challenge []byte, more bool) ([]byte, error) {
+	if !more && len(challenge) == 0 {
+		return nil, nil
+	}
+	return nil, newError(ErrorUnknown, "Unexpected challenge of SASL EXTERNAL")
+}
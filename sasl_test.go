@@ -0,0 +1,166 @@
+package ldap
+
+import (
+	"bytes"
+	"github.com/eaciit/asn1-ber"
+	"testing"
+)
+
+// mockSASLMechanism sends responses and records the challenges it got.
+type mockSASLMechanism struct {
+	name       string
+	responses  [][]byte
+	challenges [][]byte
+	final      []byte
+	finished   bool
+}
+
+func (m *mockSASLMechanism) Name() string {
+	return m.name
+}
+
+func (m *mockSASLMechanism) Start(server *SASLServerInfo) ([]byte, error) {
+	m.challenges = nil
+	return m.responses[0], nil
+}
+
+func (m *mockSASLMechanism) Next(challenge []byte, more bool) ([]byte, error) {
+	if !more {
+		m.final, m.finished = challenge, true
+		return nil, nil
+	}
+	m.challenges = append(m.challenges, challenge)
+	return m.responses[len(m.challenges)], nil
+}
+
+// mockSASLBindResponse returns a BindResponse with serverSaslCreds.
+func mockSASLBindResponse(resultCode ResultCode, serverSaslCreds string) *ber.Packet {
+	response := mockLDAPResult(ApplicationBindResponse, resultCode, "")
+	response.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 7, serverSaslCreds, "serverSaslCreds"))
+	return response
+}
+
+// mockSASLServer answers the binds of the mechanism TEST with a challenge
+// and the second with success, without credentials for "nocreds", and the
+// RootDSE with the mechanisms.
+func mockSASLServer(t *testing.T) (*Connection, *mockServer) {
+	return newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		messageID := mockMessageID(request)
+		switch request.Children[1].Tag {
+		case ber.Tag(ApplicationSearchRequest):
+			s.respond(messageID, mockSearchEntry("", map[string][]string{AttributeSupportedSASLMechanisms: {"EXTERNAL", "TEST"}}))
+			s.respondResult(messageID, ApplicationSearchResultDone, ResultSuccess, "")
+		case ber.Tag(ApplicationBindRequest):
+			credentials := request.Children[1].Children[2].Children
+			if len(credentials) < 2 || packetString(credentials[0]) != "TEST" {
+				s.respondResult(messageID, ApplicationBindResponse, ResultAuthMethodNotSupported, "")
+			} else if packetString(credentials[1]) == "hello" {
+				s.respond(messageID, mockSASLBindResponse(ResultSaslBindInProgress, "challenge"))
+			} else if packetString(credentials[1]) == "response" {
+				s.respond(messageID, mockSASLBindResponse(ResultSuccess, "verifier"))
+			} else if packetString(credentials[1]) == "nocreds" {
+				s.respondResult(messageID, ApplicationBindResponse, ResultSuccess, "")
+			} else {
+				s.respondResult(messageID, ApplicationBindResponse, ResultInvalidCredentials, "")
+			}
+		}
+	})
+}
+
+func TestSASLBind(t *testing.T) {
+	l, _ := mockSASLServer(t)
+	defer l.Close()
+
+	mechanism := &mockSASLMechanism{name: "TEST", responses: [][]byte{[]byte("hello"), []byte("response")}}
+	if _, err := l.SASLBind(mechanism); err != nil {
+		t.Fatal(err)
+	}
+	if len(mechanism.challenges) != 1 || !bytes.Equal(mechanism.challenges[0], []byte("challenge")) {
+		t.Errorf("Unexpected challenges %q", mechanism.challenges)
+	}
+	if !bytes.Equal(mechanism.final, []byte("verifier")) {
+		t.Errorf("Expected the final credentials of the server, got %q", mechanism.final)
+	}
+
+	// the mechanism sees the success without credentials to reject it
+	nocreds := &mockSASLMechanism{name: "TEST", responses: [][]byte{[]byte("hello"), []byte("nocreds")}}
+	if _, err := l.SASLBind(nocreds); err != nil {
+		t.Fatal(err)
+	}
+	if !nocreds.finished || nocreds.final != nil {
+		t.Errorf("Expected the success without credentials, got %v %q", nocreds.finished, nocreds.final)
+	}
+
+	wrong := &mockSASLMechanism{name: "TEST", responses: [][]byte{[]byte("hello"), []byte("wrong")}}
+	result, err := l.SASLBind(wrong)
+	if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ResultInvalidCredentials || result == nil {
+		t.Errorf("Expected ResultInvalidCredentials with the result, got %v", err)
+	}
+}
+
+func TestNegotiateSASLBind(t *testing.T) {
+	l, s := mockSASLServer(t)
+	defer l.Close()
+
+	other := &mockSASLMechanism{name: "OTHER", responses: [][]byte{nil}}
+	mechanism := &mockSASLMechanism{name: "TEST", responses: [][]byte{[]byte("hello"), []byte("response")}}
+	if _, err := l.NegotiateSASLBind(other, mechanism); err != nil {
+		t.Fatal(err)
+	}
+	search := <-s.requests
+	if search.Children[1].Tag != ber.Tag(ApplicationSearchRequest) || packetString(search.Children[1].Children[0]) != "" {
+		t.Errorf("Expected a search of the RootDSE, got %v", search.Children[1])
+	}
+	if bind := <-s.requests; packetString(bind.Children[1].Children[2].Children[0]) != "TEST" {
+		t.Errorf("Expected a bind with the supported mechanism, got %v", bind.Children[1])
+	}
+
+	_, err := l.NegotiateSASLBind(other)
+	if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ResultAuthMethodNotSupported {
+		t.Errorf("Expected ResultAuthMethodNotSupported, got %v", err)
+	}
+}
+
+// mockLayerMechanism negotiated the mockSecurityLayer.
+type mockLayerMechanism struct {
+	mockSASLMechanism
+}
+
+func (m *mockLayerMechanism) SecurityLayer() SASLSecurityLayer {
+	return mockSecurityLayer{}
+}
+
+// mockSecurityLayer wraps messages as they are.
+type mockSecurityLayer struct{}
+
+func (mockSecurityLayer) Wrap(message []byte) ([]byte, error) {
+	return message, nil
+}
+
+func (mockSecurityLayer) Unwrap(buffer []byte) ([]byte, error) {
+	return buffer, nil
+}
+
+func (mockSecurityLayer) MaxMessageSize() int {
+	return 0
+}
+
+func TestStartSecurityLayerWhileWriting(t *testing.T) {
+	l, _ := newMockConnection(t, nil)
+	defer l.Close()
+
+	// the writer and encrypted run while the layer replaces the connection
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			l.Abandon(int64(i + 1))
+			l.encrypted()
+		}
+	}()
+	l.startSecurityLayer(&mockLayerMechanism{})
+	<-done
+	if _, ok := l.currentConn().(*saslConn); !ok {
+		t.Error("Expected the security layer to be installed")
+	}
+}
@@ -0,0 +1,136 @@
+package ldap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+// SASL security layers negotiated by GSSAPIMechanism and
+// DigestMD5Mechanism, the LDAP messages after the bind are signed with
+// SASLLayerIntegrity and also encrypted with SASLLayerConfidentiality
+const (
+	SASLLayerNone            = 1
+	SASLLayerIntegrity       = 2
+	SASLLayerConfidentiality = 4
+)
+
+// saslMaxBuffer is the largest buffer received, and announced to servers.
+const saslMaxBuffer = 1<<24 - 1
+
+// SASLSecurityLayer protects the LDAP messages after a SASL bind
+// negotiated integrity or confidentiality [https://tools.ietf.org/html/rfc4422#section-3.7].
+type SASLSecurityLayer interface {
+	// Wrap returns the buffer sent for message.
+	Wrap(message []byte) ([]byte, error)
+	// Unwrap returns the message of a buffer received.
+	Unwrap(buffer []byte) ([]byte, error)
+	// MaxMessageSize is the size of the largest message wrapped into a
+	// buffer the server accepts, longer messages are split.
+	MaxMessageSize() int
+}
+
+// SASLLayerMechanism is a SASLMechanism negotiating security layers.
+type SASLLayerMechanism interface {
+	SASLMechanism
+	// SecurityLayer returns the layer negotiated by the last bind, nil if
+	// the bind negotiated none.
+	SecurityLayer() SASLSecurityLayer
+}
+
+// startSecurityLayer installs the security layer negotiated by mechanism,
+// replacing the one of an earlier bind. The reader has to be paused.
+func (l *Connection) startSecurityLayer(mechanism SASLMechanism) {
+	layered, ok := mechanism.(SASLLayerMechanism)
+	if !ok {
+		return
+	}
+	layer := layered.SecurityLayer()
+	if layer == nil {
+		return
+	}
+	l.connLock.Lock()
+	defer l.connLock.Unlock()
+	conn := l.conn
+	if sasl, ok := conn.(*saslConn); ok {
+		conn = sasl.Conn
+	}
+	l.conn = &saslConn{Conn: conn, layer: layer}
+}
+
+// encryptingLayer is a SASLSecurityLayer reporting whether it encrypts the
+// messages besides protecting their integrity.
+type encryptingLayer interface {
+	encrypts() bool
+}
+
+// encrypted returns whether the messages of the connection are encrypted,
+// with TLS or a SASL security layer.
+func (l *Connection) encrypted() bool {
+	if l.tlsActive() {
+		return true
+	}
+	sasl, ok := l.currentConn().(*saslConn)
+	if !ok {
+		return false
+	}
+	layer, ok := sasl.layer.(encryptingLayer)
+	return ok && layer.encrypts()
+}
+
+// saslConn frames the LDAP messages in the buffers of a security layer, a
+// four-octet big endian length followed by the wrapped message.
+type saslConn struct {
+	net.Conn
+	layer SASLSecurityLayer
+
+	unwrapped []byte
+}
+
+func (c *saslConn) Read(b []byte) (int, error) {
+	for len(c.unwrapped) == 0 {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(c.Conn, header); err != nil {
+			return 0, err
+		}
+		size := binary.BigEndian.Uint32(header)
+		if size > saslMaxBuffer {
+			return 0, newError(ErrorDecoding, fmt.Sprintf("SASL buffer of %d bytes exceeds the maximum size", size))
+		}
+		buffer := make([]byte, size)
+		if _, err := io.ReadFull(c.Conn, buffer); err != nil {
+			return 0, err
+		}
+		message, err := c.layer.Unwrap(buffer)
+		if err != nil {
+			return 0, err
+		}
+		c.unwrapped = message
+	}
+	n := copy(b, c.unwrapped)
+	c.unwrapped = c.unwrapped[n:]
+	return n, nil
+}
+
+func (c *saslConn) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		size := len(b)
+		if max := c.layer.MaxMessageSize(); max > 0 && size > max {
+			size = max
+		}
+		buffer, err := c.layer.Wrap(b[:size])
+		if err != nil {
+			return written, err
+		}
+		framed := make([]byte, 4, 4+len(buffer))
+		binary.BigEndian.PutUint32(framed, uint32(len(buffer)))
+		if _, err := c.Conn.Write(append(framed, buffer...)); err != nil {
+			return written, err
+		}
+		written += size
+		b = b[size:]
+	}
+	return written, nil
+}
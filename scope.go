@@ -1,9 +1,51 @@
 package ldap
 
+import (
+	"strings"
+)
+
+// Scope is the scope of a search [https://tools.ietf.org/html/rfc4511#section-4.5.1.2],
+// see SearchRequest.Scope.
 type Scope uint8
 
 const (
-	ScopeBaseObject   Scope = 0
-	ScopeSingleLevel  Scope = 1
+	// only the base object itself
+	ScopeBaseObject Scope = 0
+	// the immediate subordinates of the base object, not the base object
+	ScopeSingleLevel Scope = 1
+	// the base object and all its subordinates
 	ScopeWholeSubtree Scope = 2
 )
+
+// ParseScope returns the Scope of the names of the -s option of ldapsearch,
+// "base", "one" or "sub", also in the long forms "baseObject",
+// "oneLevel" or "singleLevel" and "subtree" or "wholeSubtree". LDAP URLs
+// only have the short forms.
+func ParseScope(name string) (Scope, error) {
+	if scope, ok := parseURLScope(name); ok {
+		return scope, nil
+	}
+	switch strings.ToLower(name) {
+	case "baseobject":
+		return ScopeBaseObject, nil
+	case "onelevel", "singlelevel":
+		return ScopeSingleLevel, nil
+	case "subtree", "wholesubtree":
+		return ScopeWholeSubtree, nil
+	}
+	return 0, newError(ErrorInvalidArgument, "Unknown search scope "+name)
+}
+
+// parseURLScope returns the Scope of the scope of an LDAP URL, "base",
+// "one" or "sub", and false for other names.
+func parseURLScope(name string) (Scope, bool) {
+	switch strings.ToLower(name) {
+	case "base":
+		return ScopeBaseObject, true
+	case "one":
+		return ScopeSingleLevel, true
+	case "sub":
+		return ScopeWholeSubtree, true
+	}
+	return 0, false
+}
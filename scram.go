@@ -0,0 +1,194 @@
+package ldap
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// SCRAM mechanisms [https://tools.ietf.org/html/rfc5802], the -PLUS variants
+// bind the authentication to the TLS channel
+const (
+	SCRAMSHA1       = "SCRAM-SHA-1"
+	SCRAMSHA1Plus   = "SCRAM-SHA-1-PLUS"
+	SCRAMSHA256     = "SCRAM-SHA-256"
+	SCRAMSHA256Plus = "SCRAM-SHA-256-PLUS"
+)
+
+// SCRAMMechanism is a SASL SCRAM mechanism, the password is neither sent nor
+// needed in clear text by the server and the server proves it knows the
+// salted password. The username and password are used as given, without
+// SASLprep.
+type SCRAMMechanism struct {
+	// Mechanism, e.g. SCRAMSHA256; over TLS servers offering the -PLUS
+	// variant reject the one without
+	Mechanism string
+	Username  string
+	Password  string
+	// AuthzID to authorize as, empty for the identity of Username
+	AuthzID string
+	// ChannelBinding type of the -PLUS variants, e.g.
+	// ChannelBindingTLSServerEndPoint; empty for tls-exporter with TLS 1.3
+	// and tls-unique before
+	ChannelBinding string
+
+	newHash         func() hash.Hash
+	gs2Header       string
+	channelBinding  []byte
+	nonce           string
+	clientFirstBare string
+	serverSignature []byte
+	verified        bool
+}
+
+// NewSCRAMMechanism returns the SCRAM mechanism of username with password,
+// e.g. SCRAMSHA256.
+func NewSCRAMMechanism(mechanism, username, password string) *SCRAMMechanism {
+	return &SCRAMMechanism{Mechanism: mechanism, Username: username, Password: password}
+}
+
+// SCRAMBind binds with the SCRAM mechanism as username with password.
+func (l *Connection) SCRAMBind(mechanism, username, password string) (*LDAPResult, error) {
+	return l.SCRAMBindContext(context.Background(), mechanism, username, password)
+}
+
+// SCRAMBindContext is SCRAMBind with ctx.
+func (l *Connection) SCRAMBindContext(ctx context.Context, mechanism, username, password string) (*LDAPResult, error) {
+	return l.SASLBindContext(ctx, NewSCRAMMechanism(mechanism, username, password))
+}
+
+func (m *SCRAMMechanism) Name() string {
+	return m.Mechanism
+}
+
+func (m *SCRAMMechanism) Start(server *SASLServerInfo) ([]byte, error) {
+	switch m.Mechanism {
+	case SCRAMSHA1, SCRAMSHA1Plus:
+		m.newHash = sha1.New
+	case SCRAMSHA256, SCRAMSHA256Plus:
+		m.newHash = sha256.New
+	default:
+		return nil, newError(ErrorInvalidArgument, "SCRAM: unsupported mechanism "+m.Mechanism)
+	}
+	authzID := ""
+	if m.AuthzID != "" {
+		authzID = "a=" + gs2Name(m.AuthzID)
+	}
+	// "y" tells the server that channel binding would have been used over
+	// TLS if it offered the -PLUS variant, detecting stripped mechanisms
+	flag := "n"
+	if server.TLS != nil {
+		flag = "y"
+	}
+	m.gs2Header, m.channelBinding = flag+","+authzID+",", nil
+	if strings.HasSuffix(m.Mechanism, "-PLUS") {
+		cbType, data, err := server.ChannelBinding(m.ChannelBinding)
+		if err != nil {
+			return nil, err
+		}
+		m.gs2Header, m.channelBinding = "p="+cbType+","+authzID+",", data
+	}
+
+	random := make([]byte, 18)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	m.nonce = base64.StdEncoding.EncodeToString(random)
+	m.clientFirstBare = "n=" + gs2Name(m.Username) + ",r=" + m.nonce
+	m.serverSignature, m.verified = nil, false
+	return []byte(m.gs2Header + m.clientFirstBare), nil
+}
+
+func (m *SCRAMMechanism) Next(challenge []byte, more bool) ([]byte, error) {
+	attributes := scramAttributes(string(challenge))
+	if e, ok := attributes["e"]; ok {
+		return nil, newError(ResultInvalidCredentials, "SCRAM: "+e)
+	}
+	if v, ok := attributes["v"]; ok {
+		// the server-final-message, arriving as challenge or along with
+		// the result
+		verifier, err := base64.StdEncoding.DecodeString(v)
+		if err != nil || m.serverSignature == nil || !hmac.Equal(verifier, m.serverSignature) {
+			return nil, newError(ErrorUnknown, "SCRAM: the server failed to authenticate")
+		}
+		m.verified = true
+		if more {
+			return []byte{}, nil
+		}
+		return nil, nil
+	}
+	if !more {
+		if m.verified {
+			return nil, nil
+		}
+		return nil, newError(ErrorUnknown, "SCRAM: the server didn't authenticate")
+	}
+	if m.serverSignature != nil {
+		return nil, newError(ErrorUnknown, "SCRAM: unexpected second server-first-message")
+	}
+
+	nonce := attributes["r"]
+	if !strings.HasPrefix(nonce, m.nonce) || len(nonce) == len(m.nonce) {
+		return nil, newError(ErrorUnknown, "SCRAM: invalid nonce of the server")
+	}
+	salt, err := base64.StdEncoding.DecodeString(attributes["s"])
+	if err != nil || len(salt) == 0 {
+		return nil, newError(ErrorDecoding, "SCRAM: invalid salt")
+	}
+	iterations, err := strconv.Atoi(attributes["i"])
+	if err != nil || iterations <= 0 || iterations > 1<<24 {
+		return nil, newError(ErrorDecoding, "SCRAM: invalid iteration count")
+	}
+	clientFinal, serverSignature := scramClientFinal(m.newHash, m.Password, salt, iterations,
+		m.clientFirstBare, string(challenge), append([]byte(m.gs2Header), m.channelBinding...), nonce)
+	m.serverSignature = serverSignature
+	return []byte(clientFinal), nil
+}
+
+// scramClientFinal returns the client-final-message to the server-first-message
+// and the ServerSignature expected in the server-final-message.
+func scramClientFinal(newHash func() hash.Hash, password string, salt []byte, iterations int, clientFirstBare, serverFirst string, channelBinding []byte, nonce string) (string, []byte) {
+	saltedPassword := pbkdf2(newHash, []byte(password), salt, iterations, newHash().Size())
+	withoutProof := "c=" + base64.StdEncoding.EncodeToString(channelBinding) + ",r=" + nonce
+	authMessage := []byte(clientFirstBare + "," + serverFirst + "," + withoutProof)
+
+	clientKey := scramHMAC(newHash, saltedPassword, []byte("Client Key"))
+	storedKey := newHash()
+	storedKey.Write(clientKey)
+	proof := scramHMAC(newHash, storedKey.Sum(nil), authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+	serverKey := scramHMAC(newHash, saltedPassword, []byte("Server Key"))
+	return withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof), scramHMAC(newHash, serverKey, authMessage)
+}
+
+func scramHMAC(newHash func() hash.Hash, key, data []byte) []byte {
+	mac := hmac.New(newHash, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// gs2Name returns name as saslname of a GS2 header, escaping "," and "=".
+func gs2Name(name string) string {
+	return strings.NewReplacer("=", "=3D", ",", "=2C").Replace(name)
+}
+
+// scramAttributes returns the attributes of a message of the server.
+func scramAttributes(message string) map[string]string {
+	attributes := make(map[string]string)
+	for _, attribute := range strings.Split(message, ",") {
+		if len(attribute) >= 2 && attribute[1] == '=' {
+			if _, ok := attributes[attribute[:1]]; !ok {
+				attributes[attribute[:1]] = attribute[2:]
+			}
+		}
+	}
+	return attributes
+}
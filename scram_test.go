@@ -0,0 +1,141 @@
+package ldap
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"github.com/eaciit/asn1-ber"
+	"hash"
+	"strings"
+	"testing"
+)
+
+func TestSCRAMClientFinal(t *testing.T) {
+	for _, test := range []struct {
+		newHash                  func() hash.Hash
+		clientNonce, serverFirst string
+		clientFinal, serverFinal string
+	}{
+		// RFC 5802 section 5
+		{sha1.New, "fyko+d2lbbFgONRv9qkxdawL",
+			"r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,s=QSXCR+Q6sek8bf92,i=4096",
+			"c=biws,r=fyko+d2lbbFgONRv9qkxdawL3rfcNHYJY1ZVvWVs7j,p=v0X8v3Bz2T0CJGbJQyF0X+HI4Ts=",
+			"v=rmF9pqV8S7suAoZWja4dJRkFsKQ="},
+		// RFC 7677 section 3
+		{sha256.New, "rOprNGfwEbeRWgbNEkqO",
+			"r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096",
+			"c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=",
+			"v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="},
+	} {
+		attributes := scramAttributes(test.serverFirst)
+		salt, _ := base64.StdEncoding.DecodeString(attributes["s"])
+		clientFinal, serverSignature := scramClientFinal(test.newHash, "pencil", salt, 4096,
+			"n=user,r="+test.clientNonce, test.serverFirst, []byte("n,,"), attributes["r"])
+		if clientFinal != test.clientFinal {
+			t.Errorf("Expected %s, got %s", test.clientFinal, clientFinal)
+		}
+		if serverFinal := "v=" + base64.StdEncoding.EncodeToString(serverSignature); serverFinal != test.serverFinal {
+			t.Errorf("Expected %s, got %s", test.serverFinal, serverFinal)
+		}
+	}
+}
+
+// mockSCRAMServer answers SCRAM-SHA-256 binds of user with the password
+// pencil, sending a wrong ServerSignature with badVerifier and none with
+// noVerifier.
+func mockSCRAMServer(t *testing.T, badVerifier, noVerifier bool) *Connection {
+	salt := []byte("salt of the user")
+	var clientFirstBare, serverFirst string
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		messageID := mockMessageID(request)
+		sasl := request.Children[1].Children[2]
+		if packetString(sasl.Children[0]) != SCRAMSHA256 || len(sasl.Children) != 2 {
+			s.respondResult(messageID, ApplicationBindResponse, ResultAuthMethodNotSupported, "")
+			return
+		}
+		message := packetString(sasl.Children[1])
+		if strings.HasPrefix(message, "n,,") {
+			clientFirstBare = message[3:]
+			serverFirst = "r=" + scramAttributes(clientFirstBare)["r"] + "server,s=" + base64.StdEncoding.EncodeToString(salt) + ",i=4096"
+			s.respond(messageID, mockSASLBindResponse(ResultSaslBindInProgress, serverFirst))
+			return
+		}
+		expected, serverSignature := scramClientFinal(sha256.New, "pencil", salt, 4096, clientFirstBare, serverFirst, []byte("n,,"), scramAttributes(serverFirst)["r"])
+		if message != expected {
+			s.respond(messageID, mockSASLBindResponse(ResultInvalidCredentials, "e=invalid-proof"))
+			return
+		}
+		if noVerifier {
+			s.respondResult(messageID, ApplicationBindResponse, ResultSuccess, "")
+			return
+		}
+		if badVerifier {
+			serverSignature[0] ^= 1
+		}
+		s.respond(messageID, mockSASLBindResponse(ResultSuccess, "v="+base64.StdEncoding.EncodeToString(serverSignature)))
+	})
+	return l
+}
+
+func TestSCRAMBind(t *testing.T) {
+	l := mockSCRAMServer(t, false, false)
+	defer l.Close()
+	if _, err := l.SCRAMBind(SCRAMSHA256, "user", "pencil"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := l.SCRAMBind(SCRAMSHA256, "user", "wrong")
+	if err == nil || result == nil || result.ResultCode != ResultInvalidCredentials {
+		t.Errorf("Expected invalid credentials, got %v", err)
+	}
+
+	l2 := mockSCRAMServer(t, true, false)
+	defer l2.Close()
+	if _, err := l2.SCRAMBind(SCRAMSHA256, "user", "pencil"); err == nil {
+		t.Error("Expected the verification of the server to fail")
+	}
+
+	// a success without the server-final-message doesn't authenticate the
+	// server
+	l3 := mockSCRAMServer(t, false, true)
+	defer l3.Close()
+	if _, err := l3.SCRAMBind(SCRAMSHA256, "user", "pencil"); err == nil {
+		t.Error("Expected an error without the server-final-message")
+	}
+}
+
+func TestSCRAMChannelBinding(t *testing.T) {
+	m := &SCRAMMechanism{Mechanism: SCRAMSHA1Plus, Username: "a=b,c", Password: "pencil"}
+	if _, err := m.Start(&SASLServerInfo{Host: "localhost"}); err == nil {
+		t.Error("Expected an error for channel binding without TLS")
+	}
+	first, err := m.Start(&SASLServerInfo{Host: "localhost", TLS: &tls.ConnectionState{Version: tls.VersionTLS12, TLSUnique: []byte("unique")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(first), "p=tls-unique,,n=a=3Db=2Cc,r=") {
+		t.Errorf("Unexpected client-first-message %s", first)
+	}
+	final, err := m.Next([]byte("r="+m.nonce+"server,s=c2FsdA==,i=1"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cbind := base64.StdEncoding.EncodeToString([]byte("p=tls-unique,,unique")); !strings.HasPrefix(string(final), "c="+cbind+",") {
+		t.Errorf("Unexpected client-final-message %s", final)
+	}
+
+	// without -PLUS over TLS the client supports channel binding
+	m.Mechanism = SCRAMSHA1
+	if first, err = m.Start(&SASLServerInfo{Host: "localhost", TLS: &tls.ConnectionState{Version: tls.VersionTLS12}}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(first), "y,,n=") {
+		t.Errorf("Unexpected client-first-message %s", first)
+	}
+	if final, err = m.Next([]byte("r="+m.nonce+"server,s=c2FsdA==,i=1"), true); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(final), "c=eSws,") {
+		t.Errorf("Unexpected client-final-message %s", final)
+	}
+}
@@ -1,10 +1,11 @@
 package ldap
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/eaciit/asn1-ber"
-	"log"
+	"strings"
 )
 
 type SearchResult struct {
@@ -18,6 +19,7 @@ type DiscreteSearchResult struct {
 	Entry            *Entry
 	Referrals        []string
 	Controls         []Control
+	Intermediate     *IntermediateResponse
 }
 
 type ConnectionInfo struct {
@@ -31,15 +33,22 @@ type SearchResultHandler interface {
 
 // SearchRequest passed to Search functions.
 type SearchRequest struct {
-	BaseDN       string
-	Scope        Scope
+	BaseDN string
+	Scope  Scope
+	// DerefAliases of the search, NeverDerefAliases by default
 	DerefAliases Deref
-	SizeLimit    int
-	TimeLimit    int
-	TypesOnly    bool
-	Filter       string
-	Attributes   []string
-	Controls     []Control
+	// SizeLimit in entries and TimeLimit in seconds of the search, 0 for
+	// none. The entries returned until a limit was exceeded are kept, with
+	// an error whose LimitExceeded holds.
+	SizeLimit int
+	TimeLimit int
+	// TypesOnly returns the attribute names without the values, e.g. for an
+	// inventory of the attributes without transferring large values like
+	// jpegPhoto, see Entry.AttributeNames
+	TypesOnly  bool
+	Filter     string
+	Attributes []string
+	Controls   []Control
 }
 
 //NewSimpleSearchRequest only requires four parameters and defaults the
@@ -78,27 +87,33 @@ func NewSearchRequest(BaseDN string, Scope Scope, DerefAliases Deref, SizeLimit,
 	}
 }
 
-//SearchWithPaging adds a paging control to the the searchRequest, with a size of pagingSize.
-//It combines all the paged results into the returned SearchResult. It is a helper function for
-//use with servers that require paging for certain result sizes (AD?).
+//SearchWithPaging searches with a paging control (RFC 2696) of pagingSize and
+//follows the cookies returned by the server until the last page, so servers with
+//a size limit per search (AD: 1000) return the whole result. It combines all the
+//paged results into the returned SearchResult, on an error the pages received so
+//far are returned along with it. A paging control already in searchRequest is
+//replaced for the search, searchRequest itself is not modified.
 //
 //It is NOT an efficent way to process huge result sets i.e. it doesn't process on a pageSize
 //number of entries, it returns the combined result.
 func (l *Connection) SearchWithPaging(searchRequest *SearchRequest, pagingSize uint32) (*SearchResult, error) {
 	pagingControl := NewControlPaging(pagingSize)
-	searchRequest.AddControl(pagingControl)
-	allResults := new(SearchResult)
+	pageRequest := searchRequest.withControl(pagingControl)
+
+	allResults := &SearchResult{
+		Entries:   make([]*Entry, 0),
+		Referrals: make([]string, 0),
+		Controls:  make([]Control, 0)}
 
 	for i := 0; ; i++ {
 		searchResult := new(SearchResult)
-		err := l.SearchWithHandler(searchRequest, searchResult, nil)
-		if err != nil {
-			return allResults, err
-		}
-
+		err := l.SearchWithHandler(pageRequest, searchResult, nil)
 		allResults.Entries = append(allResults.Entries, searchResult.Entries...)
 		allResults.Referrals = append(allResults.Referrals, searchResult.Referrals...)
 		allResults.Controls = append(allResults.Controls, searchResult.Controls...)
+		if err != nil {
+			return allResults, err
+		}
 
 		_, pagingResponsePacket := FindControl(searchResult.Controls, ControlTypePaging)
 		// If initial result and no paging control then server doesn't support paging
@@ -122,8 +137,88 @@ func (l *Connection) SearchWithPaging(searchRequest *SearchRequest, pagingSize u
 	return allResults, nil
 }
 
+//DirSync returns the changes since cookie using the Active Directory DirSync
+//control, an empty cookie returns all entries of searchRequest. It keeps on
+//searching while the server has more results and returns the combined result
+//with the cookie to pass to the next DirSync. flags are the DirSync* flags.
+func (l *Connection) DirSync(searchRequest *SearchRequest, flags, maxBytes int64, cookie []byte) (*SearchResult, []byte, error) {
+	dirSyncControl := NewControlDirSyncRequest(flags, maxBytes, cookie)
+	result, err := l.ResumeDirSync(searchRequest, dirSyncControl)
+	return result, dirSyncControl.Cookie, err
+}
+
+// ResumeDirSync is DirSync with the flags and cookie of dirSyncControl, which
+// is updated with the new cookie. The control can be stored with MarshalBinary
+// for the next synchronization.
+func (l *Connection) ResumeDirSync(searchRequest *SearchRequest, dirSyncControl *ControlDirSyncRequest) (*SearchResult, error) {
+	dirSyncRequest := searchRequest.withControl(dirSyncControl)
+
+	allResults := &SearchResult{
+		Entries:   make([]*Entry, 0),
+		Referrals: make([]string, 0),
+		Controls:  make([]Control, 0)}
+
+	for {
+		searchResult := new(SearchResult)
+		err := l.SearchWithHandler(dirSyncRequest, searchResult, nil)
+		allResults.Entries = append(allResults.Entries, searchResult.Entries...)
+		allResults.Referrals = append(allResults.Referrals, searchResult.Referrals...)
+		allResults.Controls = append(allResults.Controls, searchResult.Controls...)
+		if err != nil {
+			return allResults, err
+		}
+
+		_, control := FindControl(searchResult.Controls, ControlTypeDirSync)
+		response, ok := control.(*ControlDirSyncResponse)
+		if !ok {
+			return allResults, newError(ErrorMissingControl, "Expected DirSync Control, it was not found.")
+		}
+		dirSyncControl.SetCookie(response.Cookie)
+		if !response.MoreResults {
+			break
+		}
+	}
+	return allResults, nil
+}
+
+// SearchPage returns the next page of a paged search with pagingControl and
+// sets the cookie of the server in pagingControl, the search is complete when
+// the cookie is empty. Saved with MarshalBinary, pagingControl continues the
+// search after a restart, also on a new connection if the server accepts the
+// cookie there (Active Directory does, OpenLDAP only on the same connection).
+func (l *Connection) SearchPage(searchRequest *SearchRequest, pagingControl *ControlPaging) (*SearchResult, error) {
+	result, err := l.Search(searchRequest.withControl(pagingControl))
+	if err != nil {
+		return result, err
+	}
+	_, control := FindControl(result.Controls, ControlTypePaging)
+	response, ok := control.(*ControlPaging)
+	if !ok {
+		// paging unsupported, the result is complete
+		pagingControl.SetCookie(nil)
+		return result, nil
+	}
+	pagingControl.SetCookie(response.Cookie)
+	return result, nil
+}
+
+// withControl returns a copy of the request with control replacing the
+// controls of the same type.
+func (req *SearchRequest) withControl(control Control) *SearchRequest {
+	copied := *req
+	copied.Controls = make([]Control, 0, len(req.Controls)+1)
+	for _, c := range req.Controls {
+		if c.OID() != control.OID() {
+			copied.Controls = append(copied.Controls, c)
+		}
+	}
+	copied.Controls = append(copied.Controls, control)
+	return &copied
+}
+
 //ProcessDiscreteResult handles an individual result from a server. Member of the
-//SearchResultHandler interface. Results are placed into a SearchResult.
+//SearchResultHandler interface. Results are placed into a SearchResult, intermediate
+//responses are ignored.
 func (sr *SearchResult) ProcessDiscreteResult(dsr *DiscreteSearchResult, connInfo *ConnectionInfo) (stopProcessing bool, err error) {
 	switch dsr.SearchResultType {
 	case SearchResultEntry:
@@ -140,23 +235,58 @@ func (sr *SearchResult) ProcessDiscreteResult(dsr *DiscreteSearchResult, connInf
 
 //Search is a blocking search. nil error on success.
 func (l *Connection) Search(searchRequest *SearchRequest) (*SearchResult, error) {
+	return l.SearchContext(context.Background(), searchRequest)
+}
+
+// SearchContext is Search with ctx, the search is abandoned when ctx is done
+// before the search result done arrived. The entries received so far are
+// returned with the error, an ErrorTimeout *Error once the deadline passed.
+func (l *Connection) SearchContext(ctx context.Context, searchRequest *SearchRequest) (*SearchResult, error) {
 	result := &SearchResult{
 		Entries:   make([]*Entry, 0),
 		Referrals: make([]string, 0),
 		Controls:  make([]Control, 0)}
 
-	err := l.SearchWithHandler(searchRequest, result, nil)
+	err := l.SearchWithHandlerContext(ctx, searchRequest, result, nil)
 	if err != nil {
 		return result, err
 	}
 	return result, nil
 }
 
+// SearchFunc passes the entries of searchRequest on to fn as they arrive,
+// without collecting them. fn returning stop or an error abandons the
+// search, e.g. once the first match was found, and the error is returned.
+func (l *Connection) SearchFunc(searchRequest *SearchRequest, fn func(entry *Entry) (stop bool, err error)) error {
+	return l.SearchFuncContext(context.Background(), searchRequest, fn)
+}
+
+// SearchFuncContext is SearchFunc with ctx, see SearchWithHandlerContext.
+func (l *Connection) SearchFuncContext(ctx context.Context, searchRequest *SearchRequest, fn func(entry *Entry) (stop bool, err error)) error {
+	return l.SearchWithHandlerContext(ctx, searchRequest, searchFuncHandler(fn), nil)
+}
+
+// searchFuncHandler is the SearchResultHandler of SearchFunc.
+type searchFuncHandler func(entry *Entry) (bool, error)
+
+func (fn searchFuncHandler) ProcessDiscreteResult(dsr *DiscreteSearchResult, connInfo *ConnectionInfo) (bool, error) {
+	if dsr.SearchResultType != SearchResultEntry {
+		return false, nil
+	}
+	return fn(dsr.Entry)
+}
+
 func encodeSearchRequest(req *SearchRequest) (*ber.Packet, error) {
+	if req.Scope > ScopeWholeSubtree {
+		return nil, newError(ErrorInvalidArgument, "Invalid search scope "+req.Scope.String())
+	}
+	if req.DerefAliases > DerefAlways {
+		return nil, newError(ErrorInvalidArgument, "Invalid alias dereferencing "+req.DerefAliases.String())
+	}
 	searchRequest := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(ApplicationSearchRequest), nil, "Search Request")
 	searchRequest.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, req.BaseDN, "Base DN"))
-	searchRequest.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, uint64(req.Scope), "Scope"))
-	searchRequest.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, uint64(req.DerefAliases), "Deref Aliases"))
+	searchRequest.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, uint64(req.Scope), "Scope: "+req.Scope.String()))
+	searchRequest.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, uint64(req.DerefAliases), "Deref Aliases: "+req.DerefAliases.String()))
 	searchRequest.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, uint64(req.SizeLimit), "Size Limit"))
 	searchRequest.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, uint64(req.TimeLimit), "Time Limit"))
 	searchRequest.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, req.TypesOnly, "Types Only"))
@@ -193,6 +323,13 @@ func decodeSearchResponse(packet *ber.Packet) (discreteSearchResult *DiscreteSea
 		if entry.DN, ok = packet.Children[1].Children[0].Value.(string); !ok {
 			return nil, NewValueMismatchError(packet.Children[1].Children[0].Value)
 		}
+		if strings.HasPrefix(entry.DN, "<") {
+			extended, err := ParseExtendedDN(entry.DN)
+			if err != nil {
+				return nil, err
+			}
+			entry.DN, entry.GUID, entry.SID = extended.DN, extended.GUID, extended.SID
+		}
 
 		for _, child := range packet.Children[1].Children[1].Children {
 			attr := new(EntryAttribute)
@@ -211,34 +348,22 @@ func decodeSearchResponse(packet *ber.Packet) (discreteSearchResult *DiscreteSea
 			entry.Attributes = append(entry.Attributes, attr)
 		}
 		discreteSearchResult.Entry = entry
-		return discreteSearchResult, nil
-	case SearchResultDone:
-		discreteSearchResult.SearchResultType = SearchResultDone
-		resultCode, result_description := getResultCode(packet)
-		if resultCode != 0 {
-			return discreteSearchResult, newError(ResultCode(resultCode), result_description)
-		}
-
 		if len(packet.Children) == 3 {
-			controls := make([]Control, 0)
-			for _, child := range packet.Children[2].Children {
-				controlOid, ok := child.Children[0].Value.(string)
-				if !ok {
-					return nil, NewValueMismatchError(child.Children[0].Value)
-				}
-
-				decodeFunc, err := ControlType(controlOid).function()
-
-				if err != nil {
-					log.Println("Couldn't decode Control : " + controlOid)
-				} else {
-					c, _ := decodeFunc(child)
-					controls = append(controls, c)
-				}
+			controls, err := decodeControls(packet.Children[2])
+			if err != nil {
+				return nil, err
 			}
 			discreteSearchResult.Controls = controls
 		}
 		return discreteSearchResult, nil
+	case SearchResultDone:
+		discreteSearchResult.SearchResultType = SearchResultDone
+		result, err := decodeLDAPResult(packet)
+		if err != nil {
+			return nil, err
+		}
+		discreteSearchResult.Controls = result.Controls
+		return discreteSearchResult, result.err()
 	case SearchResultReference:
 		discreteSearchResult.SearchResultType = SearchResultReference
 		for ref := range packet.Children[1].Children {
@@ -249,6 +374,15 @@ func decodeSearchResponse(packet *ber.Packet) (discreteSearchResult *DiscreteSea
 			}
 		}
 		return discreteSearchResult, nil
+	case SearchResultIntermediate:
+		discreteSearchResult.SearchResultType = SearchResultIntermediate
+		intermediate, err := decodeIntermediateResponse(packet)
+		if err != nil {
+			return nil, err
+		}
+		discreteSearchResult.Intermediate = intermediate
+		discreteSearchResult.Controls = intermediate.Controls
+		return discreteSearchResult, nil
 	}
 	return nil, newError(ErrorDecoding, "Couldn't decode search result.")
 }
@@ -272,10 +406,51 @@ func sendError(errChannel chan<- error, err error) error {
 func (l *Connection) SearchWithHandler(
 	searchRequest *SearchRequest, resultHandler SearchResultHandler, errorChan chan<- error,
 ) error {
-	messageID, ok := l.nextMessageID()
-	if !ok {
-		err := newError(ErrorClosing, "MessageID channel is closed.")
-		return sendError(errorChan, err)
+	return l.SearchWithHandlerContext(context.Background(), searchRequest, resultHandler, errorChan)
+}
+
+// SearchWithHandlerContext is SearchWithHandler with ctx, the search is
+// abandoned when ctx is done or the RequestTimeout passed before the search
+// result done arrived. An ErrorTimeout *Error is returned for a passed
+// deadline, context.Canceled for a canceled ctx. A search that failed
+// because the connection broke before passing on a result is retried once
+// after reconnecting, see AutoReconnect.
+func (l *Connection) SearchWithHandlerContext(
+	ctx context.Context, searchRequest *SearchRequest, resultHandler SearchResultHandler, errorChan chan<- error,
+) error {
+	ctx, cancel := l.requestContext(ctx)
+	defer cancel()
+	return sendError(errorChan, l.searchRetried(ctx, searchRequest, resultHandler))
+}
+
+// searchRetried runs the search, retried once if the connection broke before
+// it passed on a result.
+func (l *Connection) searchRetried(ctx context.Context, searchRequest *SearchRequest, resultHandler SearchResultHandler) error {
+	handler := &processedHandler{SearchResultHandler: resultHandler}
+	err := l.searchOnce(ctx, searchRequest, handler)
+	if !handler.processed && l.retryable(ctx, err) {
+		err = l.searchOnce(ctx, searchRequest, handler)
+	}
+	return err
+}
+
+func (l *Connection) searchOnce(ctx context.Context, searchRequest *SearchRequest, resultHandler SearchResultHandler) error {
+	messageID, release, err := l.nextRequestID(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return l.searchWithHandler(ctx, messageID, searchRequest, resultHandler, nil)
+}
+
+// searchWithHandler is SearchWithHandler with a messageID obtained by the
+// caller.
+func (l *Connection) searchWithHandler(
+	ctx context.Context, messageID int64, searchRequest *SearchRequest, resultHandler SearchResultHandler, errorChan chan<- error,
+) error {
+	defer l.markUsed(ctx)
+	if ctx.Err() != nil {
+		return sendError(errorChan, doneError(ctx))
 	}
 
 	searchPacket, err := encodeSearchRequest(searchRequest)
@@ -293,12 +468,12 @@ func (l *Connection) SearchWithHandler(
 		ber.PrintPacket(packet)
 	}
 
-	channel, err := l.sendMessage(packet)
+	queue, err := l.sendMessage(packet)
 
 	if err != nil {
 		return sendError(errorChan, err)
 	}
-	if channel == nil {
+	if queue == nil {
 		err = newError(ErrorNetwork, "Could not send message")
 		return sendError(errorChan, err)
 	}
@@ -309,18 +484,28 @@ func (l *Connection) SearchWithHandler(
 		MessageID: messageID,
 	}
 
+	var ok bool
 	for {
 		if l.Debug {
 			fmt.Printf("%d: waiting for response\n", messageID)
 		}
-		packet, ok = <-channel
+		packet, ok = queue.pop()
+		if ok && packet == nil {
+			select {
+			case <-queue.ready:
+				continue
+			case <-ctx.Done():
+				l.abandonOnDone(messageID)
+				return sendError(errorChan, doneError(ctx))
+			}
+		}
 
 		if l.Debug {
 			fmt.Printf("%d: got response %p, %v\n", messageID, packet, ok)
 		}
 
 		if !ok {
-			return newError(ErrorClosing, "Response Channel Closed")
+			return sendError(errorChan, l.closedQueueError(messageID))
 		}
 
 		if packet == nil {
@@ -336,21 +521,25 @@ func (l *Connection) SearchWithHandler(
 		}
 
 		discreteSearchResult, err := decodeSearchResponse(packet)
-
+		if err != nil && discreteSearchResult != nil {
+			// a failed search result done, e.g. with sizeLimitExceeded,
+			// still passes on its controls
+			resultHandler.ProcessDiscreteResult(discreteSearchResult, connectionInfo)
+		}
 		if err != nil {
 			return sendError(errorChan, err)
 		}
 
 		stop, err := resultHandler.ProcessDiscreteResult(discreteSearchResult, connectionInfo)
-		if err != nil {
+		if discreteSearchResult.SearchResultType == SearchResultDone {
 			return sendError(errorChan, err)
 		}
-
-		if discreteSearchResult.SearchResultType == SearchResultDone || stop {
-			break
+		if err != nil || stop {
+			// the server would go on sending results nobody waits for
+			l.abandonOnDone(messageID)
+			return sendError(errorChan, err)
 		}
 	}
-	return sendError(errorChan, nil)
 }
 
 func (sr *SearchResult) String() (dump string) {
@@ -0,0 +1,391 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"github.com/eaciit/asn1-ber"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func mockSearchEntry(dn string, attributes map[string][]string) *ber.Packet {
+	entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(ApplicationSearchResultEntry), nil, ApplicationSearchResultEntry.String())
+	entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "objectName"))
+	attributeList := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for name, values := range attributes {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		valueSet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		for _, value := range values {
+			valueSet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "value"))
+		}
+		attribute.AppendChild(valueSet)
+		attributeList.AppendChild(attribute)
+	}
+	entry.AppendChild(attributeList)
+	return entry
+}
+
+// mockRequestControl returns the request control of controlType decoded with
+// its decode function, nil if the request doesn't have it.
+func mockRequestControl(t *testing.T, request *ber.Packet, controlType ControlType) Control {
+	if len(request.Children) < 3 {
+		return nil
+	}
+	controls, err := decodeControls(request.Children[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, control := FindControl(controls, controlType)
+	return control
+}
+
+// mockPagedSearch returns a handler answering paged searches for entries
+// entries, the cookie is the offset of the next page.
+func mockPagedSearch(t *testing.T, entries int) func(s *mockServer, request *ber.Packet) {
+	return func(s *mockServer, request *ber.Packet) {
+		messageID := mockMessageID(request)
+		paging, ok := mockRequestControl(t, request, ControlTypePaging).(*ControlPaging)
+		if !ok {
+			s.respondResult(messageID, ApplicationSearchResultDone, ResultUnwillingToPerform, "paging required")
+			return
+		}
+		offset := 0
+		if len(paging.Cookie) > 0 {
+			offset, _ = strconv.Atoi(string(paging.Cookie))
+		}
+		next := offset + int(paging.PagingSize)
+		if next > entries {
+			next = entries
+		}
+		for i := offset; i < next; i++ {
+			s.respond(messageID, mockSearchEntry("cn=user"+strconv.Itoa(i)+",o=bigcorp", map[string][]string{"cn": {"user" + strconv.Itoa(i)}}))
+		}
+		response := NewControlPaging(0)
+		if next < entries {
+			response.SetCookie([]byte(strconv.Itoa(next)))
+		}
+		control, _ := response.Encode()
+		s.respond(messageID, mockLDAPResult(ApplicationSearchResultDone, ResultSuccess, ""), control)
+	}
+}
+
+func TestSearchWithPagingFollowsCookies(t *testing.T) {
+	const entries = 5
+	l, _ := newMockConnection(t, mockPagedSearch(t, entries))
+	defer l.Close()
+
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", []string{"cn"})
+	result, err := l.SearchWithPaging(searchRequest, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != entries {
+		t.Fatalf("Expected %d entries, got %d", entries, len(result.Entries))
+	}
+	for i, entry := range result.Entries {
+		if entry.DN != "cn=user"+strconv.Itoa(i)+",o=bigcorp" {
+			t.Errorf("Unexpected entry %d: %s", i, entry.DN)
+		}
+	}
+	if len(searchRequest.Controls) != 0 {
+		t.Errorf("SearchWithPaging modified the search request controls: %v", searchRequest.Controls)
+	}
+}
+
+func TestSearchPageResumesOnNewConnection(t *testing.T) {
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", []string{"cn"})
+	l1, _ := newMockConnection(t, mockPagedSearch(t, 5))
+	pagingControl := NewControlPaging(3)
+	result, err := l1.SearchPage(searchRequest, pagingControl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 3 || string(pagingControl.Cookie) != "3" {
+		t.Fatalf("Unexpected first page: %d entries, cookie %q", len(result.Entries), pagingControl.Cookie)
+	}
+	saved, err := pagingControl.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l1.Close()
+
+	l2, _ := newMockConnection(t, mockPagedSearch(t, 5))
+	defer l2.Close()
+	resumed := new(ControlPaging)
+	if err := resumed.UnmarshalBinary(saved); err != nil {
+		t.Fatal(err)
+	}
+	if resumed.PagingSize != 3 || string(resumed.Cookie) != "3" {
+		t.Fatalf("Unexpected restored control %s", resumed)
+	}
+	result, err = l2.SearchPage(searchRequest, resumed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 2 || result.Entries[0].DN != "cn=user3,o=bigcorp" || len(resumed.Cookie) != 0 {
+		t.Errorf("Unexpected second page: %d entries, cookie %q", len(result.Entries), resumed.Cookie)
+	}
+}
+
+func TestSearchContextDeadline(t *testing.T) {
+	// the server sends an entry but never the search result done
+	l, s := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		if request.Children[1].Tag == ber.Tag(ApplicationSearchRequest) {
+			s.respond(mockMessageID(request), mockSearchEntry("cn=user1,o=bigcorp", nil))
+		}
+	})
+	defer l.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", []string{"cn"})
+	_, err := l.SearchContext(ctx, searchRequest)
+	if lerr, ok := err.(*Error); !ok || !lerr.Timeout() || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected an ErrorTimeout wrapping context.DeadlineExceeded, got %v", err)
+	}
+
+	search := <-s.requests
+	abandon := <-s.requests
+	if abandonID, _ := packetInt64(abandon.Children[1]); abandon.Children[1].Tag != ber.Tag(ApplicationAbandonRequest) || abandonID != mockMessageID(search) {
+		t.Errorf("Expected the search to be abandoned, got %v", abandon.Children[1])
+	}
+}
+
+func mockDirSyncResponse(moreResults bool, cookie string) *ber.Packet {
+	more := 0
+	if moreResults {
+		more = 1
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "DirSyncResponseValue")
+	value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, more, "MoreResults"))
+	value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 0, "unused"))
+	value.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, cookie, "CookieServer"))
+	return mockControl(ControlTypeDirSync, value)
+}
+
+func TestDirSync(t *testing.T) {
+	var cookies []string
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		messageID := mockMessageID(request)
+		// the request control has no decode function, read the cookie directly
+		value := ber.DecodePacket(request.Children[2].Children[0].Children[2].Data.Bytes())
+		cookie := value.Children[2].Data.String()
+		cookies = append(cookies, cookie)
+		switch cookie {
+		case "":
+			s.respond(messageID, mockSearchEntry("cn=a,o=bigcorp", nil))
+			s.respond(messageID, mockLDAPResult(ApplicationSearchResultDone, ResultSuccess, ""), mockDirSyncResponse(true, "c1"))
+		case "c1":
+			s.respond(messageID, mockSearchEntry("cn=b,o=bigcorp", nil))
+			s.respond(messageID, mockLDAPResult(ApplicationSearchResultDone, ResultSuccess, ""), mockDirSyncResponse(false, "c2"))
+		}
+	})
+	defer l.Close()
+
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(objectClass=*)", nil)
+	result, cookie, err := l.DirSync(searchRequest, DirSyncObjectSecurity, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 2 || string(cookie) != "c2" {
+		t.Errorf("Unexpected DirSync result: %d entries, cookie %q", len(result.Entries), cookie)
+	}
+	if len(cookies) != 2 || cookies[1] != "c1" {
+		t.Errorf("Unexpected request cookies %q", cookies)
+	}
+}
+
+// blockingHandler closes started and waits for release on the first entry.
+type blockingHandler struct {
+	started chan struct{}
+	release chan struct{}
+	entries int
+}
+
+func (h *blockingHandler) ProcessDiscreteResult(dsr *DiscreteSearchResult, connInfo *ConnectionInfo) (bool, error) {
+	if dsr.SearchResultType == SearchResultEntry {
+		if h.entries == 0 {
+			close(h.started)
+			<-h.release
+		}
+		h.entries++
+	}
+	return false, nil
+}
+
+func TestSearchSlowHandlerPipelining(t *testing.T) {
+	// many more entries than fit into a channel buffer, followed by the
+	// response to the compare
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		switch request.Children[1].Tag {
+		case ber.Tag(ApplicationSearchRequest):
+			for i := 0; i < 50; i++ {
+				s.respond(mockMessageID(request), mockSearchEntry("cn=user"+strconv.Itoa(i)+",o=bigcorp", nil))
+			}
+			s.respondResult(mockMessageID(request), ApplicationSearchResultDone, ResultSuccess, "")
+		case ber.Tag(ApplicationCompareRequest):
+			s.respondResult(mockMessageID(request), ApplicationCompareResponse, ResultCompareTrue, "")
+		}
+	})
+	defer l.Close()
+
+	handler := &blockingHandler{started: make(chan struct{}), release: make(chan struct{})}
+	searchDone := make(chan error)
+	go func() {
+		searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", nil)
+		searchDone <- l.SearchWithHandler(searchRequest, handler, nil)
+	}()
+	<-handler.started
+
+	compareDone := make(chan error)
+	go func() {
+		_, err := l.Compare(NewCompareRequest("cn=user1,o=bigcorp", "cn", "user1"))
+		compareDone <- err
+	}()
+	select {
+	case err := <-compareDone:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The compare was blocked by the slow search")
+	}
+
+	close(handler.release)
+	if err := <-searchDone; err != nil || handler.entries != 50 {
+		t.Errorf("Search returned %v after %d entries", err, handler.entries)
+	}
+}
+
+func TestSearchFunc(t *testing.T) {
+	l, s := mockStreamServer(t, 1000)
+	defer l.Close()
+
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", nil)
+	calls := 0
+	err := l.SearchFunc(searchRequest, func(entry *Entry) (bool, error) {
+		calls++
+		return entry.DN == "cn=user2,o=bigcorp", nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("Expected the search to stop after 3 entries, got %d: %v", calls, err)
+	}
+	<-s.requests
+	if abandon := <-s.requests; abandon.Children[1].Tag != ber.Tag(ApplicationAbandonRequest) {
+		t.Errorf("Expected the search to be abandoned, got %v", abandon.Children[1])
+	}
+
+	failed := errors.New("failed")
+	if err := l.SearchFunc(searchRequest, func(entry *Entry) (bool, error) { return false, failed }); err != failed {
+		t.Errorf("Expected the error of the function, got %v", err)
+	}
+	calls = 0
+	if err := l.SearchFunc(searchRequest, func(entry *Entry) (bool, error) { calls++; return false, nil }); err != nil || calls != 1000 {
+		t.Errorf("Expected 1000 entries, got %d: %v", calls, err)
+	}
+}
+
+func TestSearchSizeLimitExceeded(t *testing.T) {
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		sizeLimit, _ := packetInt64(request.Children[1].Children[3])
+		messageID := mockMessageID(request)
+		for i := 0; i < int(sizeLimit); i++ {
+			s.respond(messageID, mockSearchEntry("cn=user"+strconv.Itoa(i)+",o=bigcorp", nil))
+		}
+		control, _ := NewControlPaging(0).Encode()
+		s.respond(messageID, mockLDAPResult(ApplicationSearchResultDone, ResultSizeLimitExceeded, ""), control)
+	})
+	defer l.Close()
+
+	searchRequest := NewSearchRequest("o=bigcorp", ScopeWholeSubtree, NeverDerefAliases, 3, 0, false, "(cn=*)", nil, nil)
+	result, err := l.Search(searchRequest)
+	if lerr, ok := err.(*Error); !ok || !lerr.LimitExceeded() {
+		t.Fatalf("Expected the size limit to be exceeded, got %v", err)
+	}
+	if len(result.Entries) != 3 || len(result.Controls) != 1 {
+		t.Errorf("Expected the 3 entries and the control returned, got %d entries, controls %v", len(result.Entries), result.Controls)
+	}
+
+	// also for a paged search
+	result, err = l.SearchWithPaging(searchRequest, 10)
+	if lerr, ok := err.(*Error); !ok || !lerr.LimitExceeded() || len(result.Entries) != 3 {
+		t.Errorf("Expected the 3 entries with the error, got %d: %v", len(result.Entries), err)
+	}
+}
+
+func TestDerefAliases(t *testing.T) {
+	for name, expected := range map[string]Deref{"never": NeverDerefAliases, "search": DerefInSearching, "finding": DerefFindingBaseObj, "Always": DerefAlways} {
+		if deref, err := ParseDeref(name); err != nil || deref != expected {
+			t.Errorf("Expected %s for %s, got %s: %v", expected, name, deref, err)
+		}
+	}
+	if _, err := ParseDeref("sometimes"); err == nil {
+		t.Error("Expected an error for an unknown dereferencing")
+	}
+
+	searchRequest := NewSearchRequest("o=bigcorp", ScopeWholeSubtree, DerefAlways, 0, 0, false, "(cn=*)", nil, nil)
+	packet, err := encodeSearchRequest(searchRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deref, _ := packetInt64(packet.Children[2]); Deref(deref) != DerefAlways {
+		t.Errorf("Expected derefAlways, got %d", deref)
+	}
+	searchRequest.DerefAliases = 4
+	if _, err := encodeSearchRequest(searchRequest); err == nil {
+		t.Error("Expected an error for an invalid dereferencing")
+	}
+}
+
+func TestScope(t *testing.T) {
+	for name, expected := range map[string]Scope{"base": ScopeBaseObject, "one": ScopeSingleLevel, "singleLevel": ScopeSingleLevel, "SUB": ScopeWholeSubtree, "subtree": ScopeWholeSubtree} {
+		if scope, err := ParseScope(name); err != nil || scope != expected {
+			t.Errorf("Expected %s for %s, got %s: %v", expected, name, scope, err)
+		}
+	}
+	if _, err := ParseScope("children"); err == nil {
+		t.Error("Expected an error for an unknown scope")
+	}
+	if s := Scope(3).String(); s != "Scope(3)" {
+		t.Errorf("Unexpected name %s of an invalid scope", s)
+	}
+
+	searchRequest := NewSearchRequest("o=bigcorp", ScopeSingleLevel, NeverDerefAliases, 0, 0, false, "(cn=*)", nil, nil)
+	packet, err := encodeSearchRequest(searchRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packet.Children[1].Description != "Scope: ScopeSingleLevel" {
+		t.Errorf("Expected the scope name in the description, got %q", packet.Children[1].Description)
+	}
+	searchRequest.Scope = 3
+	if _, err := encodeSearchRequest(searchRequest); err == nil {
+		t.Error("Expected an error for an invalid scope")
+	}
+}
+
+func TestSearchTypesOnly(t *testing.T) {
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		messageID := mockMessageID(request)
+		if typesOnly, _ := request.Children[1].Children[5].Value.(bool); typesOnly {
+			s.respond(messageID, mockSearchEntry("cn=bob,o=bigcorp", map[string][]string{"jpegPhoto": nil}))
+		}
+		s.respondResult(messageID, ApplicationSearchResultDone, ResultSuccess, "")
+	})
+	defer l.Close()
+
+	searchRequest := NewSearchRequest("o=bigcorp", ScopeWholeSubtree, NeverDerefAliases, 0, 0, true, "(cn=bob)", nil, nil)
+	result, err := l.Search(searchRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Entries) != 1 {
+		t.Fatalf("Expected the typesOnly entry, got %v", result.Entries)
+	}
+	entry := result.Entries[0]
+	if names := entry.AttributeNames(); len(names) != 1 || names[0] != "jpegPhoto" || len(entry.GetAttributeValues("jpegPhoto")) != 0 {
+		t.Errorf("Expected jpegPhoto without values, got %v", entry.Attributes)
+	}
+}
@@ -0,0 +1,96 @@
+package ldap
+
+import (
+	"context"
+)
+
+// SearchStream is a search started by SearchAsync, passing on the entries
+// as they arrive instead of collecting them in a SearchResult.
+type SearchStream struct {
+	// Entries of the search, closed once the search is done
+	Entries <-chan *Entry
+
+	entries   chan *Entry
+	ctx       context.Context
+	cancel    context.CancelFunc
+	done      chan struct{}
+	err       error
+	referrals []string
+	controls  []Control
+}
+
+// SearchAsync starts searchRequest and passes the entries on to Entries as
+// they arrive, e.g. to export millions of entries without holding them in
+// memory. Up to bufferSize entries wait in Entries and MaxQueuedResponses in
+// the queue of the search, once both are full the reader of the connection
+// pauses until Entries is read, delaying the other operations on the
+// connection as well. So Entries has to be read until it is closed or the
+// search stopped with Close. RequestTimeout doesn't apply to the search, it
+// is abandoned when ctx is done.
+func (l *Connection) SearchAsync(ctx context.Context, searchRequest *SearchRequest, bufferSize int) *SearchStream {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &SearchStream{
+		entries: make(chan *Entry, bufferSize),
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	s.Entries = s.entries
+	go func() {
+		defer cancel()
+		s.err = l.searchRetried(ctx, searchRequest, s)
+		close(s.entries)
+		close(s.done)
+	}()
+	return s
+}
+
+// ProcessDiscreteResult passes the entries of the search on to Entries.
+func (s *SearchStream) ProcessDiscreteResult(dsr *DiscreteSearchResult, connInfo *ConnectionInfo) (bool, error) {
+	switch dsr.SearchResultType {
+	case SearchResultEntry:
+		select {
+		case s.entries <- dsr.Entry:
+		case <-s.ctx.Done():
+			return false, doneError(s.ctx)
+		}
+	case SearchResultReference:
+		s.referrals = append(s.referrals, dsr.Referrals...)
+	case SearchResultDone:
+		s.controls = dsr.Controls
+	}
+	return false, nil
+}
+
+// Close abandons the search unless it is done already and waits until
+// Entries is closed, the entries buffered before can still be read.
+func (s *SearchStream) Close() {
+	s.cancel()
+	<-s.done
+}
+
+// Done is closed together with Entries once the search is done.
+func (s *SearchStream) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err waits until the search is done and returns the error that ended it,
+// nil if it succeeded and context.Canceled after Close.
+func (s *SearchStream) Err() error {
+	<-s.done
+	return s.err
+}
+
+// Referrals waits until the search is done and returns the search result
+// references.
+func (s *SearchStream) Referrals() []string {
+	<-s.done
+	return s.referrals
+}
+
+// Controls waits until the search is done and returns the response controls
+// of the search result done, e.g. with the cookie of a paged search.
+func (s *SearchStream) Controls() []Control {
+	<-s.done
+	return s.controls
+}
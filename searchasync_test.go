@@ -0,0 +1,87 @@
+package ldap
+
+import (
+	"context"
+	"github.com/eaciit/asn1-ber"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// mockStreamServer answers searches with entries entries.
+func mockStreamServer(t *testing.T, entries int) (*Connection, *mockServer) {
+	return newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		if request.Children[1].Tag != ber.Tag(ApplicationSearchRequest) {
+			return
+		}
+		messageID := mockMessageID(request)
+		for i := 0; i < entries; i++ {
+			s.respond(messageID, mockSearchEntry("cn=user"+strconv.Itoa(i)+",o=bigcorp", nil))
+		}
+		s.respondResult(messageID, ApplicationSearchResultDone, ResultSuccess, "")
+	})
+}
+
+func TestSearchAsync(t *testing.T) {
+	l, _ := mockStreamServer(t, 1000)
+	defer l.Close()
+
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", nil)
+	stream := l.SearchAsync(context.Background(), searchRequest, 10)
+	i := 0
+	for entry := range stream.Entries {
+		if entry.DN != "cn=user"+strconv.Itoa(i)+",o=bigcorp" {
+			t.Fatalf("Unexpected entry %s", entry.DN)
+		}
+		i++
+	}
+	if err := stream.Err(); err != nil || i != 1000 {
+		t.Errorf("Expected 1000 entries, got %d: %v", i, err)
+	}
+	<-stream.Done()
+}
+
+func TestSearchAsyncClose(t *testing.T) {
+	l, s := mockStreamServer(t, 1000)
+	defer l.Close()
+
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", nil)
+	stream := l.SearchAsync(context.Background(), searchRequest, 0)
+	if entry := <-stream.Entries; entry == nil {
+		t.Fatal("Expected an entry")
+	}
+	stream.Close()
+	if stream.Err() != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", stream.Err())
+	}
+	<-s.requests
+	if abandon := <-s.requests; abandon.Children[1].Tag != ber.Tag(ApplicationAbandonRequest) {
+		t.Errorf("Expected the search to be abandoned, got %v", abandon.Children[1])
+	}
+	// the connection goes on
+	stream = l.SearchAsync(context.Background(), searchRequest, 1000)
+	<-stream.Done()
+	if err := stream.Err(); err != nil || len(stream.Entries) != 1000 {
+		t.Errorf("Expected 1000 entries, got %d: %v", len(stream.Entries), err)
+	}
+}
+
+func TestSearchAsyncRequestTimeout(t *testing.T) {
+	l, _ := mockStreamServer(t, 100)
+	defer l.Close()
+	l.RequestTimeout = 20 * time.Millisecond
+
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(cn=*)", nil)
+	stream := l.SearchAsync(context.Background(), searchRequest, 0)
+	errs := make(chan error, 1)
+	go func() { errs <- stream.Err() }()
+	// a slow reader doesn't run into the RequestTimeout
+	time.Sleep(50 * time.Millisecond)
+	i := 0
+	for range stream.Entries {
+		i++
+	}
+	if err := <-errs; err != nil || i != 100 {
+		t.Errorf("Expected 100 entries, got %d: %v", i, err)
+	}
+}
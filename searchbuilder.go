@@ -0,0 +1,101 @@
+package ldap
+
+import (
+	"strconv"
+)
+
+// SearchBuilder builds a SearchRequest with named settings instead of the
+// positional arguments of NewSearchRequest, e.g.
+//
+//	req, err := NewSearchBuilder().Base("o=bigcorp").Filter("(uid=bob)").Attrs("cn", "mail").Build()
+//
+// The search defaults to the subtree of the base DN, all user attributes
+// and the filter "(objectClass=*)".
+type SearchBuilder struct {
+	req SearchRequest
+}
+
+// NewSearchBuilder returns a SearchBuilder of a subtree search for all
+// entries.
+func NewSearchBuilder() *SearchBuilder {
+	return &SearchBuilder{req: SearchRequest{Scope: ScopeWholeSubtree, Filter: "(objectClass=*)"}}
+}
+
+// Base sets the base DN.
+func (b *SearchBuilder) Base(baseDN string) *SearchBuilder {
+	b.req.BaseDN = baseDN
+	return b
+}
+
+// Scope sets the scope, ScopeWholeSubtree by default.
+func (b *SearchBuilder) Scope(scope Scope) *SearchBuilder {
+	b.req.Scope = scope
+	return b
+}
+
+// Deref sets the alias dereferencing, NeverDerefAliases by default.
+func (b *SearchBuilder) Deref(deref Deref) *SearchBuilder {
+	b.req.DerefAliases = deref
+	return b
+}
+
+// SizeLimit sets the size limit in entries, 0 for none.
+func (b *SearchBuilder) SizeLimit(sizeLimit int) *SearchBuilder {
+	b.req.SizeLimit = sizeLimit
+	return b
+}
+
+// TimeLimit sets the time limit in seconds, 0 for none.
+func (b *SearchBuilder) TimeLimit(timeLimit int) *SearchBuilder {
+	b.req.TimeLimit = timeLimit
+	return b
+}
+
+// TypesOnly returns the attribute names without the values.
+func (b *SearchBuilder) TypesOnly() *SearchBuilder {
+	b.req.TypesOnly = true
+	return b
+}
+
+// Filter sets the filter.
+func (b *SearchBuilder) Filter(filter string) *SearchBuilder {
+	b.req.Filter = filter
+	return b
+}
+
+// Attrs adds attributes to return.
+func (b *SearchBuilder) Attrs(attributes ...string) *SearchBuilder {
+	b.req.Attributes = append(b.req.Attributes, attributes...)
+	return b
+}
+
+// Control adds a control.
+func (b *SearchBuilder) Control(control Control) *SearchBuilder {
+	b.req.Controls = append(b.req.Controls, control)
+	return b
+}
+
+// Build returns the SearchRequest, or an ErrorInvalidArgument *Error for an
+// invalid scope, alias dereferencing, negative limits or empty attribute
+// names, and the error of CompileFilter for an invalid filter. The builder
+// can be reused, changing it doesn't change the requests built before.
+func (b *SearchBuilder) Build() (*SearchRequest, error) {
+	if b.req.SizeLimit < 0 {
+		return nil, newError(ErrorInvalidArgument, "Negative size limit "+strconv.Itoa(b.req.SizeLimit))
+	}
+	if b.req.TimeLimit < 0 {
+		return nil, newError(ErrorInvalidArgument, "Negative time limit "+strconv.Itoa(b.req.TimeLimit))
+	}
+	for _, attribute := range b.req.Attributes {
+		if attribute == "" {
+			return nil, newError(ErrorInvalidArgument, "Empty attribute name")
+		}
+	}
+	req := b.req
+	req.Attributes = append([]string(nil), b.req.Attributes...)
+	req.Controls = append([]Control(nil), b.req.Controls...)
+	if _, err := encodeSearchRequest(&req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
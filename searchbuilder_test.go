@@ -0,0 +1,39 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchBuilder(t *testing.T) {
+	b := NewSearchBuilder().Base("o=bigcorp").Scope(ScopeSingleLevel).Filter("(uid=bob)").Attrs("cn").Attrs("mail").SizeLimit(10).Control(NewControlManageDsaITRequest(false))
+	req, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := NewSearchRequest("o=bigcorp", ScopeSingleLevel, NeverDerefAliases, 10, 0, false, "(uid=bob)", []string{"cn", "mail"}, []Control{NewControlManageDsaITRequest(false)})
+	if !reflect.DeepEqual(req, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, req)
+	}
+	b.Attrs("sn")
+	if len(req.Attributes) != 2 {
+		t.Errorf("Changing the builder changed the request %v", req.Attributes)
+	}
+
+	if req, err := NewSearchBuilder().Build(); err != nil || req.Scope != ScopeWholeSubtree || req.Filter != "(objectClass=*)" {
+		t.Errorf("Unexpected default search %+v: %v", req, err)
+	}
+
+	for name, b := range map[string]*SearchBuilder{
+		"filter":     NewSearchBuilder().Filter("(uid=bob"),
+		"scope":      NewSearchBuilder().Scope(3),
+		"deref":      NewSearchBuilder().Deref(4),
+		"size limit": NewSearchBuilder().SizeLimit(-1),
+		"time limit": NewSearchBuilder().TimeLimit(-1),
+		"attribute":  NewSearchBuilder().Attrs("cn", ""),
+	} {
+		if _, err := b.Build(); err == nil {
+			t.Errorf("Expected an error for an invalid %s", name)
+		}
+	}
+}
@@ -3,7 +3,8 @@ package ldap
 type SearchResultType uint8
 
 const (
-	SearchResultEntry     SearchResultType = SearchResultType(ApplicationSearchResultEntry)
-	SearchResultReference SearchResultType = SearchResultType(ApplicationSearchResultReference)
-	SearchResultDone      SearchResultType = SearchResultType(ApplicationSearchResultDone)
+	SearchResultEntry        SearchResultType = SearchResultType(ApplicationSearchResultEntry)
+	SearchResultReference    SearchResultType = SearchResultType(ApplicationSearchResultReference)
+	SearchResultDone         SearchResultType = SearchResultType(ApplicationSearchResultDone)
+	SearchResultIntermediate SearchResultType = SearchResultType(ApplicationIntermediateResponse)
 )
@@ -7,11 +7,13 @@ import "fmt"
 const (
 	_SearchResultType_name_0 = "SearchResultEntrySearchResultDone"
 	_SearchResultType_name_1 = "SearchResultReference"
+	_SearchResultType_name_2 = "SearchResultIntermediate"
 )
 
 var (
 	_SearchResultType_index_0 = [...]uint8{0, 17, 33}
 	_SearchResultType_index_1 = [...]uint8{0, 21}
+	_SearchResultType_index_2 = [...]uint8{0, 24}
 )
 
 func (i SearchResultType) String() string {
@@ -21,6 +23,8 @@ func (i SearchResultType) String() string {
 		return _SearchResultType_name_0[_SearchResultType_index_0[i]:_SearchResultType_index_0[i+1]]
 	case i == 19:
 		return _SearchResultType_name_1
+	case i == 25:
+		return _SearchResultType_name_2
 	default:
 		return fmt.Sprintf("SearchResultType(%d)", i)
 	}
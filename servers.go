@@ -0,0 +1,270 @@
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultServerCooldown is how long a ServerList skips a failed server if no
+// Cooldown is set.
+const DefaultServerCooldown = 30 * time.Second
+
+// ServerOrder is the order in which a ServerList tries its servers.
+type ServerOrder int
+
+const (
+	// PriorityOrder tries the servers in the order of the URLs, so the first
+	// available server gets all connections
+	PriorityOrder ServerOrder = iota
+	// RoundRobinOrder starts with the server after the one tried first the
+	// last time, spreading the connections over the servers
+	RoundRobinOrder
+)
+
+// ServerList is the list of servers of Connection.Servers, shared by the
+// connections to the same directory. Failures of a server to connect, to bind
+// again after a reconnect or of its connection are counted like by a circuit
+// breaker: after FailureThreshold failures in a row the server is skipped for
+// Cooldown, then it is tried again and skipped for another Cooldown on the
+// next failure. While all servers are skipped the one that failed the longest
+// ago is still tried, so the connections don't fail until a Cooldown passed.
+type ServerList struct {
+	// LDAP URLs of the servers as for DialURL
+	URLs  []string
+	Order ServerOrder
+	// Orders the servers instead of Order if set, e.g. a
+	// LeastOutstandingBalancer
+	Balancer Balancer
+	// How long a failed server is skipped, DefaultServerCooldown if 0
+	Cooldown time.Duration
+	// Failures in a row after which a server is skipped, 1 if 0
+	FailureThreshold int
+	// DNS domain whose SRV records list the servers instead of URLs, see
+	// LookupServers. The records are resolved on the first connect and
+	// again once all servers failed
+	Domain string
+	// Discover the _ldaps._tcp servers of Domain instead of _ldap._tcp
+	LDAPS bool
+	// TLS sessions shared by the connections to the servers, see
+	// Connection.TLSSessionCache
+	TLSSessionCache tls.ClientSessionCache
+
+	lock        sync.Mutex
+	next        int
+	failed      map[string]time.Time
+	failures    map[string]int
+	connections map[string]map[*Connection]bool
+}
+
+// NewServerList returns a ServerList of urls in PriorityOrder, with a
+// TLSSessionCache of the default capacity.
+func NewServerList(urls ...string) *ServerList {
+	return &ServerList{URLs: urls, TLSSessionCache: tls.NewLRUClientSessionCache(0)}
+}
+
+// NewDomainServerList returns a ServerList of the servers of the DNS domain
+// discovered with LookupServers, with a TLSSessionCache of the default
+// capacity.
+func NewDomainServerList(domain string, ldaps bool) *ServerList {
+	return &ServerList{Domain: domain, LDAPS: ldaps, TLSSessionCache: tls.NewLRUClientSessionCache(0)}
+}
+
+// lookupSRV resolves SRV records, replaced in tests
+var lookupSRV = net.LookupSRV
+
+// LookupServers returns the URLs of the servers of domain announced by the
+// SRV records _ldap._tcp.<domain>, or of _ldaps._tcp.<domain> with ldaps, as
+// used by Active Directory. The URLs are ordered by priority and randomly by
+// weight within a priority [https://tools.ietf.org/html/rfc2782].
+func LookupServers(domain string, ldaps bool) ([]string, error) {
+	service, scheme := "ldap", "ldap://"
+	if ldaps {
+		service, scheme = "ldaps", "ldaps://"
+	}
+	_, records, err := lookupSRV(service, "tcp", domain)
+	if err != nil {
+		return nil, newError(ErrorNetwork, "SRV lookup failed: "+err.Error())
+	}
+	urls := make([]string, 0, len(records))
+	for _, record := range records {
+		// a target of "." announces that the service isn't available
+		if target := strings.TrimSuffix(record.Target, "."); target != "" {
+			urls = append(urls, scheme+net.JoinHostPort(target, strconv.Itoa(int(record.Port))))
+		}
+	}
+	if len(urls) == 0 {
+		return nil, newError(ErrorNetwork, "No "+service+" servers announced for "+domain)
+	}
+	return urls, nil
+}
+
+// resolve replaces the URLs with the servers of Domain if there are no URLs
+// yet or force is set. The URLs are kept if the lookup fails.
+func (s *ServerList) resolve(force bool) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.Domain == "" || (len(s.URLs) > 0 && !force) {
+		return nil
+	}
+	urls, err := LookupServers(s.Domain, s.LDAPS)
+	if err != nil {
+		if len(s.URLs) > 0 {
+			return nil
+		}
+		return err
+	}
+	s.URLs = urls
+	s.next = 0
+	return nil
+}
+
+// Servers returns the URLs in the order to try them without the servers in
+// cooldown, or only the one whose cooldown ends first if all are.
+func (s *ServerList) Servers() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.URLs) == 0 {
+		return nil
+	}
+	var ordered []string
+	if s.Balancer != nil {
+		ordered = s.Balancer.Order(s.status())
+	} else {
+		start := 0
+		if s.Order == RoundRobinOrder {
+			start = s.next % len(s.URLs)
+			s.next = start + 1
+		}
+		ordered = append(append(ordered, s.URLs[start:]...), s.URLs[:start]...)
+	}
+	now := time.Now()
+	available := make([]string, 0, len(ordered))
+	leastRecent := ""
+	for _, serverURL := range ordered {
+		until, ok := s.failed[serverURL]
+		if !ok || !now.Before(until) {
+			available = append(available, serverURL)
+		} else if leastRecent == "" || until.Before(s.failed[leastRecent]) {
+			leastRecent = serverURL
+		}
+	}
+	if len(available) == 0 {
+		// all in cooldown, the server that failed the longest ago is the
+		// last resort
+		available = append(available, leastRecent)
+	}
+	return available
+}
+
+// status returns the ServerStatus of the URLs. s.lock must be held.
+func (s *ServerList) status() []ServerStatus {
+	status := make([]ServerStatus, len(s.URLs))
+	for i, serverURL := range s.URLs {
+		status[i].URL = serverURL
+		for l := range s.connections[serverURL] {
+			status[i].Connections++
+			l.lockResponses.RLock()
+			status[i].Outstanding += len(l.responses)
+			l.lockResponses.RUnlock()
+		}
+	}
+	return status
+}
+
+// register adds l to the connections to serverURL.
+func (s *ServerList) register(serverURL string, l *Connection) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.connections == nil {
+		s.connections = map[string]map[*Connection]bool{}
+	}
+	if s.connections[serverURL] == nil {
+		s.connections[serverURL] = map[*Connection]bool{}
+	}
+	s.connections[serverURL][l] = true
+}
+
+// unregister removes l from the connections to serverURL once it closed.
+func (s *ServerList) unregister(serverURL string, l *Connection) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.connections[serverURL], l)
+}
+
+// MarkFailed counts a failure of the server of serverURL and puts it into
+// cooldown once FailureThreshold is reached.
+func (s *ServerList) MarkFailed(serverURL string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.failures == nil {
+		s.failures = map[string]int{}
+		s.failed = map[string]time.Time{}
+	}
+	s.failures[serverURL]++
+	threshold := s.FailureThreshold
+	if threshold <= 0 {
+		threshold = 1
+	}
+	if s.failures[serverURL] < threshold {
+		return
+	}
+	cooldown := s.Cooldown
+	if cooldown <= 0 {
+		cooldown = DefaultServerCooldown
+	}
+	s.failed[serverURL] = time.Now().Add(cooldown)
+}
+
+// MarkSucceeded resets the failures of the server of serverURL.
+func (s *ServerList) MarkSucceeded(serverURL string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.failures, serverURL)
+	delete(s.failed, serverURL)
+}
+
+// connectServers connects to the first of Servers accepting the connection,
+// counting the failures of the servers that don't. The servers of a Domain are
+// resolved again if all of them failed.
+func (l *Connection) connectServers(ctx context.Context) error {
+	if err := l.Servers.resolve(false); err != nil {
+		return err
+	}
+	serverURLs := l.Servers.Servers()
+	if len(serverURLs) == 0 {
+		return newError(ErrorInvalidArgument, "No servers to connect to.")
+	}
+	var err error
+	for _, serverURL := range serverURLs {
+		if err = l.setServer(serverURL); err != nil {
+			return err
+		}
+		if err = l.connectClean(ctx); err == nil {
+			return nil
+		}
+		if l.Debug {
+			log.Printf("Connecting to %s failed: %s", serverURL, err)
+		}
+		l.Servers.MarkFailed(serverURL)
+	}
+	// the next connect uses the servers announced by then
+	l.Servers.resolve(true)
+	return err
+}
+
+// setServer makes serverURL the server of the connection.
+func (l *Connection) setServer(serverURL string) error {
+	u, err := newURLConnection(serverURL, nil)
+	if err != nil {
+		return err
+	}
+	l.Network, l.Addr, l.IsSSL = u.Network, u.Addr, u.IsSSL
+	l.server = serverURL
+	return nil
+}
@@ -0,0 +1,205 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// mockListener serves the connections accepted on a TCP listener like
+// newMockConnection, the mockServer of each connection is sent on the channel.
+func mockListener(t *testing.T, handle func(s *mockServer, request *ber.Packet)) (net.Listener, chan *mockServer) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	servers := make(chan *mockServer, 4)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s := &mockServer{conn: conn, requests: make(chan *ber.Packet, 16)}
+			servers <- s
+			go s.serve(handle)
+		}
+	}()
+	return listener, servers
+}
+
+func TestServerList(t *testing.T) {
+	s := NewServerList("ldap://a", "ldap://b", "ldap://c")
+	if servers := s.Servers(); !reflect.DeepEqual(servers, []string{"ldap://a", "ldap://b", "ldap://c"}) {
+		t.Errorf("Unexpected priority order %v", servers)
+	}
+	s.MarkFailed("ldap://a")
+	if servers := s.Servers(); !reflect.DeepEqual(servers, []string{"ldap://b", "ldap://c"}) {
+		t.Errorf("Expected the failed server to be skipped, got %v", servers)
+	}
+	s.MarkSucceeded("ldap://a")
+	if servers := s.Servers(); len(servers) != 3 {
+		t.Errorf("Expected the server back after a success, got %v", servers)
+	}
+
+	s = &ServerList{URLs: []string{"ldap://a", "ldap://b", "ldap://c"}, Order: RoundRobinOrder, Cooldown: time.Millisecond}
+	for _, first := range []string{"ldap://a", "ldap://b", "ldap://c", "ldap://a"} {
+		if servers := s.Servers(); servers[0] != first {
+			t.Errorf("Expected %s first, got %v", first, servers)
+		}
+	}
+	s.MarkFailed("ldap://b")
+	time.Sleep(5 * time.Millisecond)
+	if servers := s.Servers(); !reflect.DeepEqual(servers, []string{"ldap://b", "ldap://c", "ldap://a"}) {
+		t.Errorf("Expected the server back after the cooldown, got %v", servers)
+	}
+}
+
+func TestServerListCircuitBreaker(t *testing.T) {
+	s := &ServerList{URLs: []string{"ldap://a", "ldap://b"}, FailureThreshold: 2, Cooldown: 20 * time.Millisecond}
+	s.MarkFailed("ldap://a")
+	if servers := s.Servers(); len(servers) != 2 {
+		t.Errorf("Expected the server to stay below the threshold, got %v", servers)
+	}
+	s.MarkFailed("ldap://a")
+	if servers := s.Servers(); !reflect.DeepEqual(servers, []string{"ldap://b"}) {
+		t.Errorf("Expected the circuit to open at the threshold, got %v", servers)
+	}
+
+	// after the cooldown one more failure opens the circuit again
+	time.Sleep(30 * time.Millisecond)
+	if servers := s.Servers(); len(servers) != 2 {
+		t.Errorf("Expected a retry after the cooldown, got %v", servers)
+	}
+	s.MarkFailed("ldap://a")
+	if servers := s.Servers(); !reflect.DeepEqual(servers, []string{"ldap://b"}) {
+		t.Errorf("Expected the circuit to open again, got %v", servers)
+	}
+
+	// the server failed the longest ago is the last resort while all
+	// circuits are open
+	time.Sleep(time.Millisecond)
+	s.MarkFailed("ldap://b")
+	s.MarkFailed("ldap://b")
+	if servers := s.Servers(); !reflect.DeepEqual(servers, []string{"ldap://a"}) {
+		t.Errorf("Expected the least recently failed server, got %v", servers)
+	}
+}
+
+func TestConnectionServersFailover(t *testing.T) {
+	down, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down.Close()
+	handle := func(s *mockServer, request *ber.Packet) {
+		if request.Children[1].Tag == ber.Tag(ApplicationDelRequest) {
+			s.respondResult(mockMessageID(request), ApplicationDelResponse, ResultSuccess, "")
+		}
+	}
+	first, firstServers := mockListener(t, handle)
+	defer first.Close()
+	second, secondServers := mockListener(t, handle)
+	defer second.Close()
+
+	l := &Connection{Servers: NewServerList("ldap://"+down.Addr().String(), "ldap://"+first.Addr().String(), "ldap://"+second.Addr().String())}
+	if err := l.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if l.Addr != first.Addr().String() {
+		t.Fatalf("Connected to %s instead of %s", l.Addr, first.Addr())
+	}
+
+	// the connection to the first server breaks
+	done := l.chanDone
+	(<-firstServers).conn.Close()
+	<-done
+
+	if _, err := l.Delete(NewDeleteRequest("cn=bob,o=bigcorp")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case s := <-secondServers:
+		if request := <-s.requests; request.Children[1].Tag != ber.Tag(ApplicationDelRequest) {
+			t.Errorf("Unexpected request %v", request.Children[1])
+		}
+	default:
+		t.Fatal("The delete was not sent to the second server")
+	}
+
+	l.Close()
+	<-l.chanDone
+	if _, err := l.Delete(NewDeleteRequest("cn=bob,o=bigcorp")); err == nil {
+		t.Error("Expected no reconnect after Close")
+	}
+}
+
+func TestLookupServers(t *testing.T) {
+	defer func() { lookupSRV = net.LookupSRV }()
+	var lookups []string
+	lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		lookups = append(lookups, "_"+service+"._"+proto+"."+name)
+		return "", []*net.SRV{
+			{Target: "dc1.example.com.", Port: 389, Priority: 0},
+			{Target: "dc2.example.com.", Port: 3268, Priority: 10},
+		}, nil
+	}
+
+	urls, err := LookupServers("example.com", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(urls, []string{"ldap://dc1.example.com:389", "ldap://dc2.example.com:3268"}) {
+		t.Errorf("Unexpected servers %v", urls)
+	}
+	if urls, _ := LookupServers("example.com", true); urls[0] != "ldaps://dc1.example.com:389" {
+		t.Errorf("Unexpected LDAPS servers %v", urls)
+	}
+	if !reflect.DeepEqual(lookups, []string{"_ldap._tcp.example.com", "_ldaps._tcp.example.com"}) {
+		t.Errorf("Unexpected lookups %v", lookups)
+	}
+
+	lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		return "", []*net.SRV{{Target: "."}}, nil
+	}
+	if _, err := LookupServers("example.com", false); err == nil {
+		t.Error("Expected an error for a domain without the service")
+	}
+}
+
+func TestConnectionDomainServers(t *testing.T) {
+	defer func() { lookupSRV = net.LookupSRV }()
+	listener, _ := mockListener(t, nil)
+	defer listener.Close()
+	down, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down.Close()
+
+	// the first lookup only returns the server that is down
+	lookups := 0
+	lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		lookups++
+		addr := down.Addr().(*net.TCPAddr)
+		if lookups > 1 {
+			addr = listener.Addr().(*net.TCPAddr)
+		}
+		return "", []*net.SRV{{Target: addr.IP.String() + ".", Port: uint16(addr.Port)}}, nil
+	}
+
+	l := &Connection{Servers: NewDomainServerList("example.com", false)}
+	if err := l.Connect(); err == nil {
+		t.Fatal("Expected the connect to the server that is down to fail")
+	}
+	if err := l.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if l.Addr != listener.Addr().String() || lookups != 2 {
+		t.Errorf("Connected to %s after %d lookups", l.Addr, lookups)
+	}
+}
@@ -0,0 +1,239 @@
+package ldap
+
+import (
+	"bytes"
+	"context"
+	"github.com/eaciit/asn1-ber"
+)
+
+// DER of the OIDs of SPNEGO and its mechanisms
+var (
+	spnegoOID = []byte{0x06, 0x06, 0x2b, 0x06, 0x01, 0x05, 0x05, 0x02}
+	// 1.2.840.48018.1.2.2, the Kerberos OID with a wrong encoding used by
+	// older versions of Windows
+	msKerberosMechanismOID = []byte{0x06, 0x09, 0x2a, 0x86, 0x48, 0x82, 0xf7, 0x12, 0x01, 0x02, 0x02}
+	ntlmMechanismOID       = []byte{0x06, 0x0a, 0x2b, 0x06, 0x01, 0x04, 0x01, 0x82, 0x37, 0x02, 0x02, 0x0a}
+)
+
+// negState of NegTokenResp
+const (
+	spnegoAcceptCompleted  = 0
+	spnegoAcceptIncomplete = 1
+	spnegoReject           = 2
+	spnegoRequestMIC       = 3
+)
+
+// SPNEGOMechanism is the SASL GSS-SPNEGO mechanism preferred by Active
+// Directory, negotiating Kerberos or NTLM with SPNEGO [https://tools.ietf.org/html/rfc4178].
+// Kerberos is used if a security context can be established, only NTLM is
+// offered if not, e.g. without a KDC or a ticket. As with GSSAPIMechanism
+// no security layer is negotiated, the connection should be protected with
+// TLS. With NTLM the mechanism list is protected with a mechListMIC, the
+// one of the server is verified if it returns one; with Kerberos, the only
+// mechanism offered then, none is sent or verified.
+type SPNEGOMechanism struct {
+	// Kerberos and NTLM clients, e.g. a KerberosClient and a NTLMClient, at
+	// least one of them
+	Kerberos GSSAPIClient
+	NTLM     GSSAPIClient
+	// Target service principal, empty for "ldap/<host>"
+	Target string
+	// ChannelBinding type sent with TLS, see GSSAPIMechanism
+	ChannelBinding string
+
+	mechanism   []byte
+	mechTypes   []byte
+	context     GSSAPIContext
+	established bool
+	micSent     bool
+}
+
+// spnegoMICContext is a GSSAPIContext computing the mechListMIC over the
+// mechanism list.
+type spnegoMICContext interface {
+	getMIC(message []byte) ([]byte, bool)
+	verifyMIC(message, mic []byte) error
+}
+
+// SPNEGOBind binds with GSS-SPNEGO using Kerberos, or NTLM if no Kerberos
+// security context can be established. Either client may be nil.
+func (l *Connection) SPNEGOBind(kerberos *KerberosClient, ntlm *NTLMClient) (*LDAPResult, error) {
+	return l.SPNEGOBindContext(context.Background(), kerberos, ntlm)
+}
+
+// SPNEGOBindContext is SPNEGOBind with ctx.
+func (l *Connection) SPNEGOBindContext(ctx context.Context, kerberos *KerberosClient, ntlm *NTLMClient) (*LDAPResult, error) {
+	mechanism := &SPNEGOMechanism{}
+	if kerberos != nil {
+		mechanism.Kerberos = kerberos
+	}
+	if ntlm != nil {
+		mechanism.NTLM = ntlm
+	}
+	return l.SASLBindContext(ctx, mechanism)
+}
+
+// NTLMBind binds with GSS-SPNEGO and NTLMv2 as username in domain with
+// password, the password is not sent over the connection.
+func (l *Connection) NTLMBind(domain, username, password string) (*LDAPResult, error) {
+	return l.NTLMBindContext(context.Background(), domain, username, password)
+}
+
+// NTLMBindContext is NTLMBind with ctx.
+func (l *Connection) NTLMBindContext(ctx context.Context, domain, username, password string) (*LDAPResult, error) {
+	return l.SPNEGOBindContext(ctx, nil, NewNTLMClient(domain, username, password))
+}
+
+func (m *SPNEGOMechanism) Name() string {
+	return "GSS-SPNEGO"
+}
+
+func (m *SPNEGOMechanism) Start(server *SASLServerInfo) ([]byte, error) {
+	channelBinding, err := gssapiChannelBinding(m.ChannelBinding, server)
+	if err != nil {
+		return nil, err
+	}
+	target := gssapiTarget(m.Target, server)
+	m.context, m.established, m.micSent = nil, false, false
+	var token []byte
+	if m.Kerberos != nil {
+		token, err = m.start(m.Kerberos, kerberosMechanismOID, target, channelBinding)
+	}
+	if m.context == nil && m.NTLM != nil {
+		token, err = m.start(m.NTLM, ntlmMechanismOID, target, channelBinding)
+	}
+	if m.context == nil {
+		if err == nil {
+			err = newError(ErrorInvalidArgument, "SPNEGO: neither a Kerberos nor a NTLM client")
+		}
+		return nil, err
+	}
+
+	/*
+		NegTokenInit ::= SEQUENCE {
+			mechTypes       [0] MechTypeList,
+			reqFlags        [1] ContextFlags  OPTIONAL,
+			mechToken       [2] OCTET STRING  OPTIONAL,
+			mechListMIC     [3] OCTET STRING  OPTIONAL }
+	*/
+	mechTypes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "MechTypeList")
+	mechTypes.AppendChild(ber.DecodePacket(m.mechanism))
+	m.mechTypes = mechTypes.Bytes()
+	init := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "NegTokenInit")
+	init.AppendChild(krbTag(0, mechTypes))
+	init.AppendChild(krbTag(2, krbOctets(token)))
+	initialToken := ber.Encode(ber.ClassApplication, ber.TypeConstructed, 0, nil, "InitialContextToken")
+	initialToken.Data.Write(spnegoOID)
+	initialToken.Data.Write(krbTag(0, init).Bytes())
+	return initialToken.Bytes(), nil
+}
+
+// start establishes the security context of client and returns its initial
+// token.
+func (m *SPNEGOMechanism) start(client GSSAPIClient, mechanism []byte, target string, channelBinding []byte) ([]byte, error) {
+	secContext, err := client.NewSecContext(target, channelBinding)
+	if err != nil {
+		return nil, err
+	}
+	if k, ok := secContext.(*kerberosContext); ok {
+		// Active Directory signs or seals the LDAP messages after the bind
+		// for the flags of the context, there is no security layer
+		// negotiation
+		k.flags = gssMutualFlag | gssSequenceFlag
+	}
+	token, done, err := secContext.Step(nil)
+	if err != nil {
+		return nil, err
+	}
+	m.mechanism, m.context, m.established = mechanism, secContext, done
+	return token, nil
+}
+
+func (m *SPNEGOMechanism) Next(challenge []byte, more bool) ([]byte, error) {
+	if len(challenge) == 0 && !more && m.established {
+		return nil, nil
+	}
+	state, mechanism, token, mic, err := decodeNegTokenResp(challenge)
+	if err != nil {
+		return nil, err
+	}
+	micContext, canMIC := m.context.(spnegoMICContext)
+	switch {
+	case state == spnegoReject:
+		return nil, newError(ResultInvalidCredentials, "SPNEGO: the server rejected the authentication")
+	case state == spnegoRequestMIC && !canMIC:
+		return nil, newError(ErrorUnknown, "SPNEGO: the server requested an unsupported mechListMIC")
+	case mechanism != nil && !bytes.Equal(mechanism, m.mechanism) &&
+		!(bytes.Equal(mechanism, msKerberosMechanismOID) && bytes.Equal(m.mechanism, kerberosMechanismOID)):
+		return nil, newError(ErrorUnknown, "SPNEGO: the server selected a mechanism not offered")
+	}
+
+	var output []byte
+	if token != nil {
+		if m.established {
+			return nil, newError(ErrorDecoding, "SPNEGO: unexpected token after the security context was established")
+		}
+		if output, m.established, err = m.context.Step(token); err != nil {
+			return nil, err
+		}
+	}
+	if mic != nil && m.micSent {
+		if err := micContext.verifyMIC(m.mechTypes, mic); err != nil {
+			return nil, err
+		}
+	}
+	if !more {
+		if !m.established || state != spnegoAcceptCompleted {
+			return nil, newError(ErrorUnknown, "SPNEGO: the security context was not established")
+		}
+		return nil, nil
+	}
+	if output == nil {
+		return []byte{}, nil
+	}
+
+	/*
+		NegTokenResp ::= SEQUENCE {
+			negState       [0] ENUMERATED OPTIONAL,
+			supportedMech  [1] MechType      OPTIONAL,
+			responseToken  [2] OCTET STRING  OPTIONAL,
+			mechListMIC    [3] OCTET STRING  OPTIONAL }
+	*/
+	resp := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "NegTokenResp")
+	resp.AppendChild(krbTag(2, krbOctets(output)))
+	if m.established && canMIC {
+		if mic, ok := micContext.getMIC(m.mechTypes); ok {
+			resp.AppendChild(krbTag(3, krbOctets(mic)))
+			m.micSent = true
+		}
+	}
+	return krbTag(1, resp).Bytes(), nil
+}
+
+// decodeNegTokenResp returns the negState, accept-completed if absent, the
+// supportedMech, the responseToken and the mechListMIC of a NegTokenResp.
+func decodeNegTokenResp(data []byte) (int64, []byte, []byte, []byte, error) {
+	packet, err := ber.ReadPacket(bytes.NewReader(data))
+	if err != nil || packet.ClassType != ber.ClassContext || packet.Tag != 1 || len(packet.Children) != 1 {
+		return 0, nil, nil, nil, newError(ErrorDecoding, "SPNEGO: invalid NegTokenResp")
+	}
+	resp := packet.Children[0]
+	state := int64(spnegoAcceptCompleted)
+	if p := krbField(resp, 0); p != nil {
+		var ok bool
+		if state, ok = packetInt64(p); !ok {
+			return 0, nil, nil, nil, newError(ErrorDecoding, "SPNEGO: invalid negState")
+		}
+	}
+	var mechanism, token, mic []byte
+	if p := krbField(resp, 1); p != nil {
+		mechanism = p.Bytes()
+	}
+	if p := krbField(resp, 2); p != nil {
+		token = p.Data.Bytes()
+	}
+	if p := krbField(resp, 3); p != nil {
+		mic = p.Data.Bytes()
+	}
+	return state, mechanism, token, mic, nil
+}
@@ -0,0 +1,179 @@
+package ldap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/eaciit/asn1-ber"
+	"net"
+	"testing"
+)
+
+// mockNegTokenResp returns a NegTokenResp with an optional supportedMech
+// and responseToken.
+func mockNegTokenResp(state int64, mechanism, token []byte) string {
+	return mockNegTokenRespMIC(state, mechanism, token, nil)
+}
+
+// mockNegTokenRespMIC is mockNegTokenResp with an optional mechListMIC.
+func mockNegTokenRespMIC(state int64, mechanism, token, mic []byte) string {
+	resp := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "NegTokenResp")
+	resp.AppendChild(krbTag(0, ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, state, "")))
+	if mechanism != nil {
+		resp.AppendChild(krbTag(1, ber.DecodePacket(mechanism)))
+	}
+	if token != nil {
+		resp.AppendChild(krbTag(2, krbOctets(token)))
+	}
+	if mic != nil {
+		resp.AppendChild(krbTag(3, krbOctets(mic)))
+	}
+	return string(krbTag(1, resp).Bytes())
+}
+
+// mockNTLMChallenge returns a CHALLENGE_MESSAGE of the domain EXAMPLE with
+// the time of the server.
+func mockNTLMChallenge(serverChallenge []byte) []byte {
+	targetName := []byte(encodeUTF16LE("EXAMPLE"))
+	info := appendNTLMAvPair(nil, 2, targetName)
+	info = appendNTLMAvPair(info, ntlmAvTimestamp, []byte{1, 2, 3, 4, 5, 6, 7, 8})
+	info = appendNTLMAvPair(info, ntlmAvEOL, nil)
+	message := make([]byte, 56)
+	copy(message, ntlmSignature)
+	binary.LittleEndian.PutUint32(message[8:], 2)
+	binary.LittleEndian.PutUint16(message[12:], uint16(len(targetName)))
+	binary.LittleEndian.PutUint32(message[16:], 56)
+	binary.LittleEndian.PutUint32(message[20:], ntlmFlags)
+	copy(message[24:], serverChallenge)
+	binary.LittleEndian.PutUint16(message[40:], uint16(len(info)))
+	binary.LittleEndian.PutUint32(message[44:], uint32(56+len(targetName)))
+	message = append(message, targetName...)
+	return append(message, info...)
+}
+
+// mockSPNEGOServer answers GSS-SPNEGO binds with Kerberos tickets of kdc or
+// NTLM with the password "secret" and sends the mechanisms used to
+// mechanisms. The MICs of NTLM are verified and a mechListMIC returned.
+func mockSPNEGOServer(t *testing.T, kdc *mockKDC, mechanisms chan<- string) *Connection {
+	serverChallenge := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	var negotiate, mechTypes []byte
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		messageID := mockMessageID(request)
+		sasl := request.Children[1].Children[2]
+		if packetString(sasl.Children[0]) != "GSS-SPNEGO" || len(sasl.Children) != 2 {
+			s.respondResult(messageID, ApplicationBindResponse, ResultAuthMethodNotSupported, "")
+			return
+		}
+		credentials := sasl.Children[1].Data.Bytes()
+		if len(credentials) > 0 && credentials[0] == 0x60 {
+			initialToken := ber.DecodePacket(credentials)
+			init := initialToken.Children[1].Children[0]
+			mechanism := krbField(init, 0).Children[0].Bytes()
+			token := krbField(init, 2).Data.Bytes()
+			switch {
+			case bytes.Equal(mechanism, ntlmMechanismOID):
+				mechanisms <- "NTLM"
+				negotiate, mechTypes = token, krbField(init, 0).Bytes()
+				s.respond(messageID, mockSASLBindResponse(ResultSaslBindInProgress,
+					mockNegTokenResp(spnegoAcceptIncomplete, ntlmMechanismOID, mockNTLMChallenge(serverChallenge))))
+			case bytes.Equal(mechanism, kerberosMechanismOID):
+				mechanisms <- "Kerberos"
+				_, inner, _ := decodeGSSToken(token)
+				sessionKey, authenticator := kdc.decryptAPReq(ber.DecodePacket(inner).Children[0], kdc.serverKey, kerberosUsageAPReqAuth)
+				gssChecksum := krbField(krbField(authenticator, 3), 1).Data.Bytes()
+				if flags := binary.LittleEndian.Uint32(gssChecksum[20:]); flags != gssMutualFlag|gssSequenceFlag {
+					t.Errorf("Expected no integrity or confidentiality, got flags %x", flags)
+				}
+				apRep := mockAPRep(sessionKey, authenticator, kerberosKey{KerberosAES256CTSHMACSHA196, bytes.Repeat([]byte{6}, 32)})
+				s.respond(messageID, mockSASLBindResponse(ResultSuccess, mockNegTokenResp(spnegoAcceptCompleted, msKerberosMechanismOID, apRep)))
+			default:
+				s.respond(messageID, mockSASLBindResponse(ResultInvalidCredentials, mockNegTokenResp(spnegoReject, nil, nil)))
+			}
+			return
+		}
+
+		_, _, authenticate, mechListMIC, err := decodeNegTokenResp(credentials)
+		if err != nil || len(authenticate) < 88 {
+			t.Errorf("Unexpected NegTokenResp %x: %v", credentials, err)
+			s.respondResult(messageID, ApplicationBindResponse, ResultInvalidCredentials, "")
+			return
+		}
+		ntResponse, _ := ntlmField(authenticate, 20)
+		domain, _ := ntlmField(authenticate, 28)
+		user, _ := ntlmField(authenticate, 36)
+		ntowf := ntowfv2(NTLMHash("secret"), decodeUTF16LE(user), decodeUTF16LE(domain))
+		if len(ntResponse) < 44 || !bytes.Equal(ntlmHMAC(ntowf, serverChallenge, ntResponse[16:]), ntResponse[:16]) {
+			s.respond(messageID, mockSASLBindResponse(ResultInvalidCredentials, mockNegTokenResp(spnegoReject, nil, nil)))
+			return
+		}
+		pairs, err := parseNTLMTargetInfo(ntResponse[44:])
+		if err != nil || len(pairs) != 5 || pairs[2].id != ntlmAvFlags || binary.LittleEndian.Uint32(pairs[2].value) != ntlmAvFlagMIC ||
+			pairs[3].id != ntlmAvTargetName || decodeUTF16LE(pairs[3].value) != "ldap/dc.example.com" {
+			t.Errorf("Unexpected target info %v: %v", pairs, err)
+		}
+		server := &ntlmContext{flags: ntlmFlags, sessionKey: ntlmHMAC(ntowf, ntResponse[:16])}
+		zeroed := append([]byte(nil), authenticate...)
+		copy(zeroed[72:88], make([]byte, 16))
+		if !bytes.Equal(ntlmHMAC(server.sessionKey, negotiate, mockNTLMChallenge(serverChallenge), zeroed), authenticate[72:88]) {
+			t.Error("Invalid MIC of the AUTHENTICATE_MESSAGE")
+		}
+		if !bytes.Equal(ntlmSign(server.signingKey("client-to-server"), 0, mechTypes), mechListMIC) {
+			t.Errorf("Invalid mechListMIC %x", mechListMIC)
+		}
+		mic := ntlmSign(server.signingKey("server-to-client"), 0, mechTypes)
+		s.respond(messageID, mockSASLBindResponse(ResultSuccess, mockNegTokenRespMIC(spnegoAcceptCompleted, nil, nil, mic)))
+	})
+	return l
+}
+
+func TestNTLMBind(t *testing.T) {
+	mechanisms := make(chan string, 4)
+	l := mockSPNEGOServer(t, nil, mechanisms)
+	defer l.Close()
+
+	if _, err := l.SASLBind(&SPNEGOMechanism{NTLM: NewNTLMClient("", "alice", "secret"), Target: "ldap/dc.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if mechanism := <-mechanisms; mechanism != "NTLM" {
+		t.Errorf("Expected NTLM, got %s", mechanism)
+	}
+	result, err := l.SASLBind(&SPNEGOMechanism{NTLM: NewNTLMClientWithHash("EXAMPLE", "alice", NTLMHash("wrong")), Target: "ldap/dc.example.com"})
+	if err == nil || result == nil || result.ResultCode != ResultInvalidCredentials {
+		t.Errorf("Expected invalid credentials, got %v", err)
+	}
+	<-mechanisms
+}
+
+func TestSPNEGOBind(t *testing.T) {
+	kdc := newMockKDC(t)
+	mechanisms := make(chan string, 4)
+	l := mockSPNEGOServer(t, kdc, mechanisms)
+	defer l.Close()
+
+	kerberos := NewKerberosClientWithPassword("alice", "EXAMPLE.COM", "secret")
+	kerberos.KDCs = []string{kdc.addr}
+	ntlm := NewNTLMClient("EXAMPLE", "alice", "secret")
+	if _, err := l.SASLBind(&SPNEGOMechanism{Kerberos: kerberos, NTLM: ntlm, Target: "ldap/dc.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if mechanism := <-mechanisms; mechanism != "Kerberos" {
+		t.Errorf("Expected Kerberos, got %s", mechanism)
+	}
+
+	// NTLM without a KDC
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.Close()
+	unreachable := NewKerberosClientWithPassword("bob", "EXAMPLE.COM", "secret")
+	unreachable.KDCs = []string{listener.Addr().String()}
+	if _, err := l.SASLBind(&SPNEGOMechanism{Kerberos: unreachable, NTLM: ntlm, Target: "ldap/dc.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if mechanism := <-mechanisms; mechanism != "NTLM" {
+		t.Errorf("Expected NTLM, got %s", mechanism)
+	}
+	if _, err := l.SASLBind(&SPNEGOMechanism{Kerberos: unreachable, Target: "ldap/dc.example.com"}); err == nil {
+		t.Error("Expected the error of the KDC")
+	}
+}
@@ -0,0 +1,183 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/eaciit/asn1-ber"
+)
+
+// Content Synchronization [https://tools.ietf.org/html/rfc4533]
+const IntermediateResponseSyncInfo = "1.3.6.1.4.1.4203.1.9.1.4"
+
+// Types of a SyncInfo message
+const (
+	SyncInfoNewCookie      = 0
+	SyncInfoRefreshDelete  = 1
+	SyncInfoRefreshPresent = 2
+	SyncInfoSyncIdSet      = 3
+)
+
+/*
+syncInfoValue ::= CHOICE {
+     newcookie      [0] syncCookie,
+     refreshDelete  [1] SEQUENCE {
+         cookie         syncCookie OPTIONAL,
+         refreshDone    BOOLEAN DEFAULT TRUE
+     },
+     refreshPresent [2] SEQUENCE {
+         cookie         syncCookie OPTIONAL,
+         refreshDone    BOOLEAN DEFAULT TRUE
+     },
+     syncIdSet      [3] SEQUENCE {
+         cookie         syncCookie OPTIONAL,
+         refreshDeletes BOOLEAN DEFAULT FALSE,
+         syncUUIDs      SET OF syncUUID
+     }
+}
+*/
+
+// SyncInfo is a syncInfo message the server sends as an intermediate response
+// during a synchronization.
+type SyncInfo struct {
+	// SyncInfo*
+	Type   int
+	Cookie []byte
+	// Set for SyncInfoRefreshDelete and SyncInfoRefreshPresent at the end of
+	// the refresh phase
+	RefreshDone bool
+	// Set for SyncInfoSyncIdSet if SyncUUIDs lists deleted entries instead of
+	// present ones
+	RefreshDeletes bool
+	SyncUUIDs      []string
+}
+
+// SyncEvent is passed to the SyncHandler for every entry and syncInfo message
+// of a synchronization. For entries Entry is set, with only the DN for the
+// states SyncStatePresent and SyncStateDelete, otherwise Info is set.
+type SyncEvent struct {
+	// SyncState* of the entry
+	State     int
+	EntryUUID string
+	Entry     *Entry
+	Info      *SyncInfo
+}
+
+// SyncHandler handles the events of Sync, returning stop ends the
+// synchronization.
+type SyncHandler func(event *SyncEvent) (stop bool, err error)
+
+// Sync runs searchRequest as a content synchronization in mode
+// (SyncRequestMode*) starting from cookie, nil for the initial content, and
+// passes the changes to handler. It returns the last cookie sent by the
+// server, which is the starting point of the next synchronization. In
+// SyncRequestModeRefreshAndPersist the search only ends when handler stops it
+// or the connection is closed. An error with ResultSyncRefreshRequired means
+// the synchronization has to be restarted with a nil cookie.
+func (l *Connection) Sync(searchRequest *SearchRequest, mode int, cookie []byte, handler SyncHandler) ([]byte, error) {
+	messageID, release, err := l.nextRequestID(context.Background())
+	if err != nil {
+		return cookie, err
+	}
+	defer release()
+
+	sh := &syncHandler{handler: handler, cookie: cookie}
+	syncRequest := searchRequest.withControl(NewControlSyncRequest(mode, cookie, false))
+	// searchWithHandler abandons the search once handler stopped it
+	err = l.searchWithHandler(context.Background(), messageID, syncRequest, sh, nil)
+	return sh.cookie, err
+}
+
+type syncHandler struct {
+	handler SyncHandler
+	cookie  []byte
+}
+
+func (sh *syncHandler) setCookie(cookie []byte) {
+	if cookie != nil {
+		sh.cookie = cookie
+	}
+}
+
+func (sh *syncHandler) ProcessDiscreteResult(dsr *DiscreteSearchResult, connInfo *ConnectionInfo) (bool, error) {
+	var event *SyncEvent
+	switch dsr.SearchResultType {
+	case SearchResultEntry:
+		event = &SyncEvent{State: SyncStateAdd, Entry: dsr.Entry}
+		if _, control := FindControl(dsr.Controls, ControlTypeSyncState); control != nil {
+			if state, ok := control.(*ControlSyncState); ok {
+				event.State = state.State
+				event.EntryUUID = state.EntryUUID
+				sh.setCookie(state.Cookie)
+			}
+		}
+	case SearchResultIntermediate:
+		if dsr.Intermediate.Name != IntermediateResponseSyncInfo {
+			return false, nil
+		}
+		info, err := decodeSyncInfo(dsr.Intermediate.Value)
+		if err != nil {
+			return false, err
+		}
+		sh.setCookie(info.Cookie)
+		event = &SyncEvent{Info: info}
+	case SearchResultDone:
+		if _, control := FindControl(dsr.Controls, ControlTypeSyncDone); control != nil {
+			if done, ok := control.(*ControlSyncDone); ok {
+				sh.setCookie(done.Cookie)
+			}
+		}
+		return false, nil
+	default:
+		return false, nil
+	}
+
+	return sh.handler(event)
+}
+
+func decodeSyncInfo(data []byte) (*SyncInfo, error) {
+	value := ber.DecodePacket(data)
+	if value == nil || value.ClassType != ber.ClassContext {
+		return nil, newError(ErrorDecoding, "Couldn't decode syncInfoValue.")
+	}
+	info := &SyncInfo{Type: int(value.Tag)}
+	switch info.Type {
+	case SyncInfoNewCookie:
+		info.Cookie = value.Data.Bytes()
+	case SyncInfoRefreshDelete, SyncInfoRefreshPresent:
+		info.RefreshDone = true
+		for _, child := range value.Children {
+			switch child.Tag {
+			case ber.TagOctetString:
+				info.Cookie = child.Data.Bytes()
+			case ber.TagBoolean:
+				info.RefreshDone, _ = child.Value.(bool)
+			}
+		}
+	case SyncInfoSyncIdSet:
+		for _, child := range value.Children {
+			switch child.Tag {
+			case ber.TagOctetString:
+				info.Cookie = child.Data.Bytes()
+			case ber.TagBoolean:
+				info.RefreshDeletes, _ = child.Value.(bool)
+			case ber.TagSet:
+				for _, uuid := range child.Children {
+					info.SyncUUIDs = append(info.SyncUUIDs, formatSyncUUID(uuid.Data.Bytes()))
+				}
+			}
+		}
+	default:
+		return nil, newError(ErrorDecoding, fmt.Sprintf("Unknown syncInfoValue %d.", info.Type))
+	}
+	return info, nil
+}
+
+// formatSyncUUID formats the 16 bytes of a syncUUID as specified in
+// https://tools.ietf.org/html/rfc4122, other lengths are returned in hex.
+func formatSyncUUID(uuid []byte) string {
+	if len(uuid) != 16 {
+		return fmt.Sprintf("%x", uuid)
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16])
+}
@@ -0,0 +1,132 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"testing"
+	"time"
+)
+
+var mockEntryUUID = []byte{0x6b, 0xa7, 0xb8, 0x10, 0x9d, 0xad, 0x11, 0xd1, 0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}
+
+func mockSyncState(state int, cookie string) *ber.Packet {
+	value := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "syncStateValue")
+	value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, state, "state"))
+	value.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(mockEntryUUID), "entryUUID"))
+	if len(cookie) > 0 {
+		value.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, cookie, "cookie"))
+	}
+	return mockControl(ControlTypeSyncState, value)
+}
+
+func mockSyncDone(cookie string) *ber.Packet {
+	value := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "syncDoneValue")
+	value.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, cookie, "cookie"))
+	return mockControl(ControlTypeSyncDone, value)
+}
+
+func TestSync(t *testing.T) {
+	l, s := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		if request.Children[1].Tag != ber.Tag(ApplicationSearchRequest) {
+			return
+		}
+		messageID := mockMessageID(request)
+		s.respond(messageID, mockSearchEntry("cn=new,o=bigcorp", map[string][]string{"cn": {"new"}}), mockSyncState(SyncStateAdd, ""))
+
+		refreshPresent := ber.Encode(ber.ClassContext, ber.TypeConstructed, SyncInfoRefreshPresent, nil, "refreshPresent")
+		refreshPresent.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "cookie1", "cookie"))
+		refreshPresent.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, false, "refreshDone"))
+		s.respond(messageID, mockIntermediateResponse(IntermediateResponseSyncInfo, refreshPresent.Bytes()))
+
+		s.respond(messageID, mockSearchEntry("cn=old,o=bigcorp", nil), mockSyncState(SyncStatePresent, ""))
+		s.respond(messageID, mockLDAPResult(ApplicationSearchResultDone, ResultSuccess, ""), mockSyncDone("cookie2"))
+	})
+	defer l.Close()
+
+	var events []*SyncEvent
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(objectClass=*)", nil)
+	cookie, err := l.Sync(searchRequest, SyncRequestModeRefreshOnly, []byte("cookie0"), func(event *SyncEvent) (bool, error) {
+		events = append(events, event)
+		return false, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(cookie) != "cookie2" {
+		t.Errorf("Unexpected cookie %q", cookie)
+	}
+
+	request := <-s.requests
+	if len(request.Children) != 3 || packetString(request.Children[2].Children[0].Children[0]) != string(ControlTypeSyncRequest) {
+		t.Fatal("SyncRequest control missing from the search request")
+	}
+	value := ber.DecodePacket(request.Children[2].Children[0].Children[2].Data.Bytes())
+	if mode, _ := packetInt64(value.Children[0]); mode != SyncRequestModeRefreshOnly || packetString(value.Children[1]) != "cookie0" {
+		t.Errorf("Unexpected sync request value %v", value.Children)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+	if events[0].State != SyncStateAdd || events[0].Entry.GetAttributeValue("cn") != "new" ||
+		events[0].EntryUUID != "6ba7b810-9dad-11d1-80b4-00c04fd430c8" {
+		t.Errorf("Unexpected event %+v", events[0])
+	}
+	if info := events[1].Info; info == nil || info.Type != SyncInfoRefreshPresent || string(info.Cookie) != "cookie1" || info.RefreshDone {
+		t.Errorf("Unexpected syncInfo %+v", events[1].Info)
+	}
+	if events[2].State != SyncStatePresent || events[2].Entry.DN != "cn=old,o=bigcorp" {
+		t.Errorf("Unexpected event %+v", events[2])
+	}
+}
+
+func TestSyncRefreshRequired(t *testing.T) {
+	l, _ := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		if request.Children[1].Tag != ber.Tag(ApplicationSearchRequest) {
+			return
+		}
+		s.respondResult(mockMessageID(request), ApplicationSearchResultDone, ResultSyncRefreshRequired, "")
+	})
+	defer l.Close()
+
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(objectClass=*)", nil)
+	_, err := l.Sync(searchRequest, SyncRequestModeRefreshOnly, []byte("stale"), func(event *SyncEvent) (bool, error) {
+		return false, nil
+	})
+	if lerr, ok := err.(*Error); !ok || lerr.ResultCode != ResultSyncRefreshRequired {
+		t.Errorf("Expected ResultSyncRefreshRequired, got %v", err)
+	}
+}
+
+func TestSyncStopped(t *testing.T) {
+	l, s := newMockConnection(t, func(s *mockServer, request *ber.Packet) {
+		if request.Children[1].Tag == ber.Tag(ApplicationSearchRequest) {
+			s.respond(mockMessageID(request), mockSearchEntry("cn=new,o=bigcorp", nil), mockSyncState(SyncStateAdd, "cookie1"))
+		}
+	})
+	defer l.Close()
+
+	searchRequest := NewSimpleSearchRequest("o=bigcorp", ScopeWholeSubtree, "(objectClass=*)", nil)
+	cookie, err := l.Sync(searchRequest, SyncRequestModeRefreshAndPersist, nil, func(event *SyncEvent) (bool, error) {
+		return true, nil
+	})
+	if err != nil || string(cookie) != "cookie1" {
+		t.Fatalf("Unexpected cookie %q: %v", cookie, err)
+	}
+
+	<-s.requests
+	abandons := 0
+	for {
+		select {
+		case request := <-s.requests:
+			if request.Children[1].Tag == ber.Tag(ApplicationAbandonRequest) {
+				abandons++
+			}
+			continue
+		case <-time.After(100 * time.Millisecond):
+		}
+		break
+	}
+	if abandons != 1 {
+		t.Errorf("Expected the search to be abandoned once, got %d abandons", abandons)
+	}
+}
@@ -0,0 +1,94 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"sync"
+	"time"
+)
+
+// LoadClientCertificate returns a copy of config, nil for a new one, that
+// presents the client certificate of the PEM files certFile and keyFile for
+// mutual TLS. certFile may hold the intermediate certificates after the client
+// certificate, they are sent along as its chain. Set AutoExternalBind on the
+// Connection to bind as the identity of the certificate.
+func LoadClientCertificate(config *tls.Config, certFile, keyFile string) (*tls.Config, error) {
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, newError(ErrorInvalidArgument, "Couldn't load the client certificate: "+err.Error())
+	}
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+	config.Certificates = append(append([]tls.Certificate{}, config.Certificates...), certificate)
+	return config, nil
+}
+
+// CertificateFiles provides the configuration of TLS with the CA bundle and
+// the client certificate of PEM files, which are loaded again once one of them
+// changed. Its TLSConfig method is meant as the TLSConfigProvider of
+// connections, so they pick up rotated certificates when they reconnect.
+type CertificateFiles struct {
+	// Configuration the certificates are added to, optional
+	Config *tls.Config
+	// CA bundle verifying the server, the system roots if empty
+	CAFile string
+	// Client certificate with its chain and its key, optional
+	CertFile string
+	KeyFile  string
+
+	lock    sync.Mutex
+	modTime time.Time
+	config  *tls.Config
+}
+
+// TLSConfig returns the configuration with the current content of the files.
+func (f *CertificateFiles) TLSConfig() (*tls.Config, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var modTime time.Time
+	for _, name := range []string{f.CAFile, f.CertFile, f.KeyFile} {
+		if name == "" {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil, newError(ErrorInvalidArgument, "Couldn't read the certificates: "+err.Error())
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	if f.config != nil && !modTime.After(f.modTime) {
+		return f.config, nil
+	}
+
+	config := f.Config
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+	if f.CAFile != "" {
+		bundle, err := os.ReadFile(f.CAFile)
+		if err != nil {
+			return nil, newError(ErrorInvalidArgument, "Couldn't read the CA bundle: "+err.Error())
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(bundle) {
+			return nil, newError(ErrorInvalidArgument, "No certificates in the CA bundle "+f.CAFile)
+		}
+	}
+	if f.CertFile != "" {
+		var err error
+		if config, err = LoadClientCertificate(config, f.CertFile, f.KeyFile); err != nil {
+			return nil, err
+		}
+	}
+	f.config, f.modTime = config, modTime
+	return config, nil
+}
@@ -0,0 +1,163 @@
+package ldap
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/eaciit/asn1-ber"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeMockCertificate writes certificate and its key as PEM files and returns
+// their paths.
+func writeMockCertificate(t *testing.T, certificate tls.Certificate) (string, string) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	key, err := x509.MarshalECPrivateKey(certificate.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Certificate[0]}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestClientCertificateExternalBind(t *testing.T) {
+	serverCertificate, clientCertificate := mockCertificate(t), mockCertificate(t)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCertificate},
+		ClientAuth:   tls.RequireAnyClientCert,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	requests := make(chan *ber.Packet, 1)
+	presented := make(chan bool, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tlsConn := conn.(*tls.Conn)
+		if err := tlsConn.Handshake(); err != nil {
+			return
+		}
+		peers := tlsConn.ConnectionState().PeerCertificates
+		presented <- len(peers) == 1 && string(peers[0].Raw) == string(clientCertificate.Certificate[0])
+		request, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		requests <- request
+		(&mockServer{conn: conn}).respondResult(mockMessageID(request), ApplicationBindResponse, ResultSuccess, "")
+		ber.ReadPacket(conn)
+	}()
+
+	certFile, keyFile := writeMockCertificate(t, clientCertificate)
+	config, err := LoadClientCertificate(&tls.Config{InsecureSkipVerify: true}, certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewSSLConnection(listener.Addr().String(), config)
+	l.AutoExternalBind = true
+	if err := l.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if !<-presented {
+		t.Error("Client certificate was not presented")
+	}
+	sasl := (<-requests).Children[1].Children[2]
+	if sasl.Tag != 3 || packetString(sasl.Children[0]) != "EXTERNAL" {
+		t.Errorf("Expected a SASL EXTERNAL bind, got %v", sasl)
+	}
+
+	if _, err := LoadClientCertificate(nil, keyFile, certFile); err == nil {
+		t.Error("Expected an error for swapped files")
+	}
+}
+
+func TestStartTLSExternalBind(t *testing.T) {
+	clientCertificate := mockCertificate(t)
+	startTLS := mockStartTLS(&tls.Config{Certificates: []tls.Certificate{mockCertificate(t)}, ClientAuth: tls.RequireAnyClientCert})
+	listener, servers := mockListener(t, func(s *mockServer, request *ber.Packet) {
+		switch request.Children[1].Tag {
+		case ber.Tag(ApplicationExtendedRequest):
+			startTLS(s, request)
+		case ber.Tag(ApplicationBindRequest):
+			s.respondResult(mockMessageID(request), ApplicationBindResponse, ResultSuccess, "")
+		}
+	})
+	defer listener.Close()
+
+	l := NewTLSConnection(listener.Addr().String(), &tls.Config{InsecureSkipVerify: true, Certificates: []tls.Certificate{clientCertificate}})
+	l.AutoExternalBind = true
+	if err := l.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	s := <-servers
+	if extended := <-s.requests; extended.Children[1].Tag != ber.Tag(ApplicationExtendedRequest) {
+		t.Errorf("Expected StartTLS, got %v", extended.Children[1])
+	}
+	if sasl := (<-s.requests).Children[1].Children[2]; sasl.Tag != 3 || packetString(sasl.Children[0]) != "EXTERNAL" {
+		t.Errorf("Expected a SASL EXTERNAL bind after StartTLS, got %v", sasl)
+	}
+}
+
+func TestCertificateFiles(t *testing.T) {
+	serverCertificate := mockCertificate(t)
+	certFile, keyFile := writeMockCertificate(t, mockCertificate(t))
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: serverCertificate.Certificate[0]}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	files := &CertificateFiles{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}
+
+	l, _ := newMockConnection(t, mockStartTLS(&tls.Config{Certificates: []tls.Certificate{serverCertificate}}))
+	defer l.Close()
+	l.Addr = "ldap.example.com:389"
+	l.TLSConfigProvider = files.TLSConfig
+	if err := l.StartTLS(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := files.TLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := files.TLSConfig(); again != config {
+		t.Error("Unchanged files were loaded again")
+	}
+
+	rotated := mockCertificate(t)
+	rotatedCert, rotatedKey := writeMockCertificate(t, rotated)
+	for _, rename := range [][2]string{{rotatedCert, certFile}, {rotatedKey, keyFile}} {
+		if err := os.Rename(rename[0], rename[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(certFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	config, err = files.TLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Certificates) != 1 || string(config.Certificates[0].Certificate[0]) != string(rotated.Certificate[0]) {
+		t.Error("Rotated client certificate was not loaded")
+	}
+}
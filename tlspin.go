@@ -0,0 +1,59 @@
+package ldap
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+)
+
+// CertificatePin returns the SHA-256 hash of the certificate for PinTLSConfig.
+func CertificatePin(cert *x509.Certificate) []byte {
+	sum := sha256.Sum256(cert.Raw)
+	return sum[:]
+}
+
+// PublicKeyPin returns the SHA-256 hash of the public key (SPKI) of the
+// certificate for PinTLSConfig, the hash stays the same when a certificate is
+// renewed with the same key. It is the hash printed by
+//
+//	openssl x509 -pubkey -noout | openssl pkey -pubin -outform der | openssl dgst -sha256
+func PublicKeyPin(cert *x509.Certificate) []byte {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return sum[:]
+}
+
+// PinTLSConfig returns a copy of config, nil for a new one, that only accepts
+// a server certificate matching one of pins, hashes returned by
+// CertificatePin or PublicKeyPin. The server is verified by the pins instead
+// of the CA chain and the host name, e.g. for self-signed domain controllers,
+// only the pins of the server certificate itself are checked. The pins are
+// checked for resumed TLS sessions as well, followed by the VerifyConnection
+// of config; its VerifyPeerCertificate still only runs on full handshakes.
+func PinTLSConfig(config *tls.Config, pins ...[]byte) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+	config.InsecureSkipVerify = true
+	// unlike VerifyPeerCertificate, VerifyConnection is called on resumption
+	verify := config.VerifyConnection
+	config.VerifyConnection = func(state tls.ConnectionState) error {
+		if len(state.PeerCertificates) == 0 {
+			return newError(ErrorNetwork, "No server certificate to verify against the pins.")
+		}
+		cert := state.PeerCertificates[0]
+		certPin, keyPin := CertificatePin(cert), PublicKeyPin(cert)
+		for _, pin := range pins {
+			if bytes.Equal(pin, certPin) || bytes.Equal(pin, keyPin) {
+				if verify != nil {
+					return verify(state)
+				}
+				return nil
+			}
+		}
+		return newError(ErrorNetwork, "Server certificate doesn't match the pins.")
+	}
+	return config
+}
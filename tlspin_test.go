@@ -0,0 +1,82 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"errors"
+	"crypto/x509"
+	"testing"
+)
+
+func TestPinTLSConfig(t *testing.T) {
+	certificate := mockCertificate(t)
+	cert, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{certificate}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	// the certificate isn't valid for 127.0.0.1 and not signed by a trusted CA
+	for _, pin := range [][]byte{CertificatePin(cert), PublicKeyPin(cert)} {
+		l := NewSSLConnection(listener.Addr().String(), PinTLSConfig(nil, []byte("other pin"), pin))
+		if err := l.Connect(); err != nil {
+			t.Errorf("Connect with pin %x failed: %s", pin, err)
+			continue
+		}
+		l.Close()
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	l := NewSSLConnection(listener.Addr().String(), PinTLSConfig(config, []byte("other pin")))
+	if err := l.Connect(); err == nil {
+		l.Close()
+		t.Error("Expected Connect to fail without a matching pin")
+	}
+	if config.InsecureSkipVerify || config.VerifyConnection != nil {
+		t.Error("config was modified")
+	}
+
+	// the VerifyConnection of config runs after the pins matched
+	verified := false
+	config = &tls.Config{VerifyConnection: func(tls.ConnectionState) error {
+		verified = true
+		return errors.New("rejected by the caller")
+	}}
+	l = NewSSLConnection(listener.Addr().String(), PinTLSConfig(config, CertificatePin(cert)))
+	if err := l.Connect(); err == nil {
+		l.Close()
+		t.Error("Expected the VerifyConnection of config to reject the server")
+	}
+	if !verified {
+		t.Error("The VerifyConnection of config was not called")
+	}
+
+	// a resumed session is checked against the pins as well
+	cache := tls.NewLRUClientSessionCache(0)
+	l = NewSSLConnection(listener.Addr().String(), PinTLSConfig(&tls.Config{ClientSessionCache: cache}, CertificatePin(cert)))
+	if err := l.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	// the session ticket arrives after the handshake
+	stopped := l.chanStopped
+	<-stopped
+	l.Close()
+	l = NewSSLConnection(listener.Addr().String(), PinTLSConfig(&tls.Config{ClientSessionCache: cache}, []byte("other pin")))
+	if err := l.Connect(); err == nil {
+		l.Close()
+		t.Error("Expected the resumed session to fail without a matching pin")
+	}
+}
@@ -0,0 +1,98 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+)
+
+const ExtendedOperationTurn = "1.3.6.1.1.19"
+
+/*
+TurnValue ::= SEQUENCE {
+     mutual          BOOLEAN DEFAULT FALSE,
+     identifier      LDAPString }
+*/
+
+// TurnHandler handles an LDAPMessage the peer sends as a client once the
+// connection has been turned. The returned protocolOp, normally a response
+// built with NewLDAPResultPacket, is sent back with the messageID of the
+// request, nil sends nothing (e.g. for abandon and unbind requests).
+// Handlers run in their own goroutine.
+type TurnHandler func(messageID int64, request *ber.Packet) *ber.Packet
+
+// Turn reverses the roles of client and server on the connection as specified
+// in https://tools.ietf.org/html/rfc4531. With mutual set both peers can act as
+// client and server afterwards. All requests arriving after a successful turn
+// are passed to handler.
+func (l *Connection) Turn(mutual bool, identifier string, handler TurnHandler) error {
+	if handler == nil {
+		return newError(ErrorInvalidArgument, "Turn needs a TurnHandler.")
+	}
+	value := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "TurnValue")
+	if mutual {
+		value.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, mutual, "mutual"))
+	}
+	value.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, identifier, "identifier"))
+
+	// set before the request is sent, the peer may start right after its response.
+	l.setTurnHandler(handler)
+	_, err := l.Extended(NewExtendedRequest(ExtendedOperationTurn, value))
+	if err != nil {
+		l.setTurnHandler(nil)
+	}
+	return err
+}
+
+func (l *Connection) setTurnHandler(handler TurnHandler) {
+	l.lockResponses.Lock()
+	defer l.lockResponses.Unlock()
+	l.turnHandler = handler
+}
+
+func (l *Connection) getTurnHandler() TurnHandler {
+	l.lockResponses.RLock()
+	defer l.lockResponses.RUnlock()
+	return l.turnHandler
+}
+
+// NewLDAPResultPacket builds a response protocolOp, e.g. with application
+// ApplicationModifyResponse, for use in a TurnHandler.
+func NewLDAPResultPacket(application ApplicationCode, result *LDAPResult) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(application), nil, application.String())
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, uint64(result.ResultCode), "resultCode"))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, result.MatchedDN, "matchedDN"))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, result.DiagnosticMessage, "diagnosticMessage"))
+	if len(result.Referrals) > 0 {
+		referral := ber.Encode(ber.ClassContext, ber.TypeConstructed, 3, nil, "referral")
+		for _, uri := range result.Referrals {
+			referral.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, uri, "URI"))
+		}
+		p.AppendChild(referral)
+	}
+	return p
+}
+
+// isRequest reports whether the protocolOp of an LDAPMessage is a request.
+func isRequest(code ApplicationCode) bool {
+	switch code {
+	case ApplicationBindRequest, ApplicationUnbindRequest, ApplicationSearchRequest,
+		ApplicationModifyRequest, ApplicationAddRequest, ApplicationDelRequest,
+		ApplicationModifyDNRequest, ApplicationCompareRequest, ApplicationAbandonRequest,
+		ApplicationExtendedRequest:
+		return true
+	}
+	return false
+}
+
+// handleTurnedRequest passes a request of the peer to the TurnHandler and
+// writes its response.
+func (l *Connection) handleTurnedRequest(handler TurnHandler, messageID int64, request *ber.Packet) {
+	response := handler(messageID, request)
+	if response == nil {
+		return
+	}
+	packet, err := requestBuildPacket(messageID, response, nil)
+	if err != nil {
+		return
+	}
+	l.sendProcessMessage(&messagePacket{Op: MessageRequest, MessageID: messageID, Packet: packet})
+}
@@ -0,0 +1,69 @@
+package ldap
+
+import (
+	"github.com/eaciit/asn1-ber"
+	"time"
+)
+
+/*
+UnbindRequest ::= [APPLICATION 2] NULL
+
+Unbind ends the session gracefully. No new operations are accepted, the
+operations already running get up to DrainTimeout to complete, the ones still
+outstanding after that are abandoned and return an ErrorAbandoned *Error. Then
+the UnbindRequest is sent and the connection is closed. With a DrainTimeout
+of 0 outstanding operations are abandoned right away.
+*/
+func (l *Connection) Unbind() error {
+	l.setClosed(true)
+	if l.State() != StateClosed {
+		l.setState(StateClosing)
+	}
+	messageID, ok := l.nextMessageID()
+	if !ok {
+		return l.messageIDError()
+	}
+
+	l.lockResponses.Lock()
+	if l.responses == nil || l.draining {
+		l.lockResponses.Unlock()
+		return newError(ErrorClosing, "Connection closing/closed")
+	}
+	drained := make(chan struct{})
+	l.draining = true
+	l.chanDrained = drained
+	l.checkDrained()
+	l.lockResponses.Unlock()
+
+	if l.DrainTimeout > 0 {
+		select {
+		case <-drained:
+		case <-time.After(l.DrainTimeout):
+		}
+	}
+	l.abandonOutstanding()
+
+	encodedUnbind := ber.Encode(ber.ClassApplication, ber.TypePrimitive, ber.Tag(ApplicationUnbindRequest), nil, ApplicationUnbindRequest.String())
+	packet, err := requestBuildPacket(messageID, encodedUnbind, nil)
+	if err != nil {
+		return err
+	}
+	if l.Debug {
+		ber.PrintPacket(packet)
+	}
+
+	l.sendProcessMessage(&messagePacket{Op: MessageUnbind, MessageID: messageID, Packet: packet})
+	<-l.chanDone
+	return nil
+}
+
+// abandonOutstanding releases all operations still waiting for a response.
+func (l *Connection) abandonOutstanding() {
+	l.lockResponses.Lock()
+	defer l.lockResponses.Unlock()
+	for messageID, queue := range l.responses {
+		delete(l.responses, messageID)
+		l.abandonedMessages[messageID] = true
+		queue.close()
+	}
+}
@@ -0,0 +1,89 @@
+package ldap
+
+import (
+	"context"
+	"unicode/utf16"
+)
+
+// Active Directory password attribute, it can only be written over an
+// encrypted connection and never be read.
+const AttributeUnicodePwd = "unicodePwd"
+
+// EncodeUnicodePwd returns password in the format of unicodePwd values: the
+// password in double quotes encoded as UTF-16LE.
+func EncodeUnicodePwd(password string) string {
+	return encodeUTF16LE("\"" + password + "\"")
+}
+
+// encodeUTF16LE encodes s as UTF-16LE as used by Active Directory.
+func encodeUTF16LE(s string) string {
+	encoded := utf16.Encode([]rune(s))
+	b := make([]byte, 0, 2*len(encoded))
+	for _, c := range encoded {
+		b = append(b, byte(c), byte(c>>8))
+	}
+	return string(b)
+}
+
+// NewUnicodePwdResetRequest returns a ModifyRequest for an administrative
+// reset of the password of dn. With enforcePolicy the request carries a
+// PolicyHints control, so the server also checks the password history and
+// complexity as for a user changing the password.
+func NewUnicodePwdResetRequest(dn, password string, enforcePolicy bool) *ModifyRequest {
+	req := NewModifyRequest(dn)
+	req.AddMod(NewMod(ModReplace, AttributeUnicodePwd, []string{EncodeUnicodePwd(password)}))
+	if enforcePolicy {
+		req.AddControl(NewControlPolicyHintsRequest())
+	}
+	return req
+}
+
+// NewUnicodePwdChangeRequest returns a ModifyRequest for the user dn changing
+// the password from oldPassword to newPassword, deleting the old value and
+// adding the new one. The server checks the old password and the password
+// policy, the connection may also be bound as another user.
+func NewUnicodePwdChangeRequest(dn, oldPassword, newPassword string) *ModifyRequest {
+	req := NewModifyRequest(dn)
+	req.AddMod(NewMod(ModDelete, AttributeUnicodePwd, []string{EncodeUnicodePwd(oldPassword)}))
+	req.AddMod(NewMod(ModAdd, AttributeUnicodePwd, []string{EncodeUnicodePwd(newPassword)}))
+	return req
+}
+
+// ResetUnicodePwd resets the password of dn with NewUnicodePwdResetRequest.
+// Without TLS or a SASL confidentiality layer the request is refused with a
+// ResultConfidentialityRequired *Error before sending the password, like
+// the server would after receiving it.
+func (l *Connection) ResetUnicodePwd(dn, password string, enforcePolicy bool) (*LDAPResult, error) {
+	return l.ResetUnicodePwdContext(context.Background(), dn, password, enforcePolicy)
+}
+
+// ResetUnicodePwdContext is ResetUnicodePwd with ctx.
+func (l *Connection) ResetUnicodePwdContext(ctx context.Context, dn, password string, enforcePolicy bool) (*LDAPResult, error) {
+	if err := l.unicodePwdAllowed(); err != nil {
+		return nil, err
+	}
+	return l.ModifyContext(ctx, NewUnicodePwdResetRequest(dn, password, enforcePolicy))
+}
+
+// ChangeUnicodePwd changes the password of dn with
+// NewUnicodePwdChangeRequest, requiring encryption as ResetUnicodePwd.
+func (l *Connection) ChangeUnicodePwd(dn, oldPassword, newPassword string) (*LDAPResult, error) {
+	return l.ChangeUnicodePwdContext(context.Background(), dn, oldPassword, newPassword)
+}
+
+// ChangeUnicodePwdContext is ChangeUnicodePwd with ctx.
+func (l *Connection) ChangeUnicodePwdContext(ctx context.Context, dn, oldPassword, newPassword string) (*LDAPResult, error) {
+	if err := l.unicodePwdAllowed(); err != nil {
+		return nil, err
+	}
+	return l.ModifyContext(ctx, NewUnicodePwdChangeRequest(dn, oldPassword, newPassword))
+}
+
+// unicodePwdAllowed returns the error for writing unicodePwd over a
+// connection without encryption.
+func (l *Connection) unicodePwdAllowed() error {
+	if !l.encrypted() {
+		return newError(ResultConfidentialityRequired, "Refusing to send a unicodePwd without TLS or a SASL confidentiality layer")
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package ldap
 
 import (
 	"fmt"
+	"github.com/eaciit/asn1-ber"
 	"testing"
 )
 
@@ -39,3 +40,61 @@ func TestVlvRequest(t *testing.T) {
 	}
 	fmt.Println("TestVlvRequest finsished.")
 }
+
+func TestVlvResponse(t *testing.T) {
+	value := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "VirtualListViewResponse")
+	value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 42, "targetPosition"))
+	value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, 1000, "contentCount"))
+	value.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, 0, "virtualListViewResult"))
+	value.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "ctx1", "contextID"))
+
+	control, err := NewControlVlvResponse(ber.DecodePacket(mockControl(ControlTypeVlvResponse, value).Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := control.(*ControlVlvResponse)
+	if response.TargetPosition != 42 || response.ContentCount != 1000 || response.Err != nil || response.ContextID != "ctx1" {
+		t.Fatalf("Unexpected response %s", response)
+	}
+
+	vlv := NewControlVlvRequestByOffset(0, 19, 42, 0)
+	vlv.Update(response)
+	if string(vlv.ContextID) != "ctx1" || vlv.ByOffset.ContentCount != 1000 {
+		t.Errorf("Request not updated from response %s", vlv)
+	}
+	if _, err := vlv.Encode(); err != nil {
+		t.Error(err)
+	}
+
+	// the request by assertion value decodes to its fields
+	assertion := NewControlVlvRequestGreaterThanOrEqual(5, 10, "smith")
+	assertion.ContextID = []byte("ctx2")
+	encoded, err := assertion.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeControl(ber.DecodePacket(encoded.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, ok := decoded.(*RawControl)
+	if !ok || raw.OID() != ControlTypeVlvRequest || !raw.Criticality() {
+		t.Fatalf("Unexpected control %v", decoded)
+	}
+	request := ber.DecodePacket(raw.Value)
+	if len(request.Children) != 4 {
+		t.Fatalf("Unexpected VirtualListViewRequest with %d fields", len(request.Children))
+	}
+	beforeCount, _ := packetInt64(request.Children[0])
+	afterCount, _ := packetInt64(request.Children[1])
+	target := request.Children[2]
+	if beforeCount != 5 || afterCount != 10 {
+		t.Errorf("Unexpected counts %d, %d", beforeCount, afterCount)
+	}
+	if target.ClassType != ber.ClassContext || target.Tag != 1 || string(target.Data.Bytes()) != "smith" {
+		t.Errorf("Unexpected target %v", target)
+	}
+	if contextID := string(request.Children[3].Data.Bytes()); contextID != "ctx2" {
+		t.Errorf("Unexpected contextID %q", contextID)
+	}
+}
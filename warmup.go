@@ -0,0 +1,86 @@
+package ldap
+
+import (
+	"context"
+	"strconv"
+)
+
+// WarmUp establishes n connections ahead of the first operations, e.g. to
+// fill a pool, so they don't pay the latency of connecting and binding.
+// newConnection returns each connection not yet connected, e.g. with
+// NewConnection or with a shared ServerList, and bind binds it once connected,
+// nil to leave it anonymous. At most parallelism connections are established
+// at a time, all at once if 0.
+//
+// WarmUp returns once all connections are ready or ctx is done, with the
+// connections ready and the first error. The connections are dialed with ctx
+// and those still connecting when ctx is done are closed.
+func WarmUp(ctx context.Context, n, parallelism int, newConnection func() *Connection, bind func(ctx context.Context, l *Connection) error) ([]*Connection, error) {
+	if n < 0 {
+		return nil, newError(ErrorInvalidArgument, "Invalid number of connections: "+strconv.Itoa(n))
+	}
+	if parallelism <= 0 || parallelism > n {
+		parallelism = n
+	}
+	type result struct {
+		l   *Connection
+		err error
+	}
+	results := make(chan result, n)
+	slots := make(chan struct{}, parallelism)
+	go func() {
+		for i := 0; i < n; i++ {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				for ; i < n; i++ {
+					results <- result{err: doneError(ctx)}
+				}
+				return
+			}
+			go func() {
+				defer func() { <-slots }()
+				l := newConnection()
+				err := l.ConnectContext(ctx)
+				if err == nil && bind != nil {
+					if err = bind(ctx, l); err != nil {
+						l.Close()
+					}
+				}
+				if err != nil {
+					l = nil
+				}
+				results <- result{l, err}
+			}()
+		}
+	}()
+
+	var ready []*Connection
+	var firstErr error
+	for i := 0; i < n; i++ {
+		select {
+		case r := <-results:
+			if r.err != nil {
+				if firstErr == nil {
+					firstErr = r.err
+				}
+				continue
+			}
+			ready = append(ready, r.l)
+		case <-ctx.Done():
+			// close the connections finishing after the deadline
+			go func(pending int) {
+				for ; pending > 0; pending-- {
+					if r := <-results; r.l != nil {
+						r.l.Close()
+					}
+				}
+			}(n - i)
+			if firstErr == nil {
+				firstErr = doneError(ctx)
+			}
+			return ready, firstErr
+		}
+	}
+	return ready, firstErr
+}
@@ -0,0 +1,92 @@
+package ldap
+
+import (
+	"context"
+	"github.com/eaciit/asn1-ber"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWarmUp(t *testing.T) {
+	listener, servers := mockListener(t, func(s *mockServer, request *ber.Packet) {
+		time.Sleep(10 * time.Millisecond)
+		s.respondResult(mockMessageID(request), ApplicationBindResponse, ResultSuccess, "")
+	})
+	defer listener.Close()
+	go func() {
+		for range servers {
+		}
+	}()
+
+	var running, maxRunning int32
+	newConnection := func() *Connection {
+		if r := atomic.AddInt32(&running, 1); r > atomic.LoadInt32(&maxRunning) {
+			atomic.StoreInt32(&maxRunning, r)
+		}
+		return NewConnection(listener.Addr().String())
+	}
+	bind := func(ctx context.Context, l *Connection) error {
+		defer atomic.AddInt32(&running, -1)
+		return l.BindContext(ctx, "cn=admin,o=bigcorp", "secret")
+	}
+	ready, err := WarmUp(context.Background(), 5, 2, newConnection, bind)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range ready {
+		defer l.Close()
+	}
+	if len(ready) != 5 {
+		t.Errorf("Expected 5 connections, got %d", len(ready))
+	}
+	if maxRunning > 2 {
+		t.Errorf("Expected at most 2 connections at a time, got %d", maxRunning)
+	}
+}
+
+func TestWarmUpTimeout(t *testing.T) {
+	// binds are never answered
+	listener, _ := mockListener(t, nil)
+	defer listener.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	newConnection := func() *Connection { return NewConnection(listener.Addr().String()) }
+	bind := func(ctx context.Context, l *Connection) error {
+		return l.BindContext(ctx, "cn=admin,o=bigcorp", "secret")
+	}
+	ready, err := WarmUp(ctx, 3, 0, newConnection, bind)
+	if len(ready) != 0 {
+		t.Errorf("Expected no connections, got %d", len(ready))
+	}
+	if lerr, ok := err.(*Error); !ok || !lerr.Timeout() {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+}
+
+func TestWarmUpDialContext(t *testing.T) {
+	if _, err := WarmUp(context.Background(), -1, 0, nil, nil); err == nil || err.(*Error).ResultCode != ErrorInvalidArgument {
+		t.Errorf("Expected ErrorInvalidArgument for -1 connections, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	dialed := make(chan context.Context, 1)
+	newConnection := func() *Connection {
+		l := NewConnection("ldap.example.com:389")
+		l.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialed <- ctx
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return l
+	}
+	if _, err := WarmUp(ctx, 1, 0, newConnection, nil); err == nil {
+		t.Error("Expected WarmUp to time out")
+	}
+	if _, ok := (<-dialed).Deadline(); !ok {
+		t.Error("Expected the connection to be dialed with the ctx of WarmUp")
+	}
+}
@@ -0,0 +1,14 @@
+package ldap
+
+const ExtendedOperationWhoAmI = "1.3.6.1.4.1.4203.1.11.3"
+
+// WhoAmI returns the authorization identity of the connection as
+// specified in https://tools.ietf.org/html/rfc4532, e.g. "dn:cn=bob,o=bigcorp"
+// or "u:bob". The empty string is returned for an anonymous connection.
+func (l *Connection) WhoAmI() (string, error) {
+	response, err := l.Extended(NewExtendedRequest(ExtendedOperationWhoAmI, nil))
+	if err != nil {
+		return "", err
+	}
+	return string(response.Value), nil
+}